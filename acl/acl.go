@@ -0,0 +1,279 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+// DefaultPath is where the access list is kept by default.
+const DefaultPath = "/etc/openers/acl.json"
+
+// All, in a rule's openers, matches every opener.
+const All = "*"
+
+// Rule grants a user, or the members of a group, the use of some openers.
+type Rule struct {
+	User    string   `json:"user,omitempty"`
+	Group   string   `json:"group,omitempty"`
+	Openers []string `json:"openers"`
+	// Hours, if not empty, limits the rule to a daily window of local time,
+	// such as "07:00-22:00". Windows may wrap past midnight.
+	Hours string `json:"hours,omitempty"`
+}
+
+// Who returns the user or group the rule is for, with groups prefixed by "@".
+func (r *Rule) Who() string {
+	if r.Group != "" {
+		return "@" + r.Group
+	}
+	return r.User
+}
+
+func (r *Rule) String() string {
+	s := fmt.Sprintf("%s: %s", r.Who(), strings.Join(r.Openers, ","))
+	if r.Hours != "" {
+		s += " (" + r.Hours + ")"
+	}
+	return s
+}
+
+// ACL is an access list.
+type ACL struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Caller identifies a process asking to trigger an opener.
+type Caller struct {
+	UID    int
+	GID    int
+	User   string   // Empty if the uid has no name.
+	Groups []string // Names of the groups the user is in, including GID's.
+}
+
+func (c *Caller) String() string {
+	if c.User == "" {
+		return fmt.Sprintf("uid %d", c.UID)
+	}
+	return fmt.Sprintf("%s (uid %d)", c.User, c.UID)
+}
+
+// LookupCaller fills in the user and group names for a uid and gid.
+func LookupCaller(uid, gid int) *Caller {
+	c := &Caller{UID: uid, GID: gid}
+	gids := []string{strconv.Itoa(gid)}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		c.User = u.Username
+		if ids, err := u.GroupIds(); err == nil {
+			gids = append(gids, ids...)
+		}
+	}
+	seen := map[string]bool{}
+	for _, id := range gids {
+		g, err := user.LookupGroupId(id)
+		if err != nil || seen[g.Name] {
+			continue
+		}
+		seen[g.Name] = true
+		c.Groups = append(c.Groups, g.Name)
+	}
+	return c
+}
+
+// Load reads an access list. A missing file is an empty list, allowing only
+// root.
+func Load(path string) (*ACL, error) {
+	a := &ACL{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := a.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return a, nil
+}
+
+// Save writes an access list, replacing the file atomically. It is readable
+// only by its owner, since it reveals who can open what.
+func (a *ACL) Save(path string) error {
+	if err := a.check(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, append(data, '\n'), 0600)
+}
+
+// check validates the rules.
+func (a *ACL) check() error {
+	for i, r := range a.Rules {
+		if (r.User == "") == (r.Group == "") {
+			return fmt.Errorf("rule %d: want exactly one of user and group", i)
+		}
+		if len(r.Openers) == 0 {
+			return fmt.Errorf("rule %d (%s): no openers", i, r.Who())
+		}
+		if r.Hours != "" {
+			if _, _, err := ParseHours(r.Hours); err != nil {
+				return fmt.Errorf("rule %d (%s): %v", i, r.Who(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// find returns the index of the rule for a user or group, or -1.
+func (a *ACL) find(name string, group bool) int {
+	for i, r := range a.Rules {
+		if (group && r.Group == name) || (!group && r.User == name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add grants a user, or group, the use of openers. If it already has a rule,
+// the openers are added to it, and its hours replaced if hours is not empty.
+func (a *ACL) Add(name string, group bool, openers []string, hours string) error {
+	if hours != "" {
+		if _, _, err := ParseHours(hours); err != nil {
+			return err
+		}
+	}
+	i := a.find(name, group)
+	if i < 0 {
+		r := Rule{User: name}
+		if group {
+			r = Rule{Group: name}
+		}
+		a.Rules = append(a.Rules, r)
+		i = len(a.Rules) - 1
+	}
+	r := &a.Rules[i]
+	for _, o := range openers {
+		if !contains(r.Openers, o) {
+			r.Openers = append(r.Openers, o)
+		}
+	}
+	sort.Strings(r.Openers)
+	if hours != "" {
+		r.Hours = hours
+	}
+	return nil
+}
+
+// Revoke takes away a user's, or group's, use of openers; all of them if
+// none are given. Named openers can't be taken out of a rule for all of
+// them, short of revoking All itself.
+func (a *ACL) Revoke(name string, group bool, openers []string) error {
+	i := a.find(name, group)
+	if i < 0 {
+		return fmt.Errorf("no rule for %q", name)
+	}
+	r := &a.Rules[i]
+	if len(openers) > 0 && contains(r.Openers, All) && !contains(openers, All) {
+		return fmt.Errorf("%q may trigger every opener; revoke them all, then add back the ones to keep", name)
+	}
+	if len(openers) > 0 {
+		var kept []string
+		for _, o := range r.Openers {
+			if !contains(openers, o) {
+				kept = append(kept, o)
+			}
+		}
+		r.Openers = kept
+	}
+	if len(openers) == 0 || len(r.Openers) == 0 {
+		a.Rules = append(a.Rules[:i], a.Rules[i+1:]...)
+	}
+	return nil
+}
+
+// Check returns nil if the caller may trigger the named opener at the given
+// time, or an error saying why not.
+func (a *ACL) Check(c *Caller, opener string, now time.Time) error {
+	if c.UID == 0 {
+		return nil
+	}
+	outOfHours := ""
+	for _, r := range a.Rules {
+		if !(r.User != "" && r.User == c.User) && !(r.Group != "" && contains(c.Groups, r.Group)) {
+			continue
+		}
+		if !contains(r.Openers, opener) && !contains(r.Openers, All) {
+			continue
+		}
+		if r.Hours == "" {
+			return nil
+		}
+		start, end, err := ParseHours(r.Hours)
+		if err != nil {
+			return err
+		}
+		if InHours(start, end, now) {
+			return nil
+		}
+		outOfHours = r.Hours
+	}
+	if outOfHours != "" {
+		return fmt.Errorf("%s may only trigger %q during %s", c, opener, outOfHours)
+	}
+	return fmt.Errorf("%s may not trigger %q", c, opener)
+}
+
+// ParseHours parses a daily window such as "07:00-22:00", returning its start
+// and end as offsets from midnight.
+func ParseHours(s string) (start, end time.Duration, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid hours %q; want eg. 07:00-22:00", s)
+	}
+	var times [2]time.Duration
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hours %q; want eg. 07:00-22:00", s)
+		}
+		times[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if times[0] == times[1] {
+		return 0, 0, fmt.Errorf("invalid hours %q: empty window", s)
+	}
+	return times[0], times[1], nil
+}
+
+// InHours reports whether t falls in the daily window from start to end,
+// which wraps past midnight if end is before start.
+func InHours(start, end time.Duration, t time.Time) bool {
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package acl_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/acl"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2021, 3, 14, hour, minute, 0, 0, time.Local)
+}
+
+func TestCheck(t *testing.T) {
+	a := &acl.ACL{Rules: []acl.Rule{
+		{User: "alice", Openers: []string{"*"}},
+		{User: "bob", Openers: []string{"garage"}, Hours: "07:00-22:00"},
+		{User: "carol", Openers: []string{"gate"}, Hours: "22:00-02:00"},
+		{Group: "family", Openers: []string{"gate"}},
+	}}
+	alice := &acl.Caller{UID: 1000, User: "alice"}
+	bob := &acl.Caller{UID: 1001, User: "bob", Groups: []string{"bob"}}
+	carol := &acl.Caller{UID: 1002, User: "carol"}
+	dave := &acl.Caller{UID: 1003, User: "dave", Groups: []string{"dave", "family"}}
+	root := &acl.Caller{UID: 0, User: "root"}
+	testcases := []struct {
+		caller *acl.Caller
+		opener string
+		now    time.Time
+		want   bool
+	}{
+		{alice, "garage", at(3, 0), true},
+		{alice, "gate", at(3, 0), true},
+		{bob, "garage", at(7, 0), true},
+		{bob, "garage", at(21, 59), true},
+		{bob, "garage", at(22, 0), false},
+		{bob, "gate", at(12, 0), false},
+		{carol, "gate", at(23, 0), true},
+		{carol, "gate", at(1, 30), true},
+		{carol, "gate", at(12, 0), false},
+		{dave, "gate", at(12, 0), true},
+		{dave, "garage", at(12, 0), false},
+		{root, "garage", at(3, 0), true},
+		{&acl.Caller{UID: 1004}, "gate", at(12, 0), false},
+	}
+	for _, tt := range testcases {
+		err := a.Check(tt.caller, tt.opener, tt.now)
+		if got := err == nil; got != tt.want {
+			t.Errorf("want Check(%s, %q, %s) allowed=%v; got error %v", tt.caller, tt.opener, tt.now.Format("15:04"), tt.want, err)
+		}
+	}
+}
+
+func TestAddRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.json")
+	a, err := acl.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add("bob", false, []string{"garage"}, "07:00-22:00"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add("bob", false, []string{"gate", "garage"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add("family", true, []string{"gate"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Add("eve", false, []string{"gate"}, "7am-10pm"); err == nil {
+		t.Errorf("want error for invalid hours")
+	}
+	if err := a.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if a, err = acl.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	want := []acl.Rule{
+		{User: "bob", Openers: []string{"garage", "gate"}, Hours: "07:00-22:00"},
+		{Group: "family", Openers: []string{"gate"}},
+	}
+	if !reflect.DeepEqual(a.Rules, want) {
+		t.Errorf("want rules %v; got %v", want, a.Rules)
+	}
+
+	if err := a.Revoke("bob", false, []string{"gate"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Revoke("family", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Revoke("family", true, nil); err == nil {
+		t.Errorf("want error revoking a missing rule")
+	}
+	if err := a.Add("carol", false, []string{acl.All}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Revoke("carol", false, []string{"gate"}); err == nil {
+		t.Errorf("want error revoking one opener from a rule for all of them")
+	}
+	if err := a.Revoke("carol", false, []string{acl.All}); err != nil {
+		t.Fatal(err)
+	}
+	want = []acl.Rule{{User: "bob", Openers: []string{"garage"}, Hours: "07:00-22:00"}}
+	if !reflect.DeepEqual(a.Rules, want) {
+		t.Errorf("want rules %v; got %v", want, a.Rules)
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package acl decides which users may trigger which openers, and when, for the
+privileged helper.
+
+The list is a small JSON file of rules, each granting a user (or the members
+of a group) the use of some openers, optionally only between certain hours.
+Root may always trigger every opener.
+*/
+package acl
@@ -0,0 +1,342 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+)
+
+// DefaultPath is where the audit log is kept by default.
+const DefaultPath = "/var/lib/openers/audit.jsonl"
+
+// DefaultKeyPath is where the fingerprint key is kept by default.
+const DefaultKeyPath = "/var/lib/openers/audit.key"
+
+// keySize is the size of a fingerprint key, in bytes.
+const keySize = 32
+
+// Results.
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+// tailSize is how much of the end of the log is read to find the last entry.
+const tailSize = 64 * 1024
+
+// Entry records a transmission.
+type Entry struct {
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Opener   string    `json:"opener,omitempty"` // Empty for codes given on the command line.
+	Action   string    `json:"action,omitempty"`
+	Protocol string    `json:"protocol"`
+	Fixed    string    `json:"fixed,omitempty"`   // Fingerprint of the fixed code or identifier.
+	Rolling  *uint32   `json:"rolling,omitempty"` // Security+2.0 only.
+	User     string    `json:"user,omitempty"`    // Local user running the command.
+	Source   string    `json:"source"`            // What asked for it: cli, mqtt, an API client's address...
+	Backend  string    `json:"backend"`
+
+	// Attempts counts the sends, including retries and one for each leg of a
+	// frequency plan, and MaxDeviation is the worst timing error of any of
+	// them. They are only recorded by backends that measure their timing.
+	Attempts     int           `json:"attempts,omitempty"`
+	MaxDeviation time.Duration `json:"max_deviation_ns,omitempty"`
+
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+
+	Prev string `json:"prev,omitempty"` // Hash of the previous entry, if chained.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Who returns who asked for the transmission.
+func (e *Entry) Who() string {
+	if e.User == "" || e.User == e.Source {
+		return e.Source
+	}
+	return e.User + " (" + e.Source + ")"
+}
+
+// Key is the secret fingerprints are made with. Codes are short enough to
+// guess from a plain hash of them, so it must be kept from whoever can read
+// the log.
+type Key []byte
+
+// LoadKey reads the key file, creating it with a new random key, readable
+// only by its owner, if it does not exist yet.
+func LoadKey(path string) (Key, error) {
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		k, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(k) != keySize {
+			return nil, fmt.Errorf("%s: invalid key", path)
+		}
+		return k, nil
+	}
+	k := make(Key, keySize)
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// Created by another process meanwhile.
+		return LoadKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(k)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Fingerprint returns a short fingerprint of a fixed code or identifier: the
+// start of its HMAC-SHA-256 with the key, along with the protocol. Without
+// a key, it returns "".
+func (k Key) Fingerprint(protocol string, code *big.Int) string {
+	if len(k) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(protocol + ":" + code.String()))
+	return hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// OpenerFingerprint returns the fingerprint of an opener's fixed code or
+// identifier, or "" if it has none.
+func (k Key) OpenerFingerprint(o *config.Opener) string {
+	switch o.Protocol {
+	case secplus.Protocol:
+		if fixed, err := o.FixedCode(); err == nil {
+			return k.Fingerprint(o.Protocol, fixed)
+		}
+	case megacode.Protocol:
+		if id, err := o.ID(); err == nil {
+			return k.Fingerprint(o.Protocol, new(big.Int).SetUint64(uint64(id)))
+		}
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the entry for a transmission, for
+// whatever sends it to fill in and append.
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, e)
+}
+
+// FromContext returns the entry carried by the context, or nil.
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(contextKey{}).(*Entry)
+	return e
+}
+
+// Log is an audit log file.
+type Log struct {
+	Path string
+	// Chain, if true, hash-chains new entries.
+	Chain bool
+}
+
+// Append adds an entry to the log, filling in its sequence number, its time
+// if not set, and if chained, its hashes.
+func (l *Log) Append(e *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("cannot lock %s: %v", l.Path, err)
+	}
+
+	last, err := lastEntry(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", l.Path, err)
+	}
+	e.Seq, e.Prev, e.Hash = 1, "", ""
+	if last != nil {
+		e.Seq = last.Seq + 1
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if l.Chain && last != nil {
+		e.Prev = last.Hash
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if l.Chain {
+		e.Hash = hash(line)
+		line = withHash(line, e.Hash)
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// hash returns the hash of an entry as marshalled without one.
+func hash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// withHash adds a hash to an entry marshalled without one.
+func withHash(line []byte, h string) []byte {
+	return append(line[:len(line)-1:len(line)-1], `,"hash":"`+h+`"}`...)
+}
+
+// lastEntry returns the last entry in the file, or nil if it is empty.
+func lastEntry(f *os.File) (*Entry, error) {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return nil, err
+	}
+	n := fi.Size()
+	if n > tailSize {
+		n = tailSize
+	}
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, fi.Size()-n); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	var e Entry
+	if err := json.Unmarshal(buf, &e); err != nil {
+		return nil, fmt.Errorf("cannot read last entry: %v", err)
+	}
+	return &e, nil
+}
+
+// Read returns the entries in the log file, which need not exist yet.
+func Read(path string) ([]Entry, error) {
+	var entries []Entry
+	err := scan(path, func(n int, line []byte, e *Entry) error {
+		entries = append(entries, *e)
+		return nil
+	})
+	return entries, err
+}
+
+// scan calls fn with each entry in the log file, and its line number.
+func scan(path string, fn func(n int, line []byte, e *Entry) error) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if err := fn(n, s.Bytes(), &e); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// ChainError describes where a hash chain is broken.
+type ChainError struct {
+	Line   int
+	Seq    int
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("line %d (entry %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Verify checks the hash chain of the log file, returning the number of
+// entries and the hash of the last one. Every entry must be chained.
+func Verify(path string) (int, string, error) {
+	count, prev, prevSeq := 0, "", 0
+	err := scan(path, func(n int, line []byte, e *Entry) error {
+		broken := func(format string, args ...interface{}) error {
+			return &ChainError{Line: n, Seq: e.Seq, Reason: fmt.Sprintf(format, args...)}
+		}
+		switch {
+		case e.Hash == "":
+			return broken("not hash-chained")
+		case prevSeq != 0 && e.Seq != prevSeq+1:
+			return broken("follows entry %d", prevSeq)
+		case e.Prev != prev:
+			return broken("previous hash %.12s does not match %.12s", e.Prev, prev)
+		}
+		suffix := `,"hash":"` + e.Hash + `"}`
+		if !strings.HasSuffix(string(line), suffix) {
+			return broken("hash is not last")
+		}
+		body := append(line[:len(line)-len(suffix):len(line)-len(suffix)], '}')
+		if h := hash(body); h != e.Hash {
+			return broken("contents do not match hash")
+		}
+		count, prev, prevSeq = count+1, e.Hash, e.Seq
+		return nil
+	})
+	return count, prev, err
+}
+
+// Filter selects entries.
+type Filter struct {
+	Opener string    // If not empty, only this opener.
+	Who    string    // If not empty, only entries whose user or source contains this.
+	Since  time.Time // If not zero, only entries at or after this time.
+}
+
+// Match reports whether the filter selects an entry.
+func (f *Filter) Match(e *Entry) bool {
+	if f.Opener != "" && e.Opener != f.Opener {
+		return false
+	}
+	if f.Who != "" && !strings.Contains(e.User, f.Who) && !strings.Contains(e.Source, f.Who) {
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
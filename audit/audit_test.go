@@ -0,0 +1,217 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+)
+
+var testKey = audit.Key(bytes.Repeat([]byte{0x5a}, 32))
+
+func appendEntries(t *testing.T, l *audit.Log, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		rolling := uint32(240124710 + i)
+		e := &audit.Entry{
+			Opener:   "garage",
+			Action:   "open",
+			Protocol: "secplus-v2",
+			Fixed:    testKey.Fingerprint("secplus-v2", big.NewInt(70678577664)),
+			Rolling:  &rolling,
+			Source:   "cli",
+			User:     "alice",
+			Backend:  "gpio",
+			Result:   audit.ResultOK,
+		}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Seq != i+1 || (e.Hash == "") == l.Chain {
+			t.Errorf("want entry %d, with hash if chained; got %+v", i+1, e)
+		}
+	}
+}
+
+func TestAppendAndVerify(t *testing.T) {
+	l := &audit.Log{Path: filepath.Join(t.TempDir(), "audit.jsonl"), Chain: true}
+	if n, _, err := audit.Verify(l.Path); err != nil || n != 0 {
+		t.Errorf("want empty log to verify; got %d, %v", n, err)
+	}
+	appendEntries(t, l, 3)
+
+	entries, err := audit.Read(l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].Prev != entries[0].Hash || *entries[2].Rolling != 240124712 {
+		t.Fatalf("want 3 chained entries; got %+v", entries)
+	}
+	n, last, err := audit.Verify(l.Path)
+	if err != nil || n != 3 || last != entries[2].Hash {
+		t.Errorf("want 3 entries verified, ending %s; got %d, %s, %v", entries[2].Hash, n, last, err)
+	}
+
+	data, err := ioutil.ReadFile(l.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("70678577664")) {
+		t.Errorf("want fixed code kept out of the log; got:\n%s", data)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	testcases := []struct {
+		name   string
+		edit   func() string
+		line   int
+		reason string
+	}{
+		{"edited", func() string {
+			return strings.Replace(string(data), `"user":"alice"`, `"user":"mallory"`, 1)
+		}, 1, "contents"},
+		{"deleted", func() string {
+			return lines[0] + lines[2]
+		}, 2, "follows entry 1"},
+		{"reordered", func() string {
+			return lines[1] + lines[0] + lines[2]
+		}, 1, "previous hash"},
+	}
+	for _, tt := range testcases {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		if err := ioutil.WriteFile(path, []byte(tt.edit()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := audit.Verify(path)
+		var ce *audit.ChainError
+		if !errors.As(err, &ce) || ce.Line != tt.line || !strings.Contains(ce.Reason, tt.reason) {
+			t.Errorf("%s: want chain broken at line %d (%s); got %v", tt.name, tt.line, tt.reason, err)
+		}
+	}
+}
+
+func TestUnchained(t *testing.T) {
+	l := &audit.Log{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	appendEntries(t, l, 2)
+	if _, _, err := audit.Verify(l.Path); err == nil || !strings.Contains(err.Error(), "not hash-chained") {
+		t.Errorf("want unchained log to fail verification; got %v", err)
+	}
+	entries, err := audit.Read(l.Path)
+	if err != nil || len(entries) != 2 || entries[1].Seq != 2 {
+		t.Errorf("want 2 entries; got %+v, %v", entries, err)
+	}
+}
+
+func TestConcurrentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Separate Logs open the file separately, like separate
+			// processes would.
+			appendConcurrently(t, &audit.Log{Path: path, Chain: true})
+		}()
+	}
+	wg.Wait()
+	if n, _, err := audit.Verify(path); err != nil || n != 20 {
+		t.Errorf("want 20 entries verified; got %d, %v", n, err)
+	}
+}
+
+func appendConcurrently(t *testing.T, l *audit.Log) {
+	for i := 0; i < 5; i++ {
+		if err := l.Append(&audit.Entry{Protocol: "megacode", Source: "cli", Result: audit.ResultOK}); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	e := &audit.Entry{Opener: "gate", User: "bob", Source: "bob (uid 1000) via helper", Time: now}
+	testcases := []struct {
+		f    audit.Filter
+		want bool
+	}{
+		{audit.Filter{}, true},
+		{audit.Filter{Opener: "gate"}, true},
+		{audit.Filter{Opener: "garage"}, false},
+		{audit.Filter{Who: "helper"}, true},
+		{audit.Filter{Who: "alice"}, false},
+		{audit.Filter{Since: now}, true},
+		{audit.Filter{Since: now.Add(time.Second)}, false},
+	}
+	for _, tt := range testcases {
+		if got := tt.f.Match(e); got != tt.want {
+			t.Errorf("%+v: want %v; got %v", tt.f, tt.want, got)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if audit.FromContext(context.Background()) != nil {
+		t.Errorf("want no entry in background context")
+	}
+	e := &audit.Entry{Source: "mqtt"}
+	if got := audit.FromContext(audit.NewContext(context.Background(), e)); got != e {
+		t.Errorf("want entry back from context; got %+v", got)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.key")
+	key, err := audit.LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("want key file readable only by its owner; got %v, %v", fi.Mode(), err)
+	}
+	again, err := audit.LoadKey(path)
+	if err != nil || !bytes.Equal(again, key) {
+		t.Errorf("want the same key loaded again; got %x, %v", again, err)
+	}
+	other, err := audit.LoadKey(filepath.Join(t.TempDir(), "audit.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := big.NewInt(0x876543)
+	a := key.Fingerprint("megacode", code)
+	if len(a) != 12 || a == key.Fingerprint("secplus-v2", code) {
+		t.Errorf("want 12-digit fingerprint depending on protocol; got %s", a)
+	}
+	if a != again.Fingerprint("megacode", code) {
+		t.Errorf("want the same fingerprint with the same key")
+	}
+	if a == other.Fingerprint("megacode", code) {
+		t.Errorf("want the fingerprint to depend on the key")
+	}
+	// A plain hash of the code, which could be brute-forced.
+	sum := sha256.Sum256([]byte("megacode:" + code.String()))
+	if a == hex.EncodeToString(sum[:6]) {
+		t.Errorf("want fingerprint not to be an unkeyed hash")
+	}
+	if fp := audit.Key(nil).Fingerprint("megacode", code); fp != "" {
+		t.Errorf("want no fingerprint without a key; got %s", fp)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("short\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := audit.LoadKey(path); err == nil {
+		t.Errorf("want error for invalid key file")
+	}
+}
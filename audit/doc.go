@@ -0,0 +1,17 @@
+/*
+Package audit keeps an append-only log of every transmission: who asked for
+which opener, when, with what code, on what hardware, and how it went.
+
+The log is a file of JSON lines, one entry per transmission attempt, appended
+under a flock(2) lock so that the server, the helper and command-line use can
+share it. Entries may be hash-chained: each records the hash of the one
+before, and its own hash covers that, so editing or deleting an entry breaks
+the chain from there on. Truncating the end of the log can only be detected
+by comparing the last hash against a copy kept elsewhere.
+
+Fixed codes are never written, only short fingerprints of them, keyed with a
+random secret kept in a file only its owner can read, so the log doesn't let
+anyone who can read it clone a remote. The log itself is only readable by its
+owner and group.
+*/
+package audit
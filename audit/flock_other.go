@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package audit
+
+import "os"
+
+// lockFile does nothing: locking is only supported on Linux, so elsewhere
+// only one process should append to a log at a time.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,191 @@
+package bridge_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/bridge"
+	"github.com/zellyn/openers/bridge/bridgetest"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestCRC16(t *testing.T) {
+	// The standard check value for CRC-16/CCITT-FALSE.
+	if got := bridge.CRC16([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("want CRC16(\"123456789\")==0x29B1; got 0x%04X", got)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	hello, _ := bridge.Frame{Type: bridge.TypeHello, Seq: 7}.Marshal()
+	ack, _ := bridge.Frame{Type: bridge.TypeAck, Seq: 8}.Marshal()
+	if want := []byte{0xA5, 0x5A, 0x01, 0x07, 0x00, 0x00}; !bytes.Equal(hello[:6], want) {
+		t.Errorf("want HELLO header % x; got % x", want, hello[:6])
+	}
+	corrupt := append([]byte(nil), ack...)
+	corrupt[3] = 9
+
+	testcases := []struct {
+		name    string
+		input   []byte
+		want    bridge.Frame
+		wantCRC bool
+	}{
+		{name: "plain", input: hello, want: bridge.Frame{Type: bridge.TypeHello, Seq: 7, Payload: []byte{}}},
+		{
+			name:  "resync",
+			input: append([]byte{0x00, 0xA5, 0xA5, 0x13}, ack...),
+			want:  bridge.Frame{Type: bridge.TypeAck, Seq: 8, Payload: []byte{}},
+		},
+		{name: "bad crc", input: corrupt, wantCRC: true},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bridge.ReadFrame(bufio.NewReader(bytes.NewReader(tt.input)))
+			var crcErr *bridge.ErrBadCRC
+			if tt.wantCRC {
+				if !errors.As(err, &crcErr) {
+					t.Errorf("want CRC error; got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %+v; got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTransmitRoundTrip(t *testing.T) {
+	want := bridge.Transmit{
+		Repeats:   3,
+		RepeatGap: 50 * time.Millisecond,
+		Pulses: []waveform.Pulse{
+			{Level: 1, Duration: 250 * time.Microsecond},
+			{Level: 0, Duration: 500 * time.Microsecond},
+			{Level: 1, Duration: 30 * time.Second},
+		},
+	}
+	payload, err := want.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 20 {
+		t.Errorf("want 20-byte payload; got %d bytes", len(payload))
+	}
+	got, err := bridge.ParseTransmit(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+
+	bad := want
+	bad.Pulses = []waveform.Pulse{{Level: 1}}
+	if _, err := bad.Marshal(); err == nil {
+		t.Errorf("want error for zero-length pulse")
+	}
+}
+
+func TestFromTransmission(t *testing.T) {
+	ms := time.Millisecond
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("110"), Pulsewidth: ms, Gap: 5 * ms},
+			{Bits: bits.B("01"), Pulsewidth: ms},
+		},
+		Repeats:     2,
+		RepeatGap:   10 * ms,
+		Frequencies: []float64{310e6, 390e6},
+		Hop:         waveform.HopRound,
+	}
+	got := bridge.FromTransmission(tx)
+	if got.Repeats != 4 || got.RepeatGap != 10*ms {
+		t.Errorf("want 4 repeats 10ms apart; got %d repeats %v apart", got.Repeats, got.RepeatGap)
+	}
+	if got.Duration() != tx.Duration() {
+		t.Errorf("want duration %v; got %v", tx.Duration(), got.Duration())
+	}
+}
+
+// pipe returns a client connected to a fake device.
+func pipe(t *testing.T, d *bridgetest.Device) *bridge.Client {
+	host, device := net.Pipe()
+	done := make(chan error)
+	go func() { done <- d.Serve(device) }()
+	c := bridge.NewClient(host)
+	c.AckTimeout = 20 * time.Millisecond
+	t.Cleanup(func() {
+		c.Close()
+		if err := <-done; err != nil {
+			t.Errorf("device: %v", err)
+		}
+	})
+	return c
+}
+
+func TestClient(t *testing.T) {
+	tr := bridge.Transmit{
+		Repeats:   2,
+		RepeatGap: time.Millisecond,
+		Pulses:    []waveform.Pulse{{Level: 1, Duration: 300 * time.Microsecond}},
+	}
+
+	t.Run("hello", func(t *testing.T) {
+		c := pipe(t, &bridgetest.Device{MaxPulses: 100})
+		info, err := c.Hello()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (bridge.Info{Version: 1, MaxPulses: 100}); info != want {
+			t.Errorf("want %+v; got %+v", want, info)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		d := &bridgetest.Device{Ignore: 2}
+		c := pipe(t, d)
+		elapsed, err := c.Transmit(context.Background(), tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed != 1600*time.Microsecond {
+			t.Errorf("want elapsed 1.6ms; got %v", elapsed)
+		}
+		if got := d.Requests(); got != 3 {
+			t.Errorf("want 3 requests; got %d", got)
+		}
+		if got := d.Transmissions(); !reflect.DeepEqual(got, []bridge.Transmit{tr}) {
+			t.Errorf("want one transmission %+v; got %+v", tr, got)
+		}
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		c := pipe(t, &bridgetest.Device{Ignore: 10})
+		if _, err := c.Transmit(context.Background(), tr); err == nil {
+			t.Errorf("want error when device never answers")
+		}
+	})
+
+	t.Run("nak", func(t *testing.T) {
+		c := pipe(t, &bridgetest.Device{MaxPulses: 1})
+		long := tr
+		long.Pulses = append(long.Pulses, waveform.Pulse{Level: 0, Duration: time.Millisecond})
+		_, err := c.Transmit(context.Background(), long)
+		var nak *bridge.NakError
+		if !errors.As(err, &nak) || nak.Code != bridge.NakTooManyPulses {
+			t.Errorf("want too-many-pulses NAK; got %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// Package bridgetest provides a fake bridge device, for testing code that uses
+// package bridge.
+package bridgetest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/bridge"
+)
+
+// Device is a fake bridge device. It records every transmission it is asked
+// to play, and reports having played it in exactly the requested time,
+// without waiting.
+type Device struct {
+	// MaxPulses is the largest number of pulses accepted; 0 means no limit.
+	MaxPulses int
+	// Ignore is the number of requests to drop without answering, as if
+	// they had been lost on the line.
+	Ignore int
+
+	mu       sync.Mutex
+	requests int
+	sent     []bridge.Transmit
+	lastSeq  int
+	lastDone bridge.Done
+}
+
+// Transmissions returns the transmissions played so far.
+func (d *Device) Transmissions() []bridge.Transmit {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]bridge.Transmit(nil), d.sent...)
+}
+
+// Requests returns the number of frames received so far, including dropped
+// ones.
+func (d *Device) Requests() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.requests
+}
+
+// Serve answers requests arriving on conn until reading from it fails,
+// returning nil if it was closed.
+func (d *Device) Serve(conn io.ReadWriter) error {
+	d.mu.Lock()
+	d.lastSeq = -1
+	d.mu.Unlock()
+	r := bufio.NewReader(conn)
+	for {
+		f, err := bridge.ReadFrame(r)
+		var crcErr *bridge.ErrBadCRC
+		switch {
+		case errors.As(err, &crcErr):
+			f = bridge.Frame{Type: bridge.TypeNak, Seq: crcErr.Frame.Seq, Payload: []byte{bridge.NakBadCRC}}
+			if err := bridge.WriteFrame(conn, f); err != nil {
+				return err
+			}
+			continue
+		case err == io.EOF || err == io.ErrClosedPipe:
+			return nil
+		case err != nil:
+			return err
+		}
+		for _, reply := range d.handle(f) {
+			if err := bridge.WriteFrame(conn, reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle returns the replies to a request.
+func (d *Device) handle(f bridge.Frame) []bridge.Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests++
+	if d.Ignore > 0 {
+		d.Ignore--
+		return nil
+	}
+	reply := func(typ byte, payload []byte) bridge.Frame {
+		return bridge.Frame{Type: typ, Seq: f.Seq, Payload: payload}
+	}
+	switch f.Type {
+	case bridge.TypeHello:
+		info := bridge.Info{Version: bridge.Version, MaxPulses: d.MaxPulses}
+		if info.MaxPulses == 0 {
+			info.MaxPulses = (bridge.MaxPayload - 8) / 4
+		}
+		return []bridge.Frame{reply(bridge.TypeInfo, info.Marshal())}
+	case bridge.TypeTransmit:
+		if int(f.Seq) == d.lastSeq {
+			return []bridge.Frame{reply(bridge.TypeAck, nil), reply(bridge.TypeDone, d.lastDone.Marshal())}
+		}
+		t, err := bridge.ParseTransmit(f.Payload)
+		if err != nil {
+			return []bridge.Frame{reply(bridge.TypeNak, []byte{bridge.NakMalformed})}
+		}
+		if d.MaxPulses > 0 && len(t.Pulses) > d.MaxPulses {
+			return []bridge.Frame{reply(bridge.TypeNak, []byte{bridge.NakTooManyPulses})}
+		}
+		d.sent = append(d.sent, t)
+		d.lastSeq = int(f.Seq)
+		d.lastDone = bridge.Done{Elapsed: t.Duration().Truncate(time.Microsecond)}
+		return []bridge.Frame{reply(bridge.TypeAck, nil), reply(bridge.TypeDone, d.lastDone.Marshal())}
+	}
+	return []bridge.Frame{reply(bridge.TypeNak, []byte{bridge.NakUnknownType})}
+}
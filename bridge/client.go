@@ -0,0 +1,218 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// Defaults for the Client's timing, as given in PROTOCOL.md.
+const (
+	DefaultAckTimeout = 500 * time.Millisecond
+	DefaultRetries    = 3
+	doneMargin        = time.Second
+)
+
+// errTimeout is returned by next when no reply arrives in time.
+var errTimeout = errors.New("timed out waiting for bridge device")
+
+// Client is the host side of a connection to a bridge device. It is safe for
+// concurrent use, but handles one request at a time.
+type Client struct {
+	AckTimeout time.Duration // How long to wait for an ACK before resending.
+	Retries    int           // How many times to resend an unacknowledged request.
+
+	mu     sync.Mutex
+	conn   io.ReadWriteCloser
+	seq    byte
+	frames chan frameOrError
+}
+
+type frameOrError struct {
+	frame Frame
+	err   error
+}
+
+// NewClient returns a client talking to a bridge device over conn, such as a
+// serial port returned by OpenSerial.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		AckTimeout: DefaultAckTimeout,
+		Retries:    DefaultRetries,
+		conn:       conn,
+		frames:     make(chan frameOrError, 16),
+	}
+	go c.read()
+	return c
+}
+
+// read passes frames from the device to the channel, until reading fails.
+// Serial ports don't reliably support read deadlines, so timeouts are
+// handled on the receiving side of the channel instead.
+func (c *Client) read() {
+	r := bufio.NewReader(c.conn)
+	for {
+		f, err := ReadFrame(r)
+		var crcErr *ErrBadCRC
+		if errors.As(err, &crcErr) {
+			// Corrupted replies are dropped: the request will be resent.
+			continue
+		}
+		c.frames <- frameOrError{f, err}
+		if err != nil {
+			close(c.frames)
+			return
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// next waits up to timeout for the next reply to the request with sequence
+// number seq, discarding stale replies to earlier requests.
+func (c *Client) next(ctx context.Context, seq byte, timeout time.Duration) (Frame, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case fe, ok := <-c.frames:
+			if !ok {
+				return Frame{}, fmt.Errorf("bridge connection closed")
+			}
+			if fe.err != nil {
+				return Frame{}, fmt.Errorf("error reading from bridge device: %v", fe.err)
+			}
+			if fe.frame.Seq == seq {
+				return fe.frame, nil
+			}
+		case <-timer.C:
+			return Frame{}, errTimeout
+		case <-ctx.Done():
+			return Frame{}, ctx.Err()
+		}
+	}
+}
+
+// request sends a request, resending it if it is not answered in time or
+// arrived corrupted, and returns the first reply.
+func (c *Client) request(ctx context.Context, typ byte, payload []byte) (Frame, error) {
+	c.seq++
+	f := Frame{Type: typ, Seq: c.seq, Payload: payload}
+	for attempt := 0; ; attempt++ {
+		if err := WriteFrame(c.conn, f); err != nil {
+			return Frame{}, fmt.Errorf("error writing to bridge device: %v", err)
+		}
+		reply, err := c.next(ctx, f.Seq, c.AckTimeout)
+		if err == nil && reply.Type == TypeNak && len(reply.Payload) == 1 && reply.Payload[0] == NakBadCRC {
+			err = &NakError{Code: NakBadCRC}
+		}
+		if err == nil {
+			return reply, nil
+		}
+		var nak *NakError
+		if (err != errTimeout && !errors.As(err, &nak)) || attempt >= c.Retries {
+			return Frame{}, err
+		}
+	}
+}
+
+// nak converts a NAK reply to an error.
+func nak(f Frame) error {
+	if len(f.Payload) != 1 {
+		return fmt.Errorf("want 1-byte NAK payload; got %d bytes", len(f.Payload))
+	}
+	return &NakError{Code: int(f.Payload[0])}
+}
+
+// Hello checks that the device is there, and returns what it reports about
+// itself.
+func (c *Client) Hello() (Info, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.request(context.Background(), TypeHello, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	switch reply.Type {
+	case TypeInfo:
+		return ParseInfo(reply.Payload)
+	case TypeNak:
+		return Info{}, nak(reply)
+	}
+	return Info{}, fmt.Errorf("want INFO reply to HELLO; got message type 0x%02x", reply.Type)
+}
+
+// Transmit asks the device to play a transmission, and waits until it has
+// finished, returning how long the device reports it took. Cancelling the
+// context stops the wait, but not the device.
+func (c *Client) Transmit(ctx context.Context, t Transmit) (time.Duration, error) {
+	payload, err := t.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.request(ctx, TypeTransmit, payload)
+	if err != nil {
+		return 0, err
+	}
+	switch reply.Type {
+	case TypeAck:
+	case TypeNak:
+		return 0, nak(reply)
+	default:
+		return 0, fmt.Errorf("want ACK reply to TRANSMIT; got message type 0x%02x", reply.Type)
+	}
+
+	deadline := time.Now().Add(t.Duration() + doneMargin)
+	for {
+		reply, err := c.next(ctx, c.seq, time.Until(deadline))
+		if err == errTimeout {
+			return 0, fmt.Errorf("bridge device did not finish transmitting within %v", t.Duration()+doneMargin)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if reply.Type == TypeAck {
+			// A duplicate, from a resent request.
+			continue
+		}
+		if reply.Type != TypeDone {
+			return 0, fmt.Errorf("want DONE after TRANSMIT; got message type 0x%02x", reply.Type)
+		}
+		done, err := ParseDone(reply.Payload)
+		if err != nil {
+			return 0, err
+		}
+		if done.Status != 0 {
+			return done.Elapsed, fmt.Errorf("bridge device reported transmit failure: status %d", done.Status)
+		}
+		return done.Elapsed, nil
+	}
+}
+
+// FromTransmission converts a transmission to a TRANSMIT request. The device
+// has a single frequency, so every repeat of the frequency plan is sent on it.
+func FromTransmission(tx *waveform.Transmission) Transmit {
+	one := *tx
+	one.Repeats = 1
+	one.Frequencies = nil
+	repeats := tx.Repeats
+	if tx.Hop == waveform.HopRound && len(tx.Frequencies) > 0 {
+		repeats *= len(tx.Frequencies)
+	}
+	return Transmit{
+		Repeats:   repeats,
+		RepeatGap: tx.RepeatGap,
+		Pulses:    one.Pulses(),
+	}
+}
@@ -0,0 +1,10 @@
+/*
+Package bridge sends transmissions over a serial line to a microcontroller,
+such as an ESP32, that plays them out with hardware timers.
+
+Transmissions are described compactly, as the level and duration of each
+pulse of a single repetition, along with the number of repeats and the gap
+between them. Messages are framed and checksummed, and the device
+acknowledges each one. See PROTOCOL.md for the details.
+*/
+package bridge
@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Message types.
+const (
+	TypeHello    = 0x01
+	TypeInfo     = 0x81
+	TypeTransmit = 0x02
+	TypeAck      = 0x06
+	TypeNak      = 0x15
+	TypeDone     = 0x04
+)
+
+// NAK codes.
+const (
+	NakBadCRC        = 1
+	NakMalformed     = 2
+	NakTooManyPulses = 3
+	NakBusy          = 4
+	NakUnknownType   = 5
+)
+
+// Version is the protocol version spoken by this package.
+const Version = 1
+
+// MaxPayload is the largest payload a frame may carry.
+const MaxPayload = 4096
+
+var magic = [2]byte{0xA5, 0x5A}
+
+// Frame is a single message.
+type Frame struct {
+	Type    byte
+	Seq     byte
+	Payload []byte
+}
+
+// ErrBadCRC is returned by ReadFrame for a frame whose CRC doesn't match. The
+// frame is still returned, so the receiver can NAK it.
+type ErrBadCRC struct {
+	Frame Frame
+}
+
+func (e *ErrBadCRC) Error() string {
+	return fmt.Sprintf("bad CRC on frame of type 0x%02x", e.Frame.Type)
+}
+
+// Marshal encodes a frame.
+func (f Frame) Marshal() ([]byte, error) {
+	if len(f.Payload) > MaxPayload {
+		return nil, fmt.Errorf("frame payload of %d bytes exceeds %d", len(f.Payload), MaxPayload)
+	}
+	buf := make([]byte, 0, 8+len(f.Payload))
+	buf = append(buf, magic[0], magic[1], f.Type, f.Seq, byte(len(f.Payload)), byte(len(f.Payload)>>8))
+	buf = append(buf, f.Payload...)
+	crc := CRC16(buf[2:])
+	return append(buf, byte(crc), byte(crc>>8)), nil
+}
+
+// WriteFrame writes a frame.
+func WriteFrame(w io.Writer, f Frame) error {
+	buf, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// ReadFrame reads the next frame, skipping anything before its magic.
+func ReadFrame(r *bufio.Reader) (Frame, error) {
+	matched := 0
+	for matched < len(magic) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Frame{}, err
+		}
+		switch {
+		case b == magic[matched]:
+			matched++
+		case b == magic[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Frame{}, err
+	}
+	n := int(binary.LittleEndian.Uint16(header[2:]))
+	if n > MaxPayload {
+		return Frame{}, fmt.Errorf("frame payload of %d bytes exceeds %d", n, MaxPayload)
+	}
+	rest := make([]byte, n+2)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return Frame{}, err
+	}
+	f := Frame{Type: header[0], Seq: header[1], Payload: rest[:n]}
+	crc := CRC16(append(header, rest[:n]...))
+	if got := binary.LittleEndian.Uint16(rest[n:]); got != crc {
+		return f, &ErrBadCRC{Frame: f}
+	}
+	return f, nil
+}
+
+// CRC16 computes the CRC-16/CCITT-FALSE of data.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,152 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// levelBit marks a pulse as carrier-on, in its encoded form.
+const levelBit = 1 << 31
+
+// Info is the payload of an INFO message.
+type Info struct {
+	Version   int
+	MaxPulses int
+}
+
+// Marshal encodes an INFO payload.
+func (i Info) Marshal() []byte {
+	buf := make([]byte, 3)
+	buf[0] = byte(i.Version)
+	binary.LittleEndian.PutUint16(buf[1:], uint16(i.MaxPulses))
+	return buf
+}
+
+// ParseInfo decodes an INFO payload.
+func ParseInfo(payload []byte) (Info, error) {
+	if len(payload) != 3 {
+		return Info{}, fmt.Errorf("want 3-byte INFO payload; got %d bytes", len(payload))
+	}
+	return Info{
+		Version:   int(payload[0]),
+		MaxPulses: int(binary.LittleEndian.Uint16(payload[1:])),
+	}, nil
+}
+
+// Transmit is the payload of a TRANSMIT message: the pulses of a single
+// repetition, and how to repeat them.
+type Transmit struct {
+	Repeats   int
+	RepeatGap time.Duration
+	Pulses    []waveform.Pulse
+}
+
+// Marshal encodes a TRANSMIT payload.
+func (t Transmit) Marshal() ([]byte, error) {
+	if t.Repeats < 1 || t.Repeats > 0xFFFF {
+		return nil, fmt.Errorf("repeat count %d out of range 1-65535", t.Repeats)
+	}
+	gap := t.RepeatGap / time.Microsecond
+	if gap < 0 || gap > 0xFFFFFFFF {
+		return nil, fmt.Errorf("repeat gap %v out of range", t.RepeatGap)
+	}
+	if 8+4*len(t.Pulses) > MaxPayload {
+		return nil, fmt.Errorf("%d pulses won't fit in one frame", len(t.Pulses))
+	}
+	buf := make([]byte, 8, 8+4*len(t.Pulses))
+	binary.LittleEndian.PutUint16(buf[0:], uint16(t.Repeats))
+	binary.LittleEndian.PutUint32(buf[2:], uint32(gap))
+	binary.LittleEndian.PutUint16(buf[6:], uint16(len(t.Pulses)))
+	for _, p := range t.Pulses {
+		us := p.Duration / time.Microsecond
+		if us <= 0 || us >= levelBit {
+			return nil, fmt.Errorf("pulse duration %v out of range", p.Duration)
+		}
+		v := uint32(us)
+		if p.Level != 0 {
+			v |= levelBit
+		}
+		buf = append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	return buf, nil
+}
+
+// ParseTransmit decodes a TRANSMIT payload.
+func ParseTransmit(payload []byte) (Transmit, error) {
+	if len(payload) < 8 {
+		return Transmit{}, fmt.Errorf("TRANSMIT payload of %d bytes is too short", len(payload))
+	}
+	n := int(binary.LittleEndian.Uint16(payload[6:]))
+	if len(payload) != 8+4*n {
+		return Transmit{}, fmt.Errorf("want %d bytes of TRANSMIT payload for %d pulses; got %d", 8+4*n, n, len(payload))
+	}
+	t := Transmit{
+		Repeats:   int(binary.LittleEndian.Uint16(payload[0:])),
+		RepeatGap: time.Duration(binary.LittleEndian.Uint32(payload[2:])) * time.Microsecond,
+		Pulses:    make([]waveform.Pulse, n),
+	}
+	for i := range t.Pulses {
+		v := binary.LittleEndian.Uint32(payload[8+4*i:])
+		if v&levelBit != 0 {
+			t.Pulses[i].Level = 1
+		}
+		t.Pulses[i].Duration = time.Duration(v&^levelBit) * time.Microsecond
+	}
+	return t, nil
+}
+
+// Duration returns how long the device should take to play the transmission.
+func (t Transmit) Duration() time.Duration {
+	if t.Repeats == 0 {
+		return 0
+	}
+	return time.Duration(t.Repeats)*waveform.Duration(t.Pulses) + time.Duration(t.Repeats-1)*t.RepeatGap
+}
+
+// Done is the payload of a DONE message.
+type Done struct {
+	Status  int
+	Elapsed time.Duration
+}
+
+// Marshal encodes a DONE payload.
+func (d Done) Marshal() []byte {
+	buf := make([]byte, 5)
+	buf[0] = byte(d.Status)
+	binary.LittleEndian.PutUint32(buf[1:], uint32(d.Elapsed/time.Microsecond))
+	return buf
+}
+
+// ParseDone decodes a DONE payload.
+func ParseDone(payload []byte) (Done, error) {
+	if len(payload) != 5 {
+		return Done{}, fmt.Errorf("want 5-byte DONE payload; got %d bytes", len(payload))
+	}
+	return Done{
+		Status:  int(payload[0]),
+		Elapsed: time.Duration(binary.LittleEndian.Uint32(payload[1:])) * time.Microsecond,
+	}, nil
+}
+
+// NakError is returned when the device refuses a request.
+type NakError struct {
+	Code int
+}
+
+var nakNames = map[int]string{
+	NakBadCRC:        "bad CRC",
+	NakMalformed:     "malformed payload",
+	NakTooManyPulses: "too many pulses",
+	NakBusy:          "busy",
+	NakUnknownType:   "unknown message type",
+}
+
+func (e *NakError) Error() string {
+	if name, ok := nakNames[e.Code]; ok {
+		return fmt.Sprintf("bridge device refused request: %s", name)
+	}
+	return fmt.Sprintf("bridge device refused request: code %d", e.Code)
+}
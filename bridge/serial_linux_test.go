@@ -0,0 +1,67 @@
+package bridge_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/zellyn/openers/bridge"
+	"github.com/zellyn/openers/bridge/bridgetest"
+	"github.com/zellyn/openers/waveform"
+)
+
+// openPty returns the master side of a new pseudo-terminal, and the path of
+// its slave side.
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	var n uint32
+	rc, err := master.SyscallConn()
+	if err == nil {
+		rc.Control(func(fd uintptr) {
+			if err = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); err == nil {
+				n, err = unix.IoctlGetUint32(int(fd), unix.TIOCGPTN)
+			}
+		})
+	}
+	if err != nil {
+		master.Close()
+		t.Skipf("cannot set up pseudo-terminal: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestSerial(t *testing.T) {
+	master, path := openPty(t)
+	defer master.Close()
+
+	port, err := bridge.OpenSerial(path, bridge.DefaultBaud)
+	if err != nil {
+		t.Skipf("cannot open pseudo-terminal %s: %v", path, err)
+	}
+	d := &bridgetest.Device{}
+	go d.Serve(master)
+
+	c := bridge.NewClient(port)
+	defer c.Close()
+	tr := bridge.Transmit{
+		Repeats: 1,
+		// Bytes that a terminal in cooked mode would mangle: XON, XOFF, CR, ^C.
+		Pulses: []waveform.Pulse{{Level: 1, Duration: 0x0D1113 * time.Microsecond}, {Level: 0, Duration: 3 * time.Microsecond}},
+	}
+	if _, err := c.Transmit(context.Background(), tr); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Transmissions(); len(got) != 1 || got[0].Pulses[0] != tr.Pulses[0] {
+		t.Errorf("want transmission %+v; got %+v", tr, got)
+	}
+	if _, err := bridge.OpenSerial(path, 12345); err == nil {
+		t.Errorf("want error for unsupported baud rate")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package bridge
+
+import (
+	"fmt"
+	"os"
+)
+
+// DefaultBaud is the serial speed used by default.
+const DefaultBaud = 115200
+
+// OpenSerial reports that serial ports are only supported on Linux.
+func OpenSerial(path string, baud int) (*os.File, error) {
+	return nil, fmt.Errorf("serial ports are only supported on Linux")
+}
@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/gpiod"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Edge is a change in the level of an input line.
+type Edge struct {
+	Timestamp time.Duration // Kernel timestamp of the change.
+	Level     byte          // Level after the change.
+}
+
+// Capture collects edges. It is safe to add edges from one goroutine while
+// reading them from another.
+type Capture struct {
+	mu    sync.Mutex
+	edges []Edge
+}
+
+// HandleEvent records a GPIO line event. It can be passed to
+// gpiod.WithEventHandler.
+func (c *Capture) HandleEvent(evt gpiod.LineEvent) {
+	level := byte(0)
+	if evt.Type == gpiod.LineEventRisingEdge {
+		level = 1
+	}
+	c.Add(Edge{Timestamp: evt.Timestamp, Level: level})
+}
+
+// Add records an edge.
+func (c *Capture) Add(e Edge) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.edges = append(c.edges, e)
+}
+
+// Reset discards all the edges recorded so far.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.edges = nil
+}
+
+// Edges returns a copy of the edges recorded so far, in order.
+func (c *Capture) Edges() []Edge {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]Edge, len(c.edges))
+	copy(result, c.edges)
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result
+}
+
+// Pulses converts the edges recorded so far to pulses, starting at the first
+// rising edge. The level after the last edge is dropped, since its duration is
+// unknown. Repeated edges to the same level, left behind when the kernel's
+// event buffer overflows, are merged.
+func (c *Capture) Pulses() []waveform.Pulse {
+	return Pulses(c.Edges())
+}
+
+// Pulses converts edges, which must be in order, to pulses, like
+// Capture.Pulses.
+func Pulses(edges []Edge) []waveform.Pulse {
+	var result []waveform.Pulse
+	var last *Edge
+	for i := range edges {
+		e := &edges[i]
+		switch {
+		case last == nil:
+			if e.Level == 1 {
+				last = e
+			}
+		case e.Level != last.Level:
+			result = append(result, waveform.Pulse{Level: last.Level, Duration: e.Timestamp - last.Timestamp})
+			last = e
+		}
+	}
+	return result
+}
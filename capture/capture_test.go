@@ -0,0 +1,66 @@
+package capture_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/capture"
+	"github.com/zellyn/openers/gpiod"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestPulses(t *testing.T) {
+	us := time.Microsecond
+	testcases := []struct {
+		name   string
+		events []gpiod.LineEvent
+		want   []waveform.Pulse
+	}{
+		{
+			name: "simple",
+			events: []gpiod.LineEvent{
+				{Timestamp: 1000 * us, Type: gpiod.LineEventRisingEdge},
+				{Timestamp: 1250 * us, Type: gpiod.LineEventFallingEdge},
+				{Timestamp: 1750 * us, Type: gpiod.LineEventRisingEdge},
+				{Timestamp: 2000 * us, Type: gpiod.LineEventFallingEdge},
+			},
+			want: []waveform.Pulse{
+				{Level: 1, Duration: 250 * us},
+				{Level: 0, Duration: 500 * us},
+				{Level: 1, Duration: 250 * us},
+			},
+		},
+		{
+			name: "leading falling edge, out of order, and repeated",
+			events: []gpiod.LineEvent{
+				{Timestamp: 900 * us, Type: gpiod.LineEventFallingEdge},
+				{Timestamp: 1250 * us, Type: gpiod.LineEventFallingEdge},
+				{Timestamp: 1000 * us, Type: gpiod.LineEventRisingEdge},
+				{Timestamp: 1500 * us, Type: gpiod.LineEventFallingEdge},
+				{Timestamp: 1750 * us, Type: gpiod.LineEventRisingEdge},
+			},
+			want: []waveform.Pulse{
+				{Level: 1, Duration: 250 * us},
+				{Level: 0, Duration: 500 * us},
+			},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &capture.Capture{}
+			c.Add(capture.Edge{Timestamp: 0, Level: 1})
+			c.Reset()
+			for _, evt := range tt.events {
+				c.HandleEvent(evt)
+			}
+			if got := c.Pulses(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+/*
+Package capture collects edges seen on a GPIO input line, such as a transmit
+pin jumpered back to an input, and converts them to pulses.
+
+Edges are timestamped by the kernel as they happen, so the captured timing
+isn't disturbed by scheduling delays in reading the events.
+*/
+package capture
@@ -0,0 +1,312 @@
+package cc1101
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/zellyn/openers/spidev"
+)
+
+// Crystal is the frequency of the crystal on common CC1101 modules, in Hz.
+const Crystal = 26e6
+
+// SPISpeed is a comfortable SPI clock speed for talking to the chip.
+const SPISpeed = 1000000
+
+// Mode is how the chip gets the data to transmit.
+type Mode int
+
+const (
+	// FIFO mode packs the transmission into a bitstream fed through the
+	// transmit FIFO.
+	FIFO Mode = iota
+	// Async mode keys the carrier from the level of the GDO0 pin.
+	Async
+)
+
+// Config describes how to set up the chip for transmitting.
+type Config struct {
+	Frequency float64 // Carrier frequency in Hz.
+	Power     int     // Output power in dBm; see Powers.
+	Mode      Mode
+	DataRate  float64 // Bits per second, for FIFO mode.
+}
+
+// bands are the frequency ranges the chip supports, and their recommended
+// PATABLE settings by output power, from TI's application note DN013.
+var bands = []struct {
+	low, high float64
+	power     map[int]byte
+}{
+	{300e6, 348e6, map[int]byte{-30: 0x12, -20: 0x0D, -15: 0x1C, -10: 0x34, 0: 0x51, 5: 0x85, 7: 0xCB, 10: 0xC2}},
+	{387e6, 464e6, map[int]byte{-30: 0x12, -20: 0x0E, -15: 0x1D, -10: 0x34, 0: 0x60, 5: 0x84, 7: 0xC8, 10: 0xC0}},
+	{779e6, 928e6, map[int]byte{-30: 0x03, -20: 0x0E, -15: 0x1E, -10: 0x27, 0: 0x8E, 5: 0xCD, 7: 0xC7, 10: 0xC0}},
+}
+
+// Powers returns the supported output powers, in dBm.
+func Powers() []int {
+	var result []int
+	for p := range bands[0].power {
+		result = append(result, p)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Setting is a value for a configuration register.
+type Setting struct {
+	Register byte
+	Value    byte
+}
+
+// Settings returns the configuration register values for cfg, in the order
+// they should be written, and the value of the PATABLE entry for the carrier
+// being on.
+func Settings(cfg Config) ([]Setting, byte, error) {
+	var power byte
+	found := false
+	for _, band := range bands {
+		if cfg.Frequency >= band.low && cfg.Frequency <= band.high {
+			p, ok := band.power[cfg.Power]
+			if !ok {
+				return nil, 0, fmt.Errorf("unsupported CC1101 output power %ddBm; want one of %v", cfg.Power, Powers())
+			}
+			power, found = p, true
+		}
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("CC1101 cannot transmit at %gMHz; it supports 300-348, 387-464 and 779-928MHz", cfg.Frequency/1e6)
+	}
+
+	freq := uint32(math.Round(cfg.Frequency * (1 << 16) / Crystal))
+	settings := []Setting{
+		{IOCFG2, 0x2E}, // High impedance.
+		{IOCFG1, 0x2E},
+		{FIFOTHR, 0x47},
+		{PKTCTRL1, 0x04},
+		{FSCTRL1, 0x06},
+		{FSCTRL0, 0x00},
+		{FREQ2, byte(freq >> 16)},
+		{FREQ1, byte(freq >> 8)},
+		{FREQ0, byte(freq)},
+		{MDMCFG2, 0x30}, // OOK, with no preamble or sync word.
+		{MDMCFG1, 0x00},
+		{MDMCFG0, 0xF8},
+		{MCSM0, 0x18},  // Calibrate when leaving IDLE.
+		{FREND0, 0x11}, // Use PATABLE[0] while the carrier is off, and PATABLE[1] while on.
+		{FSCAL3, 0xE9},
+		{FSCAL2, 0x2A},
+		{FSCAL1, 0x00},
+		{FSCAL0, 0x1F},
+		{TEST2, 0x81},
+		{TEST1, 0x35},
+		{TEST0, 0x09},
+	}
+
+	switch cfg.Mode {
+	case Async:
+		settings = append(settings,
+			Setting{IOCFG0, 0x0D},   // Serial data, which is the input in TX.
+			Setting{PKTCTRL0, 0x32}, // Asynchronous serial mode, infinite length.
+			Setting{MDMCFG4, 0x8C},  // A high data rate, so GDO0 is sampled often.
+			Setting{MDMCFG3, 0x22},
+		)
+	case FIFO:
+		e, m, err := dataRate(cfg.DataRate)
+		if err != nil {
+			return nil, 0, err
+		}
+		settings = append(settings,
+			Setting{IOCFG0, 0x2E},
+			Setting{PKTCTRL0, 0x02}, // FIFO, infinite length, no CRC or whitening.
+			Setting{MDMCFG4, 0x80 | e},
+			Setting{MDMCFG3, m},
+		)
+	default:
+		return nil, 0, fmt.Errorf("unknown CC1101 mode %d", cfg.Mode)
+	}
+	return settings, power, nil
+}
+
+// dataRate returns the DRATE_E exponent and DRATE_M mantissa giving the
+// closest data rate to rate: (256+M)*2^E*Crystal/2^28.
+func dataRate(rate float64) (byte, byte, error) {
+	if rate < 600 || rate > 500e3 {
+		return 0, 0, fmt.Errorf("CC1101 data rate %gbps out of range 600-500000", rate)
+	}
+	e := int(math.Floor(math.Log2(rate * (1 << 20) / Crystal)))
+	m := int(math.Round(rate*(1<<28)/(Crystal*math.Pow(2, float64(e))))) - 256
+	if m == 256 {
+		e, m = e+1, 0
+	}
+	return byte(e), byte(m), nil
+}
+
+// DataRate returns the data rate the chip actually uses when asked for rate.
+func DataRate(rate float64) (float64, error) {
+	e, m, err := dataRate(rate)
+	if err != nil {
+		return 0, err
+	}
+	return (256 + float64(m)) * math.Pow(2, float64(e)) * Crystal / (1 << 28), nil
+}
+
+// Radio is a CC1101 connected to an SPI device.
+type Radio struct {
+	Conn spidev.Conn
+	cfg  Config
+}
+
+// WriteReg writes a configuration register.
+func (r *Radio) WriteReg(reg, value byte) error {
+	return r.Conn.Tx([]byte{reg, value}, nil)
+}
+
+// ReadStatus reads a status register.
+func (r *Radio) ReadStatus(reg byte) (byte, error) {
+	resp := make([]byte, 2)
+	if err := r.Conn.Tx([]byte{reg | ReadFlag | BurstFlag, 0}, resp); err != nil {
+		return 0, err
+	}
+	return resp[1], nil
+}
+
+// WriteBurst writes consecutive registers, or multiple bytes to PATABLE or
+// TXFIFO.
+func (r *Radio) WriteBurst(reg byte, data []byte) error {
+	return r.Conn.Tx(append([]byte{reg | BurstFlag}, data...), nil)
+}
+
+// Strobe sends a command strobe.
+func (r *Radio) Strobe(cmd byte) error {
+	return r.Conn.Tx([]byte{cmd}, nil)
+}
+
+// Configure resets the chip, checks that it is there, and sets it up for
+// transmitting.
+func (r *Radio) Configure(cfg Config) error {
+	settings, power, err := Settings(cfg)
+	if err != nil {
+		return err
+	}
+	if err := r.Strobe(SRES); err != nil {
+		return err
+	}
+	time.Sleep(time.Millisecond)
+	partnum, err := r.ReadStatus(PARTNUM)
+	if err != nil {
+		return err
+	}
+	version, err := r.ReadStatus(VERSION)
+	if err != nil {
+		return err
+	}
+	if partnum != 0x00 || version == 0x00 || version == 0xFF {
+		return fmt.Errorf("no CC1101 found: PARTNUM=0x%02x, VERSION=0x%02x; check the wiring", partnum, version)
+	}
+	for _, s := range settings {
+		if err := r.WriteReg(s.Register, s.Value); err != nil {
+			return err
+		}
+	}
+	if err := r.WriteBurst(PATABLE, []byte{0x00, power}); err != nil {
+		return err
+	}
+	r.cfg = cfg
+	return nil
+}
+
+// StartTx starts transmitting. In Async mode, the carrier then follows GDO0
+// until Idle is called.
+func (r *Radio) StartTx() error {
+	return r.Strobe(STX)
+}
+
+// Idle stops transmitting, and flushes the transmit FIFO.
+func (r *Radio) Idle() error {
+	if err := r.Strobe(SIDLE); err != nil {
+		return err
+	}
+	return r.Strobe(SFTX)
+}
+
+// txBytes reads the number of bytes in the transmit FIFO, and whether it has
+// underflowed. Per the errata, it reads until two reads agree.
+func (r *Radio) txBytes() (int, bool, error) {
+	last := -1
+	for {
+		v, err := r.ReadStatus(TXBYTES)
+		if err != nil {
+			return 0, false, err
+		}
+		if int(v) == last {
+			return int(v & 0x7F), v&txUnderflow != 0, nil
+		}
+		last = int(v)
+	}
+}
+
+// SendFIFO transmits a bitstream in FIFO mode, topping up the FIFO as it
+// drains, and waits for it to finish before going idle. If the context is
+// cancelled, it goes idle straight away, cutting the transmission short.
+func (r *Radio) SendFIFO(ctx context.Context, stream []byte) error {
+	if r.cfg.Mode != FIFO {
+		return fmt.Errorf("CC1101 is not configured for FIFO mode")
+	}
+	rate, err := DataRate(r.cfg.DataRate)
+	if err != nil {
+		return err
+	}
+	// Poll often enough to top the FIFO up before it's half empty.
+	poll := time.Duration(float64(FIFOSize/4*8) / rate * float64(time.Second))
+
+	if err := r.Idle(); err != nil {
+		return err
+	}
+	n := len(stream)
+	if n > FIFOSize {
+		n = FIFOSize
+	}
+	if err := r.WriteBurst(TXFIFO, stream[:n]); err != nil {
+		return err
+	}
+	stream = stream[n:]
+	if err := r.StartTx(); err != nil {
+		return err
+	}
+	defer r.Idle()
+
+	for {
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		queued, underflow, err := r.txBytes()
+		if err != nil {
+			return err
+		}
+		if len(stream) == 0 {
+			if queued == 0 || underflow {
+				return nil
+			}
+			continue
+		}
+		if underflow {
+			return fmt.Errorf("CC1101 transmit FIFO ran dry with %d bytes left to send", len(stream))
+		}
+		n := FIFOSize - queued
+		if n > len(stream) {
+			n = len(stream)
+		}
+		if n > 0 {
+			if err := r.WriteBurst(TXFIFO, stream[:n]); err != nil {
+				return err
+			}
+			stream = stream[n:]
+		}
+	}
+}
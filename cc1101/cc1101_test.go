@@ -0,0 +1,119 @@
+package cc1101_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/zellyn/openers/cc1101"
+	"github.com/zellyn/openers/cc1101/cc1101test"
+)
+
+func TestConfigure(t *testing.T) {
+	testcases := []struct {
+		cfg     cc1101.Config
+		freq    [3]byte
+		power   byte
+		regs    map[byte]byte
+		wantErr bool
+	}{
+		{
+			cfg:   cc1101.Config{Frequency: 310e6, Power: 10, Mode: cc1101.FIFO, DataRate: 4000},
+			freq:  [3]byte{0x0B, 0xEC, 0x4F},
+			power: 0xC2,
+			regs:  map[byte]byte{cc1101.PKTCTRL0: 0x02, cc1101.MDMCFG2: 0x30, cc1101.MDMCFG4: 0x87, cc1101.MDMCFG3: 0x43, cc1101.FREND0: 0x11},
+		},
+		{
+			cfg:   cc1101.Config{Frequency: 315e6, Power: 0, Mode: cc1101.Async},
+			freq:  [3]byte{0x0C, 0x1D, 0x8A},
+			power: 0x51,
+			regs:  map[byte]byte{cc1101.PKTCTRL0: 0x32, cc1101.IOCFG0: 0x0D},
+		},
+		{
+			cfg:   cc1101.Config{Frequency: 390e6, Power: 7, Mode: cc1101.FIFO, DataRate: 1000},
+			freq:  [3]byte{0x0F, 0x00, 0x00},
+			power: 0xC8,
+			regs:  map[byte]byte{cc1101.MDMCFG4: 0x85, cc1101.MDMCFG3: 0x43},
+		},
+		{cfg: cc1101.Config{Frequency: 370e6, Power: 10}, wantErr: true},
+		{cfg: cc1101.Config{Frequency: 310e6, Power: 3}, wantErr: true},
+		{cfg: cc1101.Config{Frequency: 310e6, Power: 10, Mode: cc1101.FIFO, DataRate: 100}, wantErr: true},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%gMHz", i, tt.cfg.Frequency/1e6), func(t *testing.T) {
+			chip := cc1101test.New()
+			r := &cc1101.Radio{Conn: chip}
+			err := r.Configure(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error configuring %+v", tt.cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [3]byte{chip.Register(cc1101.FREQ2), chip.Register(cc1101.FREQ1), chip.Register(cc1101.FREQ0)}
+			if got != tt.freq {
+				t.Errorf("want FREQ registers % x; got % x", tt.freq, got)
+			}
+			if p := chip.PATABLE(); !bytes.Equal(p, []byte{0, tt.power}) {
+				t.Errorf("want PATABLE [00 %02x]; got % x", tt.power, p)
+			}
+			for reg, want := range tt.regs {
+				if got := chip.Register(reg); got != want {
+					t.Errorf("want register 0x%02x==0x%02x; got 0x%02x", reg, want, got)
+				}
+			}
+			if s := chip.Strobes(); len(s) == 0 || s[0] != cc1101.SRES {
+				t.Errorf("want chip reset first; got strobes % x", s)
+			}
+		})
+	}
+}
+
+func TestNoChip(t *testing.T) {
+	chip := cc1101test.New()
+	chip.Version = 0
+	r := &cc1101.Radio{Conn: chip}
+	if err := r.Configure(cc1101.Config{Frequency: 310e6, Power: 10, Mode: cc1101.Async}); err == nil {
+		t.Errorf("want error configuring a missing chip")
+	}
+}
+
+func TestDataRate(t *testing.T) {
+	for _, want := range []float64{1000, 4000, 600, 100e3} {
+		got, err := cc1101.DataRate(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want)/want > 0.005 {
+			t.Errorf("want DataRate(%g) within 0.5%%; got %g", want, got)
+		}
+	}
+}
+
+func TestSendFIFO(t *testing.T) {
+	chip := cc1101test.New()
+	r := &cc1101.Radio{Conn: chip}
+	if err := r.Configure(cc1101.Config{Frequency: 310e6, Power: 10, Mode: cc1101.FIFO, DataRate: 100e3}); err != nil {
+		t.Fatal(err)
+	}
+	stream := make([]byte, 300)
+	for i := range stream {
+		stream[i] = byte(i)
+	}
+	if err := r.SendFIFO(context.Background(), stream); err != nil {
+		t.Fatal(err)
+	}
+	if got := chip.Sent(); !bytes.Equal(got, stream) {
+		t.Errorf("want whole stream sent through the FIFO; got %d bytes", len(got))
+	}
+	strobes := chip.Strobes()
+	if n := len(strobes); n < 2 || strobes[n-2] != cc1101.SIDLE || strobes[n-1] != cc1101.SFTX {
+		t.Errorf("want radio idle after sending; got strobes % x", strobes)
+	}
+}
@@ -0,0 +1,12 @@
+/*
+Package cc1101 drives a TI CC1101 sub-GHz transceiver over SPI, configured
+to transmit OOK (on-off keying) at the frequencies used by gate and garage
+openers, such as 310, 315 and 390MHz.
+
+The chip can send a transmission in one of two ways. In FIFO mode, the whole
+transmission, gaps included, is packed into a bitstream at one bit per pulse,
+and fed through the chip's 64-byte transmit FIFO. In asynchronous serial
+mode, the chip keys its carrier directly from the level of its GDO0 pin,
+which some other transmitter drives.
+*/
+package cc1101
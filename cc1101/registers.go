@@ -0,0 +1,67 @@
+package cc1101
+
+// Configuration registers.
+const (
+	IOCFG2   = 0x00
+	IOCFG1   = 0x01
+	IOCFG0   = 0x02
+	FIFOTHR  = 0x03
+	PKTLEN   = 0x06
+	PKTCTRL1 = 0x07
+	PKTCTRL0 = 0x08
+	FSCTRL1  = 0x0B
+	FSCTRL0  = 0x0C
+	FREQ2    = 0x0D
+	FREQ1    = 0x0E
+	FREQ0    = 0x0F
+	MDMCFG4  = 0x10
+	MDMCFG3  = 0x11
+	MDMCFG2  = 0x12
+	MDMCFG1  = 0x13
+	MDMCFG0  = 0x14
+	MCSM0    = 0x18
+	FREND0   = 0x22
+	FSCAL3   = 0x23
+	FSCAL2   = 0x24
+	FSCAL1   = 0x25
+	FSCAL0   = 0x26
+	TEST2    = 0x2C
+	TEST1    = 0x2D
+	TEST0    = 0x2E
+)
+
+// Command strobes.
+const (
+	SRES  = 0x30 // Reset the chip.
+	SCAL  = 0x33 // Calibrate the frequency synthesizer.
+	STX   = 0x35 // Start transmitting.
+	SIDLE = 0x36 // Stop, and go idle.
+	SFTX  = 0x3B // Flush the transmit FIFO.
+)
+
+// Status registers, read with the burst bit set.
+const (
+	PARTNUM   = 0x30
+	VERSION   = 0x31
+	MARCSTATE = 0x35
+	TXBYTES   = 0x3A
+)
+
+// Multi-byte registers.
+const (
+	PATABLE = 0x3E
+	TXFIFO  = 0x3F
+)
+
+// Header byte flags.
+const (
+	ReadFlag  = 0x80
+	BurstFlag = 0x40
+)
+
+// FIFOSize is the size of the transmit FIFO.
+const FIFOSize = 64
+
+// txUnderflow is the TXBYTES flag set when the FIFO ran dry while
+// transmitting.
+const txUnderflow = 0x80
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+)
+
+// AuditFlags holds the flags for the audit log of transmissions.
+type AuditFlags struct {
+	AuditLog   string `kong:"default='/var/lib/openers/audit.jsonl',type='path',placeholder='file',help='Append-only log recording every transmission. Empty to keep none.'"`
+	AuditChain bool   `kong:"negatable,default='true',help='Hash-chain audit log entries, so that tampering can be detected with the audit command.'"`
+	AuditKey   string `kong:"default='/var/lib/openers/audit.key',type='path',placeholder='file',help='Secret key that fixed codes are fingerprinted with in the audit log. Created, readable only by its owner, if missing.'"`
+}
+
+// key loads the fingerprint key. If it can't, fixed codes are left out of
+// the audit log.
+func (f *AuditFlags) key() audit.Key {
+	if f.AuditKey == "" {
+		return nil
+	}
+	k, err := audit.LoadKey(f.AuditKey)
+	if err != nil {
+		fmt.Printf("WARNING: cannot load audit key, not fingerprinting fixed codes: %v\n", err)
+	}
+	return k
+}
+
+// record appends a finished transmission to the audit log, if there is one.
+// Failing to record it doesn't fail the transmission, which has already
+// happened.
+func (f *AuditFlags) record(e *audit.Entry, err error) {
+	if f.AuditLog == "" {
+		return
+	}
+	e.User = invokingUser()
+	e.Result = audit.ResultOK
+	if err != nil {
+		e.Result = audit.ResultFailed
+		e.Error = err.Error()
+	}
+	l := &audit.Log{Path: f.AuditLog, Chain: f.AuditChain}
+	if err := l.Append(e); err != nil {
+		fmt.Printf("WARNING: cannot write audit log: %v\n", err)
+	}
+}
+
+// invokingUser returns the user running the command, seeing through sudo.
+func invokingUser() string {
+	if u := os.Getenv("SUDO_USER"); u != "" && os.Geteuid() == 0 {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(os.Getuid())
+}
+
+// AuditCmd is the kong `audit` command.
+type AuditCmd struct {
+	AuditLog string        `kong:"default='/var/lib/openers/audit.jsonl',type='path',placeholder='file',help='Audit log to read.'"`
+	Opener   string        `kong:"placeholder='name',help='Only show transmissions to this opener.'"`
+	Who      string        `kong:"placeholder='text',help='Only show transmissions whose user or source contains this.'"`
+	Since    time.Duration `kong:"help='Only show transmissions in this long before now.'"`
+	Limit    int           `kong:"default='50',help='Show at most this many of the latest matching transmissions. 0 shows all of them.'"`
+	JSON     bool          `kong:"name='json',help='Print matching entries as JSON lines, as they are in the log.'"`
+	Verify   bool          `kong:"help='Check the hash chain of the whole log instead, printing the hash of the last entry.'"`
+}
+
+// Help displays extended help and examples.
+func (a AuditCmd) Help() string {
+	return `Shows who transmitted to which opener, and when, from the audit log, or
+checks that the log hasn't been tampered with.
+
+The chain only shows that entries haven't been changed or removed before the
+last one; to detect the end of the log being cut off, keep a copy of the last
+hash somewhere else, and check that it is still in the log.
+
+Examples:
+	# What happened to the gate in the last day?
+	openers audit --opener=gate --since=24h
+
+	# Check the chain.
+	openers audit --verify`
+}
+
+// Run the `audit` command.
+func (a *AuditCmd) Run(globals *Globals) error {
+	if a.Verify {
+		n, last, err := audit.Verify(a.AuditLog)
+		if err != nil {
+			return fmt.Errorf("audit log %s is not intact: %v", a.AuditLog, err)
+		}
+		fmt.Printf("%d entries; chain intact\n", n)
+		if n > 0 {
+			fmt.Printf("Last hash: %s\n", last)
+		}
+		return nil
+	}
+
+	entries, err := audit.Read(a.AuditLog)
+	if err != nil {
+		return err
+	}
+	f := &audit.Filter{Opener: a.Opener, Who: a.Who}
+	if a.Since > 0 {
+		f.Since = time.Now().Add(-a.Since)
+	}
+	var matched []audit.Entry
+	for i := range entries {
+		if f.Match(&entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+	if a.Limit > 0 && len(matched) > a.Limit {
+		matched = matched[len(matched)-a.Limit:]
+	}
+	for _, e := range matched {
+		if a.JSON {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			continue
+		}
+		fmt.Println(formatEntry(&e))
+	}
+	return nil
+}
+
+// formatEntry describes an audit log entry on one line.
+func formatEntry(e *audit.Entry) string {
+	what := e.Protocol
+	if e.Opener != "" {
+		what = e.Opener
+		if e.Action != "" {
+			what += " " + e.Action
+		}
+	}
+	s := fmt.Sprintf("%5d %s %-30s %s", e.Seq, e.Time.Local().Format("2006-01-02 15:04:05"), e.Who(), what)
+	if e.Fixed != "" {
+		s += " fixed:" + e.Fixed
+	}
+	if e.Rolling != nil {
+		s += fmt.Sprintf(" rolling:%d", *e.Rolling)
+	}
+	s += " via " + e.Backend
+	if e.Attempts > 1 || e.MaxDeviation > 0 {
+		s += fmt.Sprintf(" (%d attempts, max deviation %v)", e.Attempts, e.MaxDeviation)
+	}
+	s += ": " + e.Result
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+	return s
+}
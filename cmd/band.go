@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zellyn/openers/transmit"
+)
+
+// BandFlags holds the flags describing the band select lines of a multi-band
+// transmitter module.
+type BandFlags struct {
+	BandPins []int    `kong:"sep=',',placeholder='pin#,...',help='GPIO pins selecting the band of a multi-band transmitter module.'"`
+	Bands    []string `kong:"sep=',',placeholder='310M=0,...',help='Band select value for each frequency: bit n of the value drives the nth of --band-pins.'"`
+}
+
+// openBandSelect requests the band select lines on the named GPIO chip. The
+// returned function releases them.
+func (b *BandFlags) openBandSelect(chipName string) (transmit.Tuner, func(), error) {
+	bands := map[int64]int{}
+	for _, band := range b.Bands {
+		parts := strings.SplitN(band, "=", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("expected --bands entries like 310M=0; got %q", band)
+		}
+		frequency, err := ParseSI(parts[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := strconv.ParseUint(parts[1], 0, len(b.BandPins))
+		if err != nil {
+			return nil, nil, fmt.Errorf("band select value %q doesn't fit in %d pins", parts[1], len(b.BandPins))
+		}
+		bands[int64(math.Round(frequency))] = int(value)
+	}
+
+	bs := &transmit.BandSelect{Bands: bands}
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, pin := range b.BandPins {
+		line, r, err := openOutput(chipName, pin)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		bs.Lines = append(bs.Lines, line)
+		releases = append(releases, r)
+	}
+	return bs, release, nil
+}
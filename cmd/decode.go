@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/iq"
+	"github.com/zellyn/openers/sigrok"
+	"github.com/zellyn/openers/vcd"
+	"github.com/zellyn/openers/wav"
+	"github.com/zellyn/openers/waveform"
+)
+
+// DecodeCmd is the kong `decode` command.
+type DecodeCmd struct {
+	IQ     string `kong:"type='existingfile',xor='input',placeholder='file',help='IQ recording to decode, eg. from rtl_sdr.'"`
+	VCD    string `kong:"name='vcd',type='existingfile',xor='input',placeholder='file',help='Logic analyzer capture to decode, in Value Change Dump format.'"`
+	Sigrok string `kong:"type='existingfile',xor='input',placeholder='file',help='Logic analyzer capture to decode, as a sigrok session (.sr) file.'"`
+	WAV    string `kong:"name='wav',type='existingfile',xor='input',placeholder='file',help='Audio recording of the demodulated output of a receiver module to decode.'"`
+
+	Rate   float64 `kong:"default='2.048M',type='si',placeholder='samples/sec',help='Sample rate of the IQ recording. Accepts k, M and G suffixes.'"`
+	Format string  `kong:"default='auto',enum='auto,cu8,cs16,cf32',help='Sample format of the IQ recording. By default, it is guessed from the file extension.'"`
+	Signal string  `kong:"placeholder='name',help='Signal (channel) to decode from a logic analyzer capture. By default, the first one.'"`
+
+	Channel int  `kong:"default='1',help='Channel of the audio recording to decode, counting from 1.'"`
+	Invert  bool `kong:"help='The audio recording is low while the carrier is on.'"`
+}
+
+// Help displays extended help and examples.
+func (d DecodeCmd) Help() string {
+	return `Examples:
+	# Record two seconds from an RTL-SDR dongle at 310MHz, and decode it.
+	rtl_sdr -f 310M -s 2.4M -n 4.8M capture.cu8
+	openers decode --iq capture.cu8 --rate 2.4M
+	# Decode what a logic analyzer saw on the transmit pin.
+	openers decode --sigrok capture.sr --signal D2
+	# Decode an archived sound card recording of a receiver module.
+	openers decode --wav receiver.wav --channel 2`
+}
+
+// Run the `decode` command.
+func (d *DecodeCmd) Run(globals *Globals) error {
+	var pulses []waveform.Pulse
+	var err error
+	switch {
+	case d.IQ != "":
+		pulses, err = d.readIQ()
+	case d.VCD != "":
+		pulses, err = d.readVCD()
+	case d.Sigrok != "":
+		pulses, err = d.readSigrok()
+	case d.WAV != "":
+		pulses, err = d.readWAV()
+	default:
+		return fmt.Errorf("please specify a recording to decode with --iq, --vcd, --sigrok or --wav")
+	}
+	if err != nil {
+		return err
+	}
+	if globals.Debug > 0 {
+		fmt.Printf("Found %d pulses lasting %v\n", len(pulses), waveform.Duration(pulses))
+	}
+
+	messages := demod.Decode(pulses)
+	for _, m := range messages {
+		fmt.Printf("%12s  %s\n", m.Start, m)
+	}
+	fmt.Printf("Decoded %d transmissions\n", len(messages))
+	return nil
+}
+
+// readIQ reads pulses from an IQ recording, guessing its format from the file
+// extension if necessary.
+func (d *DecodeCmd) readIQ() ([]waveform.Pulse, error) {
+	var format iq.Format
+	var err error
+	if d.Format != "auto" {
+		format, err = iq.ParseFormat(d.Format)
+	} else if format, err = iq.FormatFromFilename(d.IQ); err != nil {
+		err = fmt.Errorf("%v; please specify --format", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(d.IQ)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return iq.Pulses(f, format, d.Rate)
+}
+
+// readVCD reads pulses from a Value Change Dump file.
+func (d *DecodeCmd) readVCD() ([]waveform.Pulse, error) {
+	f, err := os.Open(d.VCD)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return vcd.Read(f, d.Signal)
+}
+
+// readSigrok reads pulses from a sigrok session file.
+func (d *DecodeCmd) readSigrok() ([]waveform.Pulse, error) {
+	f, err := os.Open(d.Sigrok)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return sigrok.Read(f, fi.Size(), d.Signal)
+}
+
+// readWAV reads pulses from an audio recording.
+func (d *DecodeCmd) readWAV() ([]waveform.Pulse, error) {
+	f, err := os.Open(d.WAV)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return wav.Pulses(f, d.Channel-1, d.Invert)
+}
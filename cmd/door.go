@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/gpiod"
+)
+
+// traceWriter records a door's switch events as a trace.
+type traceWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Duration
+}
+
+func newTraceWriter(w io.Writer) *traceWriter {
+	return &traceWriter{w: w, start: door.Now()}
+}
+
+func (t *traceWriter) initial(sw door.Switch, active bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintln(t.w, door.Initial(sw, active))
+}
+
+func (t *traceWriter) event(e door.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.Time -= t.start
+	fmt.Fprintln(t.w, e)
+}
+
+// openDoor requests the GPIO inputs of a door's limit switches, returning
+// the model of the door they drive, set to their current levels. If trace
+// isn't nil, the levels and events are recorded in it. The returned function
+// releases the lines and the chip.
+func openDoor(s *config.Sensor, trace *traceWriter) (*door.Door, func(), error) {
+	d, err := s.Door()
+	if err != nil {
+		return nil, nil, err
+	}
+	chip, err := gpiod.NewChip(s.ChipName())
+	if err != nil {
+		return nil, nil, err
+	}
+	var lines []interface{ Close() error }
+	release := func() {
+		for _, l := range lines {
+			l.Close()
+		}
+		chip.Close()
+	}
+	for sw, pin := range s.Pins() {
+		sw := sw
+		handler := func(evt gpiod.LineEvent) {
+			e := door.LineEvent(sw, s.ActiveHigh, evt)
+			d.Handle(e)
+			if trace != nil {
+				trace.event(e)
+			}
+		}
+		line, err := chip.RequestLine(pin, gpiod.AsInput, door.Bias(s.ActiveHigh), gpiod.WithBothEdges, gpiod.WithEventHandler(handler))
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("cannot request %s switch pin %d: %v", sw, pin, err)
+		}
+		lines = append(lines, line)
+		v, err := line.Value()
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("cannot read %s switch pin %d: %v", sw, pin, err)
+		}
+		active := (v == 1) == s.ActiveHigh
+		d.Set(sw, active)
+		if trace != nil {
+			trace.initial(sw, active)
+		}
+	}
+	return d, release, nil
+}
+
+// watchDoors opens the sensors of the openers that have them, returning
+// their doors by opener name, for a server to open and close them. The
+// returned function releases them all.
+func watchDoors(c *config.Config) (map[string]*door.Door, func(), error) {
+	doors := map[string]*door.Door{}
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for i := range c.Openers {
+		o := &c.Openers[i]
+		if o.Sensor == nil {
+			continue
+		}
+		d, r, err := openDoor(o.Sensor, nil)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("cannot open sensor for %s: %v", o.Name, err)
+		}
+		releases = append(releases, r)
+		doors[o.Name] = d
+		exportDoor(o.Name, d)
+	}
+	return doors, release, nil
+}
+
+// doorSensor reports whether a door is closed, from its model.
+type doorSensor struct {
+	door *door.Door
+}
+
+// Closed reports whether the door is closed.
+func (s doorSensor) Closed() (bool, error) {
+	return s.door.State(door.Now()) == door.Closed, nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zellyn/openers/sigrok"
+	"github.com/zellyn/openers/vcd"
+	"github.com/zellyn/openers/wav"
+	"github.com/zellyn/openers/waveform"
+)
+
+// ExportFlags holds the flags shared by the commands that export
+// transmissions to files.
+type ExportFlags struct {
+	Output     string  `kong:"required,short='o',placeholder='file',help='File to write the transmission to.'"`
+	Format     string  `kong:"default='auto',enum='auto,vcd,sr,wav',help='Output format: vcd (Value Change Dump), sr (sigrok session) or wav (audio). By default, it is guessed from the file extension.'"`
+	Samplerate float64 `kong:"type='si',placeholder='samples/sec',help='Sample rate for sampled formats. Accepts k, M and G suffixes. Defaults to 1M for sr, and 48k for wav.'"`
+	Signal     string  `kong:"default='tx',help='Name of the exported signal.'"`
+	Volume     float64 `kong:"default='1.0',help='Audio level while the carrier is on, from 0 to 1, for wav output.'"`
+}
+
+// export writes a transmission to the output file.
+func (e *ExportFlags) export(tx *waveform.Transmission) error {
+	format := e.Format
+	if format == "auto" {
+		format = strings.TrimPrefix(filepath.Ext(e.Output), ".")
+	}
+
+	f, err := os.Create(e.Output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pulses := tx.Pulses()
+	switch format {
+	case "vcd":
+		err = vcd.Write(f, pulses, e.Signal)
+	case "sr":
+		err = sigrok.Write(f, pulses, e.Signal, e.samplerate(1e6))
+	case "wav":
+		rate := int(e.samplerate(48000))
+		err = wav.Write(f, wav.Render(pulses, rate, float32(e.Volume)), rate)
+	default:
+		os.Remove(e.Output)
+		return fmt.Errorf("cannot guess output format of %q; please specify --format", e.Output)
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s transmission (%v) to %s\n", tx.Protocol, tx.Duration(), e.Output)
+	return nil
+}
+
+// samplerate returns the requested sample rate, or def if none was given.
+func (e *ExportFlags) samplerate(def float64) float64 {
+	if e.Samplerate == 0 {
+		return def
+	}
+	return e.Samplerate
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/waveform"
+)
+
+// siMultipliers maps SI suffixes to their multipliers.
+var siMultipliers = map[string]float64{
+	"k": 1e3,
+	"K": 1e3,
+	"M": 1e6,
+	"G": 1e9,
+}
+
+// ParseSI parses a number with an optional k, M or G suffix, such as "310M".
+func ParseSI(s string) (float64, error) {
+	multiplier := 1.0
+	number := s
+	for suffix, m := range siMultipliers {
+		if strings.HasSuffix(s, suffix) {
+			number = strings.TrimSuffix(s, suffix)
+			multiplier = m
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number with an optional k, M or G suffix but got %q", s)
+	}
+	return f * multiplier, nil
+}
+
+// FrequencyFlags holds the flags describing the frequency plan of a
+// transmission.
+type FrequencyFlags struct {
+	Frequencies []string `kong:"sep=',',placeholder='310M,315M,390M',help='Frequencies to cycle through. Needs a backend that can change frequency: cc1101, or a multi-band module with --band-pins.'"`
+	Hop         string   `kong:"default='repeat',enum='repeat,round',help='How to cycle through --frequencies: repeat (each repeat on the next frequency) or round (each repeat on every frequency in turn).'"`
+}
+
+// plan adds the frequency plan described by the flags to a transmission.
+func (f *FrequencyFlags) plan(tx *waveform.Transmission) error {
+	tx.Frequencies = nil
+	for _, s := range f.Frequencies {
+		frequency, err := ParseSI(s)
+		if err != nil {
+			return err
+		}
+		tx.Frequencies = append(tx.Frequencies, frequency)
+	}
+	hop, err := waveform.ParseHop(f.Hop)
+	if err != nil {
+		return err
+	}
+	tx.Hop = hop
+	return nil
+}
+
+// openerPlan adds an opener's frequency plan to a transmission, unless the
+// flags describe one.
+func (f *FrequencyFlags) openerPlan(tx *waveform.Transmission, o *config.Opener) error {
+	if len(f.Frequencies) > 0 {
+		return f.plan(tx)
+	}
+	var err error
+	tx.Frequencies, tx.Hop, err = o.Plan()
+	return err
+}
@@ -1,6 +1,27 @@
 package cmd
 
+import (
+	"context"
+	"fmt"
+
+	"github.com/zellyn/openers/config"
+)
+
 // Globals holds variables global to all commands.
 type Globals struct {
-	Debug int // Debugging level (number of -v's)
+	Debug  int    // Debugging level (number of -v's)
+	Config string // Path to the configuration file.
+
+	// Context is cancelled when the process is asked to stop, by SIGINT or
+	// SIGTERM.
+	Context context.Context
+}
+
+// loadConfig reads the configuration file.
+func (g *Globals) loadConfig() (*config.Config, error) {
+	c, err := config.Load(g.Config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read configuration: %v", err)
+	}
+	return c, nil
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/gpiod"
+	"github.com/zellyn/openers/transmit"
+)
+
+// GPIOFlags holds the flags for transmitting using a GPIO pin.
+type GPIOFlags struct {
+	Chip string `kong:"default='gpiochip0',help='Chip name (device in /dev/). Must be supported by github.com/warthog618/gpiod.'"`
+	Pin  int    `kong:"default='-1',placeholder='pin#',help='GPIO pin number, for --backend=gpio.'"`
+}
+
+// openOutput requests a pin on the named GPIO chip as an output, initially
+// low. The returned function drives the line low, and releases it and the
+// chip.
+func openOutput(chipName string, pin int) (transmit.Line, func(), error) {
+	if err := gpiod.IsChip(chipName); err != nil {
+		chips := gpiod.Chips()
+		if len(chips) == 0 {
+			return nil, nil, fmt.Errorf("%q is not an available chip: there are no chips available", chipName)
+		}
+		return nil, nil, fmt.Errorf("%q is not an available chip; please choose one of %s", chipName, strings.Join(chips, ","))
+	}
+
+	chip, err := gpiod.NewChip(chipName)
+	if err != nil {
+		return nil, nil, err
+	}
+	line, err := chip.RequestLine(pin, gpiod.AsOutput(0))
+	if err != nil {
+		chip.Close()
+		return nil, nil, err
+	}
+	return line, func() {
+		line.SetValue(0)
+		line.Close()
+		chip.Close()
+	}, nil
+}
+
+// openInput requests a pin on the named GPIO chip as an input, pulled down so
+// it idles low when nothing drives it, passing kernel-timestamped events for
+// both edges to handler. The returned function releases the line and the
+// chip.
+func openInput(chipName string, pin int, handler func(gpiod.LineEvent)) (func(), error) {
+	chip, err := gpiod.NewChip(chipName)
+	if err != nil {
+		return nil, err
+	}
+	line, err := chip.RequestLine(pin, gpiod.AsInput, gpiod.WithPullDown, gpiod.WithBothEdges, gpiod.WithEventHandler(handler))
+	if err != nil {
+		chip.Close()
+		return nil, err
+	}
+	return func() {
+		line.Close()
+		chip.Close()
+	}, nil
+}
+
+// gpioSensor is a door sensor on an input pin: a reed switch to ground that
+// closes when the door does, with the pin pulled up.
+type gpioSensor struct {
+	line *gpiod.Line
+}
+
+// Closed reports whether the switch is closed.
+func (s gpioSensor) Closed() (bool, error) {
+	v, err := s.line.Value()
+	return v == 0, err
+}
+
+// openSensor requests a pin on the named GPIO chip as a door sensor input.
+// The returned function releases the line and the chip.
+func openSensor(chipName string, pin int) (gpioSensor, func(), error) {
+	chip, err := gpiod.NewChip(chipName)
+	if err != nil {
+		return gpioSensor{}, nil, err
+	}
+	line, err := chip.RequestLine(pin, gpiod.AsInput, door.Bias(false))
+	if err != nil {
+		chip.Close()
+		return gpioSensor{}, nil, err
+	}
+	return gpioSensor{line}, func() {
+		line.Close()
+		chip.Close()
+	}, nil
+}
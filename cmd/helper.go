@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zellyn/openers/acl"
+	"github.com/zellyn/openers/helper"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/waveform"
+)
+
+// HelperCmd is the kong `helper` command.
+type HelperCmd struct {
+	TransmitFlags `kong:"embed"`
+	MetricsFlags  `kong:"embed"`
+
+	Socket string `kong:"default='/run/openers-helper/helper.sock',type='path',placeholder='file',help='Unix socket to listen on.'"`
+	ACL    string `kong:"name='acl',default='/etc/openers/acl.json',type='path',placeholder='file',help='Access list of who may trigger which openers, and when.'"`
+	State  string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+}
+
+// Help displays extended help and examples.
+func (h HelperCmd) Help() string {
+	return `Runs as root, holding the transmitter hardware, and triggers openers for
+unprivileged users of "openers trigger" who are allowed to by the access
+list. The access list is reread for every request, so changes made with
+"openers users" take effect at once.
+
+Examples:
+	# Run the helper, transmitting on pin 12.
+	sudo openers helper --pin=12
+	# Let alice open the garage between 7am and 10pm.
+	sudo openers users add alice --openers=garage --hours=07:00-22:00
+	# Then, as alice:
+	openers trigger garage`
+}
+
+// Run the `helper` command.
+func (h *HelperCmd) Run(globals *Globals) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, err := acl.Load(h.ACL); err != nil {
+		return fmt.Errorf("cannot read access list: %v", err)
+	}
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return h.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: h.State})
+	srv.Limits = h.limitStore()
+	srv.AuditKey = h.key()
+	doors, release, err := watchDoors(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+	srv.Doors = doors
+	ctx := globals.Context
+	if err := h.serveMetrics(ctx); err != nil {
+		return err
+	}
+	go srv.Run(ctx)
+
+	l, err := helper.Listen(h.Socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(h.Socket)
+	hs := &helper.Server{
+		Trigger: srv.Trigger,
+		Authorize: func(caller *acl.Caller, opener string) error {
+			a, err := acl.Load(h.ACL)
+			if err != nil {
+				return fmt.Errorf("cannot read access list: %v", err)
+			}
+			return a.Check(caller, opener, time.Now())
+		},
+		Log: os.Stdout,
+	}
+	fmt.Printf("Helping with %d openers on %s\n", len(c.Openers), h.Socket)
+	return hs.Serve(ctx, l)
+}
+
+// TriggerCmd is the kong `trigger` command.
+type TriggerCmd struct {
+	Opener string `kong:"arg,placeholder='name',help='Opener to trigger.'"`
+	Action string `kong:"default='toggle',enum='open,close,toggle',help='What to ask the opener to do: open, close or toggle.'"`
+	Socket string `kong:"default='/run/openers-helper/helper.sock',type='path',placeholder='file',help='Unix socket the helper listens on.'"`
+}
+
+// Help displays extended help and examples.
+func (t TriggerCmd) Help() string {
+	return `Asks the helper ("openers helper", running as root) to trigger an opener,
+without needing root or access to the transmitter hardware. The helper checks
+that you are allowed to.
+
+Examples:
+	openers trigger garage
+	openers trigger gate --action=open`
+}
+
+// Run the `trigger` command.
+func (t *TriggerCmd) Run(globals *Globals) error {
+	result, err := helper.Trigger(globals.Context, t.Socket, t.Opener, t.Action)
+	if err != nil {
+		return err
+	}
+	if result.State == server.StateFailed {
+		return fmt.Errorf("transmission failed: %s", result.Error)
+	}
+	fmt.Printf("Sent %s to %s\n", result.Action, result.Opener)
+	if globals.Debug > 0 && result.Rolling != nil {
+		fmt.Printf("Rolling code: %d\n", *result.Rolling)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/homekit"
+	"github.com/zellyn/openers/mdns"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/waveform"
+)
+
+// HomeKitCmd is the kong `homekit` command.
+type HomeKitCmd struct {
+	TransmitFlags `kong:"embed"`
+	MetricsFlags  `kong:"embed"`
+
+	Port       int           `kong:"default='51826',help='TCP port to serve HomeKit controllers on.'"`
+	Name       string        `kong:"default='openers',help='Name of the bridge, as shown in the Home app.'"`
+	SetupCode  string        `kong:"placeholder='XXX-XX-XXX',help='Setup code to pair with. If not given, a random one is chosen and kept in the state file.'"`
+	Pairing    string        `kong:"default='/var/lib/openers/homekit.json',type='path',placeholder='file',help='File keeping the bridge keys, setup code and paired controllers.'"`
+	TravelTime time.Duration `kong:"default='15s',help='How long doors take to open or close.'"`
+	Sensors    []string      `kong:"name='sensor',sep=',',placeholder='opener=pin',help='Door sensor for an opener: a reed switch from a GPIO pin on --chip to ground, closed when the door is. Overrides any sensor in the configuration file.'"`
+	State      string        `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+}
+
+// Help displays extended help and examples.
+func (h HomeKitCmd) Help() string {
+	return `Serves each opener in the configuration file to Apple's Home app as a garage
+door opener, advertising the bridge with Bonjour. Prints the setup code to
+enter in the Home app until it is paired.
+
+Without a door sensor, a door is assumed to be where it was last sent, once
+--travel-time has passed.
+
+Examples:
+	# Transmit on pin 12, with a reed switch on pin 17 for the garage.
+	openers homekit --pin=12 --sensor=garage=17`
+}
+
+// Run the `homekit` command.
+func (h *HomeKitCmd) Run(globals *Globals) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := homekit.LoadState(h.Pairing, h.SetupCode)
+	if err != nil {
+		return err
+	}
+	sensors := map[string]homekit.Sensor{}
+	for _, s := range h.Sensors {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return fmt.Errorf("--sensor %q: want opener=pin", s)
+		}
+		name := s[:eq]
+		pin, err := strconv.Atoi(s[eq+1:])
+		if err != nil {
+			return fmt.Errorf("--sensor %q: invalid pin", s)
+		}
+		if _, err := c.Opener(name); err != nil {
+			return err
+		}
+		sensor, release, err := openSensor(h.Chip, pin)
+		if err != nil {
+			return fmt.Errorf("cannot open sensor for %s: %v", name, err)
+		}
+		defer release()
+		sensors[name] = sensor
+	}
+	doors, release, err := watchDoors(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+	for name, d := range doors {
+		if sensors[name] == nil {
+			sensors[name] = doorSensor{d}
+		}
+	}
+
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return h.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: h.State})
+	srv.Limits = h.limitStore()
+	srv.AuditKey = h.key()
+	srv.Doors = doors
+	ctx := globals.Context
+	if err := h.serveMetrics(ctx); err != nil {
+		return err
+	}
+	go srv.Run(ctx)
+
+	b := homekit.New(c, srv.Trigger, state, h.Pairing)
+	b.Name = h.Name
+	b.Sensors = sensors
+	b.Travel = h.TravelTime
+	b.Log = os.Stdout
+
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	if dot := strings.IndexByte(host, '.'); dot >= 0 {
+		host = host[:dot]
+	}
+	b.Responder = mdns.NewResponder(b.Service(host, h.Port))
+	go func() {
+		if err := b.Responder.Run(ctx); err != nil {
+			fmt.Printf("Cannot advertise the bridge: %v\n", err)
+		}
+	}()
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", h.Port))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving %d openers to HomeKit on port %d\n", len(c.Openers), h.Port)
+	if !b.Paired() {
+		fmt.Printf("Not paired yet: add %q in the Home app with setup code %s\n", h.Name, b.SetupCode())
+	}
+	return b.Serve(ctx, l)
+}
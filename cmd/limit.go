@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// limitsLock is the name of the lock on the limits file, which processes
+// using different hardware share.
+const limitsLock = "limits"
+
+// LimitFlags holds the flags for limiting transmissions.
+type LimitFlags struct {
+	LimitState string `kong:"default='/var/lib/openers/limits.json',type='path',placeholder='file',help='File recording recent transmissions to each opener, to keep them within the limits in the configuration file, or the defaults. Empty to enforce none.'"`
+}
+
+// limitStore returns the store of recent transmissions, or nil if limits
+// aren't enforced.
+func (l *LimitFlags) limitStore() *limit.Store {
+	if l.LimitState == "" {
+		return nil
+	}
+	return &limit.Store{Path: l.LimitState}
+}
+
+// limitKey returns the name a transmission's limits are kept under: its
+// opener's, or for codes given on the command line, its protocol and fixed
+// code fingerprint.
+func limitKey(e *audit.Entry) string {
+	if e.Opener != "" {
+		return e.Opener
+	}
+	if e.Fixed == "" {
+		return e.Protocol
+	}
+	return fmt.Sprintf("%s %s", e.Protocol, e.Fixed)
+}
+
+// takeLimits records a transmission against the limits in the context, or
+// returns a *limit.LimitError if it would exceed them.
+func (l *LimitFlags) takeLimits(ctx context.Context, e *audit.Entry, tx *waveform.Transmission) error {
+	s := l.limitStore()
+	if s == nil {
+		return nil
+	}
+	return s.Take(limitKey(e), limit.FromContext(ctx), tx.OnAir())
+}
+
+// limitFailed starts the cool-down after a failed transmission.
+func (l *LimitFlags) limitFailed(e *audit.Entry) {
+	s := l.limitStore()
+	if s == nil {
+		return
+	}
+	if err := s.Failed(limitKey(e)); err != nil {
+		fmt.Printf("WARNING: cannot record failure for limits: %v\n", err)
+	}
+}
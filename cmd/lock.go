@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zellyn/openers/lock"
+)
+
+// LockFlags holds the flags for the locks that stop openers processes using
+// the same hardware at once.
+type LockFlags struct {
+	LockDir  string        `kong:"default='/run/openers',type='path',placeholder='dir',help='Directory for the lock files that give each process exclusive use of the hardware.'"`
+	LockWait time.Duration `kong:"default='30s',help='How long to wait for another openers process to finish with the hardware.'"`
+}
+
+// lock takes the named hardware locks, waiting for other processes to release
+// them. The returned function releases them.
+func (l *LockFlags) lock(ctx context.Context, names []string) (func(), error) {
+	set, err := lock.AcquireAll(ctx, l.LockDir, names, l.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	waited := set.Waited()
+	lockWaitSeconds.Observe(waited.Seconds())
+	if waited >= time.Second/10 {
+		fmt.Printf("Waited %v for another openers process to finish\n", waited.Round(time.Millisecond))
+	}
+	return set.Release, nil
+}
+
+// lockNames returns the names of the locks for the hardware the selected
+// backend uses, and for the limits file, if limits are enforced.
+func (f *TransmitFlags) lockNames() []string {
+	var names []string
+	pin := func(p int) {
+		if p >= 0 {
+			names = append(names, lock.Name(f.Chip, p))
+		}
+	}
+	switch f.Backend {
+	case "gpio", "pigpio":
+		pin(f.Pin)
+	case "spi":
+		names = append(names, lock.DeviceName(f.SPIDevice))
+	case "cc1101", "rfm69":
+		names = append(names, lock.DeviceName(f.SPIDevice))
+		pin(f.Pin)
+	case "bridge":
+		names = append(names, lock.DeviceName(f.SerialDevice))
+	}
+	for _, p := range f.BandPins {
+		pin(p)
+	}
+	if f.LimitState != "" {
+		names = append(names, limitsLock)
+	}
+	return names
+}
@@ -1,7 +1,33 @@
 package cmd
 
-// SecplusCmd is the kong `secplus` subcommand.
+import (
+	"fmt"
+	"time"
+
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/waveform"
+)
+
+// MegaCodeCmd is the kong `megacode` subcommand.
 type MegaCodeCmd struct {
 	Encode   EncodeCmd   `kong:"cmd,name='encode',help='Encode MegaCode data and display the results.'"`
 	Transmit TransmitCmd `kong:"cmd,name='transmit',help='Encode MegaCode data and transmit it using a GPIO pin.'"`
+	Export   ExportCmd   `kong:"cmd,name='export',help='Encode MegaCode data and export the transmission to a file.'"`
+}
+
+// MegaCodeFlags holds the flags describing a MegaCode transmission, shared by
+// the commands that send or export one.
+type MegaCodeFlags struct {
+	Pulsewidth time.Duration `kong:"default='1ms',help='Duration of a single pulse (1/6 of a bit packet).'"`
+	Repeats    int           `kong:"default='4',help='Number of times to send the whole message.'"`
+
+	Identifier *uint32 `kong:"type='anybaseuint32',placeholder='24-bit-integer',help='Opener identifier. Required unless --opener is given.'"`
+}
+
+// transmission encodes the complete transmission described by the flags.
+func (f *MegaCodeFlags) transmission() (*waveform.Transmission, error) {
+	if f.Identifier == nil {
+		return nil, fmt.Errorf("please specify the identifier with --identifier")
+	}
+	return megacode.Transmission(*f.Identifier, f.Pulsewidth, f.Repeats)
 }
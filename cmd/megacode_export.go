@@ -0,0 +1,23 @@
+package cmd
+
+// ExportCmd is the kong `export` command.
+type ExportCmd struct {
+	ExportFlags   `kong:"embed"`
+	MegaCodeFlags `kong:"embed"`
+}
+
+// Help displays extended help and examples.
+func (e ExportCmd) Help() string {
+	return `Examples:
+	# Export a transmission for comparison with a logic analyzer capture in PulseView.
+	openers megacode export --identifier=0x876543 -o ideal.sr`
+}
+
+// Run the `export` command.
+func (e *ExportCmd) Run(globals *Globals) error {
+	tx, err := e.transmission()
+	if err != nil {
+		return err
+	}
+	return e.export(tx)
+}
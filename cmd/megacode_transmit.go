@@ -1,24 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"runtime"
-	"runtime/debug"
-	"strings"
-	"time"
+	"math/big"
 
-	"github.com/zellyn/openers/gpiod"
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/limit"
 	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/waveform"
 )
 
 // TransmitCmd is the kong `encodev2` command.
 type TransmitCmd struct {
-	Chip       string        `kong:"default='gpiochip0',help='Chip name (device in /dev/). Must be supported by github.com/warthog618/gpiod.'"`
-	Pulsewidth time.Duration `kong:"default='1ms',help='Duration of a single pulse (1/6 of a bit packet).'"`
-	Repeats    int           `kong:"default='4',help='Number of times to send the whole message.'"`
+	TransmitFlags  `kong:"embed"`
+	MegaCodeFlags  `kong:"embed"`
+	FrequencyFlags `kong:"embed"`
 
-	Pin        int    `kong:"required,placeholder='pin#',help='GPIO pin number.'"`
-	Identifier uint32 `kong:"required,type='anybaseuint32',placeholder='24-bit-integer',help='Opener identifier.'"`
+	Opener string `kong:"placeholder='name',help='Opener in the configuration file to take the identifier, frequency plan and limits from.'"`
 }
 
 // Help displays extended help and examples.
@@ -26,62 +25,62 @@ func (t TransmitCmd) Help() string {
 	return `Examples:
 	# TODO(zellyn)
 	# Encode an opener identifier.
-	openers megacode encode --identifier=0x876543`
+	openers megacode encode --identifier=0x876543
+	# An opener from the configuration file, within its limits.
+	openers megacode transmit --opener=gate --pin=12`
 }
 
 // Run the `encode` command.
 func (t *TransmitCmd) Run(globals *Globals) error {
-
-	if err := gpiod.IsChip(t.Chip); err != nil {
-		chips := gpiod.Chips()
-		if len(chips) == 0 {
-			return fmt.Errorf("%q is not an available chip: there are no chips available", t.Chip)
+	if t.Opener == "" {
+		tx, err := t.transmission()
+		if err != nil {
+			return err
+		}
+		if err := t.plan(tx); err != nil {
+			return err
 		}
-		return fmt.Errorf("%q is not an available chip; please choose one of %s", t.Chip, strings.Join(gpiod.Chips(), ","))
+		return t.send(globals.Context, tx)
 	}
 
-	databits, err := megacode.Encode(t.Identifier)
+	c, err := globals.loadConfig()
 	if err != nil {
 		return err
 	}
-	chip, err := gpiod.NewChip(t.Chip)
+	o, err := c.Opener(t.Opener)
 	if err != nil {
 		return err
 	}
-	defer chip.Close()
-
-	line, err := chip.RequestLine(t.Pin, gpiod.AsOutput(0))
-	if err != nil {
-		return err
+	if o.Protocol != megacode.Protocol {
+		return fmt.Errorf("opener %q uses %s, not %s", o.Name, o.Protocol, megacode.Protocol)
 	}
-	defer line.SetValue(0)
-	defer line.Close()
-
-	target := time.Now()
-	for i := 0; i < t.Repeats; i++ {
-		if i > 0 {
-			target.Add(t.Pulsewidth * 6)
-			for target.After(time.Now()) {
-			}
-		}
-
-		fmt.Printf("Sending repetition %d/%d\n", i+1, t.Repeats)
-
-		runtime.GC()
-		debug.SetGCPercent(-1)
-
-		for _, bit := range databits {
-			target = target.Add(t.Pulsewidth)
-			if err := line.SetValue(int(bit)); err != nil {
-				return err
-			}
-			for target.After(time.Now()) {
-			}
-		}
-		if err := line.SetValue(0); err != nil {
+	if t.Identifier == nil {
+		id, err := o.ID()
+		if err != nil {
 			return err
 		}
+		t.Identifier = &id
+	}
+	tx, err := t.transmission()
+	if err != nil {
+		return err
 	}
+	if err := t.openerPlan(tx, o); err != nil {
+		return err
+	}
+	limits, err := o.Limits.Limits()
+	if err != nil {
+		return err
+	}
+	return t.send(limit.NewContext(globals.Context, limits), tx)
+}
 
-	return nil
+// send transmits, recording the identifier sent in the audit log.
+func (t *TransmitCmd) send(ctx context.Context, tx *waveform.Transmission) error {
+	ctx = audit.NewContext(ctx, &audit.Entry{
+		Opener: t.Opener,
+		Fixed:  t.key().Fingerprint(megacode.Protocol, new(big.Int).SetUint64(uint64(*t.Identifier))),
+		Source: "cli",
+	})
+	return t.TransmitFlags.transmit(ctx, tx)
 }
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/metrics"
+)
+
+// registry holds the metrics of this process.
+var registry = metrics.NewRegistry()
+
+var (
+	transmissions = registry.Counter("openers_transmissions_total",
+		"Transmissions sent or attempted, by opener, protocol and result.", "opener", "protocol", "result")
+	failures = registry.Counter("openers_transmission_failures_total",
+		"Failed transmissions, by opener and reason: lock, limit, backend, transmit, jitter or cancelled.", "opener", "reason")
+	transmitSeconds = registry.Histogram("openers_transmit_duration_seconds",
+		"Time taken by each transmission, including its repeats and retries, once the hardware was locked.",
+		[]float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30}, "opener")
+	jitterSeconds = registry.Histogram("openers_jitter_seconds",
+		"Deviation of each pin change from its target time, early or late, when the backend measures it.",
+		[]float64{1e-6, 2e-6, 5e-6, 10e-6, 20e-6, 50e-6, 100e-6, 200e-6, 500e-6, 1e-3}, "opener")
+	lastJitter = registry.Gauge("openers_last_jitter_seconds",
+		"Percentiles of the deviation of pin changes from their target times in the last transmission.", "opener", "quantile")
+	rollingCode = registry.Gauge("openers_rolling_code",
+		"Last rolling code sent to each opener.", "opener")
+	lockWaitSeconds = registry.Histogram("openers_lock_wait_seconds",
+		"Time spent waiting for other openers processes to finish with the hardware.",
+		[]float64{0.001, 0.01, 0.1, 1, 5, 10, 30, 60})
+	doorState = registry.Gauge("openers_door_state",
+		"Whether each door is in each state, from its sensor: 1 for the state it is in, 0 otherwise.", "opener", "state")
+)
+
+// Reasons transmissions fail, for the failures metric.
+const (
+	reasonLock      = "lock"
+	reasonLimit     = "limit"
+	reasonBackend   = "backend"
+	reasonTransmit  = "transmit"
+	reasonJitter    = "jitter"
+	reasonCancelled = "cancelled"
+)
+
+// jitterQuantiles are the percentiles of the last transmission's timing
+// that are exported.
+var jitterQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
+// MetricsFlags holds the flags for exporting metrics from long-running
+// commands.
+type MetricsFlags struct {
+	MetricsListen string `kong:"placeholder='host:port',help='Serve Prometheus metrics at /metrics on this address.'"`
+}
+
+// serveMetrics serves the metrics on --metrics-listen, if given, until the
+// context is cancelled.
+func (m *MetricsFlags) serveMetrics(ctx context.Context) error {
+	if m.MetricsListen == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", m.MetricsListen)
+	if err != nil {
+		return fmt.Errorf("cannot serve metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	s := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+	go s.Serve(l)
+	fmt.Printf("Serving metrics on http://%s/metrics\n", l.Addr())
+	return nil
+}
+
+// textfiles are the metrics textfiles written by this process, by path.
+var textfiles = struct {
+	sync.Mutex
+	files map[string]*metrics.Textfile
+}{files: map[string]*metrics.Textfile{}}
+
+// writeTextfile writes the metrics to a textfile for node_exporter. Failing
+// to write it doesn't fail the transmission, which has already happened.
+func writeTextfile(path string) {
+	textfiles.Lock()
+	defer textfiles.Unlock()
+	t := textfiles.files[path]
+	if t == nil {
+		t = &metrics.Textfile{Path: path}
+		textfiles.files[path] = t
+	}
+	if err := t.Write(registry); err != nil {
+		fmt.Printf("WARNING: cannot write metrics: %v\n", err)
+	}
+}
+
+// jitterError is returned when every attempt at a transmission exceeded
+// --max-jitter.
+type jitterError struct {
+	max, limit time.Duration
+	attempts   int
+}
+
+func (e *jitterError) Error() string {
+	return fmt.Sprintf("transmission failed: maximum deviation %v exceeded --max-jitter=%v on all %d attempts", e.max, e.limit, e.attempts)
+}
+
+// failureReason returns why a transmission failed, given the stage it got
+// to.
+func failureReason(ctx context.Context, err error, stage string) string {
+	var je *jitterError
+	switch {
+	case errors.As(err, &je):
+		return reasonJitter
+	case ctx.Err() != nil:
+		return reasonCancelled
+	}
+	return stage
+}
+
+// observeTransmission counts a finished transmission in the metrics, and
+// writes them to --metrics-textfile, if given. A transmission that failed
+// at stage, one of the reasons, took d once the hardware was locked.
+func (f *TransmitFlags) observeTransmission(ctx context.Context, e *audit.Entry, err error, stage string, d time.Duration) {
+	result := audit.ResultOK
+	if err != nil {
+		result = audit.ResultFailed
+		failures.Inc(e.Opener, failureReason(ctx, err, stage))
+	}
+	transmissions.Inc(e.Opener, e.Protocol, result)
+	if stage != reasonLock && stage != reasonLimit {
+		transmitSeconds.Observe(d.Seconds(), e.Opener)
+	}
+	if e.Rolling != nil {
+		rollingCode.Set(float64(*e.Rolling), e.Opener)
+	}
+	if f.MetricsTextfile != "" {
+		writeTextfile(f.MetricsTextfile)
+	}
+}
+
+// observeJitter adds the timing of an attempt at a transmission to the
+// metrics.
+func observeJitter(opener string, deviations []time.Duration) {
+	if len(deviations) == 0 {
+		return
+	}
+	abs := make([]time.Duration, len(deviations))
+	for i, d := range deviations {
+		if d < 0 {
+			d = -d
+		}
+		abs[i] = d
+		jitterSeconds.Observe(d.Seconds(), opener)
+	}
+	sort.Slice(abs, func(i, j int) bool { return abs[i] < abs[j] })
+	for _, q := range jitterQuantiles {
+		i := int(q * float64(len(abs)-1))
+		lastJitter.Set(abs[i].Seconds(), opener, strconv.FormatFloat(q, 'g', -1, 64))
+	}
+}
+
+// doorStates are the states exported for each door.
+var doorStates = []door.State{door.Unknown, door.Open, door.Closed, door.Opening, door.Closing, door.Stopped}
+
+// exportDoor adds the state of an opener's door to the metrics.
+func exportDoor(opener string, d *door.Door) {
+	registry.OnCollect(func() {
+		now := d.State(door.Now())
+		for _, st := range doorStates {
+			v := 0.0
+			if st == now {
+				v = 1
+			}
+			doorState.Set(v, opener, string(st))
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/waveform"
+)
+
+// MoveFlags holds the flags shared by the `open` and `close` commands.
+type MoveFlags struct {
+	TransmitFlags `kong:"embed"`
+
+	Opener  string `kong:"arg,placeholder='opener',help='Opener to move the door of. Its sensor must be described in the configuration file.'"`
+	Resends int    `kong:"default='2',help='Number of times to transmit again, with the next rolling code, if the door does not move or goes the wrong way.'"`
+	State   string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+}
+
+// move moves the opener's door to target, watching its sensor.
+func (m *MoveFlags) move(globals *Globals, target door.State) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	o, err := c.Opener(m.Opener)
+	if err != nil {
+		return err
+	}
+	if o.Sensor == nil {
+		return fmt.Errorf("opener %q has no sensor in the configuration file, so there is no telling where its door is", o.Name)
+	}
+	d, release, err := openDoor(o.Sensor, nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return m.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: m.State})
+	srv.Limits = m.limitStore()
+	srv.AuditKey = m.key()
+	srv.Doors = map[string]*door.Door{o.Name: d}
+	srv.Retries = m.Resends
+	srv.Log = os.Stdout
+	ctx := globals.Context
+	go srv.Run(ctx)
+
+	action := server.Open
+	if target == door.Closed {
+		action = server.Close
+	}
+	r, err := srv.Trigger(ctx, o.Name, action, server.SourceCLI)
+	if err == nil && r.State == server.StateFailed {
+		err = errors.New(r.Error)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", o.Name, err)
+	}
+	if r.Sent == 0 {
+		fmt.Printf("%s is already %s\n", o.Name, target)
+	} else {
+		fmt.Printf("%s is %s\n", o.Name, target)
+	}
+	return nil
+}
+
+// OpenCmd is the kong `open` command.
+type OpenCmd struct {
+	MoveFlags `kong:"embed"`
+}
+
+// Help displays extended help and examples.
+func (o OpenCmd) Help() string {
+	return `Opens a door, checking its sensor first, since openers only toggle: an
+open door is left alone. Waits for the door to open, transmitting again with
+the next rolling code if it doesn't move, and fails if it never opens.
+
+Examples:
+	openers open garage --pin=12`
+}
+
+// Run the `open` command.
+func (o *OpenCmd) Run(globals *Globals) error {
+	return o.move(globals, door.Open)
+}
+
+// CloseCmd is the kong `close` command.
+type CloseCmd struct {
+	MoveFlags `kong:"embed"`
+}
+
+// Help displays extended help and examples.
+func (c CloseCmd) Help() string {
+	return `Closes a door, checking its sensor first, since openers only toggle: a
+closed door is left alone. Waits for the door to close, transmitting again
+with the next rolling code if it doesn't move, and fails if it never closes.
+
+Examples:
+	openers close garage --pin=12`
+}
+
+// Run the `close` command.
+func (c *CloseCmd) Run(globals *Globals) error {
+	return c.move(globals, door.Closed)
+}
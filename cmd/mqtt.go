@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zellyn/openers/homeassistant"
+	"github.com/zellyn/openers/mqtt"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// maxReconnectDelay limits the wait between attempts to reach the broker.
+const maxReconnectDelay = time.Minute
+
+// MQTTCmd is the kong `mqtt` command.
+type MQTTCmd struct {
+	TransmitFlags `kong:"embed"`
+	MetricsFlags  `kong:"embed"`
+
+	Broker          string `kong:"default='localhost:1883',placeholder='host:port',help='MQTT broker to connect to.'"`
+	Username        string `kong:"help='Username for the broker.'"`
+	Password        string `kong:"env='OPENERS_MQTT_PASSWORD',help='Password for the broker. Best set through the environment.'"`
+	ClientID        string `kong:"name='client-id',default='openers',help='Client identifier to connect to the broker with.'"`
+	DiscoveryPrefix string `kong:"default='homeassistant',help='Topic prefix Home Assistant watches for discovery messages.'"`
+	BaseTopic       string `kong:"default='openers',help='Topic prefix for commands and state.'"`
+	State           string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+}
+
+// Help displays extended help and examples.
+func (m MQTTCmd) Help() string {
+	return `Connects to an MQTT broker, announces each opener in the configuration file
+to Home Assistant as a cover and a toggle button, and transmits the commands
+it sends, one at a time. Reconnects if the broker goes away.
+
+Examples:
+	# Transmit on pin 12, for the Home Assistant using the broker on "hass".
+	OPENERS_MQTT_PASSWORD=secret openers mqtt --broker=hass:1883 --username=openers --pin=12`
+}
+
+// Run the `mqtt` command.
+func (m *MQTTCmd) Run(globals *Globals) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return m.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: m.State})
+	srv.Limits = m.limitStore()
+	srv.AuditKey = m.key()
+	doors, release, err := watchDoors(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+	srv.Doors = doors
+	ctx := globals.Context
+	if err := m.serveMetrics(ctx); err != nil {
+		return err
+	}
+	go srv.Run(ctx)
+
+	b := &homeassistant.Bridge{
+		Config:          c,
+		Trigger:         srv.Trigger,
+		Doors:           doors,
+		DiscoveryPrefix: m.DiscoveryPrefix,
+		BaseTopic:       m.BaseTopic,
+		Log:             os.Stdout,
+	}
+	connect := &mqtt.Connect{
+		ClientID: m.ClientID,
+		Username: m.Username,
+		Password: m.Password,
+		Will:     b.Will(),
+	}
+	delay := time.Second
+	for {
+		client, err := mqtt.Dial(ctx, m.Broker, connect)
+		if err == nil {
+			fmt.Printf("Connected to %s; announcing %d openers\n", m.Broker, len(c.Openers))
+			delay = time.Second
+			err = b.Run(ctx, client)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, refused := err.(mqtt.ConnackError); refused {
+			return err
+		}
+		fmt.Printf("%v; retrying in %v\n", err, delay)
+		if transmit.Sleep(ctx, delay) != nil {
+			return nil
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import "github.com/zellyn/openers/pigpio"
+
+// PigpioFlags holds the flags for transmitting through pigpiod.
+type PigpioFlags struct {
+	PigpioAddr string `kong:"name='pigpio-addr',default='localhost:8888',placeholder='host:port',help='Address of the pigpiod to send waveforms to, for --backend=pigpio.'"`
+}
+
+// dialPigpio connects to pigpiod. The returned function closes the
+// connection.
+func (p *PigpioFlags) dialPigpio() (*pigpio.Client, func(), error) {
+	client, err := pigpio.Dial(p.PigpioAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() { client.Close() }, nil
+}
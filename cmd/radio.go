@@ -0,0 +1,30 @@
+package cmd
+
+import "github.com/zellyn/openers/rfm69"
+
+// RadioFlags holds the flags for transmitting with a CC1101 or RFM69 radio.
+type RadioFlags struct {
+	Frequency  float64 `kong:"default='310M',type='si',placeholder='Hz',help='Carrier frequency for --backend=cc1101 or rfm69, eg. 310M, 315M or 390M.'"`
+	Power      int     `kong:"default='10',placeholder='dBm',help='Output power for --backend=cc1101 (one of -30, -20, -15, -10, 0, 5, 7 or 10dBm) or rfm69 (-2 to 20dBm, or -18 to 13dBm with --rfm69-low-power).'"`
+	CC1101Mode string  `kong:"name='cc1101-mode',default='fifo',enum='fifo,async',help='How the CC1101 gets its data: fifo (packed into its FIFO over SPI) or async (keyed through GDO0, wired to --pin).'"`
+
+	RFM69LowPower bool `kong:"name='rfm69-low-power',help='The RFM69 is a low-power module (RFM69W or RFM69CW) rather than an RFM69HW or RFM69HCW.'"`
+}
+
+// openRFM69 opens the RFM69 radio on the SPI device, and configures it. The
+// returned function puts the radio to sleep, and closes the device.
+func openRFM69(spi *SPIFlags, cfg rfm69.Config) (*rfm69.Radio, func(), error) {
+	conn, release, err := spi.openSPI(rfm69.SPISpeed)
+	if err != nil {
+		return nil, nil, err
+	}
+	radio := &rfm69.Radio{Conn: conn}
+	if err := radio.Configure(cfg); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return radio, func() {
+		radio.SetMode(rfm69.Sleep)
+		release()
+	}, nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import "github.com/zellyn/openers/realtime"
+
+// RealtimeFlags holds the flags controlling the real-time environment of the
+// commands that transmit using a GPIO pin.
+type RealtimeFlags struct {
+	Realtime bool `kong:"help='Transmit with SCHED_FIFO priority and locked memory, instead of relying on chrt. Requires root, or CAP_SYS_NICE and CAP_IPC_LOCK.'"`
+	Priority int  `kong:"default='99',help='SCHED_FIFO priority (1-99) to use with --realtime.'"`
+	CPU      int  `kong:"name='cpu',default='-1',help='CPU to pin the transmit loop to with --realtime, ideally one isolated from the scheduler with isolcpus. -1 for none.'"`
+}
+
+// enterRealtime sets up the requested real-time environment for the calling
+// goroutine, returning a function that restores the previous environment. It
+// does nothing unless --realtime was given.
+func (r *RealtimeFlags) enterRealtime() (func(), error) {
+	if !r.Realtime {
+		return func() {}, nil
+	}
+	opts := realtime.Default
+	opts.Priority = r.Priority
+	opts.CPU = r.CPU
+	restore, err := realtime.Enter(opts)
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
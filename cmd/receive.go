@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zellyn/openers/capture"
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/lock"
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// ReceiveCmd is the kong `receive` command.
+type ReceiveCmd struct {
+	GPIOFlags `kong:"embed"`
+	SPIFlags  `kong:"embed"`
+	LockFlags `kong:"embed"`
+
+	Frequency     float64       `kong:"default='310M',type='si',placeholder='Hz',help='Frequency to listen on, eg. 310M, 315M or 390M.'"`
+	RFM69LowPower bool          `kong:"name='rfm69-low-power',help='The RFM69 is a low-power module (RFM69W or RFM69CW) rather than an RFM69HW or RFM69HCW.'"`
+	Duration      time.Duration `kong:"default='10s',help='How long to listen for.'"`
+}
+
+// Help displays extended help and examples.
+func (r ReceiveCmd) Help() string {
+	return `Listens with an RFM69 radio in continuous OOK mode, capturing the demodulated
+signal from its DIO2 pin (wired to --pin), and decodes any Security+2.0 and
+MegaCode transmissions heard.
+
+Examples:
+	# Listen at 315MHz for 30 seconds, with DIO2 wired to pin 24.
+	openers receive --frequency=315M --pin=24 --duration=30s`
+}
+
+// Run the `receive` command.
+func (r *ReceiveCmd) Run(globals *Globals) error {
+	if r.Pin < 0 {
+		return fmt.Errorf("please specify the GPIO pin wired to DIO2 with --pin")
+	}
+	unlock, err := r.lock(globals.Context, []string{lock.DeviceName(r.SPIDevice), lock.Name(r.Chip, r.Pin)})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	radio, release, err := openRFM69(&r.SPIFlags, rfm69.Config{
+		Frequency: r.Frequency,
+		Bitrate:   4000,
+		HighPower: !r.RFM69LowPower,
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	edges := &capture.Capture{}
+	releaseInput, err := openInput(r.Chip, r.Pin, edges.HandleEvent)
+	if err != nil {
+		return err
+	}
+	if err := radio.SetMode(rfm69.RX); err != nil {
+		releaseInput()
+		return err
+	}
+	fmt.Printf("Listening at %s for %v\n", waveform.FormatFrequency(r.Frequency), r.Duration)
+	if err := transmit.Sleep(globals.Context, r.Duration); err != nil {
+		fmt.Printf("Interrupted; decoding what was heard so far\n")
+	}
+	releaseInput()
+	if err := radio.SetMode(rfm69.Standby); err != nil {
+		return err
+	}
+
+	pulses := edges.Pulses()
+	if globals.Debug > 0 {
+		fmt.Printf("Captured %d pulses lasting %v\n", len(pulses), waveform.Duration(pulses))
+	}
+	messages := demod.Decode(pulses)
+	for _, m := range messages {
+		fmt.Printf("%12s  %s\n", m.Start, m)
+	}
+	fmt.Printf("Decoded %d transmissions\n", len(messages))
+	return nil
+}
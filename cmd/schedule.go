@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/schedule"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/waveform"
+)
+
+// ScheduleCmd is the kong `schedule` command.
+type ScheduleCmd struct {
+	TransmitFlags `kong:"embed"`
+	MetricsFlags  `kong:"embed"`
+
+	Resends int    `kong:"default='2',help='Number of times to transmit again, with the next rolling code, if a door with a sensor does not move or goes the wrong way.'"`
+	State   string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+}
+
+// Help displays extended help and examples.
+func (s ScheduleCmd) Help() string {
+	return `Follows the rules in the "schedule" of the configuration file until
+interrupted: sending an action at a time of day, or once a door has been open
+for a while.
+
+Doors with a sensor are opened or closed the way the open and close commands
+do it, checking where the door is first. Other openers can only be toggled,
+since their doors might already be where a rule would send them.
+
+Examples:
+	# Close the garage at 22:30, and whenever it is left open for 15 minutes,
+	# with rules like these in the configuration file:
+	#   "schedule": [
+	#     {"opener": "garage", "action": "close", "at": "22:30"},
+	#     {"opener": "garage", "action": "close", "open_for": "15m"}
+	#   ]
+	openers schedule --pin=12`
+}
+
+// liveDoor is a door's state at the current time.
+type liveDoor struct {
+	door *door.Door
+}
+
+func (l liveDoor) State() door.State {
+	return l.door.State(door.Now())
+}
+
+// Run the `schedule` command.
+func (s *ScheduleCmd) Run(globals *Globals) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(c.Schedule) == 0 {
+		return fmt.Errorf("no schedule in %s", globals.Config)
+	}
+
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return s.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: s.State})
+	srv.Limits = s.limitStore()
+	srv.AuditKey = s.key()
+	sensed, release, err := watchDoors(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+	srv.Doors = sensed
+	srv.Retries = s.Resends
+	srv.Log = os.Stdout
+	ctx := globals.Context
+	if err := s.serveMetrics(ctx); err != nil {
+		return err
+	}
+	go srv.Run(ctx)
+
+	doors := map[string]schedule.Door{}
+	for name, d := range sensed {
+		doors[name] = liveDoor{d}
+	}
+	act := func(ctx context.Context, opener, action string) error {
+		r, err := srv.Trigger(ctx, opener, action, server.SourceSchedule)
+		if err == nil && r.State == server.StateFailed {
+			err = errors.New(r.Error)
+		}
+		if err == nil && r.Sent == 0 {
+			target := door.Open
+			if action == server.Close {
+				target = door.Closed
+			}
+			fmt.Printf("%s is already %s\n", opener, target)
+		}
+		return err
+	}
+	sched, err := schedule.New(c, act)
+	if err != nil {
+		return err
+	}
+	sched.Doors = doors
+	sched.Log = os.Stdout
+	fmt.Printf("Following %d schedule rules\n", len(c.Schedule))
+	err = sched.Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -1,7 +1,54 @@
 package cmd
 
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
 // SecplusCmd is the kong `secplus` subcommand.
 type SecplusCmd struct {
 	EncodeV2   EncodeV2Cmd   `kong:"cmd,name='encodev2',help='Encode Security+2.0 data and display the results.'"`
 	TransmitV2 TransmitV2Cmd `kong:"cmd,name='transmitv2',help='Encode Security+2.0 data and transmit it using a GPIO pin.'"`
+	ExportV2   ExportV2Cmd   `kong:"cmd,name='exportv2',help='Encode Security+2.0 data and export the transmission to a file.'"`
+}
+
+// SecplusV2Flags holds the flags describing a Security+2.0 transmission,
+// shared by the commands that send or export one.
+type SecplusV2Flags struct {
+	Pulsewidth time.Duration `kong:"default='250µs',help='Duration of a single on/off pulse (half a Manchester-coded bit).'"`
+	Burstgap   time.Duration `kong:"default='90ms',help='Gap between first and second burst in a message.'"`
+	Repeatgap  time.Duration `kong:"default='90ms',help='Gap between repeats of the whole message.'"`
+	Repeats    int           `kong:"default='4',help='Number of times to send the whole message.'"`
+
+	Fixed   *big.Int `kong:"type='anybaseuint72',placeholder='72-bit-integer',help='Fixed part of opener code. Required unless --opener is given.'"`
+	Rolling *uint32  `kong:"type='anybaseuint32',placeholder='28-bit-integer',help='Rolling code. Required unless --opener is given, when the next one recorded for the opener is used.'"`
+}
+
+// transmission encodes the complete transmission described by the flags.
+func (f *SecplusV2Flags) transmission() (*waveform.Transmission, error) {
+	if f.Fixed == nil {
+		return nil, fmt.Errorf("please specify the fixed code with --fixed")
+	}
+	if f.Rolling == nil {
+		return nil, fmt.Errorf("please specify the rolling code with --rolling")
+	}
+	fixedHigh, fixedLow := splitFixed(f.Fixed)
+	return secplus.TransmissionV2(fixedHigh, fixedLow, *f.Rolling, secplus.TimingV2{
+		Pulsewidth: f.Pulsewidth,
+		Burstgap:   f.Burstgap,
+		Repeatgap:  f.Repeatgap,
+		Repeats:    f.Repeats,
+	})
+}
+
+// splitFixed splits a 72-bit fixed code into its high 8 and low 64 bits.
+func splitFixed(fixed *big.Int) (uint8, uint64) {
+	fixedBytes := make([]byte, 9)
+	fixed.FillBytes(fixedBytes)
+	return fixedBytes[0], binary.BigEndian.Uint64(fixedBytes[1:])
 }
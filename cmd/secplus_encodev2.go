@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/binary"
 	"fmt"
 	"math/big"
 
@@ -26,10 +25,7 @@ func (e EncodeV2Cmd) Help() string {
 
 // Run the `encode` command.
 func (e *EncodeV2Cmd) Run(globals *Globals) error {
-	fixedBytes := make([]byte, 9)
-	e.Fixed.FillBytes(fixedBytes)
-	fixedHigh := fixedBytes[0]
-	fixedLow := binary.BigEndian.Uint64(fixedBytes[1:])
+	fixedHigh, fixedLow := splitFixed(e.Fixed)
 
 	packets, err := secplus.EncodeV2(fixedHigh, fixedLow, e.Rolling)
 	if err != nil {
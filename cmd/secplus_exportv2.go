@@ -0,0 +1,25 @@
+package cmd
+
+// ExportV2Cmd is the kong `exportv2` command.
+type ExportV2Cmd struct {
+	ExportFlags    `kong:"embed"`
+	SecplusV2Flags `kong:"embed"`
+}
+
+// Help displays extended help and examples.
+func (e ExportV2Cmd) Help() string {
+	return `Examples:
+	# Export a transmission for comparison with a logic analyzer capture in PulseView.
+	openers secplus exportv2 --rolling=240124710 --fixed=70678577664 -o ideal.sr
+	# Export with the timing used when transmitting.
+	openers secplus exportv2 --rolling=240124710 --fixed=70678577664 --repeats=2 --burstgap=50ms -o ideal.vcd`
+}
+
+// Run the `exportv2` command.
+func (e *ExportV2Cmd) Run(globals *Globals) error {
+	tx, err := e.transmission()
+	if err != nil {
+		return err
+	}
+	return e.export(tx)
+}
@@ -1,30 +1,24 @@
 package cmd
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
-	"math/big"
-	"runtime"
-	"runtime/debug"
-	"strings"
-	"time"
 
-	"github.com/zellyn/openers/bits"
-	"github.com/zellyn/openers/gpiod"
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/rolling"
 	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
 )
 
 // TransmitV2Cmd is the kong `encodev2` command.
 type TransmitV2Cmd struct {
-	Chip       string        `kong:"default='gpiochip0',help='Chip name (device in /dev/). Must be supported by github.com/warthog618/gpiod.'"`
-	Pulsewidth time.Duration `kong:"default='250µs',help='Duration of a single on/off pulse (half a Manchester-coded bit).'"`
-	Burstgap   time.Duration `kong:"default='90ms',help='Gap between first and second burst in a message.'"`
-	Repeatgap  time.Duration `kong:"default='90ms',help='Gap between repeats of the whole message.'"`
-	Repeats    int           `kong:"default='4',help='Number of times to send the whole message.'"`
+	TransmitFlags  `kong:"embed"`
+	SecplusV2Flags `kong:"embed"`
+	FrequencyFlags `kong:"embed"`
 
-	Pin     int      `kong:"required,placeholder='pin#',help='GPIO pin number.'"`
-	Fixed   *big.Int `kong:"required,type='anybaseuint72',placeholder='72-bit-integer',help='Fixed part of opener code.'"`
-	Rolling uint32   `kong:"required,type='anybaseuint32',placeholder='28-bit-integer',help='Rolling code.'"`
+	Opener string `kong:"placeholder='name',help='Opener in the configuration file to take the fixed code and frequency plan from.'"`
+	State  string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener, to take the next one from with --opener.'"`
 }
 
 // Help displays extended help and examples.
@@ -34,75 +28,78 @@ func (t TransmitV2Cmd) Help() string {
 	# shorter v2 code (encodes to 80 bits, in two 40-bit packets)
 	openers secplus encodev2 --rolling=240124710 --fixed=70678577664
 	# longer v2 code (encodes to 128 bits, in two 64-bit packets
-	openers secplus encodev2 --rolling=240129675 --fixed=4616223061045564932096`
+	openers secplus encodev2 --rolling=240129675 --fixed=4616223061045564932096
+	# An opener from the configuration file, with its next rolling code.
+	openers secplus transmitv2 --opener=garage --pin=12`
 }
 
 // Run the `encode` command.
 func (t *TransmitV2Cmd) Run(globals *Globals) error {
-
-	if err := gpiod.IsChip(t.Chip); err != nil {
-		chips := gpiod.Chips()
-		if len(chips) == 0 {
-			return fmt.Errorf("%q is not an available chip: there are no chips available", t.Chip)
+	if t.Opener == "" {
+		tx, err := t.transmission()
+		if err != nil {
+			return err
+		}
+		if err := t.plan(tx); err != nil {
+			return err
 		}
-		return fmt.Errorf("%q is not an available chip; please choose one of %s", t.Chip, strings.Join(gpiod.Chips(), ","))
+		return t.send(globals.Context, tx)
 	}
 
-	fixedBytes := make([]byte, 9)
-	t.Fixed.FillBytes(fixedBytes)
-	fixedHigh := fixedBytes[0]
-	fixedLow := binary.BigEndian.Uint64(fixedBytes[1:])
-
-	bursts, err := secplus.EncodeV2ToBursts(fixedHigh, fixedLow, t.Rolling)
+	c, err := globals.loadConfig()
 	if err != nil {
 		return err
 	}
-
-	chip, err := gpiod.NewChip(t.Chip)
+	o, err := c.Opener(t.Opener)
 	if err != nil {
 		return err
 	}
-	defer chip.Close()
-
-	line, err := chip.RequestLine(t.Pin, gpiod.AsOutput(0))
+	if o.Protocol != secplus.Protocol {
+		return fmt.Errorf("opener %q uses %s, not %s", o.Name, o.Protocol, secplus.Protocol)
+	}
+	if t.Fixed == nil {
+		if t.Fixed, err = o.FixedCode(); err != nil {
+			return err
+		}
+	}
+	limits, err := o.Limits.Limits()
 	if err != nil {
 		return err
 	}
-	defer line.SetValue(0)
-	defer line.Close()
-
-	for i := 0; i < t.Repeats; i++ {
-		if i > 0 {
-			time.Sleep(t.Repeatgap)
-		}
-
-		fmt.Printf("Sending repetition %d/%d\n", i+1, t.Repeats)
-
-		for j := 0; j < 2; j++ {
-			if j > 0 {
-				target := time.Now().Add(t.Burstgap)
-				for target.After(time.Now()) {
-				}
-			}
-
-			runtime.GC()
-			debug.SetGCPercent(-1)
-
-			fmt.Printf("    sending: %s\n", bits.S(bursts[j]))
-			target := time.Now()
-			for _, bit := range bursts[j] {
-				target = target.Add(t.Pulsewidth)
-				if err := line.SetValue(int(bit)); err != nil {
-					return err
-				}
-				for target.After(time.Now()) {
-				}
-			}
-			if err := line.SetValue(0); err != nil {
+	if t.Rolling == nil {
+		// Don't use up a rolling code on a transmission the limits stop.
+		if s := t.limitStore(); s != nil {
+			if err := s.Check(o.Name, limits); err != nil {
 				return err
 			}
 		}
+		start, err := o.RollingStart()
+		if err != nil {
+			return err
+		}
+		code, err := (&rolling.Store{Path: t.State}).Next(o.Name, start)
+		if err != nil {
+			return err
+		}
+		t.Rolling = &code
 	}
+	tx, err := t.transmission()
+	if err != nil {
+		return err
+	}
+	if err := t.openerPlan(tx, o); err != nil {
+		return err
+	}
+	return t.send(limit.NewContext(globals.Context, limits), tx)
+}
 
-	return nil
+// send transmits, recording the codes sent in the audit log.
+func (t *TransmitV2Cmd) send(ctx context.Context, tx *waveform.Transmission) error {
+	ctx = audit.NewContext(ctx, &audit.Entry{
+		Opener:  t.Opener,
+		Fixed:   t.key().Fingerprint(secplus.Protocol, t.Fixed),
+		Rolling: t.Rolling,
+		Source:  "cli",
+	})
+	return t.TransmitFlags.transmit(ctx, tx)
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/capture"
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/jitter"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Codes sent by the self-test. They don't belong to any real remote.
+const (
+	selftestFixed      = "70678577664"
+	selftestRolling    = 240124710
+	selftestIdentifier = 0x876543
+)
+
+// selftestSettle is how long to keep listening after a transmission, for the
+// last edges to arrive.
+const selftestSettle = 50 * time.Millisecond
+
+// selftestRealign is the shortest silence at which the timing comparison
+// realigns the captured pulses with the intended ones: longer than any gap
+// within a burst, and shorter than the gaps between them.
+const selftestRealign = 10 * time.Millisecond
+
+// SelftestCmd is the kong `selftest` command.
+type SelftestCmd struct {
+	TransmitFlags `kong:"embed"`
+	RxPin         int    `kong:"required,placeholder='pin#',help='GPIO pin number to listen on, jumpered to the transmit --pin (or to MOSI, with --backend=spi).'"`
+	Protocol      string `kong:"default='all',enum='all,secplus-v2,megacode',help='Protocol to test: all, secplus-v2 or megacode.'"`
+}
+
+// Help displays extended help and examples.
+func (s SelftestCmd) Help() string {
+	return `Drives the transmit pin while listening on a second pin jumpered to it, then
+decodes what was actually seen on the wire, and compares its timing with the
+intended waveform. Disconnect the transmitter module first: otherwise the test
+codes really will be transmitted.
+
+Examples:
+	# Test transmit pin 12, jumpered to pin 16.
+	sudo openers selftest --realtime --pin=12 --rx-pin=16
+	# Test the SPI backend, with MOSI (GPIO 10) jumpered to pin 16.
+	sudo openers selftest --backend=spi --rx-pin=16`
+}
+
+// Run the `selftest` command.
+func (s *SelftestCmd) Run(globals *Globals) error {
+	fixed, _ := new(big.Int).SetString(selftestFixed, 10)
+	fixedHigh, fixedLow := splitFixed(fixed)
+	var tests []*waveform.Transmission
+	if s.Protocol == "all" || s.Protocol == secplus.Protocol {
+		tx, err := secplus.TransmissionV2(fixedHigh, fixedLow, selftestRolling, secplus.DefaultTimingV2)
+		if err != nil {
+			return err
+		}
+		tests = append(tests, tx)
+	}
+	if s.Protocol == "all" || s.Protocol == megacode.Protocol {
+		tx, err := megacode.Transmission(selftestIdentifier, megacode.DefaultPulsewidth, megacode.DefaultRepeats)
+		if err != nil {
+			return err
+		}
+		tests = append(tests, tx)
+	}
+
+	edges := &capture.Capture{}
+	release, err := openInput(s.Chip, s.RxPin, edges.HandleEvent)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Retries would leave several attempts in the capture.
+	s.MaxJitter = 0
+
+	failed := 0
+	for _, tx := range tests {
+		fmt.Printf("Testing %s\n", tx.Protocol)
+		edges.Reset()
+		ctx := audit.NewContext(globals.Context, &audit.Entry{Source: "selftest"})
+		// With the transmitter module disconnected, there is nothing on air
+		// to limit.
+		ctx = limit.NewContext(ctx, limit.Limits{})
+		if err := s.TransmitFlags.transmit(ctx, tx); err != nil {
+			return err
+		}
+		time.Sleep(selftestSettle)
+		if err := checkLoopback(tx, edges.Pulses(), globals); err != nil {
+			fmt.Printf("FAIL %s: %v\n", tx.Protocol, err)
+			failed++
+			continue
+		}
+		fmt.Printf("PASS %s\n", tx.Protocol)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d self-tests failed", failed, len(tests))
+	}
+	return nil
+}
+
+// checkLoopback checks that the pulses captured from the wire decode to
+// every repeat of the transmission, and that their timing is within the
+// protocol's tolerance.
+func checkLoopback(tx *waveform.Transmission, got []waveform.Pulse, globals *Globals) error {
+	if len(got) == 0 {
+		return fmt.Errorf("no edges seen on the receive pin; is it jumpered to the transmit pin?")
+	}
+	if globals.Debug > 0 {
+		fmt.Printf("Captured %d pulses lasting %v\n", len(got), waveform.Duration(got))
+	}
+
+	want := demod.Decode(tx.Pulses())
+	messages := demod.Decode(got)
+	for _, m := range messages {
+		fmt.Printf("%12s  %s\n", m.Start, m)
+	}
+	if len(messages) != len(want) {
+		return fmt.Errorf("want %d transmissions decoded from the wire; got %d", len(want), len(messages))
+	}
+	for i := range want {
+		if messages[i].String() != want[i].String() {
+			return fmt.Errorf("want transmission %d to decode as %q; got %q", i+1, want[i], messages[i])
+		}
+	}
+
+	recorder := &jitter.Recorder{}
+	if err := recorder.Compare(tx.Pulses(), got, selftestRealign); err != nil {
+		return fmt.Errorf("captured waveform doesn't match: %v", err)
+	}
+	report := recorder.Report(tx.Tolerance)
+	fmt.Printf("On the wire %s\n", report)
+	if !report.OK() {
+		return fmt.Errorf("%d pin changes on the wire were outside the ±%v protocol tolerance", report.Missed, report.Tolerance)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/zellyn/openers/bridge"
+)
+
+// SerialFlags holds the flags for transmitting through a microcontroller on a
+// serial line.
+type SerialFlags struct {
+	SerialDevice string `kong:"default='/dev/ttyUSB0',placeholder='/dev/ttyUSB0',help='Serial port of the microcontroller, for --backend=bridge.'"`
+	Baud         int    `kong:"default='115200',help='Speed of --serial-device.'"`
+}
+
+// openBridge opens the serial port and checks that the bridge device answers.
+// The returned function closes the port.
+func (s *SerialFlags) openBridge() (*bridge.Client, func(), error) {
+	port, err := bridge.OpenSerial(s.SerialDevice, s.Baud)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := bridge.NewClient(port)
+	info, err := client.Hello()
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("no bridge device answering on %s: %v", s.SerialDevice, err)
+	}
+	if info.Version != bridge.Version {
+		client.Close()
+		return nil, nil, fmt.Errorf("bridge device on %s speaks protocol version %d; want %d", s.SerialDevice, info.Version, bridge.Version)
+	}
+	return client, func() { client.Close() }, nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/waveform"
+)
+
+// ServeCmd is the kong `serve` command.
+type ServeCmd struct {
+	TransmitFlags `kong:"embed"`
+	MetricsFlags  `kong:"embed"`
+
+	Listen string `kong:"default=':8080',placeholder='host:port',help='Address to serve the HTTP API on.'"`
+	Token  string `kong:"env='OPENERS_TOKEN',help='Token callers must give as \"Authorization: Bearer <token>\". Best set through the environment.'"`
+	State  string `kong:"default='/var/lib/openers/rolling.json',type='path',placeholder='file',help='File recording the rolling codes sent to each opener.'"`
+
+	Insecure bool `kong:"help='Serve without a token on an address other than loopback, letting anyone who can reach it trigger the openers.'"`
+}
+
+// Help displays extended help and examples.
+func (s ServeCmd) Help() string {
+	return `Serves an HTTP API for triggering the openers in the configuration file,
+transmitting one request at a time.
+
+Examples:
+	# Serve on port 8080, transmitting on pin 12.
+	OPENERS_TOKEN=sesame openers serve --pin=12
+	# Then, from anywhere:
+	curl -X POST -H 'Authorization: Bearer sesame' http://pi:8080/api/openers/garage/toggle
+	# Serve without a token, only to this machine.
+	openers serve --pin=12 --listen=localhost:8080`
+}
+
+// loopback reports whether a listen address only accepts connections from
+// this machine.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Run the `serve` command.
+func (s *ServeCmd) Run(globals *Globals) error {
+	if s.Token == "" && !s.Insecure && !loopback(s.Listen) {
+		return fmt.Errorf("refusing to serve %s without a token; set OPENERS_TOKEN, listen on loopback, or pass --insecure", s.Listen)
+	}
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return s.TransmitFlags.transmit(ctx, tx)
+	}
+	srv := server.New(c, send, &rolling.Store{Path: s.State})
+	srv.Limits = s.limitStore()
+	srv.AuditKey = s.key()
+	doors, release, err := watchDoors(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+	srv.Doors = doors
+	srv.Token = s.Token
+
+	ctx := globals.Context
+	if err := s.serveMetrics(ctx); err != nil {
+		return err
+	}
+	go srv.Run(ctx)
+	httpServer := &http.Server{Addr: s.Listen, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+	fmt.Printf("Serving %d openers on %s\n", len(c.Openers), s.Listen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
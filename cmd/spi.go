@@ -0,0 +1,18 @@
+package cmd
+
+import "github.com/zellyn/openers/spidev"
+
+// SPIFlags holds the flags for transmitting through an SPI device.
+type SPIFlags struct {
+	SPIDevice string `kong:"name='spi-device',default='/dev/spidev0.0',placeholder='device',help='SPI device whose MOSI pin keys the transmitter, for --backend=spi, or with the CC1101 attached, for --backend=cc1101.'"`
+}
+
+// openSPI opens the SPI device at the given clock speed. The returned function
+// closes it.
+func (s *SPIFlags) openSPI(speedHz uint32) (spidev.Conn, func(), error) {
+	d, err := spidev.Open(s.SPIDevice, spidev.Options{SpeedHz: speedHz})
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, func() { d.Close() }, nil
+}
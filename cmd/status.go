@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/limit"
+)
+
+// statusPoll is how often `status --watch` looks for changes.
+const statusPoll = 250 * time.Millisecond
+
+// StatusCmd is the kong `status` command.
+type StatusCmd struct {
+	Openers []string `kong:"arg,optional,placeholder='opener',help='Openers to show. Defaults to all of them.'"`
+	Watch   bool     `kong:"help='Keep watching, printing each change of state, until interrupted.'"`
+	Record  string   `kong:"type='path',placeholder='dir',help='With --watch, record each door sensor switch event to a trace file per opener in this directory, for tests.'"`
+
+	LimitFlags   `kong:"embed"`
+	MetricsFlags `kong:"embed"`
+}
+
+// Help displays extended help and examples.
+func (s StatusCmd) Help() string {
+	return `Shows where each door is, from the limit switches described by the "sensor"
+of each opener in the configuration file, and what is left of each opener's
+limits on transmissions.
+
+Examples:
+	openers status
+	# Watch the garage while opening and closing it, recording a trace.
+	openers status garage --watch --record=traces`
+}
+
+// doorWatch is an opener's door being watched.
+type doorWatch struct {
+	opener *config.Opener
+	door   *door.Door // nil if the opener has no sensor.
+	last   door.State
+}
+
+// Run the `status` command.
+func (s *StatusCmd) Run(globals *Globals) error {
+	if s.Record != "" && !s.Watch {
+		return fmt.Errorf("--record needs --watch")
+	}
+	if s.MetricsListen != "" && !s.Watch {
+		return fmt.Errorf("--metrics-listen needs --watch")
+	}
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	names := s.Openers
+	if len(names) == 0 {
+		for _, o := range c.Openers {
+			names = append(names, o.Name)
+		}
+	}
+	var watches []*doorWatch
+	for _, name := range names {
+		o, err := c.Opener(name)
+		if err != nil {
+			return err
+		}
+		w := &doorWatch{opener: o}
+		if o.Sensor != nil {
+			var trace *traceWriter
+			if s.Record != "" {
+				if err := os.MkdirAll(s.Record, 0755); err != nil {
+					return err
+				}
+				f, err := os.Create(filepath.Join(s.Record, o.Name+".trace"))
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				trace = newTraceWriter(f)
+			}
+			d, release, err := openDoor(o.Sensor, trace)
+			if err != nil {
+				return fmt.Errorf("opener %q: %v", o.Name, err)
+			}
+			defer release()
+			w.door = d
+			exportDoor(o.Name, d)
+		}
+		watches = append(watches, w)
+	}
+
+	store := s.limitStore()
+	for _, w := range watches {
+		fmt.Println(w.describe(door.Now()))
+		if store != nil {
+			fmt.Println(w.budget(store))
+		}
+	}
+	if !s.Watch {
+		return nil
+	}
+	ctx := globals.Context
+	if err := s.serveMetrics(ctx); err != nil {
+		return err
+	}
+	t := time.NewTicker(statusPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		now := door.Now()
+		for _, w := range watches {
+			if w.door != nil && w.door.State(now) != w.last {
+				fmt.Printf("%s %s\n", time.Now().Format("15:04:05"), w.describe(now))
+			}
+		}
+	}
+}
+
+// budget describes what is left of the opener's limits.
+func (w *doorWatch) budget(store *limit.Store) string {
+	l, err := w.opener.Limits.Limits()
+	if err != nil {
+		return fmt.Sprintf("%-12s limits: %v", "", err)
+	}
+	b, err := store.Budget(w.opener.Name, l)
+	if err != nil {
+		return fmt.Sprintf("%-12s limits: unknown: %v", "", err)
+	}
+	return fmt.Sprintf("%-12s limits: %s", "", b)
+}
+
+// describe describes the opener's door, recording its state as seen.
+func (w *doorWatch) describe(now time.Duration) string {
+	if w.door == nil {
+		return fmt.Sprintf("%-12s no sensor", w.opener.Name)
+	}
+	st := w.door.Status(now)
+	w.last = st.State
+	s := fmt.Sprintf("%-12s %s", w.opener.Name, st.State)
+	if st.SinceKnown {
+		ago := now - st.Since
+		s += fmt.Sprintf(" since %s (%v ago)", time.Now().Add(-ago).Format("15:04:05"), ago.Round(time.Second))
+	}
+	return s
+}
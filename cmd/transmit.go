@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/cc1101"
+	"github.com/zellyn/openers/jitter"
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// TransmitFlags holds the flags shared by the commands that transmit.
+type TransmitFlags struct {
+	Backend string `kong:"default='gpio',enum='gpio,spi,pigpio,cc1101,rfm69,bridge',help='How to key the transmitter: gpio (busy-wait toggling --pin), spi (bitstream clocked out of the MOSI pin of --spi-device), pigpio (DMA-timed waveform on --pin, played by pigpiod), cc1101 (CC1101 radio on --spi-device), rfm69 (RFM69 radio on --spi-device, keyed through DIO2 wired to --pin) or bridge (microcontroller on --serial-device).'"`
+
+	GPIOFlags   `kong:"embed"`
+	SPIFlags    `kong:"embed"`
+	PigpioFlags `kong:"embed"`
+	SerialFlags `kong:"embed"`
+	RadioFlags  `kong:"embed"`
+	BandFlags   `kong:"embed"`
+	LockFlags   `kong:"embed"`
+	AuditFlags  `kong:"embed"`
+	LimitFlags  `kong:"embed"`
+
+	DryRun bool `kong:"help='Describe the transmission, including its frequency plan, instead of sending it.'"`
+
+	MetricsTextfile string `kong:"type='path',placeholder='file',help='After each transmission, write Prometheus metrics to this file for the node_exporter textfile collector, adding to what earlier runs left there. Name it *.prom, in the collector directory.'"`
+
+	MaxJitter time.Duration `kong:"help='Mark a transmission failed, and retry it, if any pin change deviates from its target time by more than this. Only measured when busy-waiting on a GPIO pin (the gpio and rfm69 backends, and cc1101 in async mode).'"`
+	Retries   int           `kong:"default='2',help='Number of times to retry a transmission that exceeded --max-jitter.'"`
+	MaxOnTime time.Duration `kong:"default='100ms',help='Force the transmitter off if a GPIO pin keying it stays high for longer than this. 0 disables the watchdog.'"`
+
+	RealtimeFlags `kong:"embed"`
+}
+
+// transmit sends a transmission using the selected backend, tuning it to each
+// frequency in the transmission's plan in turn. Cancelling the context stops
+// it between pulses, leaving the transmitter unkeyed. Unless it is a dry run,
+// the attempt is recorded in the audit log, completing the context's entry if
+// it has one, and counted in the metrics. It is refused if it would exceed
+// the limits in the context, or the defaults.
+func (f *TransmitFlags) transmit(ctx context.Context, tx *waveform.Transmission) (err error) {
+	e := audit.FromContext(ctx)
+	if e == nil {
+		e = &audit.Entry{Source: "cli"}
+	}
+	e.Protocol, e.Backend = tx.Protocol, f.Backend
+	stage := reasonLock
+	if !f.DryRun {
+		var unlock func()
+		var start time.Time
+		defer func() {
+			// Still holding the hardware, so that one-shot commands take
+			// turns with the metrics textfile.
+			f.record(e, err)
+			f.observeTransmission(ctx, e, err, stage, time.Since(start))
+			if err != nil {
+				switch failureReason(ctx, err, stage) {
+				case reasonBackend, reasonTransmit, reasonJitter:
+					f.limitFailed(e)
+				}
+			}
+			if unlock != nil {
+				unlock()
+			}
+		}()
+		if unlock, err = f.lock(ctx, f.lockNames()); err != nil {
+			return err
+		}
+		start = time.Now()
+		stage = reasonLimit
+		if err := f.takeLimits(ctx, e, tx); err != nil {
+			return err
+		}
+	}
+
+	stage = reasonBackend
+	transmitter, recorder, release, err := f.openTransmitter(tx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if f.DryRun {
+		fmt.Printf("Transmission: %s\n", tx)
+	}
+
+	tuner, releaseTuner, err := f.openTuner(transmitter, tx)
+	if err != nil {
+		return err
+	}
+	defer releaseTuner()
+
+	restore, err := f.enterRealtime()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	stage = reasonTransmit
+	for i, leg := range tx.Legs() {
+		if i > 0 {
+			if err := transmit.Sleep(ctx, tx.RepeatGap); err != nil {
+				return err
+			}
+		}
+		if leg.Frequency != 0 {
+			if err := tuner.Tune(leg.Frequency); err != nil {
+				return err
+			}
+		}
+		if err := f.send(ctx, transmitter, recorder, leg.Tx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a transmission, printing a timing report after each attempt if
+// the backend measures its timing, and retrying if it exceeded --max-jitter.
+// Each retry is on air again, so is charged to the limits like the first
+// attempt, and not made if they would be exceeded. The attempts and their
+// worst timing are added to the audit entry.
+func (f *TransmitFlags) send(ctx context.Context, transmitter transmit.Transmitter, recorder *jitter.Recorder, tx *waveform.Transmission, e *audit.Entry) error {
+	for attempt := 1; ; attempt++ {
+		if err := transmitter.Transmit(ctx, tx); err != nil {
+			return err
+		}
+		if recorder == nil {
+			return nil
+		}
+		report := recorder.Report(tx.Tolerance)
+		observeJitter(e.Opener, recorder.Deviations())
+		e.Attempts++
+		if report.Max > e.MaxDeviation {
+			e.MaxDeviation = report.Max
+		}
+		fmt.Printf("Attempt %d %s\n", attempt, report)
+		if !report.OK() {
+			fmt.Printf("WARNING: timing was outside what %s needs; the receiver may not respond\n", tx.Protocol)
+		}
+		if f.MaxJitter == 0 || report.Max <= f.MaxJitter {
+			return nil
+		}
+		if attempt > f.Retries {
+			return &jitterError{max: report.Max, limit: f.MaxJitter, attempts: attempt}
+		}
+		if err := f.takeLimits(ctx, e, tx); err != nil {
+			fmt.Printf("Maximum deviation %v exceeded --max-jitter=%v; not retrying: %v\n", report.Max, f.MaxJitter, err)
+			return &jitterError{max: report.Max, limit: f.MaxJitter, attempts: attempt}
+		}
+		fmt.Printf("Maximum deviation %v exceeded --max-jitter=%v; retrying\n", report.Max, f.MaxJitter)
+	}
+}
+
+// openTuner returns what switches frequencies for the transmission's
+// frequency plan, if it has one: the transmitter itself if it can, or else the
+// band select lines. The returned function releases any lines.
+func (f *TransmitFlags) openTuner(transmitter transmit.Transmitter, tx *waveform.Transmission) (transmit.Tuner, func(), error) {
+	if len(tx.Frequencies) == 0 {
+		return nil, func() {}, nil
+	}
+	if tuner, ok := transmitter.(transmit.Tuner); ok {
+		return tuner, func() {}, nil
+	}
+	if len(f.BandPins) == 0 {
+		return nil, nil, fmt.Errorf("the %s backend cannot change frequency; use --backend=cc1101, or describe a multi-band module with --band-pins and --bands", f.Backend)
+	}
+	return f.openBandSelect(f.Chip)
+}
+
+// openTransmitter opens the selected backend, ready to send tx. It also
+// returns the recorder measuring the backend's timing, if any, and a function
+// that releases the backend.
+func (f *TransmitFlags) openTransmitter(tx *waveform.Transmission) (transmit.Transmitter, *jitter.Recorder, func(), error) {
+	if f.DryRun {
+		return &transmit.DryRun{Log: os.Stdout}, nil, func() {}, nil
+	}
+	switch f.Backend {
+	case "spi":
+		speed := transmit.SPIClock(tx.Bursts[0].Pulsewidth)
+		port, release, err := f.openSPI(speed)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return &transmit.SPI{Port: port, SpeedHz: speed, Log: os.Stdout}, nil, release, nil
+	case "cc1101":
+		return f.openCC1101()
+	case "rfm69":
+		return f.openRFM69(tx)
+	case "bridge":
+		client, release, err := f.openBridge()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return &transmit.Bridge{Client: client, Log: os.Stdout}, nil, release, nil
+	}
+
+	if f.Pin < 0 {
+		return nil, nil, nil, fmt.Errorf("please specify the GPIO pin to transmit on with --pin")
+	}
+	if f.Backend == "pigpio" {
+		client, release, err := f.dialPigpio()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return &transmit.Pigpio{Client: client, GPIO: f.Pin, Log: os.Stdout}, nil, release, nil
+	}
+	line, release, err := f.openKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	recorder := &jitter.Recorder{}
+	return &transmit.GPIO{Line: line, Log: os.Stdout, Jitter: recorder}, recorder, release, nil
+}
+
+// openKey requests --pin as an output keying a transmitter, guarded by the
+// --max-on-time watchdog. The returned function drives the line low, and
+// releases it.
+func (f *TransmitFlags) openKey() (transmit.Line, func(), error) {
+	line, release, err := openOutput(f.Chip, f.Pin)
+	if err != nil || f.MaxOnTime == 0 {
+		return line, release, err
+	}
+	return &transmit.Watchdog{Line: line, MaxOn: f.MaxOnTime}, release, nil
+}
+
+// openCC1101 opens the CC1101 radio on the SPI device, along with the GPIO pin
+// wired to its GDO0 pin in async mode.
+func (f *TransmitFlags) openCC1101() (transmit.Transmitter, *jitter.Recorder, func(), error) {
+	conn, release, err := f.openSPI(cc1101.SPISpeed)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c := &transmit.CC1101{
+		Radio:     &cc1101.Radio{Conn: conn},
+		Frequency: f.Frequency,
+		Power:     f.Power,
+		Log:       os.Stdout,
+	}
+	if f.CC1101Mode != "async" {
+		return c, nil, release, nil
+	}
+
+	if f.Pin < 0 {
+		release()
+		return nil, nil, nil, fmt.Errorf("please specify the GPIO pin wired to GDO0 with --pin")
+	}
+	line, releaseLine, err := f.openKey()
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	recorder := &jitter.Recorder{}
+	c.Async = &transmit.GPIO{Line: line, Log: os.Stdout, Jitter: recorder}
+	return c, recorder, func() {
+		releaseLine()
+		release()
+	}, nil
+}
+
+// openRFM69 opens and configures the RFM69 radio on the SPI device, along with
+// the GPIO pin wired to its DIO2 pin.
+func (f *TransmitFlags) openRFM69(tx *waveform.Transmission) (transmit.Transmitter, *jitter.Recorder, func(), error) {
+	if f.Pin < 0 {
+		return nil, nil, nil, fmt.Errorf("please specify the GPIO pin wired to DIO2 with --pin")
+	}
+	radio, release, err := openRFM69(&f.SPIFlags, rfm69.Config{
+		Frequency: f.Frequency,
+		Bitrate:   float64(time.Second) / float64(tx.Bursts[0].Pulsewidth),
+		Power:     f.Power,
+		HighPower: !f.RFM69LowPower,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	line, releaseLine, err := f.openKey()
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	recorder := &jitter.Recorder{}
+	r := &transmit.RFM69{
+		Radio: radio,
+		Data:  &transmit.GPIO{Line: line, Log: os.Stdout, Jitter: recorder},
+		Log:   os.Stdout,
+	}
+	return r, recorder, func() {
+		releaseLine()
+		release()
+	}, nil
+}
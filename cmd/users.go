@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/zellyn/openers/acl"
+)
+
+// UsersCmd is the kong `users` subcommand.
+type UsersCmd struct {
+	Add    UsersAddCmd    `kong:"cmd,help='Let a user or group trigger openers.'"`
+	Revoke UsersRevokeCmd `kong:"cmd,help='Stop a user or group triggering openers.'"`
+	List   UsersListCmd   `kong:"cmd,help='List who may trigger which openers.'"`
+}
+
+// ACLFlags holds the flag naming the access list, shared by the `users`
+// commands.
+type ACLFlags struct {
+	ACL string `kong:"name='acl',default='/etc/openers/acl.json',type='path',placeholder='file',help='Access list of who may trigger which openers, and when.'"`
+}
+
+// UsersAddCmd is the kong `users add` command.
+type UsersAddCmd struct {
+	ACLFlags `kong:"embed"`
+
+	Name    string   `kong:"arg,placeholder='user',help='User (or group, with --group) to allow.'"`
+	Group   bool     `kong:"help='The name is a group, and the rule applies to all its members.'"`
+	Openers []string `kong:"required,sep=',',help='Openers to allow, or * for all of them.'"`
+	Hours   string   `kong:"placeholder='HH:MM-HH:MM',help='Daily window in which the openers may be triggered, eg. 07:00-22:00. Replaces any earlier window.'"`
+}
+
+// Help displays extended help and examples.
+func (u UsersAddCmd) Help() string {
+	return `Adds to the openers a user or group may trigger through the helper. The
+helper rereads the access list for every request.
+
+Examples:
+	sudo openers users add alice --openers=garage,gate
+	sudo openers users add bob --openers=gate --hours=07:00-22:00
+	sudo openers users add family --group --openers='*'`
+}
+
+// Run the `users add` command.
+func (u *UsersAddCmd) Run(globals *Globals) error {
+	c, err := globals.loadConfig()
+	if err != nil {
+		return err
+	}
+	for _, o := range u.Openers {
+		if o == acl.All {
+			continue
+		}
+		if _, err := c.Opener(o); err != nil {
+			return err
+		}
+	}
+	a, err := acl.Load(u.ACL)
+	if err != nil {
+		return err
+	}
+	if err := a.Add(u.Name, u.Group, u.Openers, u.Hours); err != nil {
+		return err
+	}
+	return a.Save(u.ACL)
+}
+
+// UsersRevokeCmd is the kong `users revoke` command.
+type UsersRevokeCmd struct {
+	ACLFlags `kong:"embed"`
+
+	Name    string   `kong:"arg,placeholder='user',help='User (or group, with --group) to revoke.'"`
+	Group   bool     `kong:"help='The name is a group.'"`
+	Openers []string `kong:"sep=',',help='Openers to revoke. Defaults to all of them.'"`
+}
+
+// Help displays extended help and examples.
+func (u UsersRevokeCmd) Help() string {
+	return `Takes away some or all of the openers a user or group may trigger through
+the helper.
+
+Examples:
+	sudo openers users revoke bob --openers=gate
+	sudo openers users revoke alice`
+}
+
+// Run the `users revoke` command.
+func (u *UsersRevokeCmd) Run(globals *Globals) error {
+	a, err := acl.Load(u.ACL)
+	if err != nil {
+		return err
+	}
+	if err := a.Revoke(u.Name, u.Group, u.Openers); err != nil {
+		return err
+	}
+	return a.Save(u.ACL)
+}
+
+// UsersListCmd is the kong `users list` command.
+type UsersListCmd struct {
+	ACLFlags `kong:"embed"`
+}
+
+// Run the `users list` command.
+func (u *UsersListCmd) Run(globals *Globals) error {
+	a, err := acl.Load(u.ACL)
+	if err != nil {
+		return err
+	}
+	fmt.Println("root: * (always)")
+	for _, r := range a.Rules {
+		fmt.Println(r.String())
+	}
+	return nil
+}
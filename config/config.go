@@ -0,0 +1,525 @@
+package config
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/zellyn/openers/acl"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// DefaultPath is where the configuration file lives by default.
+const DefaultPath = "/etc/openers/openers.json"
+
+// Config is the whole configuration file.
+type Config struct {
+	Openers []Opener `json:"openers"`
+	// Schedule is the rules followed by the schedule command.
+	Schedule []Rule `json:"schedule,omitempty"`
+	// QuietHours are when openers may not be triggered remotely.
+	QuietHours []Quiet `json:"quiet_hours,omitempty"`
+}
+
+// Rule is a scheduled action: either at a time of day, or once a door has
+// been open for a while.
+type Rule struct {
+	Opener string `json:"opener"`
+	// Action is open, close or toggle. Openers without a sensor can only
+	// toggle, since the door might already be where it's meant to go.
+	Action string `json:"action"`
+	// At is a time of day, like "22:30", to act at.
+	At string `json:"at,omitempty"`
+	// Days are the days of the week At applies to, like "sat"; every day if
+	// empty.
+	Days []string `json:"days,omitempty"`
+	// OpenFor is how long the door must have been open, like "15m", to act.
+	// The opener needs a sensor, and the action can't be "open".
+	OpenFor string `json:"open_for,omitempty"`
+}
+
+// Quiet is a daily window in which openers may not be triggered remotely.
+type Quiet struct {
+	Hours string `json:"hours"` // Like "23:00-06:00".
+	// Openers are the openers it applies to; all of them if empty.
+	Openers []string `json:"openers,omitempty"`
+}
+
+// Opener describes a single gate or garage opener.
+type Opener struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"` // secplus.Protocol or megacode.Protocol.
+	// Kind is what the opener moves: KindGarage (the default) or KindGate.
+	Kind string `json:"kind,omitempty"`
+
+	// Fixed is the fixed part of a Security+2.0 code, in decimal or 0x-prefixed
+	// hexadecimal.
+	Fixed string `json:"fixed,omitempty"`
+	// Rolling is the Security+2.0 rolling code to start from, when no codes
+	// have been sent to the opener yet. See package rolling.
+	Rolling string `json:"rolling,omitempty"`
+	// Identifier is a MegaCode opener identifier, in decimal or 0x-prefixed
+	// hexadecimal.
+	Identifier string `json:"identifier,omitempty"`
+
+	// FrequenciesMHz is the opener's frequency plan. See
+	// waveform.Transmission.Frequencies.
+	FrequenciesMHz []float64 `json:"frequencies_mhz,omitempty"`
+	// Hop is how to move through the frequency plan: "repeat" (the default)
+	// or "round". See waveform.Hop.
+	Hop string `json:"hop,omitempty"`
+
+	// Sensor, if not nil, describes the switches that tell where the door
+	// is.
+	Sensor *Sensor `json:"sensor,omitempty"`
+
+	// Limits, if not nil, replace the default limits on transmissions to
+	// the opener.
+	Limits *Limits `json:"limits,omitempty"`
+}
+
+// Sensor describes a door's limit switches, wired to GPIO inputs. See
+// package door.
+type Sensor struct {
+	// Chip is the GPIO chip the switches are on, gpiochip0 by default.
+	Chip string `json:"chip,omitempty"`
+	// ClosedPin and OpenPin are the pins of the switches that are active
+	// when the door is fully closed and fully open. At least one is needed.
+	ClosedPin *int `json:"closed_pin,omitempty"`
+	OpenPin   *int `json:"open_pin,omitempty"`
+	// ActiveHigh is set if the switches pull their pins high when active.
+	// By default, they pull them to ground, against pull-ups.
+	ActiveHigh bool `json:"active_high,omitempty"`
+	// Debounce and Travel are durations, such as "50ms" and "15s": how long
+	// a switch must hold a new level to count, and how long the door takes
+	// to open or close.
+	Debounce string `json:"debounce,omitempty"`
+	Travel   string `json:"travel,omitempty"`
+}
+
+// Limits restrict transmissions to an opener. See package limit. Fields
+// left out take the defaults; "0s" or 0 turns a limit off.
+type Limits struct {
+	// OnAir and Window are durations, such as "30s" and "1h": the most time
+	// the carrier may be on in any window of that length.
+	OnAir  string `json:"on_air,omitempty"`
+	Window string `json:"window,omitempty"`
+	// PerMinute is the most transmissions in any minute.
+	PerMinute *int `json:"per_minute,omitempty"`
+	// CoolDown is a duration, such as "10s", to refuse transmissions for
+	// after one fails.
+	CoolDown string `json:"cool_down,omitempty"`
+}
+
+// Kinds of opener.
+const (
+	KindGarage = "garage"
+	KindGate   = "gate"
+)
+
+// Load reads and checks the configuration file at path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Parse reads and checks a configuration file.
+func Parse(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i := range c.Openers {
+		o := &c.Openers[i]
+		if o.Name == "" {
+			return nil, fmt.Errorf("opener %d has no name", i+1)
+		}
+		if seen[o.Name] {
+			return nil, fmt.Errorf("opener %q is defined more than once", o.Name)
+		}
+		seen[o.Name] = true
+		if err := o.check(); err != nil {
+			return nil, fmt.Errorf("opener %q: %v", o.Name, err)
+		}
+	}
+	for i := range c.Schedule {
+		if err := c.Schedule[i].check(&c); err != nil {
+			return nil, fmt.Errorf("schedule rule %d: %v", i+1, err)
+		}
+	}
+	for i, q := range c.QuietHours {
+		if _, _, err := acl.ParseHours(q.Hours); err != nil {
+			return nil, fmt.Errorf("quiet hours %d: %v", i+1, err)
+		}
+		for _, name := range q.Openers {
+			if _, err := c.Opener(name); err != nil {
+				return nil, fmt.Errorf("quiet hours %d: %v", i+1, err)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Opener returns the opener with the given name.
+func (c *Config) Opener(name string) (*Opener, error) {
+	for i := range c.Openers {
+		if c.Openers[i].Name == name {
+			return &c.Openers[i], nil
+		}
+	}
+	var names []string
+	for _, o := range c.Openers {
+		names = append(names, o.Name)
+	}
+	return nil, fmt.Errorf("no opener named %q; configured openers: %v", name, names)
+}
+
+// check checks that an opener's settings make sense.
+func (o *Opener) check() error {
+	switch o.Kind {
+	case "", KindGarage, KindGate:
+	default:
+		return fmt.Errorf("unknown kind %q; expected %s or %s", o.Kind, KindGarage, KindGate)
+	}
+	switch o.Protocol {
+	case secplus.Protocol:
+		if _, err := o.FixedCode(); err != nil {
+			return err
+		}
+		if _, err := o.RollingStart(); err != nil {
+			return err
+		}
+	case megacode.Protocol:
+		if _, err := o.ID(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q; expected %s or %s", o.Protocol, secplus.Protocol, megacode.Protocol)
+	}
+	if _, _, err := o.Plan(); err != nil {
+		return err
+	}
+	if o.Sensor != nil {
+		if _, err := o.Sensor.Door(); err != nil {
+			return fmt.Errorf("sensor: %v", err)
+		}
+	}
+	if _, err := o.Limits.Limits(); err != nil {
+		return fmt.Errorf("limits: %v", err)
+	}
+	return nil
+}
+
+// DeviceKind returns what the opener moves, defaulting to KindGarage.
+func (o *Opener) DeviceKind() string {
+	if o.Kind == "" {
+		return KindGarage
+	}
+	return o.Kind
+}
+
+// FixedCode returns the fixed part of a Security+2.0 opener's code.
+func (o *Opener) FixedCode() (*big.Int, error) {
+	fixed, ok := new(big.Int).SetString(o.Fixed, 0)
+	if !ok || fixed.Sign() < 0 || fixed.BitLen() > 72 {
+		return nil, fmt.Errorf("fixed code %q is not a 72-bit integer", o.Fixed)
+	}
+	return fixed, nil
+}
+
+// RollingStart returns the rolling code to start a Security+2.0 opener from:
+// 0, unless the configuration gives one.
+func (o *Opener) RollingStart() (uint32, error) {
+	if o.Rolling == "" {
+		return 0, nil
+	}
+	rolling, err := strconv.ParseUint(o.Rolling, 0, 28)
+	if err != nil {
+		return 0, fmt.Errorf("rolling code %q is not a 28-bit integer", o.Rolling)
+	}
+	return uint32(rolling), nil
+}
+
+// ID returns a MegaCode opener's identifier.
+func (o *Opener) ID() (uint32, error) {
+	id, err := strconv.ParseUint(o.Identifier, 0, 24)
+	if err != nil {
+		return 0, fmt.Errorf("identifier %q is not a 24-bit integer", o.Identifier)
+	}
+	return uint32(id), nil
+}
+
+// Plan returns an opener's frequency plan, in Hz.
+func (o *Opener) Plan() ([]float64, waveform.Hop, error) {
+	hop := waveform.HopRepeat
+	if o.Hop != "" {
+		var err error
+		if hop, err = waveform.ParseHop(o.Hop); err != nil {
+			return nil, 0, err
+		}
+	}
+	var frequencies []float64
+	for _, f := range o.FrequenciesMHz {
+		if f <= 0 {
+			return nil, 0, fmt.Errorf("invalid frequency %gMHz", f)
+		}
+		frequencies = append(frequencies, f*1e6)
+	}
+	return frequencies, hop, nil
+}
+
+// Transmission encodes the complete transmission that triggers an opener,
+// with default timing and its frequency plan. The rolling code is ignored for
+// MegaCode openers.
+func (o *Opener) Transmission(rolling uint32) (*waveform.Transmission, error) {
+	var tx *waveform.Transmission
+	var err error
+	switch o.Protocol {
+	case secplus.Protocol:
+		var fixed *big.Int
+		if fixed, err = o.FixedCode(); err != nil {
+			return nil, err
+		}
+		fixedBytes := make([]byte, 9)
+		fixed.FillBytes(fixedBytes)
+		tx, err = secplus.TransmissionV2(fixedBytes[0], binary.BigEndian.Uint64(fixedBytes[1:]), rolling, secplus.DefaultTimingV2)
+	case megacode.Protocol:
+		var id uint32
+		if id, err = o.ID(); err != nil {
+			return nil, err
+		}
+		tx, err = megacode.Transmission(id, megacode.DefaultPulsewidth, megacode.DefaultRepeats)
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", o.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tx.Frequencies, tx.Hop, err = o.Plan(); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ChipName returns the GPIO chip the switches are on.
+func (s *Sensor) ChipName() string {
+	if s.Chip == "" {
+		return "gpiochip0"
+	}
+	return s.Chip
+}
+
+// Pins returns the pin of each switch.
+func (s *Sensor) Pins() map[door.Switch]int {
+	pins := map[door.Switch]int{}
+	if s.ClosedPin != nil {
+		pins[door.ClosedSwitch] = *s.ClosedPin
+	}
+	if s.OpenPin != nil {
+		pins[door.OpenSwitch] = *s.OpenPin
+	}
+	return pins
+}
+
+// Door returns the model of the door, with its switches and timing, and all
+// its switches inactive.
+func (s *Sensor) Door() (*door.Door, error) {
+	pins := s.Pins()
+	var switches []door.Switch
+	for _, sw := range []door.Switch{door.ClosedSwitch, door.OpenSwitch} {
+		pin, ok := pins[sw]
+		if !ok {
+			continue
+		}
+		if pin < 0 {
+			return nil, fmt.Errorf("invalid %s pin %d", sw, pin)
+		}
+		switches = append(switches, sw)
+	}
+	switch {
+	case len(switches) == 0:
+		return nil, fmt.Errorf("no closed_pin or open_pin")
+	case len(switches) == 2 && *s.ClosedPin == *s.OpenPin:
+		return nil, fmt.Errorf("closed_pin and open_pin are both %d", *s.ClosedPin)
+	}
+	d := door.New(switches...)
+	for _, t := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"debounce", s.Debounce, &d.Debounce},
+		{"travel", s.Travel, &d.Travel},
+	} {
+		if t.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(t.value)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid %s time %q", t.name, t.value)
+		}
+		*t.dst = v
+	}
+	return d, nil
+}
+
+// weekdays are the names of the days of the week, as used in rules.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// check checks that a rule makes sense.
+func (r *Rule) check(c *Config) error {
+	o, err := c.Opener(r.Opener)
+	if err != nil {
+		return err
+	}
+	switch r.Action {
+	case "open", "close", "toggle":
+	default:
+		return fmt.Errorf("unknown action %q; expected open, close or toggle", r.Action)
+	}
+	switch {
+	case (r.At == "") == (r.OpenFor == ""):
+		return fmt.Errorf("want one of at or open_for")
+	case r.At != "":
+		if _, err := r.TimeOfDay(); err != nil {
+			return err
+		}
+		if _, err := r.Weekdays(); err != nil {
+			return err
+		}
+		if o.Sensor == nil && r.Action != "toggle" {
+			return fmt.Errorf("opener %q has no sensor, so can only toggle, not %s", o.Name, r.Action)
+		}
+		return nil
+	}
+	if len(r.Days) > 0 {
+		return fmt.Errorf("days only apply to rules with at")
+	}
+	if o.Sensor == nil {
+		return fmt.Errorf("open_for needs a sensor for opener %q", o.Name)
+	}
+	if r.Action == "open" {
+		return fmt.Errorf("open_for rules act on doors that are already open; want close or toggle, not open")
+	}
+	_, err = r.OpenDuration()
+	return err
+}
+
+// TimeOfDay returns the time of day a rule acts at, since midnight.
+func (r *Rule) TimeOfDay() (time.Duration, error) {
+	t, err := time.Parse("15:04", r.At)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q; want eg. 22:30", r.At)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Weekdays returns the days a rule acts on, or nil for every day.
+func (r *Rule) Weekdays() (map[time.Weekday]bool, error) {
+	if len(r.Days) == 0 {
+		return nil, nil
+	}
+	days := map[time.Weekday]bool{}
+	for _, d := range r.Days {
+		wd, ok := weekdays[d]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q; want eg. mon", d)
+		}
+		days[wd] = true
+	}
+	return days, nil
+}
+
+// OpenDuration returns how long a door must have been open for a rule to
+// act.
+func (r *Rule) OpenDuration() (time.Duration, error) {
+	d, err := time.ParseDuration(r.OpenFor)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid open_for %q; want eg. 15m", r.OpenFor)
+	}
+	return d, nil
+}
+
+// Quiet returns the quiet hours covering the named opener at time t, if any.
+func (c *Config) Quiet(opener string, t time.Time) (*Quiet, bool) {
+	for i := range c.QuietHours {
+		q := &c.QuietHours[i]
+		if len(q.Openers) > 0 && !contains(q.Openers, opener) {
+			continue
+		}
+		start, end, err := acl.ParseHours(q.Hours)
+		if err == nil && acl.InHours(start, end, t) {
+			return q, true
+		}
+	}
+	return nil, false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits returns the limits, taking the defaults for any left out. A nil
+// *Limits has all the defaults.
+func (l *Limits) Limits() (limit.Limits, error) {
+	result := limit.Defaults
+	if l == nil {
+		return result, nil
+	}
+	for _, t := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"on_air", l.OnAir, &result.OnAir},
+		{"window", l.Window, &result.Window},
+		{"cool_down", l.CoolDown, &result.CoolDown},
+	} {
+		if t.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(t.value)
+		if err != nil || v < 0 {
+			return limit.Limits{}, fmt.Errorf("invalid %s %q", t.name, t.value)
+		}
+		*t.dst = v
+	}
+	if l.PerMinute != nil {
+		if *l.PerMinute < 0 {
+			return limit.Limits{}, fmt.Errorf("invalid per_minute %d", *l.PerMinute)
+		}
+		result.PerMinute = *l.PerMinute
+	}
+	if result.OnAir > 0 && result.Window == 0 {
+		return limit.Limits{}, fmt.Errorf("on_air needs a window")
+	}
+	return result, nil
+}
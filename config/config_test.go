@@ -0,0 +1,174 @@
+package config_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/waveform"
+)
+
+const example = `{
+  "openers": [
+    {
+      "name": "garage",
+      "protocol": "secplus-v2",
+      "fixed": "1222022221850123456789",
+      "rolling": "240124710",
+      "frequencies_mhz": [310, 315, 390],
+      "hop": "round",
+      "sensor": {"closed_pin": 17, "travel": "12s"}
+    },
+    {
+      "name": "gate",
+      "protocol": "megacode",
+      "kind": "gate",
+      "identifier": "0x876543",
+      "limits": {"per_minute": 2, "cool_down": "0s"}
+    }
+  ],
+  "schedule": [
+    {"opener": "garage", "action": "close", "at": "22:30", "days": ["sat", "sun"]},
+    {"opener": "garage", "action": "close", "open_for": "15m"},
+    {"opener": "gate", "action": "toggle", "at": "07:00"}
+  ],
+  "quiet_hours": [
+    {"hours": "23:00-06:00", "openers": ["gate"]}
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	c, err := config.Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	garage, err := c.Opener("garage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := garage.FixedCode()
+	if err != nil || fixed.String() != "1222022221850123456789" {
+		t.Errorf("want garage fixed code 1222022221850123456789; got %v, %v", fixed, err)
+	}
+	frequencies, hop, err := garage.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{310e6, 315e6, 390e6}; !reflect.DeepEqual(frequencies, want) || hop != waveform.HopRound {
+		t.Errorf("want plan %v hopping every round; got %v hopping every %v", want, frequencies, hop)
+	}
+
+	gate, err := c.Opener("gate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := gate.ID(); err != nil || id != 0x876543 {
+		t.Errorf("want gate identifier 0x876543; got 0x%x, %v", id, err)
+	}
+	if frequencies, hop, err := gate.Plan(); err != nil || frequencies != nil || hop != waveform.HopRepeat {
+		t.Errorf("want no frequency plan for gate; got %v, %v, %v", frequencies, hop, err)
+	}
+
+	if rolling, err := garage.RollingStart(); err != nil || rolling != 240124710 {
+		t.Errorf("want garage rolling code start 240124710; got %d, %v", rolling, err)
+	}
+	tx, err := garage.Transmission(240124711)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Protocol != "secplus-v2" || len(tx.Frequencies) != 3 || tx.Hop != waveform.HopRound {
+		t.Errorf("want secplus-v2 transmission on 3 frequencies hopping every round; got %s", tx)
+	}
+	if tx, err := gate.Transmission(0); err != nil || tx.Protocol != "megacode" {
+		t.Errorf("want megacode transmission for gate; got %v, %v", tx, err)
+	}
+
+	if garage.DeviceKind() != config.KindGarage || gate.DeviceKind() != config.KindGate {
+		t.Errorf("want garage and gate kinds; got %q and %q", garage.DeviceKind(), gate.DeviceKind())
+	}
+
+	d, err := garage.Sensor.Door()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Travel != 12*time.Second || d.Debounce != door.DefaultDebounce || garage.Sensor.ChipName() != "gpiochip0" || garage.Sensor.Pins()[door.ClosedSwitch] != 17 {
+		t.Errorf("want closed switch on gpiochip0 pin 17, with 12s travel; got %+v", garage.Sensor)
+	}
+	if gate.Sensor != nil {
+		t.Errorf("want no sensor for gate; got %+v", gate.Sensor)
+	}
+
+	at, err := c.Schedule[0].TimeOfDay()
+	days, _ := c.Schedule[0].Weekdays()
+	if err != nil || at != 22*time.Hour+30*time.Minute || len(days) != 2 || !days[time.Saturday] {
+		t.Errorf("want rule at 22:30 on weekends; got %v, %v, %v", at, days, err)
+	}
+	if d, err := c.Schedule[1].OpenDuration(); err != nil || d != 15*time.Minute {
+		t.Errorf("want rule after 15m open; got %v, %v", d, err)
+	}
+	night := time.Date(2026, 10, 19, 23, 30, 0, 0, time.Local)
+	if _, quiet := c.Quiet("gate", night); !quiet {
+		t.Errorf("want gate quiet at 23:30")
+	}
+	if _, quiet := c.Quiet("garage", night); quiet {
+		t.Errorf("want garage not quiet at 23:30")
+	}
+	if _, quiet := c.Quiet("gate", night.Add(8*time.Hour)); quiet {
+		t.Errorf("want gate not quiet at 07:30")
+	}
+
+	if l, err := garage.Limits.Limits(); err != nil || l != limit.Defaults {
+		t.Errorf("want default limits for garage; got %+v, %v", l, err)
+	}
+	want := limit.Defaults
+	want.PerMinute, want.CoolDown = 2, 0
+	if l, err := gate.Limits.Limits(); err != nil || l != want {
+		t.Errorf("want %+v for gate; got %+v, %v", want, l, err)
+	}
+
+	if _, err := c.Opener("shed"); err == nil {
+		t.Errorf("want error for unknown opener")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []string{
+		`{"openers": [{"protocol": "megacode", "identifier": "1"}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1"}, {"name": "a", "protocol": "megacode", "identifier": "2"}]}`,
+		`{"openers": [{"name": "a", "protocol": "secplus-v1"}]}`,
+		`{"openers": [{"name": "a", "protocol": "secplus-v2", "fixed": "0x1000000000000000000"}]}`,
+		`{"openers": [{"name": "a", "protocol": "secplus-v2", "fixed": "1", "rolling": "0x10000000"}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "0x1000000"}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "hop": "burst"}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "frequencies_mhz": [-1]}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "frequency": 318}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "kind": "shed"}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "sensor": {}}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "sensor": {"closed_pin": 4, "open_pin": 4}}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "sensor": {"closed_pin": 4, "travel": "slow"}}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "limits": {"on_air": "-1s"}}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "limits": {"per_minute": -1}}]}`,
+		`{"openers": [{"name": "a", "protocol": "megacode", "identifier": "1", "limits": {"window": "0s"}}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "shed", "action": "close", "at": "22:30"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "explode", "at": "22:30"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "close"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "toggle", "at": "25:00"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "toggle", "at": "22:30", "days": ["someday"]}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "close", "open_for": "15m"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1", "sensor": {"closed_pin": 4}}], "schedule": [{"opener": "gate", "action": "open", "open_for": "15m"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "close", "at": "22:30"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "schedule": [{"opener": "gate", "action": "open", "at": "07:00"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "quiet_hours": [{"hours": "late"}]}`,
+		`{"openers": [{"name": "gate", "protocol": "megacode", "identifier": "1"}], "quiet_hours": [{"hours": "23:00-06:00", "openers": ["shed"]}]}`,
+	}
+	for _, tt := range testcases {
+		if _, err := config.Parse(strings.NewReader(tt)); err == nil {
+			t.Errorf("want error parsing %s", tt)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Package config reads the openers configuration file, which describes each
+opener by name: its protocol, its codes, and the frequencies it listens on.
+
+The file is JSON, like this:
+
+	{
+	  "openers": [
+	    {
+	      "name": "garage",
+	      "protocol": "secplus-v2",
+	      "fixed": "1222022221850123456789",
+	      "rolling": "240124710",
+	      "frequencies_mhz": [310, 315, 390],
+	      "hop": "repeat",
+	      "sensor": {"closed_pin": 17, "open_pin": 27, "travel": "15s"}
+	    },
+	    {
+	      "name": "gate",
+	      "protocol": "megacode",
+	      "kind": "gate",
+	      "identifier": "0x876543",
+	      "frequencies_mhz": [318],
+	      "limits": {"per_minute": 2, "cool_down": "1m"}
+	    }
+	  ],
+	  "schedule": [
+	    {"opener": "garage", "action": "close", "at": "22:30"},
+	    {"opener": "garage", "action": "close", "open_for": "15m"}
+	  ],
+	  "quiet_hours": [
+	    {"hours": "23:00-06:00", "openers": ["gate"]}
+	  ]
+	}
+*/
+package config
@@ -0,0 +1,171 @@
+package demod
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Protocol names, as used in Message.Protocol.
+const (
+	SecplusV2 = secplus.Protocol
+	MegaCode  = megacode.Protocol
+)
+
+// burstGap is the shortest period of silence that separates two bursts. It is
+// longer than any gap within a MegaCode transmission (8ms), and shorter than
+// the gap between its repeats.
+const burstGap = 10 * time.Millisecond
+
+// Message is a single decoded transmission.
+type Message struct {
+	Start    time.Duration // Offset of the first pulse from the start of the input.
+	Protocol string        // SecplusV2 or MegaCode.
+
+	// Security+2.0 fields.
+	FixedHigh uint8
+	FixedLow  uint64
+	Rolling   uint32
+
+	// MegaCode fields.
+	ID uint32
+}
+
+// Fixed returns the full 72-bit fixed part of a Security+2.0 code.
+func (m Message) Fixed() *big.Int {
+	fixed := new(big.Int).SetUint64(uint64(m.FixedHigh))
+	fixed.Lsh(fixed, 64)
+	return fixed.Or(fixed, new(big.Int).SetUint64(m.FixedLow))
+}
+
+// String formats a message the way the corresponding commandline flags would
+// be written.
+func (m Message) String() string {
+	switch m.Protocol {
+	case SecplusV2:
+		return fmt.Sprintf("%s --fixed=%s --rolling=%d", m.Protocol, m.Fixed(), m.Rolling)
+	case MegaCode:
+		return fmt.Sprintf("%s --identifier=0x%06x", m.Protocol, m.ID)
+	}
+	return m.Protocol
+}
+
+// Decode returns all the transmissions found in pulses, in order.
+//
+// A Security+2.0 message is only returned if both of its halves were received
+// in consecutive bursts.
+func Decode(pulses []waveform.Pulse) []Message {
+	var result []Message
+
+	// The first half of a Security+2.0 message, waiting for its second half.
+	var pending *Message
+	var pendingPacket []byte
+
+	for _, segment := range waveform.Split(pulses, burstGap) {
+		if m, ok := decodeMegaCode(segment); ok {
+			pending = nil
+			result = append(result, m)
+			continue
+		}
+
+		frame, packet, err := decodeSecplusV2Burst(segment)
+		switch {
+		case err != nil:
+			pending = nil
+		case frame == 0:
+			pending = &Message{Start: segment.Start, Protocol: SecplusV2}
+			pendingPacket = packet
+		case frame == 1 && pending != nil:
+			m := *pending
+			pending = nil
+			m.FixedHigh, m.FixedLow, m.Rolling, err = secplus.DecodeV2([2][]byte{pendingPacket, packet})
+			if err == nil {
+				result = append(result, m)
+			}
+		}
+	}
+
+	return result
+}
+
+// decodeMegaCode tries to decode a burst as a MegaCode transmission.
+func decodeMegaCode(segment waveform.Segment) (Message, bool) {
+	// Every bit is six slots holding a single one-slot pulse, so pulses start
+	// 3, 6 or 9 slots apart, depending on the bits either side. Some
+	// identifiers have no 3-slot periods at all, so the slot width can't be
+	// told from the shortest one. Instead, measure from the start of the
+	// first pulse to the start of the last, which isn't affected by
+	// receivers stretching pulses at the expense of the gaps. The first bit
+	// is always a 1, so that spans six slots per bit after the first, less
+	// three if the last bit is a 0: close enough to round every gap right.
+	pulses := len(segment.Pulses)/2 + 1
+	if pulses < 2 {
+		return Message{}, false
+	}
+	span := waveform.Duration(segment.Pulses[:len(segment.Pulses)-1])
+	width := span / time.Duration(6*(pulses-1))
+	if width == 0 {
+		return Message{}, false
+	}
+	ID, err := megacode.Decode(waveform.ToBits(segment.Pulses, width))
+	if err != nil {
+		return Message{}, false
+	}
+	return Message{Start: segment.Start, Protocol: MegaCode, ID: ID}, true
+}
+
+// decodeSecplusV2Burst tries to decode a burst as one half of a Security+2.0
+// transmission.
+func decodeSecplusV2Burst(segment waveform.Segment) (int, []byte, error) {
+	// Manchester-coded pulses and gaps are each one or two slots long. Any
+	// stretching of the pulses by the receiver shortens the gaps by the same
+	// amount, so averaging the shortest of each cancels it out.
+	var highs, lows []time.Duration
+	for _, p := range segment.Pulses {
+		if p.Level == 1 {
+			highs = append(highs, p.Duration)
+		} else {
+			lows = append(lows, p.Duration)
+		}
+	}
+	width := (shortest(highs) + shortest(lows)) / 2
+	if width == 0 || len(lows) == 0 {
+		return 0, nil, fmt.Errorf("not enough pulses")
+	}
+	slots := waveform.ToBits(segment.Pulses, width)
+
+	// A burst ending in a Manchester-coded 0 loses its final carrier-off slot
+	// to the silence that follows.
+	if len(slots)%2 == 1 {
+		slots = append(slots, 0)
+	}
+	return secplus.DecodeV2Burst(slots)
+}
+
+// shortest returns the average of the shortest of durations, or 0 if there
+// are none.
+func shortest(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// Use a low percentile rather than the minimum, to ignore glitches.
+	base := sorted[len(sorted)/10]
+	var total time.Duration
+	var count time.Duration
+	for _, d := range sorted {
+		if d >= base/2 && d <= base*3/2 {
+			total += d
+			count++
+		}
+	}
+	return total / count
+}
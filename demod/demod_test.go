@@ -0,0 +1,108 @@
+package demod_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// wobble stretches every pulse by stretch at the expense of the gap that
+// follows it, the way a real receiver would, and adds some alternating jitter.
+func wobble(pulses []waveform.Pulse, stretch time.Duration, jitter time.Duration) []waveform.Pulse {
+	result := make([]waveform.Pulse, len(pulses))
+	copy(result, pulses)
+	for i := range result {
+		if result[i].Level == 1 && i+1 < len(result) {
+			result[i].Duration += stretch
+			result[i+1].Duration -= stretch
+		}
+		if i%3 == 0 {
+			result[i].Duration += jitter
+		} else {
+			result[i].Duration -= jitter / 2
+		}
+	}
+	return result
+}
+
+func silence(d time.Duration) []waveform.Pulse {
+	return []waveform.Pulse{{Level: 0, Duration: d}}
+}
+
+func TestDecode(t *testing.T) {
+	const fixed = 4616223061045564932096
+	bursts, err := secplus.EncodeV2ToBursts(fixed>>64, fixed&(1<<64-1), 240129675)
+	if err != nil {
+		t.Fatal(err)
+	}
+	megabits, err := megacode.Encode(0x876543)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pulses []waveform.Pulse
+	pulses = append(pulses, silence(50*time.Millisecond)...)
+	// A lone second half, which should be ignored.
+	pulses = append(pulses, wobble(waveform.FromBits(bursts[1], 250*time.Microsecond), 60*time.Microsecond, 15*time.Microsecond)...)
+	pulses = append(pulses, silence(90*time.Millisecond)...)
+	secplusStart := waveform.Duration(pulses)
+	pulses = append(pulses, wobble(waveform.FromBits(bursts[0], 250*time.Microsecond), 60*time.Microsecond, 15*time.Microsecond)...)
+	pulses = append(pulses, silence(90*time.Millisecond)...)
+	pulses = append(pulses, wobble(waveform.FromBits(bursts[1], 250*time.Microsecond), 60*time.Microsecond, 15*time.Microsecond)...)
+	pulses = append(pulses, silence(90*time.Millisecond)...)
+	// A noise spike.
+	pulses = append(pulses, waveform.Pulse{Level: 1, Duration: 30 * time.Microsecond})
+	pulses = append(pulses, silence(40*time.Millisecond)...)
+	megaStart := waveform.Duration(pulses)
+	// MegaCode's leading silence merges with the gap before it.
+	pulses = append(pulses, wobble(waveform.FromBits(megabits, time.Millisecond), 150*time.Microsecond, 50*time.Microsecond)[1:]...)
+	pulses = append(pulses, silence(11*time.Millisecond)...)
+
+	got := demod.Decode(pulses)
+	want := []demod.Message{
+		{
+			Start:     secplusStart,
+			Protocol:  demod.SecplusV2,
+			FixedHigh: fixed >> 64,
+			FixedLow:  fixed & (1<<64 - 1),
+			Rolling:   240129675,
+		},
+		{
+			Start:    megaStart,
+			Protocol: demod.MegaCode,
+			ID:       0x876543,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want Decode(...)==%v; got %v", want, got)
+	}
+
+	if s := got[0].String(); s != "secplus-v2 --fixed=4616223061045564932096 --rolling=240129675" {
+		t.Errorf("unexpected String() for %v: %q", got[0], s)
+	}
+}
+
+func TestDecodeMegaCode(t *testing.T) {
+	// Identifiers whose pulses are never, or hardly ever, 3 slots apart, as
+	// well as one that has plenty.
+	for _, id := range []uint32{0x876543, 0x800000, 0xffffff, 0x800001, 0xc00000} {
+		bits, err := megacode.Encode(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stretch := range []time.Duration{0, 150 * time.Microsecond} {
+			pulses := silence(20 * time.Millisecond)
+			pulses = append(pulses, wobble(waveform.FromBits(bits, time.Millisecond), stretch, 50*time.Microsecond)...)
+			pulses = append(pulses, silence(20*time.Millisecond)...)
+			got := demod.Decode(pulses)
+			if len(got) != 1 || got[0].Protocol != demod.MegaCode || got[0].ID != id {
+				t.Errorf("want 0x%06x decoded with %v stretch; got %v", id, stretch, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+/*
+Package demod finds and decodes Security+2.0 and MegaCode transmissions in a
+stream of pulses, such as one sliced from a radio recording.
+
+Pulses are split into bursts at long periods of silence. Each protocol's
+decoder then estimates the pulse width of a burst from the timings it knows
+must be a whole number of pulses long, quantizes the burst into a bitstream,
+and tries to decode it.
+*/
+package demod
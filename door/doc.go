@@ -0,0 +1,15 @@
+/*
+Package door follows a door's position using its limit switches: reed or
+tilt switches that close when the door is fully closed or fully open.
+
+A door can have either switch, or both. Its state is derived from debounced
+switch transitions and the time since the door left a limit: a door that left
+the closed switch is opening until its travel time is up, and after that is
+open if there is no open switch to say otherwise, or stopped part way if
+there is.
+
+Switch changes arrive as edge events timestamped by the kernel, as from GPIO
+inputs. Traces of events can be recorded and replayed, which is how the model
+is tested.
+*/
+package door
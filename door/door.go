@@ -0,0 +1,195 @@
+package door
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/gpiod"
+)
+
+// Defaults for a door's timing.
+const (
+	DefaultDebounce = 50 * time.Millisecond
+	DefaultTravel   = 15 * time.Second
+)
+
+// State is where a door is.
+type State string
+
+// Door states.
+const (
+	Unknown State = "unknown"
+	Open    State = "open"
+	Closed  State = "closed"
+	Opening State = "opening"
+	Closing State = "closing"
+	Stopped State = "stopped" // Left one limit, and didn't reach the other in time.
+)
+
+// Switch is a limit switch.
+type Switch int
+
+// Limit switches.
+const (
+	ClosedSwitch Switch = iota // Active when the door is fully closed.
+	OpenSwitch                 // Active when the door is fully open.
+)
+
+func (s Switch) String() string {
+	if s == OpenSwitch {
+		return "open"
+	}
+	return "closed"
+}
+
+// ParseSwitch parses the name of a switch, "closed" or "open".
+func ParseSwitch(s string) (Switch, error) {
+	switch s {
+	case "closed":
+		return ClosedSwitch, nil
+	case "open":
+		return OpenSwitch, nil
+	}
+	return 0, fmt.Errorf("unknown switch %q; expected closed or open", s)
+}
+
+// Event is a change in a switch.
+type Event struct {
+	Time   time.Duration // On the clock GPIO events use; see Now.
+	Switch Switch
+	Active bool
+}
+
+// LineEvent converts a GPIO line event from a switch's input, which is high
+// when the switch is active if activeHigh is set, and low otherwise.
+func LineEvent(sw Switch, activeHigh bool, evt gpiod.LineEvent) Event {
+	rising := evt.Type == gpiod.LineEventRisingEdge
+	return Event{Time: evt.Timestamp, Switch: sw, Active: rising == activeHigh}
+}
+
+// Bias returns the pull to request on a switch's input, so it reads
+// inactive while the switch is open: up for a switch to ground, or down for
+// an active-high one.
+func Bias(activeHigh bool) gpiod.LineReqOption {
+	if activeHigh {
+		return gpiod.WithPullDown
+	}
+	return gpiod.WithPullUp
+}
+
+// input is the debounced state of a switch.
+type input struct {
+	present bool
+	active  bool          // Settled level.
+	changed time.Duration // When it settled there.
+	seen    bool          // Whether it has changed since the initial level.
+	raw     bool          // Latest level, maybe still bouncing.
+	rawAt   time.Duration
+}
+
+// settle accepts the latest level if it has held for the debounce time.
+func (in *input) settle(now, debounce time.Duration) {
+	if in.raw != in.active && now-in.rawAt >= debounce {
+		in.active, in.changed, in.seen = in.raw, in.rawAt, true
+	}
+}
+
+// Door is the model of a door with one or both limit switches. It is safe
+// for concurrent use.
+type Door struct {
+	// Debounce is how long a switch must hold a new level to count.
+	Debounce time.Duration
+	// Travel is how long the door takes to open or close.
+	Travel time.Duration
+
+	mu     sync.Mutex
+	inputs [2]input
+}
+
+// New returns a door with the given switches, both of them inactive until
+// set or changed.
+func New(switches ...Switch) *Door {
+	d := &Door{Debounce: DefaultDebounce, Travel: DefaultTravel}
+	for _, sw := range switches {
+		d.inputs[sw].present = true
+	}
+	return d
+}
+
+// Set sets the initial level of a switch, as read when starting.
+func (d *Door) Set(sw Switch, active bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	in := &d.inputs[sw]
+	in.active, in.raw = active, active
+}
+
+// Handle applies a switch change. Events for each switch must arrive in
+// order.
+func (d *Door) Handle(e Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	in := &d.inputs[e.Switch]
+	in.settle(e.Time, d.Debounce)
+	in.raw, in.rawAt = e.Active, e.Time
+}
+
+// Status is a door's state, and since when.
+type Status struct {
+	State State
+	// Since is when the door got to this state, on the clock GPIO events
+	// use. It is only known if it happened after the door was first
+	// seen.
+	Since      time.Duration
+	SinceKnown bool
+}
+
+// Status returns the door's state at the given time.
+func (d *Door) Status(now time.Duration) Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	closed, open := &d.inputs[ClosedSwitch], &d.inputs[OpenSwitch]
+	closed.settle(now, d.Debounce)
+	open.settle(now, d.Debounce)
+
+	switch {
+	case closed.present && closed.active:
+		return Status{Closed, closed.changed, closed.seen}
+	case open.present && open.active:
+		return Status{Open, open.changed, open.seen}
+	}
+
+	// Between the limits: moving away from whichever the door left last,
+	// if it was seen to leave.
+	left := closed
+	from, moving, arrived := ClosedSwitch, Opening, Open
+	if !closed.seen || (open.seen && open.changed > closed.changed) {
+		left = open
+		from, moving, arrived = OpenSwitch, Closing, Closed
+	}
+	if !left.seen {
+		// Not seen to move. One switch says which side of it the door
+		// is; two say it stopped somewhere in between.
+		switch {
+		case closed.present && !open.present:
+			return Status{State: Open}
+		case open.present && !closed.present:
+			return Status{State: Closed}
+		}
+		return Status{State: Unknown}
+	}
+	end := left.changed + d.Travel
+	switch {
+	case now < end:
+		return Status{moving, left.changed, true}
+	case d.inputs[1-from].present:
+		return Status{Stopped, end, true}
+	}
+	return Status{arrived, end, true}
+}
+
+// State returns the door's state at the given time.
+func (d *Door) State(now time.Duration) State {
+	return d.Status(now).State
+}
@@ -0,0 +1,144 @@
+package door_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/gpiod"
+)
+
+// loadTrace reads a trace from testdata.
+func loadTrace(t *testing.T, name string) *door.Trace {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr, err := door.ParseTrace(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return tr
+}
+
+func TestTraces(t *testing.T) {
+	s := time.Second
+	type check struct {
+		at    time.Duration
+		want  door.State
+		since time.Duration // -1 if unknown.
+	}
+	testcases := []struct {
+		trace  string
+		checks []check
+	}{
+		{"open-one-switch.trace", []check{
+			{0, door.Closed, -1},
+			{2010 * time.Millisecond, door.Closed, -1}, // Still bouncing.
+			{3 * s, door.Opening, 2011 * time.Millisecond},
+			{17 * s, door.Opening, 2011 * time.Millisecond},
+			{18 * s, door.Open, 17011 * time.Millisecond},
+		}},
+		{"cycle-two-switches.trace", []check{
+			{1 * s, door.Closed, -1},
+			{5 * s, door.Opening, 1270 * time.Millisecond},
+			{14 * s, door.Open, 13941 * time.Millisecond},
+			{80 * s, door.Closing, 75 * s},
+			{89 * s, door.Closed, 88400 * time.Millisecond},
+		}},
+		{"stopped.trace", []check{
+			{10 * s, door.Opening, 800 * time.Millisecond},
+			{20 * s, door.Stopped, 15800 * time.Millisecond},
+		}},
+		{"bump.trace", []check{
+			{5010 * time.Millisecond, door.Closed, -1},
+			{6 * s, door.Closed, -1},
+		}},
+	}
+	for _, tt := range testcases {
+		tr := loadTrace(t, tt.trace)
+		d := tr.Door()
+		events := tr.Events
+		for _, c := range tt.checks {
+			for len(events) > 0 && events[0].Time <= c.at {
+				d.Handle(events[0])
+				events = events[1:]
+			}
+			st := d.Status(c.at)
+			since := st.Since
+			if !st.SinceKnown {
+				since = -1
+			}
+			if st.State != c.want || since != c.since {
+				t.Errorf("%s at %v: want %s since %v; got %s since %v", tt.trace, c.at, c.want, c.since, st.State, since)
+			}
+		}
+	}
+}
+
+func TestInitialState(t *testing.T) {
+	testcases := []struct {
+		switches []door.Switch
+		active   map[door.Switch]bool
+		want     door.State
+	}{
+		{[]door.Switch{door.ClosedSwitch}, nil, door.Open},
+		{[]door.Switch{door.OpenSwitch}, nil, door.Closed},
+		{[]door.Switch{door.ClosedSwitch, door.OpenSwitch}, nil, door.Unknown},
+		{[]door.Switch{door.ClosedSwitch, door.OpenSwitch}, map[door.Switch]bool{door.OpenSwitch: true}, door.Open},
+		{nil, nil, door.Unknown},
+	}
+	for _, tt := range testcases {
+		d := door.New(tt.switches...)
+		for sw, active := range tt.active {
+			d.Set(sw, active)
+		}
+		if got := d.State(time.Hour); got != tt.want {
+			t.Errorf("%v with %v active: want %s; got %s", tt.switches, tt.active, tt.want, got)
+		}
+	}
+}
+
+func TestLineEvent(t *testing.T) {
+	evt := gpiod.LineEvent{Timestamp: time.Second, Type: gpiod.LineEventFallingEdge}
+	// A switch to ground, with a pull-up, goes low when it closes.
+	if e := door.LineEvent(door.ClosedSwitch, false, evt); !e.Active || e.Time != time.Second {
+		t.Errorf("want falling edge active for active-low switch; got %v", e)
+	}
+	if e := door.LineEvent(door.OpenSwitch, true, evt); e.Active || e.Switch != door.OpenSwitch {
+		t.Errorf("want falling edge inactive for active-high switch; got %v", e)
+	}
+}
+
+func TestBias(t *testing.T) {
+	if got := door.Bias(false); got != gpiod.WithPullUp {
+		t.Errorf("want pull-up for active-low switch; got %v", got)
+	}
+	if got := door.Bias(true); got != gpiod.WithPullDown {
+		t.Errorf("want pull-down for active-high switch; got %v", got)
+	}
+}
+
+func TestParseTrace(t *testing.T) {
+	e := door.Event{Time: 1250 * time.Millisecond, Switch: door.OpenSwitch, Active: true}
+	tr, err := door.ParseTrace(strings.NewReader(door.Initial(door.ClosedSwitch, true) + "\n" + e.String() + "\n"))
+	if err != nil || len(tr.Events) != 1 || tr.Events[0] != e || !tr.Initial[door.ClosedSwitch] || len(tr.Switches) != 2 {
+		t.Errorf("want trace back; got %+v, %v", tr, err)
+	}
+	for _, bad := range []string{
+		"1s closed",
+		"1s ajar active",
+		"1s closed maybe",
+		"soon closed active",
+		"2s closed active\n1s closed inactive",
+	} {
+		if _, err := door.ParseTrace(strings.NewReader(bad)); err == nil {
+			t.Errorf("want error parsing %q", bad)
+		}
+	}
+}
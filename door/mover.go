@@ -0,0 +1,153 @@
+package door
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Defaults for moving doors.
+const (
+	DefaultRetries = 2
+	DefaultGrace   = 2 * time.Second
+)
+
+// movePoll is how often a Mover looks at the door.
+const movePoll = 100 * time.Millisecond
+
+// Clock tells the time on the clock GPIO events use, and waits.
+type Clock interface {
+	Now() time.Duration
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the real clock.
+type realClock struct{}
+
+func (realClock) Now() time.Duration { return Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MoveError is returned when a door doesn't reach its target.
+type MoveError struct {
+	Target State
+	State  State
+	Sent   int
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("door is %s, not %s, after %d transmissions", e.State, e.Target, e.Sent)
+}
+
+// Mover moves a door to a target state using an opener that can only
+// toggle it, watching its switches to see what happened.
+type Mover struct {
+	Door *Door
+	// Toggle sends the opener's transmission once. Each call should use a
+	// fresh rolling code.
+	Toggle func(ctx context.Context) error
+	// Retries is how many more times to transmit when the door doesn't
+	// move, or moves the wrong way.
+	Retries int
+	// Grace is how long past its travel time the door may take to move.
+	Grace time.Duration
+	// Clock, if not nil, replaces the real clock.
+	Clock Clock
+	// Log, if not nil, gets a line for each transmission and what the door
+	// did.
+	Log io.Writer
+}
+
+// NewMover returns a mover for a door, toggling it with toggle.
+func NewMover(d *Door, toggle func(ctx context.Context) error) *Mover {
+	return &Mover{Door: d, Toggle: toggle, Retries: DefaultRetries, Grace: DefaultGrace}
+}
+
+func (m *Mover) clock() Clock {
+	if m.Clock == nil {
+		return realClock{}
+	}
+	return m.Clock
+}
+
+func (m *Mover) logf(format string, args ...interface{}) {
+	if m.Log != nil {
+		fmt.Fprintf(m.Log, format+"\n", args...)
+	}
+}
+
+// MoveTo moves the door to target, Open or Closed, transmitting only if it
+// isn't there or on its way already. It returns the number of
+// transmissions sent, and a *MoveError if the door didn't get there.
+func (m *Mover) MoveTo(ctx context.Context, target State) (int, error) {
+	if target != Open && target != Closed {
+		return 0, fmt.Errorf("cannot move a door to %s", target)
+	}
+	sent := 0
+	for {
+		st, err := m.settle(ctx)
+		if err != nil {
+			return sent, err
+		}
+		if st == target {
+			return sent, nil
+		}
+		if sent > m.Retries {
+			return sent, &MoveError{Target: target, State: st, Sent: sent}
+		}
+		m.logf("Door is %s; transmitting", st)
+		if err := m.Toggle(ctx); err != nil {
+			return sent, err
+		}
+		sent++
+		changed, err := m.waitChange(ctx, st)
+		if err != nil {
+			return sent, err
+		}
+		if !changed {
+			m.logf("Door did not move")
+		}
+	}
+}
+
+// settle waits for the door to stop moving, returning where it ended up.
+func (m *Mover) settle(ctx context.Context) (State, error) {
+	c := m.clock()
+	for {
+		st := m.Door.State(c.Now())
+		if st != Opening && st != Closing {
+			return st, nil
+		}
+		if err := c.Sleep(ctx, movePoll); err != nil {
+			return st, err
+		}
+	}
+}
+
+// waitChange waits for the door to leave a state, for up to its travel
+// time and the grace period: with one switch, leaving the far limit is only
+// seen on arrival.
+func (m *Mover) waitChange(ctx context.Context, from State) (bool, error) {
+	c := m.clock()
+	deadline := c.Now() + m.Door.Travel + m.Grace
+	for c.Now() < deadline {
+		if err := c.Sleep(ctx, movePoll); err != nil {
+			return false, err
+		}
+		if st := m.Door.State(c.Now()); st != from {
+			m.logf("Door is %s", st)
+			return true, nil
+		}
+	}
+	return false, nil
+}
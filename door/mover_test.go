@@ -0,0 +1,122 @@
+package door_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/door"
+)
+
+// garage simulates a garage door with limit switches, driven by a fake
+// clock.
+type garage struct {
+	door     *door.Door
+	now      time.Duration
+	pending  []door.Event
+	position door.State // Open, Closed, or Stopped.
+	up       bool       // Last direction of travel.
+	ignore   int        // Toggles to miss, as if out of range.
+	toggles  int
+}
+
+func newGarage(position door.State, switches ...door.Switch) *garage {
+	g := &garage{door: door.New(switches...), position: position}
+	g.door.Travel = 10 * time.Second
+	g.door.Set(door.ClosedSwitch, position == door.Closed)
+	g.door.Set(door.OpenSwitch, position == door.Open)
+	if position == door.Stopped {
+		// Stopped part way, seen moving before that.
+		g.door.Handle(door.Event{Time: 0, Switch: door.ClosedSwitch})
+		g.now = time.Minute
+	}
+	return g
+}
+
+// missing makes the garage miss the next n toggles.
+func (g *garage) missing(n int) *garage {
+	g.ignore = n
+	return g
+}
+
+func (g *garage) Now() time.Duration { return g.now }
+
+func (g *garage) Sleep(ctx context.Context, d time.Duration) error {
+	g.now += d
+	for len(g.pending) > 0 && g.pending[0].Time <= g.now {
+		g.door.Handle(g.pending[0])
+		g.pending = g.pending[1:]
+	}
+	return nil
+}
+
+// toggle moves the door the way a real opener does: a stopped door
+// reverses.
+func (g *garage) toggle(ctx context.Context) error {
+	g.toggles++
+	if g.ignore > 0 {
+		g.ignore--
+		return nil
+	}
+	switch g.position {
+	case door.Closed:
+		g.up = true
+	case door.Open:
+		g.up = false
+	default:
+		g.up = !g.up
+	}
+	leave, arrive := door.OpenSwitch, door.ClosedSwitch
+	g.position = door.Closed
+	if g.up {
+		leave, arrive = door.ClosedSwitch, door.OpenSwitch
+		g.position = door.Open
+	}
+	g.pending = append(g.pending,
+		door.Event{Time: g.now + 500*time.Millisecond, Switch: leave, Active: false},
+		door.Event{Time: g.now + 9*time.Second, Switch: arrive, Active: true},
+	)
+	return nil
+}
+
+func TestMoveTo(t *testing.T) {
+	both := []door.Switch{door.ClosedSwitch, door.OpenSwitch}
+	testcases := []struct {
+		name     string
+		g        *garage
+		target   door.State
+		wantSent int
+		wantErr  bool
+	}{
+		{"already closed", newGarage(door.Closed, both...), door.Closed, 0, false},
+		{"open", newGarage(door.Closed, both...), door.Open, 1, false},
+		{"close", newGarage(door.Open, both...), door.Closed, 1, false},
+		{"close with closed switch only", newGarage(door.Open, door.ClosedSwitch), door.Closed, 1, false},
+		{"open with open switch only", newGarage(door.Closed, door.OpenSwitch), door.Open, 1, false},
+		{"missed first", newGarage(door.Closed, both...).missing(1), door.Open, 2, false},
+		{"stopped, reverses the wrong way", newGarage(door.Stopped, both...), door.Closed, 2, false},
+		{"jammed", newGarage(door.Closed, both...).missing(10), door.Open, 3, true},
+	}
+
+	for _, tt := range testcases {
+		m := door.NewMover(tt.g.door, tt.g.toggle)
+		m.Clock = tt.g
+		sent, err := m.MoveTo(context.Background(), tt.target)
+		var me *door.MoveError
+		if (err != nil) != tt.wantErr || (err != nil && !errors.As(err, &me)) {
+			t.Errorf("%s: want error %v; got %v", tt.name, tt.wantErr, err)
+		}
+		if sent != tt.wantSent || tt.g.toggles != tt.wantSent {
+			t.Errorf("%s: want %d transmissions; got %d (%d toggles)", tt.name, tt.wantSent, sent, tt.g.toggles)
+		}
+		if !tt.wantErr && tt.g.door.State(tt.g.now) != tt.target {
+			t.Errorf("%s: want door %s; got %s", tt.name, tt.target, tt.g.door.State(tt.g.now))
+		}
+	}
+
+	m := door.NewMover(door.New(door.ClosedSwitch), nil)
+	if _, err := m.MoveTo(context.Background(), door.Stopped); err == nil {
+		t.Errorf("want error moving to stopped")
+	}
+}
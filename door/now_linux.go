@@ -0,0 +1,17 @@
+package door
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Now returns the current time on the clock the kernel timestamps GPIO
+// events with: CLOCK_MONOTONIC.
+func Now() time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		panic(err)
+	}
+	return time.Duration(ts.Nano())
+}
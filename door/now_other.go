@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package door
+
+import "time"
+
+var start = time.Now()
+
+// Now returns the time since the process started, standing in for the clock
+// the kernel timestamps GPIO events with on Linux.
+func Now() time.Duration {
+	return time.Since(start)
+}
@@ -0,0 +1,99 @@
+package door
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A trace is a recording of switch events, one per line, like
+//
+//	12.5s closed inactive
+//
+// giving the time since recording started, the switch and its new level.
+// Blank lines and lines starting with # are ignored. Lines starting with
+// "initial" give a switch's level when recording started:
+//
+//	initial closed active
+
+// String formats the event as a line of a trace.
+func (e Event) String() string {
+	return fmt.Sprintf("%v %s %s", e.Time, e.Switch, level(e.Active))
+}
+
+func level(active bool) string {
+	if active {
+		return "active"
+	}
+	return "inactive"
+}
+
+// Initial formats a switch's initial level as a line of a trace.
+func Initial(sw Switch, active bool) string {
+	return fmt.Sprintf("initial %s %s", sw, level(active))
+}
+
+// Trace is a parsed trace.
+type Trace struct {
+	Switches []Switch        // The switches that appear.
+	Initial  map[Switch]bool // Initial levels.
+	Events   []Event
+}
+
+// ParseTrace reads a trace.
+func ParseTrace(r io.Reader) (*Trace, error) {
+	t := &Trace{Initial: map[Switch]bool{}}
+	seen := map[Switch]bool{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want time, switch and level; got %q", n, line)
+		}
+		sw, err := ParseSwitch(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		var active bool
+		switch fields[2] {
+		case "active":
+			active = true
+		case "inactive":
+		default:
+			return nil, fmt.Errorf("line %d: unknown level %q; expected active or inactive", n, fields[2])
+		}
+		if !seen[sw] {
+			seen[sw] = true
+			t.Switches = append(t.Switches, sw)
+		}
+		if fields[0] == "initial" {
+			t.Initial[sw] = active
+			continue
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if len(t.Events) > 0 && at < t.Events[len(t.Events)-1].Time {
+			return nil, fmt.Errorf("line %d: events out of order", n)
+		}
+		t.Events = append(t.Events, Event{Time: at, Switch: sw, Active: active})
+	}
+	return t, s.Err()
+}
+
+// Door returns a door with the trace's switches, set to their initial
+// levels.
+func (t *Trace) Door() *Door {
+	d := New(t.Switches...)
+	for sw, active := range t.Initial {
+		d.Set(sw, active)
+	}
+	return d
+}
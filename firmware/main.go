@@ -0,0 +1,167 @@
+//go:build tinygo
+// +build tinygo
+
+/*
+Command firmware is openers for a microcontroller, such as an ESP32, built
+with TinyGo: it keys a transmitter module when a button is pressed.
+
+The codes to send are set at build time, for example:
+
+	tinygo flash -target=esp32-coreboard-v2 \
+		-ldflags="-X main.fixed=70678577664 -X main.rolling=240124710" \
+		./firmware
+
+The rolling code is kept in RAM, and increases with each press. It starts
+over from main.rolling after a reset, so reflash with a higher value (by at
+least the number of presses since the last flash) to keep the opener
+accepting it.
+*/
+package main
+
+import (
+	"machine"
+	"math/bits"
+	"strconv"
+	"time"
+
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+)
+
+// Settings, overridden at build time with -ldflags="-X main.name=value".
+var (
+	protocol   = secplus.Protocol // secplus-v2 or megacode.
+	fixed      = "0"              // Security+2.0 fixed code, up to 72 bits.
+	rolling    = "0"              // Security+2.0 rolling code to start from.
+	identifier = "0"              // MegaCode identifier.
+)
+
+// Pins: the BOOT button found on most ESP32 boards, which pulls the pin low
+// when pressed, and the data pin of the transmitter module.
+const (
+	buttonPin   = machine.GPIO0
+	transmitPin = machine.GPIO4
+)
+
+// Timing, matching the defaults of the openers command.
+const (
+	secplusPulsewidth  = 250 * time.Microsecond
+	secplusGap         = 90 * time.Millisecond
+	megacodePulsewidth = time.Millisecond
+	repeats            = 4
+)
+
+func main() {
+	buttonPin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	transmitPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	transmitPin.Low()
+
+	fixedHigh, fixedLow, ok := parseFixed(fixed)
+	if !ok {
+		fail("bad fixed code: " + fixed)
+	}
+	next, err := strconv.ParseUint(rolling, 0, 28)
+	if err != nil {
+		fail("bad rolling code: " + rolling)
+	}
+	id, err := strconv.ParseUint(identifier, 0, 24)
+	if err != nil {
+		fail("bad identifier: " + identifier)
+	}
+
+	// Buffers for the encoded bits, allocated once.
+	var bursts [2][secplus.MaxBurstBits]byte
+	var slots [megacode.EncodedBits]byte
+
+	println("openers ready:", protocol)
+	for {
+		waitForPress()
+		switch protocol {
+		case megacode.Protocol:
+			if err := megacode.EncodeInto(&slots, uint32(id)); err != nil {
+				fail(err.Error())
+			}
+			for i := 0; i < repeats; i++ {
+				send(slots[:], megacodePulsewidth)
+				wait(megacodePulsewidth * 6)
+			}
+		default:
+			n, err := secplus.EncodeV2BurstsInto(&bursts, fixedHigh, fixedLow, uint32(next))
+			if err != nil {
+				fail(err.Error())
+			}
+			for i := 0; i < repeats; i++ {
+				if i > 0 {
+					wait(secplusGap)
+				}
+				send(bursts[0][:n], secplusPulsewidth)
+				wait(secplusGap)
+				send(bursts[1][:n], secplusPulsewidth)
+			}
+			println("sent rolling code", next)
+			next++
+		}
+	}
+}
+
+// waitForPress waits for the button to be pressed and released, ignoring
+// contact bounce.
+func waitForPress() {
+	for buttonPin.Get() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	for !buttonPin.Get() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// send sets the transmit pin to each bit in turn, busy-waiting so that each
+// change lands on time regardless of how long the previous one took, and
+// leaves it low.
+func send(slots []byte, pulsewidth time.Duration) {
+	start := time.Now()
+	for i, b := range slots {
+		transmitPin.Set(b != 0)
+		deadline := start.Add(time.Duration(i+1) * pulsewidth)
+		for time.Now().Before(deadline) {
+		}
+	}
+	transmitPin.Low()
+}
+
+// wait busy-waits for d, to keep gaps as accurate as the bits around them.
+func wait(d time.Duration) {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+	}
+}
+
+// parseFixed parses a decimal fixed code of up to 72 bits, without math/big.
+func parseFixed(s string) (uint8, uint64, bool) {
+	if s == "" {
+		return 0, 0, false
+	}
+	var high, low uint64
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, 0, false
+		}
+		hi, lo := bits.Mul64(low, 10)
+		lo, carry := bits.Add64(lo, uint64(c-'0'), 0)
+		high = high*10 + hi + carry
+		low = lo
+		if high > 0xFF {
+			return 0, 0, false
+		}
+	}
+	return uint8(high), low, true
+}
+
+// fail reports a fatal configuration error forever, on the serial console.
+func fail(msg string) {
+	for {
+		println("openers:", msg)
+		time.Sleep(time.Second)
+	}
+}
@@ -5,4 +5,6 @@ go 1.16
 require (
 	github.com/alecthomas/kong v0.2.17
 	github.com/warthog618/gpiod v0.6.0
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae
 )
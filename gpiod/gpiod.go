@@ -3,7 +3,10 @@
 //go:build !linux
 package gpiod
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // IsChip checks if the named device is an accessible GPIO character device.
 //
@@ -37,6 +40,69 @@ func AsOutput(values ...int) OutputOption {
 // OutputOption indicates the line direction should be set to an output.
 type OutputOption []int
 
+// AsInput indicates that a line be requested as an input.
+//
+// This option overrides and clears any previous Output, OpenDrain, or
+// OpenSource options.
+var AsInput = InputOption{}
+
+// InputOption indicates the line direction should be set to an input.
+type InputOption struct{}
+
+// WithBothEdges indicates that a line will generate events when its active
+// state transitions from low to high and from high to low.
+//
+// Events are forwarded to the provided handler function.
+var WithBothEdges = EdgeOption{}
+
+// EdgeOption indicates how a line is to be configured for edge detection.
+type EdgeOption struct{}
+
+// WithEventHandler indicates that a line will generate events when its active
+// state transitions, and that the events are forwarded to the provided handler
+// function.
+func WithEventHandler(e func(LineEvent)) EventHandlerOption {
+	return EventHandlerOption{}
+}
+
+// EventHandlerOption provides the handler for events on requested lines.
+type EventHandlerOption struct{}
+
+// WithPullUp indicates that a line have its internal pull-up enabled.
+var WithPullUp = BiasOption{pullUp: true}
+
+// WithPullDown indicates that a line have its internal pull-down enabled.
+var WithPullDown = BiasOption{pullDown: true}
+
+// BiasOption indicates how a line is to be biased.
+type BiasOption struct {
+	pullUp, pullDown bool
+}
+
+// LineEventType indicates the type of change to the line active state.
+type LineEventType int
+
+const (
+	_ LineEventType = iota
+	// LineEventRisingEdge indicates an inactive to active event.
+	LineEventRisingEdge
+
+	// LineEventFallingEdge indicates an active to inactive event.
+	LineEventFallingEdge
+)
+
+// LineEvent represents a change in the state of a line.
+type LineEvent struct {
+	// The line offset within the GPIO chip.
+	Offset int
+
+	// Timestamp indicates the time the event was detected.
+	Timestamp time.Duration
+
+	// The type of state change event this structure represents.
+	Type LineEventType
+}
+
 // ChipOption defines the interface required to provide a Chip option.
 type ChipOption interface{}
 
@@ -71,6 +137,11 @@ func (l *Line) SetValue(value int) error {
 	return fmt.Errorf("this is just a fake; SetValue is not supported except on Linux")
 }
 
+// Value returns the current value (active state) of the line.
+func (l *Line) Value() (int, error) {
+	return 0, fmt.Errorf("this is just a fake; Value is not supported except on Linux")
+}
+
 // Close releases all resources held by the requested line.
 //
 // Note that this includes waiting for any running event handler to return.
@@ -4,6 +4,35 @@ import (
 	realgpiod "github.com/warthog618/gpiod"
 )
 
+// LineEvent represents a change in the state of a line.
+type LineEvent = realgpiod.LineEvent
+
+// LineEventType indicates the type of change to the line active state.
+type LineEventType = realgpiod.LineEventType
+
+const (
+	// LineEventRisingEdge indicates an inactive to active event.
+	LineEventRisingEdge = realgpiod.LineEventRisingEdge
+
+	// LineEventFallingEdge indicates an active to inactive event.
+	LineEventFallingEdge = realgpiod.LineEventFallingEdge
+)
+
+// Line represents a single requested line.
+type Line = realgpiod.Line
+
+// AsInput indicates that a line be requested as an input.
+//
+// This option overrides and clears any previous Output, OpenDrain, or
+// OpenSource options.
+var AsInput = realgpiod.AsInput
+
+// WithBothEdges indicates that a line will generate events when its active
+// state transitions from low to high and from high to low.
+//
+// Events are forwarded to the provided handler function.
+var WithBothEdges = realgpiod.WithBothEdges
+
 // IsChip checks if the named device is an accessible GPIO character device.
 //
 // Returns an error if not.
@@ -32,3 +61,19 @@ func NewChip(name string, options ...realgpiod.ChipOption) (*realgpiod.Chip, err
 func AsOutput(values ...int) realgpiod.OutputOption {
 	return realgpiod.AsOutput(values...)
 }
+
+// WithEventHandler indicates that a line will generate events when its active
+// state transitions, and that the events are forwarded to the provided handler
+// function.
+func WithEventHandler(e func(LineEvent)) realgpiod.EventHandlerOption {
+	return realgpiod.WithEventHandler(e)
+}
+
+// LineReqOption is an option for a line request.
+type LineReqOption = realgpiod.LineReqOption
+
+// WithPullUp indicates that a line have its internal pull-up enabled.
+var WithPullUp = realgpiod.WithPullUp
+
+// WithPullDown indicates that a line have its internal pull-down enabled.
+var WithPullDown = realgpiod.WithPullDown
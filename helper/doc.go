@@ -0,0 +1,12 @@
+/*
+Package helper lets unprivileged users trigger openers through a small
+root-owned process that holds the transmitter hardware.
+
+The helper listens on a Unix socket anyone can connect to, identifies each
+caller by the uid and gid the kernel reports for the connection
+(SO_PEERCRED), and checks them against an access list before queueing the
+transmission. Each connection carries one request and its response, as lines
+of JSON. The socket is kept in a directory only root can write to, apart
+from the lock directory, which anyone can.
+*/
+package helper
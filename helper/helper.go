@@ -0,0 +1,186 @@
+package helper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/zellyn/openers/acl"
+	"github.com/zellyn/openers/server"
+)
+
+// DefaultSocket is where the helper listens by default. It is in a
+// directory of its own: the lock directory is writable by everyone, so
+// anyone could replace a socket there with their own.
+const DefaultSocket = "/run/openers-helper/helper.sock"
+
+// requestTimeout limits how long a caller has to send its request.
+const requestTimeout = 5 * time.Second
+
+// Request asks the helper to trigger an opener.
+type Request struct {
+	Opener string `json:"opener"`
+	Action string `json:"action"`
+}
+
+// Response is the helper's answer: the result of the transmission, or why
+// it wasn't attempted.
+type Response struct {
+	Result *server.Result `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// TriggerFunc triggers an opener, waiting for the result. server.Server's
+// Trigger method is one.
+type TriggerFunc func(ctx context.Context, opener, action, source string) (*server.Result, error)
+
+// Server answers requests on the socket.
+type Server struct {
+	Trigger TriggerFunc
+	// Authorize returns an error if the caller may not trigger the opener.
+	Authorize func(c *acl.Caller, opener string) error
+	// Log, if not nil, gets a line for each request.
+	Log io.Writer
+}
+
+// Listen removes any stale socket at path, and listens there, allowing
+// anyone to connect: callers are checked per request. The socket's
+// directory, created with mode 0755 if need be, must belong to this user or
+// root, and not be writable by anyone else, so that nobody else can replace
+// the socket.
+func Listen(path string) (*net.UnixListener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() || fi.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("%s: socket directory must only be writable by its owner; got mode %v", dir, fi.Mode())
+	}
+	uid, err := owner(fi)
+	if err != nil {
+		return nil, err
+	}
+	if uid != os.Geteuid() && uid != 0 {
+		return nil, fmt.Errorf("%s: socket directory must belong to this user or root; owned by uid %d", dir, uid)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve answers connections on l until the context is cancelled.
+func (s *Server) Serve(ctx context.Context, l *net.UnixListener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+// handle answers a single connection.
+func (s *Server) handle(ctx context.Context, conn *net.UnixConn) {
+	defer conn.Close()
+	resp := s.answer(ctx, conn)
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// answer reads, checks and carries out the request on a connection.
+func (s *Server) answer(ctx context.Context, conn *net.UnixConn) *Response {
+	uid, gid, err := peerCred(conn)
+	if err != nil {
+		return &Response{Error: fmt.Sprintf("cannot identify caller: %v", err)}
+	}
+	caller := acl.LookupCaller(uid, gid)
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return &Response{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	if err := s.Authorize(caller, req.Opener); err != nil {
+		s.logf("%s: refused %s %q: %v", caller, req.Action, req.Opener, err)
+		return &Response{Error: err.Error()}
+	}
+	s.logf("%s: %s %q", caller, req.Action, req.Opener)
+	result, err := s.Trigger(ctx, req.Opener, req.Action, caller.String()+" via helper")
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+	return &Response{Result: result}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
+
+// Trigger asks the helper listening at path to trigger an opener, and waits
+// for the result. A transmission that was attempted but failed is reported
+// in the result, not as an error.
+func Trigger(ctx context.Context, path, opener, action string) (*server.Result, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach the openers helper: %v", err)
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if err := json.NewEncoder(conn).Encode(Request{Opener: opener, Action: action}); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("no answer from the openers helper: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Result, nil
+}
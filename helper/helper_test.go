@@ -0,0 +1,99 @@
+package helper_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zellyn/openers/acl"
+	"github.com/zellyn/openers/helper"
+	"github.com/zellyn/openers/server"
+)
+
+func TestHelper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	l, err := helper.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0666 {
+		t.Errorf("want socket mode 0666; got %v (%v)", fi.Mode(), err)
+	}
+
+	// A directory anyone can write to, like /tmp.
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := helper.Listen(filepath.Join(shared, "helper.sock")); err == nil {
+		l.Close()
+		t.Errorf("want error listening in a directory anyone can write to")
+	}
+
+	// A directory someone else owns, who could swap it for another.
+	if os.Geteuid() == 0 {
+		foreign := filepath.Join(t.TempDir(), "foreign")
+		if err := os.Mkdir(foreign, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chown(foreign, 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if l, err := helper.Listen(filepath.Join(foreign, "helper.sock")); err == nil {
+			l.Close()
+			t.Errorf("want error listening in a directory another user owns")
+		}
+	}
+
+	var callers []*acl.Caller
+	s := &helper.Server{
+		Authorize: func(c *acl.Caller, opener string) error {
+			callers = append(callers, c)
+			if opener == "gate" {
+				return fmt.Errorf("%s may not trigger %q", c, opener)
+			}
+			return nil
+		},
+		Trigger: func(ctx context.Context, opener, action, source string) (*server.Result, error) {
+			if opener != "garage" {
+				return nil, fmt.Errorf("no opener named %q", opener)
+			}
+			return &server.Result{Opener: opener, Action: action, Source: source, State: server.StateDone}, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	result, err := helper.Trigger(ctx, path, "garage", server.Toggle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Opener != "garage" || result.Action != server.Toggle || result.State != server.StateDone {
+		t.Errorf("want garage toggled; got %+v", result)
+	}
+	if len(callers) != 1 || callers[0].UID != os.Getuid() || callers[0].GID != os.Getgid() {
+		t.Fatalf("want caller identified as uid %d gid %d; got %+v", os.Getuid(), os.Getgid(), callers)
+	}
+	if want := callers[0].String() + " via helper"; result.Source != want {
+		t.Errorf("want source %q; got %q", want, result.Source)
+	}
+
+	if _, err := helper.Trigger(ctx, path, "gate", server.Open); err == nil {
+		t.Errorf("want refusal for gate")
+	}
+	if _, err := helper.Trigger(ctx, path, "shed", server.Open); err == nil {
+		t.Errorf("want error for unknown opener")
+	}
+}
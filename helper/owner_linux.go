@@ -0,0 +1,16 @@
+package helper
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// owner returns the uid of a file's owner.
+func owner(fi os.FileInfo) (int, error) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("cannot tell who owns %s", fi.Name())
+	}
+	return int(st.Uid), nil
+}
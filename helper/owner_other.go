@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package helper
+
+import (
+	"fmt"
+	"os"
+)
+
+// owner reports that owners can only be checked on Linux.
+func owner(fi os.FileInfo) (int, error) {
+	return 0, fmt.Errorf("file owners are only checked on Linux")
+}
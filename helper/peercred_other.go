@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package helper
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerCred reports that callers can only be identified on Linux.
+func peerCred(conn *net.UnixConn) (uid, gid int, err error) {
+	return 0, 0, fmt.Errorf("peer credentials are only supported on Linux")
+}
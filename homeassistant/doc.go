@@ -0,0 +1,13 @@
+/*
+Package homeassistant connects openers to Home Assistant over MQTT.
+
+Each configured opener appears as a cover, with open and close commands, and
+a button that toggles it, announced through Home Assistant's MQTT discovery.
+Commands are passed to the same queue as the HTTP API's, and each opener's
+last command is published back. The state of a door with a sensor is
+published as the sensor sees it; the covers of other openers are optimistic,
+with Home Assistant assuming they did as asked, and opening or closing them
+toggles them. Availability is published as "online", with the broker
+publishing "offline" as the client's will if it goes away.
+*/
+package homeassistant
@@ -0,0 +1,323 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/mqtt"
+	"github.com/zellyn/openers/server"
+)
+
+// Defaults for the topics used.
+const (
+	DefaultDiscoveryPrefix = "homeassistant"
+	DefaultBaseTopic       = "openers"
+)
+
+// Payloads.
+const (
+	Online  = "online"
+	Offline = "offline"
+
+	payloadOpen  = "OPEN"
+	payloadClose = "CLOSE"
+	payloadPress = "PRESS"
+)
+
+// statePoll is how often the bridge looks at the doors with sensors.
+const statePoll = time.Second
+
+// TriggerFunc triggers an opener, waiting for the result. server.Server's
+// Trigger method is one.
+type TriggerFunc func(ctx context.Context, opener, action, source string) (*server.Result, error)
+
+// Bridge publishes the configured openers to Home Assistant, and carries out
+// its commands.
+type Bridge struct {
+	Config  *config.Config
+	Trigger TriggerFunc
+	// Doors holds the doors of openers with sensors, by opener name, as
+	// given to the server. Their states are published as they change. The
+	// covers of other openers are optimistic: Home Assistant assumes they
+	// did as asked.
+	Doors map[string]*door.Door
+
+	// DiscoveryPrefix is the topic prefix Home Assistant watches for
+	// discovery messages; DefaultDiscoveryPrefix if empty.
+	DiscoveryPrefix string
+	// BaseTopic is the prefix for the bridge's own topics; DefaultBaseTopic if
+	// empty.
+	BaseTopic string
+	// Log, if not nil, gets a line for each command.
+	Log io.Writer
+}
+
+func (b *Bridge) discoveryPrefix() string {
+	if b.DiscoveryPrefix == "" {
+		return DefaultDiscoveryPrefix
+	}
+	return b.DiscoveryPrefix
+}
+
+func (b *Bridge) baseTopic() string {
+	if b.BaseTopic == "" {
+		return DefaultBaseTopic
+	}
+	return b.BaseTopic
+}
+
+// AvailabilityTopic is where the bridge publishes Online or Offline.
+func (b *Bridge) AvailabilityTopic() string {
+	return b.baseTopic() + "/status"
+}
+
+// Will is the message to give the broker when connecting, for it to publish
+// if the bridge goes away.
+func (b *Bridge) Will() *mqtt.Message {
+	return &mqtt.Message{Topic: b.AvailabilityTopic(), Payload: []byte(Offline), Retain: true}
+}
+
+// Topic returns one of an opener's topics: "set", "toggle", "state" or
+// "last".
+func (b *Bridge) Topic(opener, which string) string {
+	return b.baseTopic() + "/" + opener + "/" + which
+}
+
+// device groups an opener's entities in Home Assistant.
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// cover is the discovery configuration for an opener's cover entity.
+type cover struct {
+	Name                string  `json:"name"`
+	UniqueID            string  `json:"unique_id"`
+	DeviceClass         string  `json:"device_class"`
+	CommandTopic        string  `json:"command_topic"`
+	StateTopic          string  `json:"state_topic,omitempty"`
+	Optimistic          bool    `json:"optimistic,omitempty"`
+	PayloadOpen         string  `json:"payload_open"`
+	PayloadClose        string  `json:"payload_close"`
+	PayloadStop         *string `json:"payload_stop"` // null: openers can't be stopped.
+	AvailabilityTopic   string  `json:"availability_topic"`
+	JSONAttributesTopic string  `json:"json_attributes_topic"`
+	Device              device  `json:"device"`
+}
+
+// button is the discovery configuration for an opener's toggle button.
+type button struct {
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	CommandTopic        string `json:"command_topic"`
+	PayloadPress        string `json:"payload_press"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	JSONAttributesTopic string `json:"json_attributes_topic"`
+	Device              device `json:"device"`
+}
+
+// objectID turns an opener name into something Home Assistant accepts in
+// discovery topics and unique IDs.
+func objectID(name string) string {
+	return "openers_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Discovery returns the retained discovery messages announcing an opener's
+// entities.
+func (b *Bridge) Discovery(o *config.Opener) ([]*mqtt.Message, error) {
+	id := objectID(o.Name)
+	dev := device{Identifiers: []string{id}, Name: o.Name, Manufacturer: "openers", Model: o.Protocol}
+	c := cover{
+		Name:                o.Name,
+		UniqueID:            id,
+		DeviceClass:         o.DeviceKind(),
+		CommandTopic:        b.Topic(o.Name, "set"),
+		PayloadOpen:         payloadOpen,
+		PayloadClose:        payloadClose,
+		AvailabilityTopic:   b.AvailabilityTopic(),
+		JSONAttributesTopic: b.Topic(o.Name, "last"),
+		Device:              dev,
+	}
+	if b.Doors[o.Name] != nil {
+		c.StateTopic = b.Topic(o.Name, "state")
+	} else {
+		c.Optimistic = true
+	}
+	t := button{
+		Name:                o.Name + " toggle",
+		UniqueID:            id + "_toggle",
+		CommandTopic:        b.Topic(o.Name, "toggle"),
+		PayloadPress:        payloadPress,
+		AvailabilityTopic:   b.AvailabilityTopic(),
+		JSONAttributesTopic: b.Topic(o.Name, "last"),
+		Device:              dev,
+	}
+	var msgs []*mqtt.Message
+	for _, e := range []struct {
+		component string
+		config    interface{}
+	}{{"cover", c}, {"button", t}} {
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &mqtt.Message{
+			Topic:   b.discoveryPrefix() + "/" + e.component + "/" + id + "/config",
+			Payload: payload,
+			Retain:  true,
+		})
+	}
+	return msgs, nil
+}
+
+// announce publishes the discovery messages for every opener, and clears
+// any retained state of openers without sensors.
+func (b *Bridge) announce(c *mqtt.Client) error {
+	for i := range b.Config.Openers {
+		o := &b.Config.Openers[i]
+		msgs, err := b.Discovery(o)
+		if err != nil {
+			return err
+		}
+		if b.Doors[o.Name] == nil {
+			msgs = append(msgs, &mqtt.Message{Topic: b.Topic(o.Name, "state"), Retain: true})
+		}
+		for _, m := range msgs {
+			if err := c.Publish(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run announces the openers on the connection, and carries out commands
+// until the context is cancelled, when it publishes Offline and disconnects,
+// or the connection is lost, when it returns the error.
+func (b *Bridge) Run(ctx context.Context, c *mqtt.Client) error {
+	if err := b.announce(c); err != nil {
+		return err
+	}
+	// Home Assistant announces itself when it starts, and needs to hear about
+	// the openers again.
+	if err := c.Subscribe(ctx, b.discoveryPrefix()+"/status", func(m *mqtt.Message) {
+		if string(m.Payload) == Online {
+			go b.announce(c)
+		}
+	}); err != nil {
+		return err
+	}
+	for _, which := range []string{"set", "toggle"} {
+		if err := c.Subscribe(ctx, b.Topic("+", which), func(m *mqtt.Message) {
+			go b.command(ctx, c, m)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := c.Publish(&mqtt.Message{Topic: b.AvailabilityTopic(), Payload: []byte(Online), Retain: true}); err != nil {
+		return err
+	}
+	go b.watch(ctx, c)
+
+	select {
+	case <-ctx.Done():
+		c.Publish(&mqtt.Message{Topic: b.AvailabilityTopic(), Payload: []byte(Offline), Retain: true})
+		c.Close()
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// command carries out a command message. Retained commands are ignored:
+// they were sent some time ago, and would be carried out again every time
+// the bridge connects.
+func (b *Bridge) command(ctx context.Context, c *mqtt.Client, m *mqtt.Message) {
+	if m.Retain {
+		b.logf("%s: ignoring retained command %q", m.Topic, m.Payload)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(m.Topic, b.baseTopic()+"/"), "/")
+	if len(parts) != 2 {
+		return
+	}
+	name, payload := parts[0], string(m.Payload)
+	var action string
+	switch {
+	case parts[1] == "set" && payload == payloadOpen:
+		action = server.Open
+	case parts[1] == "set" && payload == payloadClose:
+		action = server.Close
+	case parts[1] == "toggle" && payload == payloadPress:
+		action = server.Toggle
+	default:
+		b.logf("%s: ignoring %q", m.Topic, payload)
+		return
+	}
+	// Without a sensor, the server can't tell where the door is, so it can
+	// only toggle it.
+	if b.Doors[name] == nil {
+		action = server.Toggle
+	}
+
+	result, err := b.Trigger(ctx, name, action, "mqtt")
+	if err != nil {
+		b.logf("%s %s: %v", action, name, err)
+		return
+	}
+	b.logf("%s %s: %s", action, name, result.State)
+	if last, err := json.Marshal(result); err == nil {
+		c.Publish(&mqtt.Message{Topic: b.Topic(name, "last"), Payload: last, Retain: true})
+	}
+}
+
+// watch publishes the state of each door with a sensor whenever it
+// changes, until the context is cancelled or the connection is lost. Home
+// Assistant's cover states are named as the door's are; an unknown state
+// isn't published.
+func (b *Bridge) watch(ctx context.Context, c *mqtt.Client) {
+	if len(b.Doors) == 0 {
+		return
+	}
+	published := map[string]door.State{}
+	t := time.NewTicker(statePoll)
+	defer t.Stop()
+	for {
+		for name, d := range b.Doors {
+			st := d.State(door.Now())
+			if st == door.Unknown || st == published[name] {
+				continue
+			}
+			if err := c.Publish(&mqtt.Message{Topic: b.Topic(name, "state"), Payload: []byte(st), Retain: true}); err == nil {
+				published[name] = st
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (b *Bridge) logf(format string, args ...interface{}) {
+	if b.Log != nil {
+		fmt.Fprintf(b.Log, format+"\n", args...)
+	}
+}
@@ -0,0 +1,182 @@
+package homeassistant_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/homeassistant"
+	"github.com/zellyn/openers/mqtt"
+	"github.com/zellyn/openers/mqtt/mqtttest"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+const testConfig = `{
+  "openers": [
+    {"name": "garage", "protocol": "secplus-v2", "fixed": "70678577664", "rolling": "240124710", "sensor": {"closed_pin": 17, "open_pin": 27}},
+    {"name": "front gate", "protocol": "megacode", "kind": "gate", "identifier": "0x876543"}
+  ]
+}`
+
+// waitFor waits for a retained message on a topic satisfying ok.
+func waitFor(t *testing.T, broker *mqtttest.Broker, topic string, ok func(m *mqtt.Message) bool) *mqtt.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m := broker.Retained(topic)
+		if m != nil && ok(m) {
+			return m
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s; last saw %+v", topic, m)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func payloadIs(s string) func(m *mqtt.Message) bool {
+	return func(m *mqtt.Message) bool { return string(m.Payload) == s }
+}
+
+func TestBridge(t *testing.T) {
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	// Left by an earlier version, which guessed the state after each command.
+	broker.Publish(&mqtt.Message{Topic: "openers/front gate/state", Payload: []byte("open"), Retain: true})
+
+	// The garage door swings from one limit to the other when toggled.
+	garage := door.New(door.ClosedSwitch, door.OpenSwitch)
+	garage.Set(door.ClosedSwitch, true)
+	doors := map[string]*door.Door{"garage": garage}
+	dryRun := (&transmit.DryRun{Log: ioutil.Discard}).Transmit
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		if audit.FromContext(ctx).Opener == "garage" {
+			closed := garage.State(door.Now()) == door.Closed
+			garage.Set(door.ClosedSwitch, !closed)
+			garage.Set(door.OpenSwitch, closed)
+		}
+		return dryRun(ctx, tx)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := server.New(cfg, send, &rolling.Store{Path: filepath.Join(t.TempDir(), "rolling.json")})
+	srv.Doors = doors
+	go srv.Run(ctx)
+	b := &homeassistant.Bridge{Config: cfg, Trigger: srv.Trigger, Doors: doors}
+	c, err := mqtt.Dial(ctx, broker.Addr, &mqtt.Connect{ClientID: "openers", Will: b.Will()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- b.Run(ctx, c) }()
+
+	waitFor(t, broker, "openers/status", payloadIs(homeassistant.Online))
+	var cover map[string]interface{}
+	if err := json.Unmarshal(broker.Retained("homeassistant/cover/openers_garage/config").Payload, &cover); err != nil {
+		t.Fatal(err)
+	}
+	if cover["command_topic"] != "openers/garage/set" || cover["device_class"] != "garage" || cover["payload_stop"] != nil ||
+		cover["state_topic"] != "openers/garage/state" || cover["optimistic"] != nil {
+		t.Errorf("want garage cover commanded on openers/garage/set, with its sensor's state, without stop; got %v", cover)
+	}
+	cover = nil
+	if err := json.Unmarshal(broker.Retained("homeassistant/cover/openers_front_gate/config").Payload, &cover); err != nil {
+		t.Fatal(err)
+	}
+	if cover["device_class"] != "gate" || cover["state_topic"] != nil || cover["optimistic"] != true {
+		t.Errorf("want optimistic front gate cover with device class gate, without state; got %v", cover)
+	}
+	if broker.Retained("homeassistant/button/openers_front_gate/config") == nil {
+		t.Errorf("want toggle button for front gate")
+	}
+
+	waitFor(t, broker, "openers/garage/state", payloadIs("closed"))
+	broker.Publish(&mqtt.Message{Topic: "openers/garage/set", Payload: []byte("OPEN")})
+	waitFor(t, broker, "openers/garage/state", payloadIs("open"))
+	last := waitFor(t, broker, "openers/garage/last", func(m *mqtt.Message) bool { return len(m.Payload) > 0 })
+	var r server.Result
+	if err := json.Unmarshal(last.Payload, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Action != server.Open || r.Source != "mqtt" || r.Rolling == nil || *r.Rolling != 240124710 || r.Sent != 1 {
+		t.Errorf("want open from mqtt with first rolling code; got %+v", r)
+	}
+
+	broker.Publish(&mqtt.Message{Topic: "openers/front gate/set", Payload: []byte("OPEN")})
+	waitFor(t, broker, "openers/front gate/last", func(m *mqtt.Message) bool {
+		return strings.Contains(string(m.Payload), `"action":"toggle"`)
+	})
+	if broker.Retained("openers/front gate/state") != nil {
+		t.Errorf("want no state published without a sensor")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	waitFor(t, broker, "openers/status", payloadIs(homeassistant.Offline))
+}
+
+func TestRetainedCommand(t *testing.T) {
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	// Left behind by some earlier client, before the bridge started.
+	broker.Publish(&mqtt.Message{Topic: "openers/garage/set", Payload: []byte("OPEN"), Retain: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	triggered := make(chan string, 2)
+	trigger := func(ctx context.Context, name, action, source string) (*server.Result, error) {
+		triggered <- action + " " + name
+		return &server.Result{Opener: name, Action: action, State: server.StateDone}, nil
+	}
+	b := &homeassistant.Bridge{Config: cfg, Trigger: trigger}
+	c, err := mqtt.Dial(ctx, broker.Addr, &mqtt.Connect{ClientID: "openers", Will: b.Will()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- b.Run(ctx, c) }()
+	waitFor(t, broker, "openers/status", payloadIs(homeassistant.Online))
+
+	broker.Publish(&mqtt.Message{Topic: "openers/front gate/toggle", Payload: []byte("PRESS")})
+	if got := <-triggered; got != "toggle front gate" {
+		t.Errorf("want only the new toggle carried out; got %q", got)
+	}
+	select {
+	case got := <-triggered:
+		t.Errorf("want retained command ignored; got %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
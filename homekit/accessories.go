@@ -0,0 +1,204 @@
+package homekit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// HAP service and characteristic types, in their short form.
+const (
+	typeAccessoryInfo    = "3E"
+	typeProtocolInfo     = "A2"
+	typeGarageDoorOpener = "41"
+
+	typeIdentify            = "14"
+	typeManufacturer        = "20"
+	typeModel               = "21"
+	typeName                = "23"
+	typeSerialNumber        = "30"
+	typeFirmwareRevision    = "52"
+	typeVersion             = "37"
+	typeCurrentDoorState    = "E"
+	typeTargetDoorState     = "32"
+	typeObstructionDetected = "24"
+)
+
+// Permissions.
+const (
+	permRead   = "pr"
+	permWrite  = "pw"
+	permEvents = "ev"
+)
+
+// firmwareRevision is reported for every accessory.
+const firmwareRevision = "1.0.0"
+
+// HAP status codes.
+const (
+	statusSuccess      = 0
+	statusUnpaired     = -70401
+	statusReadOnly     = -70404
+	statusWriteOnly    = -70405
+	statusNoEvents     = -70406
+	statusNotFound     = -70409
+	statusInvalidValue = -70410
+)
+
+// charID identifies a characteristic by accessory and instance ID.
+type charID struct {
+	aid, iid int
+}
+
+// characteristic is a value controllers can read, write or be told about.
+type characteristic struct {
+	iid      int
+	typ      string
+	perms    []string
+	format   string
+	minValue *int
+	maxValue *int
+	read     func() interface{}          // nil if write-only.
+	write    func(json.RawMessage) error // nil if read-only.
+}
+
+func (c *characteristic) can(perm string) bool {
+	for _, p := range c.perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// service groups characteristics.
+type service struct {
+	iid             int
+	typ             string
+	primary         bool
+	characteristics []*characteristic
+}
+
+// accessory is one of the bridge's accessories: the bridge itself, or an
+// opener.
+type accessory struct {
+	aid      int
+	services []*service
+}
+
+// builder assigns instance IDs as an accessory is built.
+type builder struct {
+	a       *accessory
+	nextIID int
+}
+
+func newBuilder(aid int) *builder {
+	return &builder{a: &accessory{aid: aid}, nextIID: 1}
+}
+
+func (b *builder) service(typ string, primary bool) *service {
+	s := &service{iid: b.nextIID, typ: typ, primary: primary}
+	b.nextIID++
+	b.a.services = append(b.a.services, s)
+	return s
+}
+
+func (b *builder) add(s *service, c *characteristic) *characteristic {
+	c.iid = b.nextIID
+	b.nextIID++
+	s.characteristics = append(s.characteristics, c)
+	return c
+}
+
+// constant returns a read-only string characteristic.
+func constant(typ, value string) *characteristic {
+	return &characteristic{typ: typ, perms: []string{permRead}, format: "string", read: func() interface{} { return value }}
+}
+
+// info adds the accessory information service every accessory must have.
+func (b *builder) info(name, model, serial string, identify func()) {
+	s := b.service(typeAccessoryInfo, false)
+	b.add(s, &characteristic{typ: typeIdentify, perms: []string{permWrite}, format: "bool", write: func(v json.RawMessage) error {
+		if _, err := parseInt(v); err != nil {
+			return err
+		}
+		identify()
+		return nil
+	}})
+	b.add(s, constant(typeManufacturer, "openers"))
+	b.add(s, constant(typeModel, model))
+	b.add(s, constant(typeName, name))
+	b.add(s, constant(typeSerialNumber, serial))
+	b.add(s, constant(typeFirmwareRevision, firmwareRevision))
+}
+
+// parseInt parses a written value, which controllers may send as a number
+// or a bool.
+func parseInt(v json.RawMessage) (int, error) {
+	switch string(v) {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s", v)
+	}
+	return n, nil
+}
+
+func intPtr(i int) *int { return &i }
+
+// jsonCharacteristic is a characteristic as listed in /accessories.
+type jsonCharacteristic struct {
+	IID      int         `json:"iid"`
+	Type     string      `json:"type"`
+	Perms    []string    `json:"perms"`
+	Format   string      `json:"format"`
+	Value    interface{} `json:"value,omitempty"`
+	MinValue *int        `json:"minValue,omitempty"`
+	MaxValue *int        `json:"maxValue,omitempty"`
+	MinStep  *int        `json:"minStep,omitempty"`
+}
+
+type jsonService struct {
+	IID             int                  `json:"iid"`
+	Type            string               `json:"type"`
+	Primary         bool                 `json:"primary,omitempty"`
+	Characteristics []jsonCharacteristic `json:"characteristics"`
+}
+
+type jsonAccessory struct {
+	AID      int           `json:"aid"`
+	Services []jsonService `json:"services"`
+}
+
+type jsonAccessories struct {
+	Accessories []jsonAccessory `json:"accessories"`
+}
+
+// describe returns the accessory database as sent to controllers, with
+// current values if withValues is true.
+func describe(accessories []*accessory, withValues bool) jsonAccessories {
+	db := jsonAccessories{Accessories: []jsonAccessory{}}
+	for _, a := range accessories {
+		ja := jsonAccessory{AID: a.aid}
+		for _, s := range a.services {
+			js := jsonService{IID: s.iid, Type: s.typ, Primary: s.primary}
+			for _, c := range s.characteristics {
+				jc := jsonCharacteristic{IID: c.iid, Type: c.typ, Perms: c.perms, Format: c.format, MinValue: c.minValue, MaxValue: c.maxValue}
+				if c.minValue != nil {
+					jc.MinStep = intPtr(1)
+				}
+				if withValues && c.read != nil {
+					jc.Value = c.read()
+				}
+				js.Characteristics = append(js.Characteristics, jc)
+			}
+			ja.Services = append(ja.Services, js)
+		}
+		db.Accessories = append(db.Accessories, ja)
+	}
+	return db
+}
@@ -0,0 +1,486 @@
+package homekit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/mdns"
+	"github.com/zellyn/openers/server"
+)
+
+// DefaultPort is the TCP port the bridge listens on by default.
+const DefaultPort = 51826
+
+// bridgeCategory is the HAP accessory category for bridges.
+const bridgeCategory = 2
+
+// Content types.
+const (
+	contentJSON = "application/hap+json"
+	contentTLV8 = "application/pairing+tlv8"
+)
+
+// TriggerFunc triggers an opener, waiting for the result. server.Server's
+// Trigger method is one.
+type TriggerFunc func(ctx context.Context, opener, action, source string) (*server.Result, error)
+
+// Bridge is a HomeKit bridge with a GarageDoorOpener accessory for each
+// opener. Create one with New, and set any options before calling Serve.
+type Bridge struct {
+	// Name is the bridge's name, shown when adding it in the Home app.
+	Name string
+	// Sensors are the door sensors, by opener name.
+	Sensors map[string]Sensor
+	// Travel is how long doors take to open or close.
+	Travel time.Duration
+	// Responder, if not nil, advertises the bridge, and is updated when it
+	// is paired or unpaired.
+	Responder *mdns.Responder
+	// Log, if not nil, gets a line for each command and pairing change.
+	Log io.Writer
+
+	config    *config.Config
+	trigger   TriggerFunc
+	statePath string
+
+	accessories []*accessory
+	chars       map[charID]*characteristic
+	ids         map[*characteristic]charID
+	doors       []*door
+
+	mu            sync.Mutex
+	state         *State
+	conns         map[*conn]bool
+	setupConn     *conn
+	setupAttempts int
+}
+
+// New returns a bridge for the openers in cfg, triggering them with trigger,
+// and keeping its keys and pairings in the state file at statePath.
+func New(cfg *config.Config, trigger TriggerFunc, state *State, statePath string) *Bridge {
+	return &Bridge{
+		Name:      "openers",
+		Travel:    DefaultTravel,
+		config:    cfg,
+		trigger:   trigger,
+		state:     state,
+		statePath: statePath,
+		conns:     map[*conn]bool{},
+	}
+}
+
+// Trigger triggers an opener.
+func (b *Bridge) Trigger(ctx context.Context, opener, action, source string) (*server.Result, error) {
+	return b.trigger(ctx, opener, action, source)
+}
+
+// SetupCode returns the code to enter in the Home app to pair.
+func (b *Bridge) SetupCode() string {
+	return b.state.SetupCode
+}
+
+// Paired reports whether any controller is paired.
+func (b *Bridge) Paired() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.paired()
+}
+
+// build creates the accessories, and bumps the configuration number if they
+// have changed since last time.
+func (b *Bridge) build() error {
+	bld := newBuilder(1)
+	bld.info(b.Name, "openers bridge", b.state.ID, func() { b.logf("Identify bridge") })
+	s := bld.service(typeProtocolInfo, false)
+	bld.add(s, constant(typeVersion, "1.1.0"))
+	b.accessories = []*accessory{bld.a}
+
+	b.doors = nil
+	for i := range b.config.Openers {
+		o := &b.config.Openers[i]
+		var sensor Sensor
+		if b.Sensors != nil {
+			sensor = b.Sensors[o.Name]
+		}
+		d, a := newDoor(b, i+2, o, sensor, b.Travel)
+		b.doors = append(b.doors, d)
+		b.accessories = append(b.accessories, a)
+	}
+
+	b.chars = map[charID]*characteristic{}
+	b.ids = map[*characteristic]charID{}
+	for _, a := range b.accessories {
+		for _, s := range a.services {
+			for _, c := range s.characteristics {
+				id := charID{a.aid, c.iid}
+				b.chars[id] = c
+				b.ids[c] = id
+			}
+		}
+	}
+
+	data, err := json.Marshal(describe(b.accessories, false))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	h := hex.EncodeToString(sum[:])
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h == b.state.ConfigHash {
+		return nil
+	}
+	b.state.ConfigHash = h
+	b.state.ConfigNumber++
+	return b.saveLocked()
+}
+
+// saveLocked saves the state. b.mu must be held.
+func (b *Bridge) saveLocked() error {
+	if b.statePath == "" {
+		return nil
+	}
+	return b.state.Save(b.statePath)
+}
+
+// Service returns the mDNS service advertising the bridge.
+func (b *Bridge) Service(host string, port int) mdns.Service {
+	return mdns.Service{
+		Instance: b.Name,
+		Service:  "_hap._tcp",
+		Host:     host,
+		Port:     port,
+		Text:     b.txt(),
+	}
+}
+
+// txt returns the bridge's TXT record.
+func (b *Bridge) txt() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sf := 1
+	if b.state.paired() {
+		sf = 0
+	}
+	return []string{
+		"c#=" + strconv.Itoa(b.state.ConfigNumber),
+		"ff=0",
+		"id=" + b.state.ID,
+		"md=" + b.Name,
+		"pv=1.1",
+		"s#=1",
+		"sf=" + strconv.Itoa(sf),
+		"ci=" + strconv.Itoa(bridgeCategory),
+	}
+}
+
+// advertise updates the advertisement after pairing changes.
+func (b *Bridge) advertise() {
+	if b.Responder != nil {
+		b.Responder.SetText(b.txt())
+	}
+}
+
+// connKey is the context key for the connection serving a request.
+type connKey struct{}
+
+// Serve serves controllers on l until the context is cancelled.
+func (b *Bridge) Serve(ctx context.Context, l net.Listener) error {
+	if err := b.build(); err != nil {
+		return err
+	}
+	b.advertise()
+	for _, d := range b.doors {
+		go d.poll(ctx)
+	}
+	hs := &http.Server{
+		Handler: b,
+		ConnContext: func(ctx context.Context, nc net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, nc)
+		},
+		ConnState: func(nc net.Conn, state http.ConnState) {
+			if state == http.StateClosed || state == http.StateHijacked {
+				b.mu.Lock()
+				delete(b.conns, nc.(*conn))
+				if b.setupConn == nc {
+					b.setupConn = nil
+				}
+				b.mu.Unlock()
+			}
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		hs.Close()
+	}()
+	err := hs.Serve(&listener{Listener: l, b: b})
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (b *Bridge) addConn(c *conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.conns[c] = true
+}
+
+// notify sends events for changed values to the controllers that asked for
+// them.
+func (b *Bridge) notify(values map[*characteristic]interface{}) {
+	type value struct {
+		AID   int         `json:"aid"`
+		IID   int         `json:"iid"`
+		Value interface{} `json:"value"`
+	}
+	b.mu.Lock()
+	conns := make([]*conn, 0, len(b.conns))
+	for c := range b.conns {
+		conns = append(conns, c)
+	}
+	b.mu.Unlock()
+	for _, c := range conns {
+		if c.verified() == "" {
+			continue
+		}
+		var vs []value
+		c.mu.Lock()
+		for ch, v := range values {
+			id := b.ids[ch]
+			if c.events[id] {
+				vs = append(vs, value{id.aid, id.iid, v})
+			}
+		}
+		c.mu.Unlock()
+		if len(vs) == 0 {
+			continue
+		}
+		sort.Slice(vs, func(i, j int) bool { return vs[i].IID < vs[j].IID })
+		body, err := json.Marshal(map[string]interface{}{"characteristics": vs})
+		if err != nil {
+			continue
+		}
+		msg := fmt.Sprintf("EVENT/1.0 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s", contentJSON, len(body), body)
+		c.Write([]byte(msg))
+	}
+}
+
+// ServeHTTP serves the HAP HTTP endpoints.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := r.Context().Value(connKey{}).(*conn)
+	switch r.URL.Path {
+	case "/pair-setup", "/pair-verify":
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		b.handlePairing(w, r, c)
+		return
+	case "/identify":
+		b.handleIdentify(w, r)
+		return
+	}
+
+	if c.verified() == "" {
+		writeHAP(w, 470, map[string]int{"status": statusUnpaired})
+		return
+	}
+	switch {
+	case r.URL.Path == "/pairings" && r.Method == http.MethodPost:
+		b.handlePairing(w, r, c)
+	case r.URL.Path == "/accessories" && r.Method == http.MethodGet:
+		writeHAP(w, http.StatusOK, describe(b.accessories, true))
+	case r.URL.Path == "/characteristics" && r.Method == http.MethodGet:
+		b.handleRead(w, r)
+	case r.URL.Path == "/characteristics" && r.Method == http.MethodPut:
+		b.handleWrite(w, r, c)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// handlePairing handles the TLV8 pairing endpoints.
+func (b *Bridge) handlePairing(w http.ResponseWriter, r *http.Request, c *conn) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		return
+	}
+	req, err := parseTLV(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var resp tlv
+	switch r.URL.Path {
+	case "/pair-setup":
+		resp = b.pairSetup(c, req)
+	case "/pair-verify":
+		resp = b.pairVerify(c, req)
+	default:
+		resp = b.pairings(c, req)
+		b.mu.Lock()
+		removed := b.state.Pairings[c.verified()] == nil
+		b.mu.Unlock()
+		if removed {
+			w.Header().Set("Connection", "close")
+		}
+	}
+	w.Header().Set("Content-Type", contentTLV8)
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.encode())))
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp.encode())
+}
+
+// handleIdentify lets an unpaired bridge be identified.
+func (b *Bridge) handleIdentify(w http.ResponseWriter, r *http.Request) {
+	if b.Paired() {
+		writeHAP(w, http.StatusBadRequest, map[string]int{"status": statusUnpaired})
+		return
+	}
+	b.logf("Identify bridge")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// charStatus is a characteristic's value or error in a response.
+type charStatus struct {
+	AID    int         `json:"aid"`
+	IID    int         `json:"iid"`
+	Value  interface{} `json:"value,omitempty"`
+	Status *int        `json:"status,omitempty"`
+}
+
+// parseIDs parses the id parameter of a read, such as "2.9,2.10".
+func parseIDs(s string) ([]charID, error) {
+	var ids []charID
+	for _, part := range strings.Split(s, ",") {
+		dot := strings.IndexByte(part, '.')
+		if dot < 0 {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		aid, err1 := strconv.Atoi(part[:dot])
+		iid, err2 := strconv.Atoi(part[dot+1:])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids = append(ids, charID{aid, iid})
+	}
+	return ids, nil
+}
+
+// handleRead reads characteristics.
+func (b *Bridge) handleRead(w http.ResponseWriter, r *http.Request) {
+	ids, err := parseIDs(r.URL.Query().Get("id"))
+	if err != nil {
+		writeHAP(w, http.StatusBadRequest, map[string]int{"status": statusInvalidValue})
+		return
+	}
+	var results []charStatus
+	failed := false
+	for _, id := range ids {
+		cs := charStatus{AID: id.aid, IID: id.iid}
+		c := b.chars[id]
+		switch {
+		case c == nil:
+			cs.Status = intPtr(statusNotFound)
+		case c.read == nil:
+			cs.Status = intPtr(statusWriteOnly)
+		default:
+			cs.Value = c.read()
+		}
+		failed = failed || cs.Status != nil
+		results = append(results, cs)
+	}
+	status := http.StatusOK
+	if failed {
+		status = http.StatusMultiStatus
+		for i := range results {
+			if results[i].Status == nil {
+				results[i].Status = intPtr(statusSuccess)
+			}
+		}
+	}
+	writeHAP(w, status, map[string]interface{}{"characteristics": results})
+}
+
+// handleWrite writes characteristics, and subscribes to or unsubscribes
+// from their events.
+func (b *Bridge) handleWrite(w http.ResponseWriter, r *http.Request, conn *conn) {
+	var req struct {
+		Characteristics []struct {
+			AID   int             `json:"aid"`
+			IID   int             `json:"iid"`
+			Value json.RawMessage `json:"value"`
+			Ev    *bool           `json:"ev"`
+		} `json:"characteristics"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&req); err != nil {
+		writeHAP(w, http.StatusBadRequest, map[string]int{"status": statusInvalidValue})
+		return
+	}
+	var results []charStatus
+	failed := false
+	for _, cw := range req.Characteristics {
+		id := charID{cw.AID, cw.IID}
+		status := statusSuccess
+		c := b.chars[id]
+		switch {
+		case c == nil:
+			status = statusNotFound
+		case cw.Ev != nil && !c.can(permEvents):
+			status = statusNoEvents
+		case cw.Ev != nil:
+			conn.mu.Lock()
+			conn.events[id] = *cw.Ev
+			conn.mu.Unlock()
+		}
+		if status == statusSuccess && cw.Value != nil {
+			if c.write == nil {
+				status = statusReadOnly
+			} else if err := c.write(cw.Value); err != nil {
+				b.logf("Write to %d.%d: %v", id.aid, id.iid, err)
+				status = statusInvalidValue
+			}
+		}
+		failed = failed || status != statusSuccess
+		results = append(results, charStatus{AID: id.aid, IID: id.iid, Status: intPtr(status)})
+	}
+	if !failed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeHAP(w, http.StatusMultiStatus, map[string]interface{}{"characteristics": results})
+}
+
+// writeHAP writes a JSON response.
+func writeHAP(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentJSON)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (b *Bridge) logf(format string, args ...interface{}) {
+	if b.Log != nil {
+		fmt.Fprintf(b.Log, format+"\n", args...)
+	}
+}
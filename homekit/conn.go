@@ -0,0 +1,202 @@
+package homekit
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// maxFrame is the most plaintext sent in one encrypted frame.
+const maxFrame = 1024
+
+// tagSize is the size of the Poly1305 authentication tag on each frame.
+const tagSize = 16
+
+// session holds the keys for an encrypted connection.
+type session struct {
+	read, write       cipher.AEAD
+	readSeq, writeSeq uint64
+}
+
+func newSession(readKey, writeKey []byte) (*session, error) {
+	r, err := chacha20poly1305.New(readKey)
+	if err != nil {
+		return nil, err
+	}
+	w, err := chacha20poly1305.New(writeKey)
+	if err != nil {
+		return nil, err
+	}
+	return &session{read: r, write: w}, nil
+}
+
+func seqNonce(seq uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+// conn is a connection from a controller. It starts out in plain text, and
+// switches to encrypted frames once pair verify completes.
+type conn struct {
+	net.Conn
+
+	// Pairing state, used only by the goroutine serving HTTP requests.
+	setup  *srpServer
+	verify *verifyState
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	pending    *session // Takes effect after the next write.
+	session    *session
+	controller string // Pairing identifier, once verified.
+	raw        []byte // Ciphertext received but not yet decrypted.
+	plain      []byte // Plaintext decrypted but not yet read.
+	events     map[charID]bool
+}
+
+// secure arranges for the connection to be encrypted, starting after the
+// response currently being written.
+func (c *conn) secure(s *session, controller string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = s
+	c.controller = controller
+}
+
+// verified returns the identifier of the controller on the other end, or ""
+// if the connection is not yet encrypted.
+func (c *conn) verified() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return ""
+	}
+	return c.controller
+}
+
+// Read reads plaintext, decrypting it if the connection is encrypted. The
+// decision is made when data arrives, not when Read is called, since the
+// HTTP server may already be reading when the session starts.
+func (c *conn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.plain) > 0 {
+			n := copy(p, c.plain)
+			c.plain = c.plain[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		c.mu.Unlock()
+
+		buf := make([]byte, 4096)
+		n, err := c.Conn.Read(buf)
+		c.mu.Lock()
+		if c.session == nil {
+			c.mu.Unlock()
+			m := copy(p, buf[:n])
+			if m < n {
+				c.mu.Lock()
+				c.plain = append(c.plain, buf[m:n]...)
+				c.mu.Unlock()
+			}
+			if m == 0 && err == nil {
+				continue
+			}
+			return m, err
+		}
+		c.raw = append(c.raw, buf[:n]...)
+		decErr := c.decrypt()
+		c.mu.Unlock()
+		if decErr != nil {
+			return 0, decErr
+		}
+		if err != nil {
+			c.mu.Lock()
+			empty := len(c.plain) == 0
+			c.mu.Unlock()
+			if empty {
+				return 0, err
+			}
+		}
+	}
+}
+
+// decrypt decrypts all the complete frames received. c.mu must be held.
+func (c *conn) decrypt() error {
+	for len(c.raw) >= 2 {
+		length := int(binary.LittleEndian.Uint16(c.raw))
+		if length > maxFrame {
+			return fmt.Errorf("encrypted frame too long: %d bytes", length)
+		}
+		total := 2 + length + tagSize
+		if len(c.raw) < total {
+			return nil
+		}
+		plain, err := c.session.read.Open(nil, seqNonce(c.session.readSeq), c.raw[2:total], c.raw[:2])
+		if err != nil {
+			return fmt.Errorf("cannot decrypt frame: %v", err)
+		}
+		c.session.readSeq++
+		c.plain = append(c.plain, plain...)
+		c.raw = c.raw[total:]
+	}
+	return nil
+}
+
+// Write writes plaintext, encrypting it if the connection is encrypted.
+func (c *conn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.mu.Lock()
+	s := c.session
+	c.mu.Unlock()
+	if s == nil {
+		n, err := c.Conn.Write(p)
+		c.mu.Lock()
+		if c.pending != nil {
+			c.session, c.pending = c.pending, nil
+		}
+		c.mu.Unlock()
+		return n, err
+	}
+
+	var out []byte
+	for rest := p; len(rest) > 0; {
+		n := len(rest)
+		if n > maxFrame {
+			n = maxFrame
+		}
+		aad := []byte{byte(n), byte(n >> 8)}
+		out = append(out, aad...)
+		out = s.write.Seal(out, seqNonce(s.writeSeq), rest[:n], aad)
+		s.writeSeq++
+		rest = rest[n:]
+	}
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// listener wraps accepted connections, keeping track of them so events can
+// be sent and pairings revoked.
+type listener struct {
+	net.Listener
+	b *Bridge
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	nc, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, events: map[charID]bool{}}
+	l.b.addConn(c)
+	return c, nil
+}
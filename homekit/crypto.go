@@ -0,0 +1,41 @@
+package homekit
+
+import (
+	"crypto/sha512"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// deriveKey derives a 32-byte key from a shared secret with HKDF-SHA-512.
+func deriveKey(secret []byte, salt, info string) []byte {
+	key := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha512.New, secret, []byte(salt), []byte(info)), key)
+	return key
+}
+
+// pairingNonce turns a short label, such as "PS-Msg05", into a nonce.
+func pairingNonce(label string) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	copy(nonce[4:], label)
+	return nonce
+}
+
+// seal encrypts a pairing message.
+func seal(key []byte, label string, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, pairingNonce(label), plaintext, nil), nil
+}
+
+// open decrypts a pairing message.
+func open(key []byte, label string, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, pairingNonce(label), ciphertext, nil)
+}
@@ -0,0 +1,20 @@
+/*
+Package homekit is a HomeKit Accessory Protocol (HAP) bridge, making openers
+appear natively in Apple's Home app as garage door openers.
+
+The bridge is found with Bonjour (see package mdns), paired with the Home app
+by entering its eight-digit setup code, and thereafter talks to paired
+controllers over encrypted connections. Pairing uses SRP with the setup code,
+and Ed25519 long-term keys, which are kept in a state file along with the
+list of paired controllers. Sessions use X25519 key agreement and
+ChaCha20-Poly1305.
+
+Each opener is a GarageDoorOpener accessory. Setting its target state sends
+the opener's transmission; its current state comes from a door sensor when
+there is one, and otherwise is assumed to follow the target state after the
+door's travel time.
+
+The protocol is described in Apple's HomeKit Accessory Protocol
+Specification (Non-Commercial Version).
+*/
+package homekit
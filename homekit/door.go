@@ -0,0 +1,257 @@
+package homekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/server"
+)
+
+// Door states, as in HomeKit's CurrentDoorState. TargetDoorState uses just
+// the first two.
+const (
+	doorOpen    = 0
+	doorClosed  = 1
+	doorOpening = 2
+	doorClosing = 3
+	doorStopped = 4
+)
+
+// DefaultTravel is how long doors are assumed to take to open or close.
+const DefaultTravel = 15 * time.Second
+
+// sensorPoll is how often door sensors are read.
+const sensorPoll = 500 * time.Millisecond
+
+// Sensor reports whether a door is closed, such as by reading a reed switch.
+type Sensor interface {
+	Closed() (bool, error)
+}
+
+// door is an opener's GarageDoorOpener service, and the model of its state.
+type door struct {
+	b       *Bridge
+	opener  *config.Opener
+	sensor  Sensor // nil if there is none.
+	travel  time.Duration
+	current *characteristic
+	target  *characteristic
+	blocked *characteristic
+
+	mu             sync.Mutex
+	currentState   int
+	targetState    int
+	obstructed     bool
+	moving         *time.Timer
+	gen            int  // Incremented on every movement, to ignore stale timers.
+	lastClosed     bool // Last sensor reading.
+	lastSensorRead bool // Whether lastClosed is valid.
+}
+
+// newDoor builds an opener's accessory.
+func newDoor(b *Bridge, aid int, o *config.Opener, sensor Sensor, travel time.Duration) (*door, *accessory) {
+	d := &door{b: b, opener: o, sensor: sensor, travel: travel, currentState: doorClosed, targetState: doorClosed}
+	if sensor != nil {
+		if closed, err := sensor.Closed(); err == nil {
+			d.lastClosed, d.lastSensorRead = closed, true
+			if !closed {
+				d.currentState, d.targetState = doorOpen, doorOpen
+			}
+		}
+	}
+
+	bld := newBuilder(aid)
+	bld.info(o.Name, o.Protocol, fmt.Sprintf("%s-%d", b.state.ID, aid), func() { b.logf("Identify %s", o.Name) })
+	s := bld.service(typeGarageDoorOpener, true)
+	d.current = bld.add(s, &characteristic{
+		typ: typeCurrentDoorState, perms: []string{permRead, permEvents}, format: "uint8",
+		minValue: intPtr(doorOpen), maxValue: intPtr(doorStopped),
+		read: func() interface{} { return d.state().currentState },
+	})
+	d.target = bld.add(s, &characteristic{
+		typ: typeTargetDoorState, perms: []string{permRead, permWrite, permEvents}, format: "uint8",
+		minValue: intPtr(doorOpen), maxValue: intPtr(doorClosed),
+		read: func() interface{} { return d.state().targetState },
+		write: func(v json.RawMessage) error {
+			t, err := parseInt(v)
+			if err != nil || (t != doorOpen && t != doorClosed) {
+				return fmt.Errorf("invalid target door state %s", v)
+			}
+			d.setTarget(t)
+			return nil
+		},
+	})
+	d.blocked = bld.add(s, &characteristic{
+		typ: typeObstructionDetected, perms: []string{permRead, permEvents}, format: "bool",
+		read: func() interface{} { return d.state().obstructed },
+	})
+	bld.add(s, constant(typeName, o.Name))
+	return d, bld.a
+}
+
+// doorState is a snapshot of a door's state.
+type doorState struct {
+	currentState, targetState int
+	obstructed                bool
+}
+
+func (d *door) state() doorState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return doorState{d.currentState, d.targetState, d.obstructed}
+}
+
+// notify tells controllers about the door's state.
+func (d *door) notify() {
+	s := d.state()
+	d.b.notify(map[*characteristic]interface{}{
+		d.current: s.currentState,
+		d.target:  s.targetState,
+		d.blocked: s.obstructed,
+	})
+}
+
+// startMoving records the door as moving towards target, to settle after
+// its travel time. d.mu must be held.
+func (d *door) startMoving(target int) {
+	d.targetState = target
+	d.currentState = doorOpening
+	if target == doorClosed {
+		d.currentState = doorClosing
+	}
+	d.gen++
+	if d.moving != nil {
+		d.moving.Stop()
+	}
+	gen := d.gen
+	d.moving = time.AfterFunc(d.travel, func() { d.settle(gen) })
+}
+
+// setTarget sends the opener's transmission to move the door to a target
+// state, unless it is there already.
+func (d *door) setTarget(target int) {
+	d.mu.Lock()
+	if d.targetState == target && d.currentState == target {
+		d.mu.Unlock()
+		return
+	}
+	prev := doorState{d.currentState, d.targetState, d.obstructed}
+	d.startMoving(target)
+	gen := d.gen
+	d.mu.Unlock()
+	d.notify()
+
+	// Without a sensor in the configuration, the server can't tell where
+	// the door is, so it can only toggle it.
+	action := server.Toggle
+	if d.opener.Sensor != nil {
+		action = server.Open
+		if target == doorClosed {
+			action = server.Close
+		}
+	}
+	go func() {
+		result, err := d.b.Trigger(context.Background(), d.opener.Name, action, "homekit")
+		if err == nil && result.State == server.StateFailed {
+			err = fmt.Errorf("%s", result.Error)
+		}
+		if err == nil {
+			d.b.logf("%s %s: sent", action, d.opener.Name)
+			return
+		}
+		d.b.logf("%s %s: %v", action, d.opener.Name, err)
+		d.mu.Lock()
+		if d.gen == gen {
+			if d.moving != nil {
+				d.moving.Stop()
+				d.moving = nil
+			}
+			d.gen++
+			d.currentState, d.targetState = prev.currentState, prev.targetState
+			if d.currentState == doorOpen || d.currentState == doorClosed {
+				d.targetState = d.currentState
+			}
+		}
+		d.mu.Unlock()
+		d.notify()
+	}()
+}
+
+// settle decides where the door ended up after moving: where the sensor says
+// it is, or otherwise where it was asked to go.
+func (d *door) settle(gen int) {
+	d.mu.Lock()
+	if d.gen != gen {
+		d.mu.Unlock()
+		return
+	}
+	d.moving = nil
+	d.obstructed = false
+	if d.sensor == nil {
+		d.currentState = d.targetState
+	} else if closed, err := d.sensor.Closed(); err != nil {
+		d.b.logf("%s: cannot read sensor: %v", d.opener.Name, err)
+		d.currentState = doorStopped
+	} else {
+		d.lastClosed, d.lastSensorRead = closed, true
+		switch {
+		case closed:
+			d.currentState, d.targetState = doorClosed, doorClosed
+		case d.targetState == doorOpen:
+			d.currentState = doorOpen
+		default:
+			// Asked to close, but still open: something is in the way.
+			d.currentState, d.targetState = doorOpen, doorOpen
+			d.obstructed = true
+		}
+	}
+	d.mu.Unlock()
+	d.notify()
+}
+
+// poll reads the sensor, following the door when it is moved by other
+// means, such as a wall button or a remote, until the context is cancelled.
+func (d *door) poll(ctx context.Context) {
+	if d.sensor == nil {
+		return
+	}
+	t := time.NewTicker(sensorPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		closed, err := d.sensor.Closed()
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		changed := d.lastSensorRead && closed != d.lastClosed
+		d.lastClosed, d.lastSensorRead = closed, true
+		if !changed {
+			d.mu.Unlock()
+			continue
+		}
+		switch {
+		case closed:
+			// Closing is over as soon as the door is down.
+			if d.moving != nil {
+				d.moving.Stop()
+				d.moving = nil
+			}
+			d.gen++
+			d.currentState, d.targetState, d.obstructed = doorClosed, doorClosed, false
+		case d.moving == nil:
+			// Opened by other means.
+			d.startMoving(doorOpen)
+		}
+		d.mu.Unlock()
+		d.notify()
+	}
+}
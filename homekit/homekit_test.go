@@ -0,0 +1,363 @@
+package homekit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/homekit"
+	"github.com/zellyn/openers/homekit/homekittest"
+	"github.com/zellyn/openers/server"
+)
+
+const testConfig = `{
+  "openers": [
+    {"name": "garage", "protocol": "secplus-v2", "fixed": "70678577664", "rolling": "240124710"},
+    {"name": "gate", "protocol": "megacode", "kind": "gate", "identifier": "0x876543"}
+  ]
+}`
+
+const setupCode = "031-45-154"
+
+// triggers records triggered openers, failing those named in fail.
+type triggers struct {
+	mu    sync.Mutex
+	calls []string
+	fail  map[string]bool
+}
+
+func (tr *triggers) trigger(ctx context.Context, opener, action, source string) (*server.Result, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.calls = append(tr.calls, fmt.Sprintf("%s %s from %s", action, opener, source))
+	if tr.fail[opener] {
+		return &server.Result{Opener: opener, Action: action, State: server.StateFailed, Error: "transmitter on fire"}, nil
+	}
+	return &server.Result{Opener: opener, Action: action, State: server.StateDone}, nil
+}
+
+func (tr *triggers) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return strings.Join(tr.calls, ", ")
+}
+
+// sensor is a door sensor set by the test.
+type sensor struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *sensor) Closed() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed, nil
+}
+
+func (s *sensor) set(closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = closed
+}
+
+// newBridge starts a bridge, returning its address and the state path.
+func newBridge(t *testing.T, tr *triggers, sensors map[string]homekit.Sensor) (*homekit.Bridge, string, string) {
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "homekit.json")
+	state, err := homekit.LoadState(path, setupCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := homekit.New(cfg, tr.trigger, state, path)
+	b.Travel = 50 * time.Millisecond
+	b.Sensors = sensors
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Serve(ctx, l)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return b, l.Addr().String(), path
+}
+
+// connect dials the bridge, verifying the connection as ctl.
+func connect(t *testing.T, addr string, ctl *homekittest.Controller) *homekittest.Conn {
+	t.Helper()
+	c, err := homekittest.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := ctl.Verify(c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// pair pairs ctl with the bridge.
+func pair(t *testing.T, addr string, ctl *homekittest.Controller) {
+	t.Helper()
+	c, err := homekittest.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := ctl.Pair(c, setupCode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// doorIIDs returns the instance IDs of an accessory's current and target
+// door state characteristics.
+func doorIIDs(t *testing.T, c *homekittest.Conn, aid int) (current, target int) {
+	t.Helper()
+	resp, err := c.JSON("GET", "/accessories", nil)
+	if err != nil || resp.Status != 200 {
+		t.Fatalf("GET /accessories: %v, %+v", err, resp)
+	}
+	var db struct {
+		Accessories []struct {
+			AID      int `json:"aid"`
+			Services []struct {
+				Type            string `json:"type"`
+				Characteristics []struct {
+					IID  int    `json:"iid"`
+					Type string `json:"type"`
+				} `json:"characteristics"`
+			} `json:"services"`
+		} `json:"accessories"`
+	}
+	if err := json.Unmarshal(resp.Body, &db); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range db.Accessories {
+		for _, s := range a.Services {
+			for _, ch := range s.Characteristics {
+				if a.AID == aid && ch.Type == "E" {
+					current = ch.IID
+				}
+				if a.AID == aid && ch.Type == "32" {
+					target = ch.IID
+				}
+			}
+		}
+	}
+	if current == 0 || target == 0 {
+		t.Fatalf("no door state characteristics for accessory %d in %s", aid, resp.Body)
+	}
+	return current, target
+}
+
+type value struct {
+	AID   int `json:"aid"`
+	IID   int `json:"iid"`
+	Value int `json:"value"`
+}
+
+// read reads a characteristic.
+func read(t *testing.T, c *homekittest.Conn, aid, iid int) int {
+	t.Helper()
+	resp, err := c.JSON("GET", fmt.Sprintf("/characteristics?id=%d.%d", aid, iid), nil)
+	if err != nil || resp.Status != 200 {
+		t.Fatalf("read %d.%d: %v, %+v", aid, iid, err, resp)
+	}
+	var body struct{ Characteristics []value }
+	if err := json.Unmarshal(resp.Body, &body); err != nil || len(body.Characteristics) != 1 {
+		t.Fatalf("read %d.%d: %v, %s", aid, iid, err, resp.Body)
+	}
+	return body.Characteristics[0].Value
+}
+
+// waitFor reads a characteristic until it has the wanted value.
+func waitFor(t *testing.T, c *homekittest.Conn, aid, iid, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := read(t, c, aid, iid)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d.%d = %d; got %d", aid, iid, want, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTLV(t *testing.T) {
+	long := bytes.Repeat([]byte{7}, 600)
+	enc := homekittest.TLV{{Type: 6, Value: []byte{1}}, {Type: 3, Value: long}, {Type: 1, Value: []byte("x")}}.Encode()
+	if len(enc) != 3+2*3+600+3 {
+		t.Errorf("want 600-byte value split into three fragments; got %d bytes", len(enc))
+	}
+	back := homekittest.ParseTLV(enc)
+	if len(back) != 3 || !bytes.Equal(back.Get(3), long) || string(back.Get(1)) != "x" {
+		t.Errorf("want items back; got %d items", len(back))
+	}
+}
+
+func TestSetupCode(t *testing.T) {
+	for _, tt := range []struct {
+		code string
+		ok   bool
+	}{
+		{"031-45-154", true},
+		{"03145154", false},
+		{"123-45-678", false},
+		{"111-11-111", false},
+		{"031-45-15x", false},
+	} {
+		if err := homekit.CheckSetupCode(tt.code); (err == nil) != tt.ok {
+			t.Errorf("CheckSetupCode(%q): want ok %v; got %v", tt.code, tt.ok, err)
+		}
+	}
+	s, err := homekit.LoadState(filepath.Join(t.TempDir(), "homekit.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := homekit.CheckSetupCode(s.SetupCode); err != nil {
+		t.Errorf("want valid random setup code; got %v", err)
+	}
+}
+
+func TestPairAndOpen(t *testing.T) {
+	tr := &triggers{}
+	b, addr, path := newBridge(t, tr, nil)
+
+	// Nothing but pairing before pair verify.
+	c, err := homekittest.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if resp, err := c.JSON("GET", "/accessories", nil); err != nil || resp.Status != 470 {
+		t.Errorf("want status 470 before verify; got %v, %+v", err, resp)
+	}
+
+	ctl := homekittest.NewController("11111111-2222-3333-4444-555555555555")
+	if err := ctl.Pair(c, "031-45-155"); err == nil {
+		t.Errorf("want pairing with wrong code to fail")
+	}
+	pair(t, addr, ctl)
+	if !b.Paired() {
+		t.Errorf("want bridge paired")
+	}
+	if err := ctl.Pair(c, setupCode); err == nil {
+		t.Errorf("want second pairing to fail")
+	}
+	intruder := homekittest.NewController("intruder")
+	intruder.AccessoryKey = ctl.AccessoryKey
+	if err := intruder.Verify(c); err == nil {
+		t.Errorf("want verify by unpaired controller to fail")
+	}
+
+	c = connect(t, addr, ctl)
+	current, target := doorIIDs(t, c, 2)
+	if got := read(t, c, 2, current); got != 1 {
+		t.Errorf("want garage closed; got %d", got)
+	}
+
+	// Subscribe, and open the garage.
+	events, err := c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []map[string]interface{}{
+		{"aid": 2, "iid": current, "ev": true},
+	}})
+	if err != nil || events.Status != 204 {
+		t.Fatalf("want subscription; got %v, %+v", err, events)
+	}
+	resp, err := c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []value{{2, target, 0}}})
+	if err != nil || resp.Status != 204 {
+		t.Fatalf("want write; got %v, %+v", err, resp)
+	}
+	var states []int
+	for len(states) == 0 || states[len(states)-1] != 0 {
+		ev, err := c.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct{ Characteristics []value }
+		if !ev.Event || json.Unmarshal(ev.Body, &body) != nil || len(body.Characteristics) != 1 {
+			t.Fatalf("want event for current state; got %+v", ev)
+		}
+		states = append(states, body.Characteristics[0].Value)
+	}
+	if fmt.Sprint(states) != "[2 0]" {
+		t.Errorf("want opening, then open; got %v", states)
+	}
+	if got := tr.String(); got != "toggle garage from homekit" {
+		t.Errorf("want garage toggled; got %q", got)
+	}
+
+	// Opening it again does nothing.
+	c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []value{{2, target, 0}}})
+	if got := tr.String(); got != "toggle garage from homekit" {
+		t.Errorf("want no second transmission; got %q", got)
+	}
+
+	// The pairing survives a restart.
+	state, err := homekit.LoadState(path, "")
+	if err != nil || len(state.Pairings) != 1 || state.Pairings[ctl.ID] == nil || !state.Pairings[ctl.ID].Admin {
+		t.Errorf("want saved admin pairing; got %+v, %v", state, err)
+	}
+}
+
+func TestFailedTransmission(t *testing.T) {
+	tr := &triggers{fail: map[string]bool{"gate": true}}
+	_, addr, _ := newBridge(t, tr, nil)
+	ctl := homekittest.NewController("controller")
+	pair(t, addr, ctl)
+	c := connect(t, addr, ctl)
+	current, target := doorIIDs(t, c, 3)
+	c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []value{{3, target, 0}}})
+	waitFor(t, c, 3, target, 1)
+	if got := read(t, c, 3, current); got != 1 {
+		t.Errorf("want gate still closed; got %d", got)
+	}
+}
+
+func TestSensor(t *testing.T) {
+	garage := &sensor{closed: true}
+	tr := &triggers{}
+	_, addr, _ := newBridge(t, tr, map[string]homekit.Sensor{"garage": garage})
+	ctl := homekittest.NewController("controller")
+	pair(t, addr, ctl)
+	c := connect(t, addr, ctl)
+	current, target := doorIIDs(t, c, 2)
+
+	// Asked to open, but the sensor says it's still closed.
+	c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []value{{2, target, 0}}})
+	waitFor(t, c, 2, current, 1)
+
+	// Opened with the wall button.
+	garage.set(false)
+	waitFor(t, c, 2, current, 0)
+	waitFor(t, c, 2, target, 0)
+
+	// Asked to close, but still open after the travel time.
+	c.JSON("PUT", "/characteristics", map[string]interface{}{"characteristics": []value{{2, target, 1}}})
+	waitFor(t, c, 2, target, 0)
+	if got := read(t, c, 2, current); got != 0 {
+		t.Errorf("want garage open; got %d", got)
+	}
+	if got := tr.String(); got != "toggle garage from homekit, toggle garage from homekit" {
+		t.Errorf("want two toggles; got %q", got)
+	}
+}
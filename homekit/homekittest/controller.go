@@ -0,0 +1,420 @@
+// Package homekittest provides a stand-in for a HomeKit controller, such as
+// the Home app, for testing package homekit.
+package homekittest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// srpN and srpG are the 3072-bit SRP group from RFC 5054.
+var srpN, _ = new(big.Int).SetString(""+
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05"+
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB"+
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718"+
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33"+
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7"+
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864"+
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2"+
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF", 16)
+
+var srpG = big.NewInt(5)
+
+func pad(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, (srpN.BitLen()+7)/8))
+}
+
+func hash(parts ...[]byte) []byte {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+func deriveKey(secret []byte, salt, info string) []byte {
+	key := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha512.New, secret, []byte(salt), []byte(info)), key)
+	return key
+}
+
+func nonce(label string) []byte {
+	n := make([]byte, 12)
+	copy(n[4:], label)
+	return n
+}
+
+// TLV is a sequence of TLV8 items; values over 255 bytes are fragmented.
+type TLV []Item
+
+// Item is a TLV8 item.
+type Item struct {
+	Type  byte
+	Value []byte
+}
+
+// Encode encodes the items.
+func (t TLV) Encode() []byte {
+	var b []byte
+	for _, item := range t {
+		v := item.Value
+		for {
+			n := len(v)
+			if n > 255 {
+				n = 255
+			}
+			b = append(b, item.Type, byte(n))
+			b = append(b, v[:n]...)
+			if v = v[n:]; len(v) == 0 {
+				break
+			}
+		}
+	}
+	return b
+}
+
+// ParseTLV decodes items, joining fragments.
+func ParseTLV(b []byte) TLV {
+	var t TLV
+	last := -1
+	for len(b) >= 2 && len(b) >= 2+int(b[1]) {
+		typ, v := b[0], b[2:2+int(b[1])]
+		b = b[2+len(v):]
+		if len(t) > 0 && t[len(t)-1].Type == typ && last == 255 {
+			t[len(t)-1].Value = append(t[len(t)-1].Value, v...)
+		} else {
+			t = append(t, Item{typ, append([]byte(nil), v...)})
+		}
+		last = len(v)
+	}
+	return t
+}
+
+// Get returns the first value of a type.
+func (t TLV) Get(typ byte) []byte {
+	for _, item := range t {
+		if item.Type == typ {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// Controller is a HomeKit controller with its own pairing identity.
+type Controller struct {
+	ID  string
+	Key ed25519.PrivateKey
+
+	// AccessoryID and AccessoryKey are learned by Pair.
+	AccessoryID  string
+	AccessoryKey ed25519.PublicKey
+}
+
+// NewController returns a controller with a fresh identity.
+func NewController(id string) *Controller {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	return &Controller{ID: id, Key: key}
+}
+
+// Response is an HTTP response or event from the accessory.
+type Response struct {
+	Event  bool
+	Status int
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// Conn is a connection to an accessory, encrypted after Verify.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu                sync.Mutex
+	readKey, writeKey []byte
+	readSeq, writeSeq uint64
+	plain             bytes.Buffer
+
+	events []*Response // Read while waiting for responses.
+}
+
+// Dial connects to an accessory.
+func Dial(addr string) (*Conn, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: nc}
+	c.r = bufio.NewReader(readerFunc(c.read))
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+// read reads plaintext, decrypting frames once the session is encrypted.
+func (c *Conn) read(p []byte) (int, error) {
+	c.mu.Lock()
+	key := c.readKey
+	c.mu.Unlock()
+	if key == nil {
+		return c.conn.Read(p)
+	}
+	if c.plain.Len() == 0 {
+		var hdr [2]byte
+		if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+			return 0, err
+		}
+		frame := make([]byte, int(binary.LittleEndian.Uint16(hdr[:]))+16)
+		if _, err := io.ReadFull(c.conn, frame); err != nil {
+			return 0, err
+		}
+		aead, _ := chacha20poly1305.New(key)
+		n := make([]byte, 12)
+		binary.LittleEndian.PutUint64(n[4:], c.readSeq)
+		c.readSeq++
+		plain, err := aead.Open(nil, n, frame, hdr[:])
+		if err != nil {
+			return 0, err
+		}
+		c.plain.Write(plain)
+	}
+	return c.plain.Read(p)
+}
+
+// write writes plaintext, encrypting it once the session is encrypted.
+func (c *Conn) write(p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writeKey == nil {
+		_, err := c.conn.Write(p)
+		return err
+	}
+	aead, _ := chacha20poly1305.New(c.writeKey)
+	var out []byte
+	for len(p) > 0 {
+		n := len(p)
+		if n > 1024 {
+			n = 1024
+		}
+		hdr := []byte{byte(n), byte(n >> 8)}
+		nc := make([]byte, 12)
+		binary.LittleEndian.PutUint64(nc[4:], c.writeSeq)
+		c.writeSeq++
+		out = append(out, hdr...)
+		out = aead.Seal(out, nc, p[:n], hdr)
+		p = p[n:]
+	}
+	_, err := c.conn.Write(out)
+	return err
+}
+
+// Do sends a request and reads the response, keeping any events that arrive
+// first for Next.
+func (c *Conn) Do(method, path, contentType string, body []byte) (*Response, error) {
+	req := fmt.Sprintf("%s %s HTTP/1.1\r\nHost: accessory\r\nContent-Length: %d\r\n", method, path, len(body))
+	if contentType != "" {
+		req += "Content-Type: " + contentType + "\r\n"
+	}
+	if err := c.write(append([]byte(req+"\r\n"), body...)); err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := c.readResponse()
+		if err != nil || !resp.Event {
+			return resp, err
+		}
+		c.events = append(c.events, resp)
+	}
+}
+
+// Next returns the next event.
+func (c *Conn) Next() (*Response, error) {
+	if len(c.events) > 0 {
+		ev := c.events[0]
+		c.events = c.events[1:]
+		return ev, nil
+	}
+	return c.readResponse()
+}
+
+// readResponse reads a response or event.
+func (c *Conn) readResponse() (*Response, error) {
+	tp := textproto.NewReader(c.r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("bad status line %q", line)
+	}
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("bad status line %q", line)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{Event: strings.HasPrefix(parts[0], "EVENT/"), Status: status, Header: header}
+	if l := header.Get("Content-Length"); l != "" {
+		n, _ := strconv.Atoi(l)
+		resp.Body = make([]byte, n)
+		if _, err := io.ReadFull(c.r, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// pairing sends a pairing request, returning the TLV8 response.
+func (c *Conn) pairing(path string, req TLV) (TLV, error) {
+	resp, err := c.Do("POST", path, "application/pairing+tlv8", req.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != 200 {
+		return nil, fmt.Errorf("%s: status %d", path, resp.Status)
+	}
+	t := ParseTLV(resp.Body)
+	if e := t.Get(7); e != nil {
+		return t, fmt.Errorf("%s: error %d", path, e[0])
+	}
+	return t, nil
+}
+
+// Pair performs pair setup with a setup code.
+func (ctl *Controller) Pair(c *Conn, code string) error {
+	m2, err := c.pairing("/pair-setup", TLV{{6, []byte{1}}, {0, []byte{0}}})
+	if err != nil {
+		return err
+	}
+	salt, B := m2.Get(2), new(big.Int).SetBytes(m2.Get(3))
+
+	aBytes := make([]byte, 32)
+	rand.Read(aBytes)
+	a := new(big.Int).SetBytes(aBytes)
+	A := new(big.Int).Exp(srpG, a, srpN)
+	k := new(big.Int).SetBytes(hash(srpN.Bytes(), pad(srpG)))
+	u := new(big.Int).SetBytes(hash(pad(A), pad(B)))
+	x := new(big.Int).SetBytes(hash(salt, hash([]byte("Pair-Setup:"+code))))
+	// S = (B - k*g^x) ^ (a + u*x)
+	base := new(big.Int).Sub(B, new(big.Int).Mul(k, new(big.Int).Exp(srpG, x, srpN)))
+	base.Mod(base, srpN)
+	S := new(big.Int).Exp(base, new(big.Int).Add(a, new(big.Int).Mul(u, x)), srpN)
+	K := hash(pad(S))
+	hN, hG := hash(srpN.Bytes()), hash(srpG.Bytes())
+	for i := range hN {
+		hN[i] ^= hG[i]
+	}
+	m1 := hash(hN, hash([]byte("Pair-Setup")), salt, pad(A), pad(B), K)
+
+	m4, err := c.pairing("/pair-setup", TLV{{6, []byte{3}}, {3, A.Bytes()}, {4, m1}})
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(m4.Get(4), hash(pad(A), m1, K)) {
+		return fmt.Errorf("bad accessory proof")
+	}
+
+	key := deriveKey(K, "Pair-Setup-Encrypt-Salt", "Pair-Setup-Encrypt-Info")
+	x5 := deriveKey(K, "Pair-Setup-Controller-Sign-Salt", "Pair-Setup-Controller-Sign-Info")
+	pub := ctl.Key.Public().(ed25519.PublicKey)
+	sig := ed25519.Sign(ctl.Key, append(append(x5, ctl.ID...), pub...))
+	aead, _ := chacha20poly1305.New(key)
+	sub := TLV{{1, []byte(ctl.ID)}, {3, pub}, {10, sig}}
+	m6, err := c.pairing("/pair-setup", TLV{{6, []byte{5}}, {5, aead.Seal(nil, nonce("PS-Msg05"), sub.Encode(), nil)}})
+	if err != nil {
+		return err
+	}
+	plain, err := aead.Open(nil, nonce("PS-Msg06"), m6.Get(5), nil)
+	if err != nil {
+		return err
+	}
+	sub = ParseTLV(plain)
+	ctl.AccessoryID, ctl.AccessoryKey = string(sub.Get(1)), ed25519.PublicKey(sub.Get(3))
+	x6 := deriveKey(K, "Pair-Setup-Accessory-Sign-Salt", "Pair-Setup-Accessory-Sign-Info")
+	if !ed25519.Verify(ctl.AccessoryKey, append(append(x6, ctl.AccessoryID...), ctl.AccessoryKey...), sub.Get(10)) {
+		return fmt.Errorf("bad accessory signature")
+	}
+	return nil
+}
+
+// Verify performs pair verify, after which the connection is encrypted.
+func (ctl *Controller) Verify(c *Conn) error {
+	if ctl.AccessoryKey == nil {
+		return fmt.Errorf("not paired")
+	}
+	var secret, public [32]byte
+	rand.Read(secret[:])
+	curve25519.ScalarBaseMult(&public, &secret)
+	m2, err := c.pairing("/pair-verify", TLV{{6, []byte{1}}, {3, public[:]}})
+	if err != nil {
+		return err
+	}
+	var accessoryPublic, shared [32]byte
+	copy(accessoryPublic[:], m2.Get(3))
+	curve25519.ScalarMult(&shared, &secret, &accessoryPublic)
+	key := deriveKey(shared[:], "Pair-Verify-Encrypt-Salt", "Pair-Verify-Encrypt-Info")
+	aead, _ := chacha20poly1305.New(key)
+	plain, err := aead.Open(nil, nonce("PV-Msg02"), m2.Get(5), nil)
+	if err != nil {
+		return err
+	}
+	sub := ParseTLV(plain)
+	info := append(append(append([]byte(nil), accessoryPublic[:]...), sub.Get(1)...), public[:]...)
+	if !ed25519.Verify(ctl.AccessoryKey, info, sub.Get(10)) {
+		return fmt.Errorf("bad accessory signature")
+	}
+
+	info = append(append(append([]byte(nil), public[:]...), ctl.ID...), accessoryPublic[:]...)
+	sub = TLV{{1, []byte(ctl.ID)}, {10, ed25519.Sign(ctl.Key, info)}}
+	if _, err := c.pairing("/pair-verify", TLV{{6, []byte{3}}, {5, aead.Seal(nil, nonce("PV-Msg03"), sub.Encode(), nil)}}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.writeKey = deriveKey(shared[:], "Control-Salt", "Control-Write-Encryption-Key")
+	c.readKey = deriveKey(shared[:], "Control-Salt", "Control-Read-Encryption-Key")
+	c.mu.Unlock()
+	return nil
+}
+
+// JSON sends a request with a JSON body, if v is not nil, and returns the
+// response.
+func (c *Conn) JSON(method, path string, v interface{}) (*Response, error) {
+	var body []byte
+	if v != nil {
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return c.Do(method, path, "application/hap+json", body)
+}
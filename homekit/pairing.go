@@ -0,0 +1,285 @@
+package homekit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// maxSetupAttempts is how many wrong setup codes are allowed before pair
+// setup is refused until the bridge restarts.
+const maxSetupAttempts = 100
+
+// verifyState is the accessory's side of a pair verify exchange.
+type verifyState struct {
+	public, controllerPublic [32]byte
+	shared                   [32]byte
+	key                      []byte
+}
+
+// errorTLV returns a pairing response reporting an error.
+func errorTLV(state, code byte) tlv {
+	var t tlv
+	t.addByte(tlvState, state)
+	t.addByte(tlvError, code)
+	return t
+}
+
+// pairSetup handles a step of pair setup, in which a controller proves it
+// knows the setup code, and the bridge and controller exchange long-term
+// public keys.
+func (b *Bridge) pairSetup(c *conn, req tlv) tlv {
+	switch req.getByte(tlvState) {
+	case 1:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		switch {
+		case b.state.paired():
+			return errorTLV(2, errUnavailable)
+		case b.setupAttempts >= maxSetupAttempts:
+			return errorTLV(2, errMaxTries)
+		case b.setupConn != nil && b.setupConn != c:
+			return errorTLV(2, errBusy)
+		}
+		s, err := newSRPServer(b.state.SetupCode)
+		if err != nil {
+			return errorTLV(2, errUnknown)
+		}
+		b.setupConn = c
+		c.setup = s
+		var resp tlv
+		resp.addByte(tlvState, 2)
+		resp.add(tlvPublicKey, s.B.Bytes())
+		resp.add(tlvSalt, s.salt)
+		return resp
+
+	case 3:
+		if c.setup == nil {
+			return errorTLV(4, errUnknown)
+		}
+		proof, err := c.setup.verify(req.get(tlvPublicKey), req.get(tlvProof))
+		if err != nil {
+			b.mu.Lock()
+			b.setupAttempts++
+			b.setupConn = nil
+			b.mu.Unlock()
+			c.setup = nil
+			b.logf("Pair setup failed: %v", err)
+			return errorTLV(4, errAuthentication)
+		}
+		var resp tlv
+		resp.addByte(tlvState, 4)
+		resp.add(tlvProof, proof)
+		return resp
+
+	case 5:
+		if c.setup == nil || c.setup.key == nil {
+			return errorTLV(6, errUnknown)
+		}
+		resp, err := b.exchangeKeys(c, req)
+		c.setup = nil
+		b.mu.Lock()
+		b.setupConn = nil
+		b.mu.Unlock()
+		if err != nil {
+			b.logf("Pair setup failed: %v", err)
+			return errorTLV(6, errAuthentication)
+		}
+		return resp
+	}
+	return errorTLV(2, errUnknown)
+}
+
+// exchangeKeys checks the controller's long-term key, records the pairing,
+// and replies with the bridge's.
+func (b *Bridge) exchangeKeys(c *conn, req tlv) (tlv, error) {
+	key := deriveKey(c.setup.key, "Pair-Setup-Encrypt-Salt", "Pair-Setup-Encrypt-Info")
+	plain, err := open(key, "PS-Msg05", req.get(tlvEncryptedData))
+	if err != nil {
+		return nil, err
+	}
+	sub, err := parseTLV(plain)
+	if err != nil {
+		return nil, err
+	}
+	id, ltpk, sig := sub.get(tlvIdentifier), sub.get(tlvPublicKey), sub.get(tlvSignature)
+	if len(id) == 0 || len(ltpk) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid controller identity")
+	}
+	controllerX := deriveKey(c.setup.key, "Pair-Setup-Controller-Sign-Salt", "Pair-Setup-Controller-Sign-Info")
+	info := append(append(controllerX, id...), ltpk...)
+	if !ed25519.Verify(ltpk, info, sig) {
+		return nil, fmt.Errorf("invalid controller signature")
+	}
+
+	b.mu.Lock()
+	b.state.Pairings[string(id)] = &Pairing{PublicKey: ltpk, Admin: true}
+	err = b.saveLocked()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	b.logf("Paired with controller %s", id)
+	b.advertise()
+
+	accessoryX := deriveKey(c.setup.key, "Pair-Setup-Accessory-Sign-Salt", "Pair-Setup-Accessory-Sign-Info")
+	priv := b.state.key()
+	pub := priv.Public().(ed25519.PublicKey)
+	info = append(append(accessoryX, b.state.ID...), pub...)
+	var reply tlv
+	reply.add(tlvIdentifier, []byte(b.state.ID))
+	reply.add(tlvPublicKey, pub)
+	reply.add(tlvSignature, ed25519.Sign(priv, info))
+	encrypted, err := seal(key, "PS-Msg06", reply.encode())
+	if err != nil {
+		return nil, err
+	}
+	var resp tlv
+	resp.addByte(tlvState, 6)
+	resp.add(tlvEncryptedData, encrypted)
+	return resp, nil
+}
+
+// pairVerify handles a step of pair verify, in which a paired controller and
+// the bridge prove their identities to each other and agree on keys for the
+// session.
+func (b *Bridge) pairVerify(c *conn, req tlv) tlv {
+	switch req.getByte(tlvState) {
+	case 1:
+		controllerPublic := req.get(tlvPublicKey)
+		if len(controllerPublic) != 32 {
+			return errorTLV(2, errUnknown)
+		}
+		v := &verifyState{}
+		copy(v.controllerPublic[:], controllerPublic)
+		var secret [32]byte
+		if _, err := rand.Read(secret[:]); err != nil {
+			return errorTLV(2, errUnknown)
+		}
+		curve25519.ScalarBaseMult(&v.public, &secret)
+		curve25519.ScalarMult(&v.shared, &secret, &v.controllerPublic)
+		v.key = deriveKey(v.shared[:], "Pair-Verify-Encrypt-Salt", "Pair-Verify-Encrypt-Info")
+
+		info := append(append(append([]byte(nil), v.public[:]...), b.state.ID...), v.controllerPublic[:]...)
+		var sub tlv
+		sub.add(tlvIdentifier, []byte(b.state.ID))
+		sub.add(tlvSignature, ed25519.Sign(b.state.key(), info))
+		encrypted, err := seal(v.key, "PV-Msg02", sub.encode())
+		if err != nil {
+			return errorTLV(2, errUnknown)
+		}
+		c.verify = v
+		var resp tlv
+		resp.addByte(tlvState, 2)
+		resp.add(tlvPublicKey, v.public[:])
+		resp.add(tlvEncryptedData, encrypted)
+		return resp
+
+	case 3:
+		v := c.verify
+		c.verify = nil
+		if v == nil {
+			return errorTLV(4, errUnknown)
+		}
+		plain, err := open(v.key, "PV-Msg03", req.get(tlvEncryptedData))
+		if err != nil {
+			return errorTLV(4, errAuthentication)
+		}
+		sub, err := parseTLV(plain)
+		if err != nil {
+			return errorTLV(4, errAuthentication)
+		}
+		id := string(sub.get(tlvIdentifier))
+		b.mu.Lock()
+		p := b.state.Pairings[id]
+		b.mu.Unlock()
+		if p == nil {
+			b.logf("Pair verify from unknown controller %s", id)
+			return errorTLV(4, errAuthentication)
+		}
+		info := append(append(append([]byte(nil), v.controllerPublic[:]...), id...), v.public[:]...)
+		if !ed25519.Verify(p.PublicKey, info, sub.get(tlvSignature)) {
+			return errorTLV(4, errAuthentication)
+		}
+		s, err := newSession(
+			deriveKey(v.shared[:], "Control-Salt", "Control-Write-Encryption-Key"),
+			deriveKey(v.shared[:], "Control-Salt", "Control-Read-Encryption-Key"))
+		if err != nil {
+			return errorTLV(4, errUnknown)
+		}
+		c.secure(s, id)
+		var resp tlv
+		resp.addByte(tlvState, 4)
+		return resp
+	}
+	return errorTLV(2, errUnknown)
+}
+
+// pairings handles adding, removing and listing pairings, which only admin
+// controllers may do.
+func (b *Bridge) pairings(c *conn, req tlv) tlv {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p := b.state.Pairings[c.verified()]; p == nil || !p.Admin {
+		return errorTLV(2, errAuthentication)
+	}
+	var resp tlv
+	resp.addByte(tlvState, 2)
+	switch req.getByte(tlvMethod) {
+	case methodAddPairing:
+		id, ltpk := string(req.get(tlvIdentifier)), req.get(tlvPublicKey)
+		if id == "" || len(ltpk) != ed25519.PublicKeySize {
+			return errorTLV(2, errUnknown)
+		}
+		if p := b.state.Pairings[id]; p != nil {
+			if string(p.PublicKey) != string(ltpk) {
+				return errorTLV(2, errUnknown)
+			}
+		} else if len(b.state.Pairings) >= maxPairings {
+			return errorTLV(2, errMaxPeers)
+		}
+		b.state.Pairings[id] = &Pairing{PublicKey: ltpk, Admin: req.getByte(tlvPermissions) == 1}
+		if err := b.saveLocked(); err != nil {
+			return errorTLV(2, errUnknown)
+		}
+		b.logf("Added pairing for controller %s", id)
+
+	case methodRemovePairing:
+		id := string(req.get(tlvIdentifier))
+		delete(b.state.Pairings, id)
+		if err := b.saveLocked(); err != nil {
+			return errorTLV(2, errUnknown)
+		}
+		b.logf("Removed pairing for controller %s", id)
+		// Other connections from the removed controller are closed now; this
+		// one once the response has been sent.
+		for other := range b.conns {
+			if other != c && other.verified() == id {
+				other.Close()
+			}
+		}
+		go b.advertise()
+
+	case methodListPairings:
+		first := true
+		for id, p := range b.state.Pairings {
+			if !first {
+				resp.add(tlvSeparator, nil)
+			}
+			first = false
+			perms := byte(0)
+			if p.Admin {
+				perms = 1
+			}
+			resp.add(tlvIdentifier, []byte(id))
+			resp.add(tlvPublicKey, p.PublicKey)
+			resp.addByte(tlvPermissions, perms)
+		}
+
+	default:
+		return errorTLV(2, errUnknown)
+	}
+	return resp
+}
@@ -0,0 +1,116 @@
+package homekit
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+)
+
+// srpUsername is the SRP username HAP uses for pair setup.
+const srpUsername = "Pair-Setup"
+
+// srpN is the 3072-bit group from RFC 5054, with generator srpG.
+var srpN, _ = new(big.Int).SetString(""+
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05"+
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB"+
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718"+
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33"+
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7"+
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864"+
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2"+
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF", 16)
+
+var srpG = big.NewInt(5)
+
+// srpLen is the length of the group's numbers, in bytes.
+var srpLen = (srpN.BitLen() + 7) / 8
+
+// pad encodes n big-endian, padded to the length of the group's numbers.
+func pad(n *big.Int) []byte {
+	b := make([]byte, srpLen)
+	return n.FillBytes(b)
+}
+
+// hash is SHA-512 of the concatenated parts.
+func hash(parts ...[]byte) []byte {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// srpVerifier returns the password verifier v for a setup code and salt.
+func srpVerifier(salt []byte, code string) *big.Int {
+	x := new(big.Int).SetBytes(hash(salt, hash([]byte(srpUsername+":"+code))))
+	return new(big.Int).Exp(srpG, x, srpN)
+}
+
+// srpMultiplier returns k = H(N | PAD(g)).
+func srpMultiplier() *big.Int {
+	return new(big.Int).SetBytes(hash(srpN.Bytes(), pad(srpG)))
+}
+
+// srpServer is the accessory's side of an SRP-6a exchange.
+type srpServer struct {
+	salt []byte
+	v    *big.Int
+	b    *big.Int
+	B    *big.Int
+	key  []byte // K, the shared session key, once the client's proof is checked.
+}
+
+// newSRPServer starts an exchange for a setup code, with a fresh salt.
+func newSRPServer(code string) (*srpServer, error) {
+	salt := make([]byte, 16)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	s := &srpServer{salt: salt, v: srpVerifier(salt, code), b: new(big.Int).SetBytes(secret)}
+	// B = k*v + g^b
+	s.B = new(big.Int).Mul(srpMultiplier(), s.v)
+	s.B.Add(s.B, new(big.Int).Exp(srpG, s.b, srpN))
+	s.B.Mod(s.B, srpN)
+	return s, nil
+}
+
+// srpProof returns the client's proof, M1, and the session key, K.
+func srpProof(A, B, S *big.Int, salt []byte) (m1, key []byte) {
+	key = hash(pad(S))
+	hN, hG := hash(srpN.Bytes()), hash(srpG.Bytes())
+	for i := range hN {
+		hN[i] ^= hG[i]
+	}
+	m1 = hash(hN, hash([]byte(srpUsername)), salt, pad(A), pad(B), key)
+	return m1, key
+}
+
+// verify checks the client's public value and proof, returning the
+// server's proof, M2, if they are good.
+func (s *srpServer) verify(aBytes, m1 []byte) ([]byte, error) {
+	A := new(big.Int).SetBytes(aBytes)
+	if new(big.Int).Mod(A, srpN).Sign() == 0 {
+		return nil, fmt.Errorf("invalid SRP public key")
+	}
+	u := new(big.Int).SetBytes(hash(pad(A), pad(s.B)))
+	// S = (A * v^u) ^ b
+	S := new(big.Int).Exp(s.v, u, srpN)
+	S.Mul(S, A)
+	S.Exp(S, s.b, srpN)
+	want, key := srpProof(A, s.B, S, s.salt)
+	if subtle.ConstantTimeCompare(want, m1) != 1 {
+		return nil, fmt.Errorf("wrong setup code")
+	}
+	s.key = key
+	return hash(pad(A), m1, key), nil
+}
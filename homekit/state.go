@@ -0,0 +1,157 @@
+package homekit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+// DefaultStatePath is where the bridge keeps its keys and pairings by
+// default.
+const DefaultStatePath = "/var/lib/openers/homekit.json"
+
+// maxPairings is the most controllers that can be paired at once.
+const maxPairings = 16
+
+// Pairing is a paired controller.
+type Pairing struct {
+	PublicKey []byte `json:"public_key"` // Ed25519.
+	Admin     bool   `json:"admin"`
+}
+
+// State is everything the bridge must remember across restarts.
+type State struct {
+	// ID is the bridge's pairing identifier, which looks like a MAC address.
+	ID string `json:"id"`
+	// Seed is the seed of the bridge's Ed25519 long-term key.
+	Seed []byte `json:"seed"`
+	// SetupCode is the code entered in the Home app to pair, such as
+	// "123-45-678".
+	SetupCode string `json:"setup_code"`
+	// ConfigNumber is incremented whenever the accessories change, so that
+	// controllers reload them; ConfigHash identifies the current ones.
+	ConfigNumber int    `json:"config_number"`
+	ConfigHash   string `json:"config_hash"`
+	// Pairings are the paired controllers, by pairing identifier.
+	Pairings map[string]*Pairing `json:"pairings"`
+}
+
+// key returns the bridge's long-term key.
+func (s *State) key() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(s.Seed)
+}
+
+// LoadState reads the state file, creating new keys, identifier and, unless
+// one is given, setup code if it does not exist yet.
+func LoadState(path, setupCode string) (*State, error) {
+	s := &State{}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		if s.ID, err = randomID(); err != nil {
+			return nil, err
+		}
+		s.Seed = make([]byte, ed25519.SeedSize)
+		if _, err := rand.Read(s.Seed); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(s.Seed) != ed25519.SeedSize || s.ID == "" {
+			return nil, fmt.Errorf("%s: invalid key or identifier", path)
+		}
+	}
+	if s.Pairings == nil {
+		s.Pairings = map[string]*Pairing{}
+	}
+	if setupCode != "" {
+		if err := CheckSetupCode(setupCode); err != nil {
+			return nil, err
+		}
+		s.SetupCode = setupCode
+	}
+	if s.SetupCode == "" {
+		if s.SetupCode, err = randomSetupCode(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Save writes the state file, readable only by its owner, replacing it
+// atomically.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, append(data, '\n'), 0600)
+}
+
+// paired reports whether any controller is paired.
+func (s *State) paired() bool {
+	return len(s.Pairings) > 0
+}
+
+// randomID returns a random pairing identifier.
+func randomID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	parts := make([]string, len(b))
+	for i, x := range b {
+		parts[i] = fmt.Sprintf("%02X", x)
+	}
+	return strings.Join(parts, ":"), nil
+}
+
+// trivialCodes are setup codes the specification forbids.
+var trivialCodes = map[string]bool{
+	"000-00-000": true, "111-11-111": true, "222-22-222": true, "333-33-333": true,
+	"444-44-444": true, "555-55-555": true, "666-66-666": true, "777-77-777": true,
+	"888-88-888": true, "999-99-999": true, "123-45-678": true, "876-54-321": true,
+}
+
+// CheckSetupCode checks that a setup code has the form "XXX-XX-XXX", and is
+// not one of the forbidden trivial codes.
+func CheckSetupCode(code string) error {
+	if len(code) != 10 || code[3] != '-' || code[6] != '-' {
+		return fmt.Errorf("invalid setup code %q; want eg. 031-45-154", code)
+	}
+	for i, r := range code {
+		if i != 3 && i != 6 && (r < '0' || r > '9') {
+			return fmt.Errorf("invalid setup code %q; want eg. 031-45-154", code)
+		}
+	}
+	if trivialCodes[code] {
+		return fmt.Errorf("setup code %q is too easy to guess", code)
+	}
+	return nil
+}
+
+// randomSetupCode returns a random, non-trivial setup code.
+func randomSetupCode() (string, error) {
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+		if err != nil {
+			return "", err
+		}
+		d := fmt.Sprintf("%08d", n)
+		code := d[:3] + "-" + d[3:5] + "-" + d[5:]
+		if !trivialCodes[code] {
+			return code, nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package homekit
+
+import (
+	"fmt"
+)
+
+// TLV8 item types used in pairing.
+const (
+	tlvMethod        = 0x00
+	tlvIdentifier    = 0x01
+	tlvSalt          = 0x02
+	tlvPublicKey     = 0x03
+	tlvProof         = 0x04
+	tlvEncryptedData = 0x05
+	tlvState         = 0x06
+	tlvError         = 0x07
+	tlvSignature     = 0x0a
+	tlvPermissions   = 0x0b
+	tlvSeparator     = 0xff
+)
+
+// TLV8 error codes.
+const (
+	errUnknown        = 0x01
+	errAuthentication = 0x02
+	errMaxPeers       = 0x04
+	errMaxTries       = 0x05
+	errUnavailable    = 0x06
+	errBusy           = 0x07
+)
+
+// Pairing methods.
+const (
+	methodPairSetup     = 0
+	methodAddPairing    = 3
+	methodRemovePairing = 4
+	methodListPairings  = 5
+)
+
+// tlvItem is a single TLV8 item.
+type tlvItem struct {
+	typ   byte
+	value []byte
+}
+
+// tlv is a sequence of TLV8 items, in order.
+type tlv []tlvItem
+
+// add appends an item.
+func (t *tlv) add(typ byte, value []byte) {
+	*t = append(*t, tlvItem{typ, value})
+}
+
+// addByte appends a single-byte item.
+func (t *tlv) addByte(typ, value byte) {
+	t.add(typ, []byte{value})
+}
+
+// get returns the value of the first item of a type, or nil.
+func (t tlv) get(typ byte) []byte {
+	for _, item := range t {
+		if item.typ == typ {
+			return item.value
+		}
+	}
+	return nil
+}
+
+// getByte returns the value of a single-byte item, or -1.
+func (t tlv) getByte(typ byte) int {
+	v := t.get(typ)
+	if len(v) != 1 {
+		return -1
+	}
+	return int(v[0])
+}
+
+// encode encodes the items, splitting values longer than 255 bytes into
+// consecutive fragments.
+func (t tlv) encode() []byte {
+	var b []byte
+	for _, item := range t {
+		v := item.value
+		for {
+			n := len(v)
+			if n > 255 {
+				n = 255
+			}
+			b = append(b, item.typ, byte(n))
+			b = append(b, v[:n]...)
+			v = v[n:]
+			if len(v) == 0 {
+				break
+			}
+		}
+	}
+	return b
+}
+
+// parseTLV decodes items, joining consecutive fragments of the same type.
+func parseTLV(b []byte) (tlv, error) {
+	var t tlv
+	lastLen := -1
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, fmt.Errorf("truncated TLV8 item")
+		}
+		typ, v := b[0], b[2:2+int(b[1])]
+		b = b[2+len(v):]
+		if len(t) > 0 && t[len(t)-1].typ == typ && lastLen == 255 {
+			t[len(t)-1].value = append(t[len(t)-1].value, v...)
+		} else {
+			t = append(t, tlvItem{typ, append([]byte(nil), v...)})
+		}
+		lastLen = len(v)
+	}
+	return t, nil
+}
@@ -0,0 +1,53 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "codes.json")
+
+	testcases := []struct {
+		data string
+		perm os.FileMode
+	}{
+		{"first\n", 0600},
+		{"second\n", 0644},
+	}
+	for _, tt := range testcases {
+		if err := atomicfile.Write(path, []byte(tt.data), tt.perm); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil || string(got) != tt.data {
+			t.Errorf("want %q; got %q, %v", tt.data, got, err)
+		}
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != tt.perm {
+			t.Errorf("want mode %v; got %v, %v", tt.perm, fi.Mode().Perm(), err)
+		}
+	}
+
+	failed := errors.New("failed")
+	err := atomicfile.WriteFunc(path, 0644, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return failed
+	})
+	if err != failed {
+		t.Errorf("want error from write; got %v", err)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != "second\n" {
+		t.Errorf("want file left as it was after failed write; got %q", got)
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("want no temporary files left behind; got %d entries, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package atomicfile replaces state files atomically, so that a crash or power
+cut leaves either the old contents or the new, never a mix of the two.
+
+The new contents are written to a temporary file next to the old one, synced
+to disk, and renamed over it, and then the directory is synced so that the
+rename itself survives.
+*/
+package atomicfile
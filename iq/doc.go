@@ -0,0 +1,9 @@
+/*
+Package iq reads IQ recordings, such as those made with rtl_sdr from an
+RTL-SDR dongle, and turns them into pulses by envelope detection.
+
+The carrier does not need to be centered, since only the magnitude of each
+sample is used: the threshold between carrier-on and carrier-off is chosen
+automatically from the noise floor and the strongest signal in the recording.
+*/
+package iq
@@ -0,0 +1,134 @@
+package iq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// Format is the sample format of an IQ recording.
+type Format int
+
+const (
+	// CU8 is interleaved unsigned 8-bit I and Q, as written by rtl_sdr.
+	CU8 Format = iota
+	// CS16 is interleaved signed 16-bit little-endian I and Q.
+	CS16
+	// CF32 is interleaved 32-bit little-endian floating point I and Q, as
+	// written by GNU Radio.
+	CF32
+)
+
+var formatNames = map[Format]string{
+	CU8:  "cu8",
+	CS16: "cs16",
+	CF32: "cf32",
+}
+
+// String returns the conventional file extension (without the dot) for a
+// format.
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// sampleSize returns the size in bytes of a single (complex) sample.
+func (f Format) sampleSize() int {
+	switch f {
+	case CU8:
+		return 2
+	case CS16:
+		return 4
+	}
+	return 8
+}
+
+// ParseFormat returns the format with the given name, as returned by String.
+func ParseFormat(name string) (Format, error) {
+	for f, n := range formatNames {
+		if strings.EqualFold(name, n) {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown IQ format %q; expected cu8, cs16 or cf32", name)
+}
+
+// FormatFromFilename guesses the format of a recording from its file
+// extension.
+func FormatFromFilename(filename string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if ext == "" {
+		return 0, fmt.Errorf("cannot guess IQ format of %q: no file extension", filename)
+	}
+	return ParseFormat(ext)
+}
+
+// DefaultResolution is the envelope resolution used by Pulses: fine enough
+// for Security+2.0's 250µs pulses, while keeping long recordings manageable.
+const DefaultResolution = 10 * time.Microsecond
+
+// Envelope reads IQ samples in the given format, recorded at rate samples per
+// second, and returns their magnitude averaged over windows lasting roughly
+// resolution. It also returns the resulting envelope sample rate.
+func Envelope(r io.Reader, format Format, rate float64, resolution time.Duration) ([]float32, float64, error) {
+	if rate <= 0 {
+		return nil, 0, fmt.Errorf("sample rate must be positive; got %g", rate)
+	}
+	window := int(math.Round(resolution.Seconds() * rate))
+	if window < 1 {
+		window = 1
+	}
+
+	br := bufio.NewReaderSize(r, 1<<16)
+	buf := make([]byte, format.sampleSize())
+	var result []float32
+	var sum float64
+	count := 0
+	for {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, 0, err
+		}
+		var i, q float64
+		switch format {
+		case CU8:
+			i, q = float64(buf[0])-127.5, float64(buf[1])-127.5
+		case CS16:
+			i = float64(int16(binary.LittleEndian.Uint16(buf[0:])))
+			q = float64(int16(binary.LittleEndian.Uint16(buf[2:])))
+		case CF32:
+			i = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[0:])))
+			q = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4:])))
+		default:
+			return nil, 0, fmt.Errorf("unknown IQ format %v", format)
+		}
+		sum += math.Sqrt(i*i + q*q)
+		count++
+		if count == window {
+			result = append(result, float32(sum/float64(window)))
+			sum, count = 0, 0
+		}
+	}
+	return result, rate / float64(window), nil
+}
+
+// Pulses reads IQ samples in the given format, recorded at rate samples per
+// second, and slices them into pulses.
+func Pulses(r io.Reader, format Format, rate float64) ([]waveform.Pulse, error) {
+	envelope, envelopeRate, err := Envelope(r, format, rate, DefaultResolution)
+	if err != nil {
+		return nil, err
+	}
+	return waveform.FromEnvelope(envelope, envelopeRate), nil
+}
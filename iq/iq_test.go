@@ -0,0 +1,146 @@
+package iq_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/iq"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// synthesize renders pulses as a noisy IQ recording of an off-center carrier.
+func synthesize(format iq.Format, pulses []waveform.Pulse, rate float64, amplitude float64, noise float64) []byte {
+	rng := rand.New(rand.NewSource(1))
+	const offset = 123e3 // Carrier offset from the center frequency, in Hz.
+	var buf bytes.Buffer
+	n := 0
+	for _, p := range pulses {
+		samples := int(p.Duration.Seconds() * rate)
+		for s := 0; s < samples; s++ {
+			phase := 2 * math.Pi * offset * float64(n) / rate
+			i, q := rng.NormFloat64()*noise, rng.NormFloat64()*noise
+			if p.Level == 1 {
+				i += amplitude * math.Cos(phase)
+				q += amplitude * math.Sin(phase)
+			}
+			switch format {
+			case iq.CU8:
+				buf.WriteByte(byte(math.Max(0, math.Min(255, math.Round(i+127.5)))))
+				buf.WriteByte(byte(math.Max(0, math.Min(255, math.Round(q+127.5)))))
+			case iq.CS16:
+				binary.Write(&buf, binary.LittleEndian, [2]int16{int16(i * 256), int16(q * 256)})
+			case iq.CF32:
+				binary.Write(&buf, binary.LittleEndian, [2]float32{float32(i / 128), float32(q / 128)})
+			}
+			n++
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRecording(t *testing.T) {
+	bursts, err := secplus.EncodeV2ToBursts(0, 70678577664, 240124710)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pulses []waveform.Pulse
+	pulses = append(pulses, waveform.Pulse{Level: 0, Duration: 20 * time.Millisecond})
+	pulses = append(pulses, waveform.FromBits(bursts[0], 250*time.Microsecond)...)
+	pulses = append(pulses, waveform.Pulse{Level: 0, Duration: 90 * time.Millisecond})
+	pulses = append(pulses, waveform.FromBits(bursts[1], 250*time.Microsecond)...)
+	pulses = append(pulses, waveform.Pulse{Level: 0, Duration: 20 * time.Millisecond})
+
+	want := []demod.Message{{
+		Start:    20 * time.Millisecond,
+		Protocol: demod.SecplusV2,
+		FixedLow: 70678577664,
+		Rolling:  240124710,
+	}}
+
+	for _, format := range []iq.Format{iq.CU8, iq.CS16, iq.CF32} {
+		t.Run(format.String(), func(t *testing.T) {
+			const rate = 2.4e6
+			recording := synthesize(format, pulses, rate, 40, 4)
+			got, err := iq.Pulses(bytes.NewReader(recording), format, rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := demod.Decode(got)
+			// Allow for the start to be off by a sample or two.
+			for i := range messages {
+				messages[i].Start = messages[i].Start.Round(time.Millisecond)
+			}
+			if !reflect.DeepEqual(messages, want) {
+				t.Errorf("want %v; got %v", want, messages)
+			}
+		})
+	}
+}
+
+func TestSquelch(t *testing.T) {
+	recording := synthesize(iq.CU8, []waveform.Pulse{{Level: 0, Duration: 50 * time.Millisecond}}, 1e6, 0, 4)
+	got, err := iq.Pulses(bytes.NewReader(recording), iq.CU8, 1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Level != 0 {
+		t.Errorf("want a single period of silence from pure noise; got %d pulses", len(got))
+	}
+}
+
+func TestMostlyOn(t *testing.T) {
+	pulses := []waveform.Pulse{
+		{Level: 0, Duration: 5 * time.Millisecond},
+		{Level: 1, Duration: 40 * time.Millisecond},
+		{Level: 0, Duration: 5 * time.Millisecond},
+	}
+	recording := synthesize(iq.CU8, pulses, 1e6, 40, 4)
+	got, err := iq.Pulses(bytes.NewReader(recording), iq.CU8, 1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1].Level != 1 || got[1].Duration.Round(time.Millisecond) != 40*time.Millisecond {
+		t.Errorf("want a 40ms pulse from a mostly-on recording; got %v", got)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	testcases := []struct {
+		filename string
+		want     iq.Format
+		wantErr  bool
+	}{
+		{filename: "capture.cu8", want: iq.CU8},
+		{filename: "/tmp/capture.CS16", want: iq.CS16},
+		{filename: "capture.cf32", want: iq.CF32},
+		{filename: "capture", wantErr: true},
+		{filename: "capture.wav", wantErr: true},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.filename), func(t *testing.T) {
+			got, err := iq.FormatFromFilename(tt.filename)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want FormatFromFilename(%q) to fail; got %v", tt.filename, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("want FormatFromFilename(%q)==%v; got %v", tt.filename, tt.want, got)
+			}
+		})
+	}
+}
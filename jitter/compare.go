@@ -0,0 +1,37 @@
+package jitter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// Compare records how far each edge in got, such as pulses captured from the
+// wire, deviates from the corresponding edge in want. Both are split into
+// bursts at silences lasting at least realign, and each burst is aligned on its
+// first edge, so that the (less critical) gaps between bursts don't count. It
+// returns an error if got doesn't have the same bursts and edges as want.
+func (r *Recorder) Compare(want, got []waveform.Pulse, realign time.Duration) error {
+	wantBursts := waveform.Split(want, realign)
+	gotBursts := waveform.Split(got, realign)
+	if len(wantBursts) != len(gotBursts) {
+		return fmt.Errorf("want %d bursts; got %d", len(wantBursts), len(gotBursts))
+	}
+	r.Reset(len(want))
+	for i := range wantBursts {
+		w, g := wantBursts[i].Pulses, gotBursts[i].Pulses
+		if len(w) != len(g) {
+			return fmt.Errorf("want %d pulses in burst %d; got %d", len(w), i+1, len(g))
+		}
+		var wantEdge, gotEdge time.Duration
+		for j := range w {
+			wantEdge += w[j].Duration
+			gotEdge += g[j].Duration
+			if r != nil {
+				r.deviations = append(r.deviations, gotEdge-wantEdge)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+/*
+Package jitter measures how accurately a transmitter keeps to its schedule.
+
+A Recorder collects the deviation of every pin change from its target time,
+and the number of overruns: pulses that were already late by the time the
+transmitter got around to waiting for them. A Report summarizes them, and
+judges them against the timing tolerance of the protocol being sent.
+*/
+package jitter
@@ -0,0 +1,108 @@
+package jitter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Recorder records the timing of pin changes. A nil *Recorder records
+// nothing, so transmitters can call it unconditionally.
+type Recorder struct {
+	deviations []time.Duration
+	overruns   int
+}
+
+// Reset clears the recorder, making room for n pin changes so that recording
+// them doesn't allocate.
+func (r *Recorder) Reset(n int) {
+	if r == nil {
+		return
+	}
+	if cap(r.deviations) < n {
+		r.deviations = make([]time.Duration, 0, n)
+	}
+	r.deviations = r.deviations[:0]
+	r.overruns = 0
+}
+
+// Record records a pin change that was meant to happen at target, and
+// actually happened at actual.
+func (r *Recorder) Record(target, actual time.Time) {
+	if r == nil {
+		return
+	}
+	r.deviations = append(r.deviations, actual.Sub(target))
+}
+
+// Overrun records that the transmitter was already late for its next pin
+// change before it started waiting for it.
+func (r *Recorder) Overrun() {
+	if r == nil {
+		return
+	}
+	r.overruns++
+}
+
+// Deviations returns the recorded deviations, in order. Positive deviations
+// are late, and negative ones early.
+func (r *Recorder) Deviations() []time.Duration {
+	if r == nil {
+		return nil
+	}
+	return r.deviations
+}
+
+// Report summarizes the timing of a transmission.
+type Report struct {
+	Changes   int           // Number of pin changes.
+	Max       time.Duration // Largest deviation from target, early or late.
+	Mean      time.Duration // Mean absolute deviation.
+	P99       time.Duration // 99th percentile absolute deviation.
+	Overruns  int           // Number of pulses that started waiting too late.
+	Tolerance time.Duration // Largest deviation the protocol tolerates.
+	Missed    int           // Number of pin changes outside Tolerance.
+}
+
+// Report summarizes the recorded timing, judging it against tolerance (which
+// may be 0 if unknown).
+func (r *Recorder) Report(tolerance time.Duration) Report {
+	report := Report{Tolerance: tolerance}
+	if r == nil || len(r.deviations) == 0 {
+		return report
+	}
+	abs := make([]time.Duration, len(r.deviations))
+	var total time.Duration
+	for i, d := range r.deviations {
+		if d < 0 {
+			d = -d
+		}
+		abs[i] = d
+		total += d
+		if tolerance > 0 && d > tolerance {
+			report.Missed++
+		}
+	}
+	sort.Slice(abs, func(i, j int) bool { return abs[i] < abs[j] })
+	report.Changes = len(abs)
+	report.Max = abs[len(abs)-1]
+	report.Mean = total / time.Duration(len(abs))
+	report.P99 = abs[(len(abs)-1)*99/100]
+	report.Overruns = r.overruns
+	return report
+}
+
+// OK returns true if every pin change was within the protocol's tolerance,
+// and there were no overruns.
+func (r Report) OK() bool {
+	return r.Missed == 0 && r.Overruns == 0
+}
+
+// String formats the report for humans.
+func (r Report) String() string {
+	s := fmt.Sprintf("timing: %d pin changes; deviation max %v, mean %v, p99 %v; %d overruns", r.Changes, r.Max, r.Mean, r.P99, r.Overruns)
+	if r.Tolerance > 0 {
+		s += fmt.Sprintf("; %d outside the ±%v protocol tolerance", r.Missed, r.Tolerance)
+	}
+	return s
+}
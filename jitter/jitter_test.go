@@ -0,0 +1,95 @@
+package jitter_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/jitter"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestReport(t *testing.T) {
+	r := &jitter.Recorder{}
+	r.Reset(200)
+	start := time.Now()
+	us := time.Microsecond
+	// 198 changes 2µs late, one 30µs early, and one 80µs late.
+	for i := 0; i < 198; i++ {
+		target := start.Add(time.Duration(i) * 250 * us)
+		r.Record(target, target.Add(2*us))
+	}
+	r.Record(start, start.Add(-30*us))
+	r.Record(start, start.Add(80*us))
+	r.Overrun()
+
+	got := r.Report(50 * us)
+	want := jitter.Report{
+		Changes:   200,
+		Max:       80 * us,
+		Mean:      (198*2*us + 30*us + 80*us) / 200,
+		P99:       2 * us,
+		Overruns:  1,
+		Tolerance: 50 * us,
+		Missed:    1,
+	}
+	if got != want {
+		t.Errorf("want %+v; got %+v", want, got)
+	}
+	if got.OK() {
+		t.Errorf("want report with a missed change and an overrun not to be OK")
+	}
+
+	r.Reset(0)
+	if got := r.Report(50 * us); got != (jitter.Report{Tolerance: 50 * us}) || !got.OK() {
+		t.Errorf("want empty OK report after Reset; got %+v", got)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *jitter.Recorder
+	r.Reset(10)
+	r.Record(time.Now(), time.Now())
+	r.Overrun()
+	if got := r.Report(0); got.Changes != 0 {
+		t.Errorf("want nil recorder to record nothing; got %+v", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	us := time.Microsecond
+	want := []waveform.Pulse{
+		{Level: 0, Duration: 5000 * us},
+		{Level: 1, Duration: 250 * us},
+		{Level: 0, Duration: 500 * us},
+		{Level: 1, Duration: 250 * us},
+		{Level: 0, Duration: 90000 * us},
+		{Level: 1, Duration: 500 * us},
+	}
+	// The first burst runs 10µs long, then 5µs late; the second starts 300µs
+	// late, which doesn't count.
+	got := []waveform.Pulse{
+		{Level: 1, Duration: 260 * us},
+		{Level: 0, Duration: 495 * us},
+		{Level: 1, Duration: 250 * us},
+		{Level: 0, Duration: 90300 * us},
+		{Level: 1, Duration: 498 * us},
+		{Level: 0, Duration: 1000 * us},
+	}
+
+	r := &jitter.Recorder{}
+	if err := r.Compare(want, got, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	wantDeviations := []time.Duration{10 * us, 5 * us, 5 * us, -2 * us}
+	if got := r.Deviations(); !reflect.DeepEqual(got, wantDeviations) {
+		t.Errorf("want deviations %v; got %v", wantDeviations, got)
+	}
+
+	if err := r.Compare(want, got[:3], 10*time.Millisecond); err == nil {
+		t.Errorf("want error comparing against a missing burst")
+	}
+	if err := r.Compare(want, got[1:], 10*time.Millisecond); err == nil {
+		t.Errorf("want error comparing against a missing pulse")
+	}
+}
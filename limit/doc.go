@@ -0,0 +1,11 @@
+/*
+Package limit keeps transmissions to each opener within its limits: how long
+the carrier may be on in a window of time, how many transmissions may be sent
+in a minute, and how long to wait after one fails.
+
+Recent transmissions are recorded in a state file, so that the limits hold
+across processes, such as a script running one-shot commands in a loop.
+Processes sharing the file must take turns using it, such as by holding a
+lock.
+*/
+package limit
@@ -0,0 +1,301 @@
+package limit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+// DefaultPath is where recent transmissions are recorded by default.
+const DefaultPath = "/var/lib/openers/limits.json"
+
+// Limits restrict transmissions to an opener. A zero field means no limit.
+type Limits struct {
+	// OnAir is the most time the carrier may be on in any Window.
+	OnAir  time.Duration
+	Window time.Duration
+	// PerMinute is the most transmissions in any minute.
+	PerMinute int
+	// CoolDown is how long to refuse transmissions after one fails.
+	CoolDown time.Duration
+}
+
+// Defaults are the limits of openers that don't set their own.
+var Defaults = Limits{
+	OnAir:     30 * time.Second,
+	Window:    time.Hour,
+	PerMinute: 6,
+	CoolDown:  10 * time.Second,
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the limits of the opener a
+// transmission is for.
+func NewContext(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the limits carried by a context, or Defaults if it
+// has none.
+func FromContext(ctx context.Context) Limits {
+	if l, ok := ctx.Value(contextKey{}).(Limits); ok {
+		return l
+	}
+	return Defaults
+}
+
+// LimitError is returned for a transmission that would exceed a limit.
+type LimitError struct {
+	Opener string
+	Limit  string        // Which limit, for humans.
+	Wait   time.Duration // How long until it would be allowed.
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s; try again in %v", e.Opener, e.Limit, e.Wait.Round(time.Second))
+}
+
+// sent is a recorded transmission.
+type sent struct {
+	Time  time.Time     `json:"time"`
+	OnAir time.Duration `json:"on_air_ns"`
+}
+
+// history is what is recorded for each opener.
+type history struct {
+	Sent   []sent     `json:"sent,omitempty"`   // Oldest first.
+	Failed *time.Time `json:"failed,omitempty"` // Nil unless cooling down.
+}
+
+// Store is a file of recent transmissions, keyed by opener name. It is safe
+// for concurrent use within a process.
+type Store struct {
+	Path string
+	// Now, if not nil, replaces time.Now.
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+func (s *Store) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// load reads the file, which need not exist yet.
+func (s *Store) load() (map[string]*history, error) {
+	h := map[string]*history{}
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return h, nil
+}
+
+// save replaces the file atomically. It is readable by everyone, so that
+// anyone can see what is left of the limits.
+func (s *Store) save(h map[string]*history) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.Path, append(data, '\n'), 0644)
+}
+
+// get returns an opener's history, dropping transmissions that no longer
+// count towards l.
+func get(all map[string]*history, opener string, l Limits, now time.Time) *history {
+	h := all[opener]
+	if h == nil {
+		h = &history{}
+		all[opener] = h
+	}
+	keep := time.Minute
+	if l.Window > keep {
+		keep = l.Window
+	}
+	i := 0
+	for i < len(h.Sent) && !h.Sent[i].Time.After(now.Add(-keep)) {
+		i++
+	}
+	h.Sent = h.Sent[i:]
+	if h.Failed != nil && !h.Failed.After(now.Add(-l.CoolDown)) {
+		h.Failed = nil
+	}
+	return h
+}
+
+// since returns the transmissions after t.
+func (h *history) since(t time.Time) []sent {
+	for i, s := range h.Sent {
+		if s.Time.After(t) {
+			return h.Sent[i:]
+		}
+	}
+	return nil
+}
+
+// check returns a *LimitError if a transmission with onAir time would
+// exceed l now.
+func (h *history) check(opener string, l Limits, onAir time.Duration, now time.Time) error {
+	if l.CoolDown > 0 && h.Failed != nil {
+		return &LimitError{Opener: opener, Limit: fmt.Sprintf("cooling down for %v after a failed transmission", l.CoolDown), Wait: h.Failed.Add(l.CoolDown).Sub(now)}
+	}
+	if l.PerMinute > 0 {
+		if recent := h.since(now.Add(-time.Minute)); len(recent) >= l.PerMinute {
+			// Wait for enough of them to be over a minute ago.
+			first := recent[len(recent)-l.PerMinute]
+			return &LimitError{Opener: opener, Limit: fmt.Sprintf("already sent %d transmissions in the last minute", len(recent)), Wait: first.Time.Add(time.Minute).Sub(now)}
+		}
+	}
+	if l.OnAir > 0 {
+		if onAir > l.OnAir {
+			return fmt.Errorf("%s: transmission is on air for %v, longer than the limit of %v per %v", opener, onAir, l.OnAir, l.Window)
+		}
+		recent := h.since(now.Add(-l.Window))
+		used := onAir
+		for _, s := range recent {
+			used += s.OnAir
+		}
+		if used > l.OnAir {
+			// Wait for enough on-air time to be over a window ago.
+			i := 0
+			for ; used > l.OnAir; i++ {
+				used -= recent[i].OnAir
+			}
+			return &LimitError{Opener: opener, Limit: fmt.Sprintf("would be on air for more than %v in %v", l.OnAir, l.Window), Wait: recent[i-1].Time.Add(l.Window).Sub(now)}
+		}
+	}
+	return nil
+}
+
+// Check returns a *LimitError if a transmission to an opener would exceed
+// l now, not counting its own on-air time. It is for refusing requests
+// early, before a rolling code is used up; Take still decides.
+func (s *Store) Check(opener string, l Limits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	// As if it were on air for no time at all.
+	return get(all, opener, l, now).check(opener, l, time.Nanosecond, now)
+}
+
+// Take records a transmission to an opener that will be on air for onAir,
+// if it is within l. Otherwise it returns a *LimitError saying when it
+// would be, and records nothing.
+func (s *Store) Take(opener string, l Limits, onAir time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	h := get(all, opener, l, now)
+	if err := h.check(opener, l, onAir, now); err != nil {
+		return err
+	}
+	h.Sent = append(h.Sent, sent{Time: now, OnAir: onAir})
+	return s.save(all)
+}
+
+// Failed records that a transmission to an opener failed, starting its
+// cool-down.
+func (s *Store) Failed(opener string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	h := all[opener]
+	if h == nil {
+		h = &history{}
+		all[opener] = h
+	}
+	now := s.now()
+	h.Failed = &now
+	return s.save(all)
+}
+
+// Budget is what is left of an opener's limits.
+type Budget struct {
+	Limits Limits
+	// Transmissions is how many more may be sent before the minute is up.
+	Transmissions int
+	// OnAir is how much more time the carrier may be on in the window.
+	OnAir time.Duration
+	// CoolDown is how long is left of the cool-down after a failure, if
+	// any.
+	CoolDown time.Duration
+}
+
+// Budget returns what is left of an opener's limits.
+func (s *Store) Budget(opener string, l Limits) (Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return Budget{}, err
+	}
+	now := s.now()
+	h := get(all, opener, l, now)
+	b := Budget{Limits: l}
+	if l.PerMinute > 0 {
+		b.Transmissions = l.PerMinute - len(h.since(now.Add(-time.Minute)))
+		if b.Transmissions < 0 {
+			b.Transmissions = 0
+		}
+	}
+	if l.OnAir > 0 {
+		b.OnAir = l.OnAir
+		for _, s := range h.since(now.Add(-l.Window)) {
+			b.OnAir -= s.OnAir
+		}
+		if b.OnAir < 0 {
+			b.OnAir = 0
+		}
+	}
+	if l.CoolDown > 0 && h.Failed != nil {
+		b.CoolDown = h.Failed.Add(l.CoolDown).Sub(now)
+	}
+	return b, nil
+}
+
+// String describes the budget for humans.
+func (b Budget) String() string {
+	var parts []string
+	if b.Limits.PerMinute > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d transmissions left this minute", b.Transmissions, b.Limits.PerMinute))
+	}
+	if b.Limits.OnAir > 0 {
+		parts = append(parts, fmt.Sprintf("%v of %v on air left per %v", b.OnAir.Round(time.Millisecond), b.Limits.OnAir, b.Limits.Window))
+	}
+	if b.CoolDown > 0 {
+		parts = append(parts, fmt.Sprintf("cooling down for %v", b.CoolDown.Round(time.Second)))
+	}
+	if len(parts) == 0 {
+		return "no limits"
+	}
+	return strings.Join(parts, "; ")
+}
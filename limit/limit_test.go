@@ -0,0 +1,198 @@
+package limit_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/limit"
+)
+
+// clock is a fake clock.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newStore(t *testing.T) (*limit.Store, *clock) {
+	c := &clock{now: time.Date(2026, 10, 5, 18, 0, 0, 0, time.UTC)}
+	return &limit.Store{Path: filepath.Join(t.TempDir(), "limits.json"), Now: c.Now}, c
+}
+
+// step is a transmission attempted after a pause, or a failure recorded.
+type step struct {
+	after  time.Duration
+	onAir  time.Duration
+	failed bool          // Record a failure instead.
+	wait   time.Duration // If not 0, the transmission is refused, to be allowed after this long.
+}
+
+func TestTake(t *testing.T) {
+	const s = time.Second
+	testdata := []struct {
+		name   string
+		limits limit.Limits
+		steps  []step
+	}{
+		{
+			name:   "per minute",
+			limits: limit.Limits{PerMinute: 3},
+			steps: []step{
+				{onAir: s}, {after: 10 * s, onAir: s}, {after: 10 * s, onAir: s},
+				{after: 10 * s, onAir: s, wait: 30 * s},
+				{after: 30 * s, onAir: s},
+				{after: 0, onAir: s, wait: 10 * s},
+			},
+		},
+		{
+			name:   "on air",
+			limits: limit.Limits{OnAir: 3 * s, Window: time.Hour},
+			steps: []step{
+				{onAir: 2 * s}, {after: time.Minute, onAir: s},
+				{after: time.Minute, onAir: s, wait: 58 * time.Minute},
+				{after: 58 * time.Minute, onAir: 2 * s},
+			},
+		},
+		{
+			name:   "on air, waiting for several to expire",
+			limits: limit.Limits{OnAir: 3 * s, Window: time.Hour},
+			steps: []step{
+				{onAir: s}, {after: time.Minute, onAir: s}, {after: time.Minute, onAir: s},
+				{after: time.Minute, onAir: 2 * s, wait: 58 * time.Minute},
+			},
+		},
+		{
+			name:   "cool down",
+			limits: limit.Limits{CoolDown: 10 * s},
+			steps: []step{
+				{onAir: s}, {failed: true},
+				{after: 4 * s, onAir: s, wait: 6 * s},
+				{after: 6 * s, onAir: s},
+				{after: 0, onAir: s},
+			},
+		},
+		{
+			name:   "no limits",
+			limits: limit.Limits{},
+			steps:  []step{{onAir: time.Hour}, {onAir: time.Hour}, {failed: true}, {onAir: time.Hour}},
+		},
+	}
+	for _, tt := range testdata {
+		t.Run(tt.name, func(t *testing.T) {
+			store, c := newStore(t)
+			for i, st := range tt.steps {
+				c.now = c.now.Add(st.after)
+				if st.failed {
+					if err := store.Failed("garage"); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				err := store.Take("garage", tt.limits, st.onAir)
+				var le *limit.LimitError
+				switch {
+				case st.wait == 0 && err != nil:
+					t.Errorf("step %d: want allowed; got %v", i, err)
+				case st.wait != 0 && !errors.As(err, &le):
+					t.Errorf("step %d: want LimitError; got %v", i, err)
+				case st.wait != 0 && le.Wait != st.wait:
+					t.Errorf("step %d: want wait %v; got %v", i, st.wait, le.Wait)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	store, _ := newStore(t)
+	l := limit.Limits{OnAir: time.Second, Window: time.Minute}
+	if err := store.Take("garage", l, 600*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Check("garage", l); err != nil {
+		t.Errorf("want check to pass with on-air time left; got %v", err)
+	}
+	if err := store.Take("garage", l, 600*time.Millisecond); err == nil {
+		t.Errorf("want take to fail without enough on-air time left")
+	}
+	if err := store.Take("garage", l, 400*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	var le *limit.LimitError
+	if err := store.Check("garage", l); !errors.As(err, &le) {
+		t.Errorf("want check to fail with no on-air time left; got %v", err)
+	}
+}
+
+func TestTooLong(t *testing.T) {
+	store, _ := newStore(t)
+	err := store.Take("garage", limit.Limits{OnAir: time.Second, Window: time.Minute}, 2*time.Second)
+	var le *limit.LimitError
+	if err == nil || errors.As(err, &le) {
+		t.Errorf("want an error that waiting won't fix; got %v", err)
+	}
+}
+
+func TestOpenersSeparate(t *testing.T) {
+	store, _ := newStore(t)
+	l := limit.Limits{PerMinute: 1}
+	if err := store.Take("garage", l, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Take("gate", l, time.Second); err != nil {
+		t.Errorf("want gate allowed; got %v", err)
+	}
+	if err := store.Take("garage", l, time.Second); err == nil {
+		t.Errorf("want garage refused")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	store, c := newStore(t)
+	l := limit.Limits{OnAir: 10 * time.Second, Window: time.Hour, PerMinute: 6, CoolDown: 10 * time.Second}
+	b, err := store.Budget("garage", l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "6 of 6 transmissions left this minute; 10s of 10s on air left per 1h0m0s"; b.String() != want {
+		t.Errorf("want %q; got %q", want, b)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Take("garage", l, 1500*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Failed("garage"); err != nil {
+		t.Fatal(err)
+	}
+	c.now = c.now.Add(3 * time.Second)
+	if b, err = store.Budget("garage", l); err != nil {
+		t.Fatal(err)
+	}
+	if want := "4 of 6 transmissions left this minute; 7s of 10s on air left per 1h0m0s; cooling down for 7s"; b.String() != want {
+		t.Errorf("want %q; got %q", want, b)
+	}
+	c.now = c.now.Add(time.Minute)
+	if b, err = store.Budget("garage", l); err != nil {
+		t.Fatal(err)
+	}
+	if b.Transmissions != 6 || b.OnAir != 7*time.Second || b.CoolDown != 0 {
+		t.Errorf("want 6 transmissions, 7s and no cool-down a minute later; got %+v", b)
+	}
+	if got := (limit.Budget{}).String(); got != "no limits" {
+		t.Errorf("want no limits; got %q", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := limit.FromContext(context.Background()); got != limit.Defaults {
+		t.Errorf("want defaults; got %+v", got)
+	}
+	l := limit.Limits{PerMinute: 1}
+	if got := limit.FromContext(limit.NewContext(context.Background(), l)); got != l {
+		t.Errorf("want %+v; got %+v", l, got)
+	}
+}
@@ -0,0 +1,12 @@
+/*
+Package lock gives openers processes exclusive use of transmitter hardware,
+so that two of them keying the same pin at once can't interleave their pulses.
+
+Locks are advisory flock(2) locks on files in a directory, one per
+resource, such as "gpiochip0-12" for pin 12 of gpiochip0. The holder writes
+its details into the file, so that a process that gives up waiting can say
+who it was waiting for. The directory may be shared with a group, but not
+with everyone, and lock files that are links, or belong to other users, are
+refused rather than written through.
+*/
+package lock
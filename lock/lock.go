@@ -0,0 +1,212 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDir is where lock files live by default.
+const DefaultDir = "/run/openers"
+
+// poll is how often a waiting process retries a held lock.
+const poll = 50 * time.Millisecond
+
+// Holder describes the process holding a lock.
+type Holder struct {
+	PID     int       `json:"pid"`
+	User    string    `json:"user"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+func (h *Holder) String() string {
+	return fmt.Sprintf("pid %d (%s, %q) since %s", h.PID, h.User, h.Command, h.Since.Format("15:04:05"))
+}
+
+// BusyError is returned when a lock is still held at the end of the wait.
+type BusyError struct {
+	Name   string
+	Waited time.Duration
+	Holder *Holder // nil if the holder couldn't be identified.
+}
+
+func (e *BusyError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("%s is in use by another process; gave up after %v", e.Name, e.Waited)
+	}
+	return fmt.Sprintf("%s is in use by %s; gave up after %v", e.Name, e.Holder, e.Waited)
+}
+
+// Name returns the name of the lock for a GPIO pin.
+func Name(chip string, pin int) string {
+	return chip + "-" + strconv.Itoa(pin)
+}
+
+// DeviceName returns the name of the lock for a device file, such as
+// /dev/spidev0.0.
+func DeviceName(path string) string {
+	return filepath.Base(path)
+}
+
+// Lock is a held lock.
+type Lock struct {
+	Name   string
+	Waited time.Duration // How long it took to acquire.
+	f      *os.File
+}
+
+// Acquire takes the named lock in dir, creating the directory and lock file
+// as needed. If another process holds it, Acquire waits for up to timeout,
+// or until the context is cancelled.
+//
+// The directory must not be writable by other users, so that nobody can
+// plant a link in place of a lock file for a privileged process to write
+// through. Processes of different users share the locks through the
+// directory's group, or through lock files root created.
+func Acquire(ctx context.Context, dir, name string, timeout time.Duration) (*Lock, error) {
+	if err := os.Mkdir(dir, 0775); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("cannot create lock directory: %v", err)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create lock directory: %v", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("lock directory %s is not a directory", dir)
+	}
+	if fi.Mode().Perm()&0002 != 0 {
+		return nil, fmt.Errorf("lock directory %s is writable by anyone; make it 0755, or 0775 for its group", dir)
+	}
+	path := filepath.Join(dir, name+".lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|noFollow, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open lock file: %v", err)
+	}
+	if err := checkFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock file %s: %v", path, err)
+	}
+	// Only works for the file's owner, which is enough for a new one.
+	f.Chmod(0666)
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("cannot lock %s: %v", path, err)
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, &BusyError{Name: name, Waited: time.Since(start).Round(time.Millisecond), Holder: ReadHolder(dir, name)}
+		}
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	l := &Lock{Name: name, Waited: time.Since(start), f: f}
+	if err := l.writeHolder(); err != nil {
+		l.Release()
+		return nil, fmt.Errorf("cannot write lock file %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// writeHolder records this process as the holder.
+func (l *Lock) writeHolder() error {
+	h := Holder{
+		PID:     os.Getpid(),
+		Command: strings.Join(os.Args, " "),
+		Since:   time.Now(),
+	}
+	if u, err := user.Current(); err == nil {
+		h.User = u.Username
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = l.f.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	l.f.Truncate(0)
+	return l.f.Close()
+}
+
+// ReadHolder returns the details the holder of the named lock recorded, or
+// nil if there are none.
+func ReadHolder(dir, name string) *Holder {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name+".lock"))
+	if err != nil {
+		return nil
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil || h.PID == 0 {
+		return nil
+	}
+	return &h
+}
+
+// Set is a group of locks, taken together.
+type Set []*Lock
+
+// AcquireAll takes the named locks in dir, always in the same order so that
+// processes wanting overlapping sets can't deadlock. The timeout applies to
+// the whole set.
+func AcquireAll(ctx context.Context, dir string, names []string, timeout time.Duration) (Set, error) {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	deadline := time.Now().Add(timeout)
+	var set Set
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		l, err := Acquire(ctx, dir, name, time.Until(deadline))
+		if err != nil {
+			set.Release()
+			return nil, err
+		}
+		set = append(set, l)
+	}
+	return set, nil
+}
+
+// Waited returns the total time spent waiting for the locks.
+func (s Set) Waited() time.Duration {
+	var total time.Duration
+	for _, l := range s {
+		total += l.Waited
+	}
+	return total
+}
+
+// Release releases all the locks in the set.
+func (s Set) Release() {
+	for i := len(s) - 1; i >= 0; i-- {
+		s[i].Release()
+	}
+}
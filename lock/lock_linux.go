@@ -0,0 +1,39 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// noFollow stops opening a lock file through a symbolic link.
+const noFollow = unix.O_NOFOLLOW
+
+// tryLock takes an exclusive lock on f without blocking, returning false if
+// another open file holds it.
+func tryLock(f *os.File) (bool, error) {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkFile checks that an open lock file is a regular file with no other
+// links, owned by this process's user or root.
+func checkFile(f *os.File) error {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(f.Fd()), &st); err != nil {
+		return err
+	}
+	switch {
+	case st.Mode&unix.S_IFMT != unix.S_IFREG:
+		return fmt.Errorf("not a regular file")
+	case st.Nlink != 1:
+		return fmt.Errorf("has %d links; want 1", st.Nlink)
+	case st.Uid != uint32(os.Geteuid()) && st.Uid != 0:
+		return fmt.Errorf("owned by uid %d; want %d or root", st.Uid, os.Geteuid())
+	}
+	return nil
+}
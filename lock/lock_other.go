@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package lock
+
+import (
+	"fmt"
+	"os"
+)
+
+// noFollow is left out where locking isn't supported.
+const noFollow = 0
+
+// tryLock reports that locking is only supported on Linux.
+func tryLock(f *os.File) (bool, error) {
+	return false, fmt.Errorf("locking is only supported on Linux")
+}
+
+// checkFile accepts any file, since locking is only supported on Linux.
+func checkFile(f *os.File) error {
+	return nil
+}
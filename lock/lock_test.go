@@ -0,0 +1,121 @@
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/lock"
+)
+
+func TestAcquire(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "locks")
+	name := lock.Name("gpiochip0", 12)
+	if name != "gpiochip0-12" {
+		t.Errorf("want name gpiochip0-12; got %s", name)
+	}
+
+	l, err := lock.Acquire(context.Background(), dir, name, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := lock.ReadHolder(dir, name)
+	if h == nil || h.PID != os.Getpid() {
+		t.Errorf("want holder with pid %d; got %+v", os.Getpid(), h)
+	}
+
+	// Flock locks belong to open files, so a second Acquire in the same
+	// process waits like another process would.
+	_, err = lock.Acquire(context.Background(), dir, name, 100*time.Millisecond)
+	var busy *lock.BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("want BusyError; got %v", err)
+	}
+	if busy.Holder == nil || busy.Holder.PID != os.Getpid() || busy.Waited < 100*time.Millisecond {
+		t.Errorf("want error naming holder pid %d after waiting 100ms; got %v", os.Getpid(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := lock.Acquire(ctx, dir, name, time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("want %v; got %v", context.DeadlineExceeded, err)
+	}
+
+	// A waiter gets the lock once it is released.
+	got := make(chan error)
+	go func() {
+		l2, err := lock.Acquire(context.Background(), dir, name, 5*time.Second)
+		if err == nil {
+			if l2.Waited < 100*time.Millisecond {
+				err = errors.New("acquired lock without waiting")
+			}
+			l2.Release()
+		}
+		got <- err
+	}()
+	time.Sleep(150 * time.Millisecond)
+	l.Release()
+	if err := <-got; err != nil {
+		t.Error(err)
+	}
+	if h := lock.ReadHolder(dir, name); h != nil {
+		t.Errorf("want no holder after release; got %+v", h)
+	}
+}
+
+func TestAcquireAll(t *testing.T) {
+	dir := t.TempDir()
+	set, err := lock.AcquireAll(context.Background(), dir, []string{"spidev0.0", "gpiochip0-25", "spidev0.0"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 || set[0].Name != "gpiochip0-25" || set[1].Name != "spidev0.0" {
+		t.Errorf("want locks gpiochip0-25 and spidev0.0, in order; got %v", set)
+	}
+	if _, err := lock.AcquireAll(context.Background(), dir, []string{"gpiochip0-24", "spidev0.0"}, 50*time.Millisecond); err == nil {
+		t.Errorf("want error taking an overlapping set")
+	}
+	// The lock that was free must not be left held.
+	l, err := lock.Acquire(context.Background(), dir, "gpiochip0-24", 0)
+	if err != nil {
+		t.Errorf("want gpiochip0-24 released after failure; got %v", err)
+	} else {
+		l.Release()
+	}
+	set.Release()
+}
+
+func TestAcquireUnsafe(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(target, []byte("root:x:0:0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "symlink.lock")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(target, filepath.Join(dir, "hardlink.lock")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"symlink", "hardlink"} {
+		if l, err := lock.Acquire(ctx, dir, name, 0); err == nil {
+			l.Release()
+			t.Errorf("%s: want error for lock file that isn't its own", name)
+		}
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "root:x:0:0\n" {
+		t.Errorf("want link target untouched; got %q, %v", data, err)
+	}
+
+	if err := os.Chmod(dir, 0777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := lock.Acquire(ctx, dir, "gpiochip0-12", 0); err == nil {
+		l.Release()
+		t.Errorf("want error for world-writable lock directory")
+	}
+}
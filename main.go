@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"os"
+	"os/signal"
 	"reflect"
 	"strconv"
+	"syscall"
 
 	"github.com/zellyn/openers/cmd"
 
@@ -13,10 +16,25 @@ import (
 )
 
 var cli struct {
-	Debug int `kong:"short='v',type='counter',help='Enable debug mode.'"`
+	Debug  int    `kong:"short='v',type='counter',help='Enable debug mode.'"`
+	Config string `kong:"default='/etc/openers/openers.json',type='path',placeholder='file',help='Configuration file describing the openers.'"`
 
 	Secplus  cmd.SecplusCmd  `cmd:"" help:"Work with Security+2.0 devices."`
 	Megacode cmd.MegaCodeCmd `cmd:"" help:"Work with MegaCode devices."`
+	Decode   cmd.DecodeCmd   `cmd:"" help:"Decode Security+2.0 and MegaCode transmissions from recordings."`
+	Receive  cmd.ReceiveCmd  `cmd:"" help:"Listen with an RFM69 radio, and decode Security+2.0 and MegaCode transmissions."`
+	Selftest cmd.SelftestCmd `cmd:"" help:"Check transmit timing end to end, using a second GPIO pin jumpered to the transmit pin."`
+	Open     cmd.OpenCmd     `cmd:"" help:"Open a door, checking with its sensor that it opened."`
+	Close    cmd.CloseCmd    `cmd:"" help:"Close a door, checking with its sensor that it closed."`
+	Status   cmd.StatusCmd   `cmd:"" help:"Show where each door is, from its limit switches."`
+	Schedule cmd.ScheduleCmd `cmd:"" help:"Follow the schedule in the configuration file, closing doors at night or when left open."`
+	Serve    cmd.ServeCmd    `cmd:"" help:"Serve an HTTP API for triggering the configured openers."`
+	MQTT     cmd.MQTTCmd     `cmd:"" name:"mqtt" help:"Connect openers to Home Assistant over MQTT."`
+	HomeKit  cmd.HomeKitCmd  `cmd:"" name:"homekit" help:"Connect openers to Apple's Home app, as a HomeKit bridge."`
+	Helper   cmd.HelperCmd   `cmd:"" help:"Run as root, triggering openers for users allowed by the access list."`
+	Trigger  cmd.TriggerCmd  `cmd:"" help:"Ask the helper to trigger an opener."`
+	Users    cmd.UsersCmd    `cmd:"" help:"Manage who may trigger openers through the helper."`
+	Audit    cmd.AuditCmd    `cmd:"" help:"Show or verify the audit log of transmissions."`
 }
 
 func run() error {
@@ -30,10 +48,22 @@ func run() error {
 		}),
 		kong.NamedMapper("anybaseuint32", hexUint32Mapper{}),
 		kong.NamedMapper("anybaseuint72", hexUint72Mapper{}),
+		kong.NamedMapper("si", siFloatMapper{}),
 	)
 
+	// Stop cleanly on the first SIGINT or SIGTERM, so transmitters are left
+	// unkeyed; a second one kills the process.
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-sigCtx.Done()
+		stop()
+	}()
+
 	globals := &cmd.Globals{
-		Debug: cli.Debug,
+		Debug:   cli.Debug,
+		Config:  cli.Config,
+		Context: sigCtx,
 	}
 	// Call the Run() method of the selected parsed command.
 	return ctx.Run(globals)
@@ -69,6 +99,10 @@ func (h hexUint32Mapper) Decode(ctx *kong.DecodeContext, target reflect.Value) e
 	if err != nil {
 		return fmt.Errorf("expected a valid %d bit uint but got %q", 32, sv)
 	}
+	// Optional flags are pointers, which kong has already allocated.
+	if target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
 	target.SetUint(n)
 	return nil
 }
@@ -105,3 +139,29 @@ func (h hexUint72Mapper) Decode(ctx *kong.DecodeContext, target reflect.Value) e
 	}
 	return nil
 }
+
+type siFloatMapper struct{}
+
+func (s siFloatMapper) Decode(ctx *kong.DecodeContext, target reflect.Value) error {
+	t, err := ctx.Scan.PopValue("float")
+	if err != nil {
+		return err
+	}
+	var sv string
+	switch v := t.Value.(type) {
+	case string:
+		sv = v
+
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		sv = fmt.Sprintf("%v", v)
+
+	default:
+		return fmt.Errorf("expected a number but got %q (%T)", t, t.Value)
+	}
+	f, err := cmd.ParseSI(sv)
+	if err != nil {
+		return err
+	}
+	target.SetFloat(f)
+	return nil
+}
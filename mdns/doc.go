@@ -0,0 +1,9 @@
+/*
+Package mdns advertises a DNS-SD service on the local network with multicast
+DNS, so that clients such as Apple's Home app can find it without any
+configuration.
+
+It answers queries for a single service instance, and announces it when it
+starts and when its TXT record changes. See RFC 6762 and RFC 6763.
+*/
+package mdns
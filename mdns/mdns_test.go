@@ -0,0 +1,95 @@
+package mdns_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zellyn/openers/mdns"
+)
+
+func newResponder() *mdns.Responder {
+	r := mdns.NewResponder(mdns.Service{
+		Instance: "openers",
+		Service:  "_hap._tcp",
+		Host:     "pizero",
+		Port:     51826,
+		Text:     []string{"c#=1", "sf=1"},
+	})
+	r.Addrs = func() [][4]byte { return [][4]byte{{192, 168, 1, 20}} }
+	return r
+}
+
+// roundTrip packs and parses a message, as if sent over the network.
+func roundTrip(t *testing.T, m *mdns.Message) *mdns.Message {
+	t.Helper()
+	back, err := mdns.Parse(m.Pack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return back
+}
+
+func TestAnswer(t *testing.T) {
+	r := newResponder()
+	q := roundTrip(t, &mdns.Message{Questions: []mdns.Question{{Name: "_hap._tcp.local.", Type: mdns.TypePTR}}})
+	resp, unicast := r.Answer(q)
+	if resp == nil || unicast {
+		t.Fatalf("want multicast answer; got %v, %v", resp, unicast)
+	}
+	resp = roundTrip(t, resp)
+	if len(resp.Answers) != 1 || resp.Answers[0].Target() != "openers._hap._tcp.local." {
+		t.Errorf("want PTR to openers._hap._tcp.local.; got %+v", resp.Answers)
+	}
+	types := map[uint16]*mdns.Record{}
+	for i, rr := range resp.Additional {
+		types[rr.Type] = &resp.Additional[i]
+	}
+	if srv := types[mdns.TypeSRV]; srv == nil || srv.Target() != "pizero.local." || srv.Data[4] != 51826>>8 || srv.Data[5] != 51826&0xff {
+		t.Errorf("want SRV for pizero.local.:51826; got %+v", srv)
+	}
+	if txt := types[mdns.TypeTXT]; txt == nil || !reflect.DeepEqual(txt.Text(), []string{"c#=1", "sf=1"}) {
+		t.Errorf("want TXT c#=1 sf=1; got %+v", txt)
+	}
+	if a := types[mdns.TypeA]; a == nil || !reflect.DeepEqual(a.Data, []byte{192, 168, 1, 20}) {
+		t.Errorf("want A 192.168.1.20; got %+v", a)
+	}
+
+	r.SetText([]string{"c#=1", "sf=0"})
+	q = roundTrip(t, &mdns.Message{Questions: []mdns.Question{{Name: "OPENERS._hap._tcp.local.", Type: mdns.TypeTXT, Unicast: true}}})
+	resp, unicast = r.Answer(q)
+	if resp == nil || !unicast || len(resp.Answers) != 1 || !reflect.DeepEqual(resp.Answers[0].Text(), []string{"c#=1", "sf=0"}) {
+		t.Errorf("want unicast updated TXT; got %+v, %v", resp, unicast)
+	}
+
+	for _, q := range []*mdns.Message{
+		{Questions: []mdns.Question{{Name: "_airplay._tcp.local.", Type: mdns.TypePTR}}},
+		{Questions: []mdns.Question{{Name: "pizero.local.", Type: mdns.TypeSRV}}},
+		{Response: true, Questions: []mdns.Question{{Name: "_hap._tcp.local.", Type: mdns.TypePTR}}},
+	} {
+		if resp, _ := r.Answer(q); resp != nil {
+			t.Errorf("want no answer to %+v; got %+v", q, resp)
+		}
+	}
+}
+
+func TestParseCompressed(t *testing.T) {
+	// A query for _hap._tcp.local PTR and, compressed, _airplay._tcp.local.
+	b := []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0,
+		4, '_', 'h', 'a', 'p', 4, '_', 't', 'c', 'p', 5, 'l', 'o', 'c', 'a', 'l', 0, 0, 12, 0, 1,
+		8, '_', 'a', 'i', 'r', 'p', 'l', 'a', 'y', 0xc0, 17, 0, 12, 0x80, 1,
+	}
+	m, err := mdns.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mdns.Question{
+		{Name: "_hap._tcp.local.", Type: mdns.TypePTR},
+		{Name: "_airplay._tcp.local.", Type: mdns.TypePTR, Unicast: true},
+	}
+	if !reflect.DeepEqual(m.Questions, want) {
+		t.Errorf("want %+v; got %+v", want, m.Questions)
+	}
+	if _, err := mdns.Parse(b[:20]); err == nil {
+		t.Errorf("want error for truncated message")
+	}
+}
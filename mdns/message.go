@@ -0,0 +1,256 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Record types.
+const (
+	TypeA   = 1
+	TypePTR = 12
+	TypeTXT = 16
+	TypeSRV = 33
+	TypeANY = 255
+)
+
+// classIN is the Internet class; the top bit of the class is the cache-flush
+// bit in records, and the unicast-response bit in questions.
+const (
+	classIN   = 1
+	classFlag = 0x8000
+)
+
+var errShort = errors.New("mdns: message too short")
+
+// Question is a query for records.
+type Question struct {
+	Name    string
+	Type    uint16
+	Unicast bool // The querier would like a unicast response.
+}
+
+// Record is a resource record. Data is the record data in wire format, with
+// any names in it uncompressed.
+type Record struct {
+	Name       string
+	Type       uint16
+	CacheFlush bool
+	TTL        uint32
+	Data       []byte
+}
+
+// Message is a DNS message.
+type Message struct {
+	ID         uint16
+	Response   bool
+	Questions  []Question
+	Answers    []Record
+	Additional []Record
+}
+
+// appendName appends a name in wire format, without compression.
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// Pack encodes the message.
+func (m *Message) Pack() []byte {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	if m.Response {
+		binary.BigEndian.PutUint16(b[2:], 0x8400) // Response, authoritative.
+	}
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+	for _, q := range m.Questions {
+		b = appendName(b, q.Name)
+		class := uint16(classIN)
+		if q.Unicast {
+			class |= classFlag
+		}
+		b = append(b, byte(q.Type>>8), byte(q.Type), byte(class>>8), byte(class))
+	}
+	for _, rrs := range [][]Record{m.Answers, m.Additional} {
+		for _, r := range rrs {
+			b = appendName(b, r.Name)
+			class := uint16(classIN)
+			if r.CacheFlush {
+				class |= classFlag
+			}
+			b = append(b, byte(r.Type>>8), byte(r.Type), byte(class>>8), byte(class))
+			b = append(b, byte(r.TTL>>24), byte(r.TTL>>16), byte(r.TTL>>8), byte(r.TTL))
+			b = append(b, byte(len(r.Data)>>8), byte(len(r.Data)))
+			b = append(b, r.Data...)
+		}
+	}
+	return b
+}
+
+// readName reads a possibly compressed name at off, returning it and the
+// offset just past it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errShort
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errShort
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, fmt.Errorf("mdns: too many compression pointers")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		default:
+			if off+1+l > len(b) {
+				return "", 0, errShort
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+// Parse decodes a message. Names in record data are left as they are, so
+// may be compressed.
+func Parse(b []byte) (*Message, error) {
+	if len(b) < 12 {
+		return nil, errShort
+	}
+	m := &Message{
+		ID:       binary.BigEndian.Uint16(b[0:]),
+		Response: b[2]&0x80 != 0,
+	}
+	qd := int(binary.BigEndian.Uint16(b[4:]))
+	counts := []int{int(binary.BigEndian.Uint16(b[6:])), int(binary.BigEndian.Uint16(b[8:])), int(binary.BigEndian.Uint16(b[10:]))}
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, next, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(b) {
+			return nil, errShort
+		}
+		class := binary.BigEndian.Uint16(b[next+2:])
+		m.Questions = append(m.Questions, Question{
+			Name:    name,
+			Type:    binary.BigEndian.Uint16(b[next:]),
+			Unicast: class&classFlag != 0,
+		})
+		off = next + 4
+	}
+	for section, n := range counts {
+		for i := 0; i < n; i++ {
+			name, next, err := readName(b, off)
+			if err != nil {
+				return nil, err
+			}
+			if next+10 > len(b) {
+				return nil, errShort
+			}
+			length := int(binary.BigEndian.Uint16(b[next+8:]))
+			if next+10+length > len(b) {
+				return nil, errShort
+			}
+			r := Record{
+				Name:       name,
+				Type:       binary.BigEndian.Uint16(b[next:]),
+				CacheFlush: binary.BigEndian.Uint16(b[next+2:])&classFlag != 0,
+				TTL:        binary.BigEndian.Uint32(b[next+4:]),
+				Data:       b[next+10 : next+10+length],
+			}
+			off = next + 10 + length
+			switch section {
+			case 0:
+				m.Answers = append(m.Answers, r)
+			case 2:
+				m.Additional = append(m.Additional, r)
+			}
+		}
+	}
+	return m, nil
+}
+
+// PTR returns a pointer record.
+func PTR(name, target string, ttl uint32) Record {
+	return Record{Name: name, Type: TypePTR, TTL: ttl, Data: appendName(nil, target)}
+}
+
+// SRV returns a service record.
+func SRV(name, target string, port int, ttl uint32) Record {
+	data := []byte{0, 0, 0, 0, byte(port >> 8), byte(port)} // Priority and weight 0.
+	return Record{Name: name, Type: TypeSRV, CacheFlush: true, TTL: ttl, Data: appendName(data, target)}
+}
+
+// TXT returns a text record.
+func TXT(name string, text []string, ttl uint32) Record {
+	var data []byte
+	for _, t := range text {
+		if len(t) > 255 {
+			t = t[:255]
+		}
+		data = append(data, byte(len(t)))
+		data = append(data, t...)
+	}
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+	return Record{Name: name, Type: TypeTXT, CacheFlush: true, TTL: ttl, Data: data}
+}
+
+// A returns an IPv4 address record.
+func A(name string, ip [4]byte, ttl uint32) Record {
+	return Record{Name: name, Type: TypeA, CacheFlush: true, TTL: ttl, Data: ip[:]}
+}
+
+// Text decodes the strings of a TXT record.
+func (r *Record) Text() []string {
+	var text []string
+	for d := r.Data; len(d) > 0 && len(d) > int(d[0]); d = d[1+int(d[0]):] {
+		if d[0] > 0 {
+			text = append(text, string(d[1:1+int(d[0])]))
+		}
+	}
+	return text
+}
+
+// Target decodes the name in a PTR record, or the target of an SRV record,
+// if it is not compressed.
+func (r *Record) Target() string {
+	data := r.Data
+	if r.Type == TypeSRV {
+		if len(data) < 6 {
+			return ""
+		}
+		data = data[6:]
+	}
+	name, _, err := readName(data, 0)
+	if err != nil {
+		return ""
+	}
+	return name
+}
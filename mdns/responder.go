@@ -0,0 +1,237 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TTLs for the records, as recommended by RFC 6762 section 10.
+const (
+	hostTTL  = 120
+	otherTTL = 4500
+)
+
+// group is the mDNS IPv4 multicast group.
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service describes a service instance to advertise.
+type Service struct {
+	Instance string   // Instance name, such as "openers".
+	Service  string   // Service type, such as "_hap._tcp".
+	Host     string   // Host name, without ".local".
+	Port     int      // TCP port the service listens on.
+	Text     []string // TXT record entries, such as "sf=1".
+}
+
+func (s *Service) serviceName() string  { return s.Service + ".local." }
+func (s *Service) instanceName() string { return s.Instance + "." + s.serviceName() }
+func (s *Service) hostName() string     { return s.Host + ".local." }
+
+// Responder answers mDNS queries for a service.
+type Responder struct {
+	// Addrs returns the IPv4 addresses to advertise; by default, those of
+	// the machine's multicast-capable interfaces.
+	Addrs func() [][4]byte
+
+	mu      sync.Mutex
+	service Service
+	updated chan struct{}
+}
+
+// NewResponder returns a responder for a service. It is not started until
+// Run is called.
+func NewResponder(s Service) *Responder {
+	return &Responder{
+		Addrs:   localAddrs,
+		service: s,
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// SetText replaces the service's TXT record, and announces the change.
+func (r *Responder) SetText(text []string) {
+	r.mu.Lock()
+	r.service.Text = append([]string(nil), text...)
+	r.mu.Unlock()
+	select {
+	case r.updated <- struct{}{}:
+	default:
+	}
+}
+
+// records returns the service's records, with the given TTL for the
+// pointers and service details.
+func (r *Responder) records(goodbye bool) (ptrs, others []Record) {
+	r.mu.Lock()
+	s := r.service
+	r.mu.Unlock()
+	host, other := uint32(hostTTL), uint32(otherTTL)
+	if goodbye {
+		host, other = 0, 0
+	}
+	ptrs = []Record{
+		PTR(s.serviceName(), s.instanceName(), other),
+		PTR("_services._dns-sd._udp.local.", s.serviceName(), other),
+	}
+	others = []Record{
+		SRV(s.instanceName(), s.hostName(), s.Port, host),
+		TXT(s.instanceName(), s.Text, other),
+	}
+	for _, ip := range r.Addrs() {
+		others = append(others, A(s.hostName(), ip, host))
+	}
+	return ptrs, others
+}
+
+// Answer returns the response to a query, or nil if it asks about nothing
+// the responder knows, and whether the response should be sent by unicast.
+func (r *Responder) Answer(q *Message) (*Message, bool) {
+	if q.Response {
+		return nil, false
+	}
+	ptrs, others := r.records(false)
+	resp := &Message{Response: true}
+	unicast := len(q.Questions) > 0
+	answered := map[int]bool{}
+	all := append(ptrs, others...)
+	for _, question := range q.Questions {
+		unicast = unicast && question.Unicast
+		for i, rr := range all {
+			if answered[i] || !strings.EqualFold(rr.Name, question.Name) {
+				continue
+			}
+			if question.Type != TypeANY && question.Type != rr.Type {
+				continue
+			}
+			answered[i] = true
+			resp.Answers = append(resp.Answers, rr)
+		}
+	}
+	if len(resp.Answers) == 0 {
+		return nil, false
+	}
+	// Save a round trip by including the records needed to connect.
+	for i, rr := range all {
+		if answered[i] || i < len(ptrs) {
+			continue
+		}
+		resp.Additional = append(resp.Additional, rr)
+	}
+	return resp, unicast
+}
+
+// Run listens for queries and answers them until the context is cancelled,
+// announcing the service at the start, and whenever its TXT record changes,
+// and saying goodbye at the end.
+func (r *Responder) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	announce := func(goodbye bool) {
+		ptrs, others := r.records(goodbye)
+		m := &Message{Response: true, Answers: append(ptrs, others...)}
+		conn.WriteToUDP(m.Pack(), group)
+	}
+	go func() {
+		// RFC 6762 section 8.3: announce at least twice, a second apart.
+		for {
+			announce(false)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			announce(false)
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.updated:
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				announceGoodbye(r, group)
+				return nil
+			}
+			return err
+		}
+		q, err := Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		resp, unicast := r.Answer(q)
+		if resp == nil {
+			continue
+		}
+		to := group
+		if unicast || from.Port != group.Port {
+			// Legacy unicast queriers (RFC 6762 section 6.7) want their
+			// question and ID back.
+			if from.Port != group.Port {
+				resp.ID = q.ID
+				resp.Questions = q.Questions
+			}
+			to = from
+		}
+		conn.WriteToUDP(resp.Pack(), to)
+	}
+}
+
+// announceGoodbye tells the network the service is going away, on a fresh
+// socket since the listening one is closed.
+func announceGoodbye(r *Responder, to *net.UDPAddr) {
+	conn, err := net.DialUDP("udp4", nil, to)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	ptrs, others := r.records(true)
+	m := &Message{Response: true, Answers: append(ptrs, others...)}
+	conn.Write(m.Pack())
+}
+
+// localAddrs returns the IPv4 addresses of the machine's up, multicast
+// capable, non-loopback interfaces.
+func localAddrs() [][4]byte {
+	var ips [][4]byte
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				var ip [4]byte
+				copy(ip[:], ip4)
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
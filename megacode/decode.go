@@ -0,0 +1,47 @@
+//go:build !tinygo
+// +build !tinygo
+
+package megacode
+
+import "fmt"
+
+// Decode decodes a bitstream of simple 0s and 1s, as produced by Encode, back
+// into a MegaCode identifier.
+//
+// Since the leading and trailing silence of a received transmission can't be
+// told apart from the gaps around it, the bitstream is aligned on its first
+// pulse (which is always the sync bit), and may be missing trailing 0s.
+func Decode(input []byte) (uint32, error) {
+	start := -1
+	for i, b := range input {
+		if b > 1 {
+			return 0, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b, i)
+		}
+		if b == 1 && start < 0 {
+			start = i
+		}
+	}
+	if start < 0 {
+		return 0, fmt.Errorf("no pulses found")
+	}
+	aligned := make([]byte, EncodedBits)
+	offset := len(one) - 1
+	if len(input)-start+offset > len(aligned) {
+		return 0, fmt.Errorf("expected at most %d slots after the sync pulse; got %d", len(aligned)-offset, len(input)-start)
+	}
+	copy(aligned[offset:], input[start:])
+
+	var ID uint32
+	for i := 0; i < Bits; i++ {
+		slots := aligned[i*pulsesPerBit : (i+1)*pulsesPerBit]
+		switch string(slots) {
+		case string(zero):
+			ID <<= 1
+		case string(one):
+			ID = ID<<1 | 1
+		default:
+			return 0, fmt.Errorf("bit %d: expected a single pulse in slot 2 or 5; got %v", i, slots)
+		}
+	}
+	return ID, nil
+}
@@ -1,8 +1,13 @@
 /*
-Package megacode implements MegaCode encoding.
+Package megacode implements MegaCode encoding and decoding.
 
 Writing it would have been difficult without the work done by CuVoodoo in
 describing and decoding the MegaCode protocol on their excellent [MegaCode
 hacking page](https://wiki.cuvoodoo.info/doku.php?id=megacode).
+
+Encoding into buffers the caller provides, with EncodeInto, needs nothing
+but package errors, so that TinyGo firmware can use it. Transmissions and
+decoding, which need packages waveform, time and fmt, are left out of TinyGo
+builds.
 */
 package megacode
@@ -1,9 +1,59 @@
 package megacode
 
-import "fmt"
+import "errors"
+
+// Protocol is the name of the MegaCode protocol, as used in
+// waveform.Transmission.Protocol.
+const Protocol = "megacode"
+
+// pulsesPerBit is the number of pulse-width slots in a single MegaCode bit.
+const pulsesPerBit = 6
+
+// Bits is the number of bits in a MegaCode identifier.
+const Bits = 24
+
+// EncodedBits is the length of an encoded identifier, in pulse-width slots.
+const EncodedBits = Bits * pulsesPerBit
+
+// Errors from encoding, made once so that it doesn't allocate.
+var (
+	errRange = errors.New("identifier must be < 2^24")
+	errSync  = errors.New("identifier must have its high (sync) bit set")
+)
+
+// Each bit is a single pulse, positioned within its six slots according to
+// the bit's value.
+var (
+	zero = []byte{0, 0, 1, 0, 0, 0}
+	one  = []byte{0, 0, 0, 0, 0, 1}
+)
 
 // Encode encodes a MegaCode identifier to a bitstream of simple 0s and 1s.
 // ID must be < 2**24, and the high bit must be set.
 func Encode(ID uint32) ([]byte, error) {
-	return nil, fmt.Errorf("not implemented yet")
+	var result [EncodedBits]byte
+	if err := EncodeInto(&result, ID); err != nil {
+		return nil, err
+	}
+	return result[:], nil
+}
+
+// EncodeInto is Encode, writing the bitstream into a buffer provided by the
+// caller instead of allocating it, so that it can be used on
+// microcontrollers.
+func EncodeInto(dst *[EncodedBits]byte, ID uint32) error {
+	if ID >= 1<<Bits {
+		return errRange
+	}
+	if ID&(1<<(Bits-1)) == 0 {
+		return errSync
+	}
+	for i := 0; i < Bits; i++ {
+		slots := zero
+		if ID&(1<<(Bits-1-i)) != 0 {
+			slots = one
+		}
+		copy(dst[i*pulsesPerBit:], slots)
+	}
+	return nil
 }
@@ -1 +1,130 @@
 package megacode_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/megacode"
+)
+
+func TestEncode(t *testing.T) {
+	testcases := []struct {
+		name    string
+		ID      uint32
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "all-zeros",
+			ID:   0x800000,
+			want: "000001" + strings.Repeat("001000", 23),
+		},
+		{
+			name: "all-ones",
+			ID:   0xffffff,
+			want: strings.Repeat("000001", 24),
+		},
+		{
+			name: "mixed",
+			ID:   0x876543,
+			want: "000001001000001000001000" + // 8
+				"001000000001000001000001" + // 7
+				"001000000001000001001000" + // 6
+				"001000000001001000000001" + // 5
+				"001000000001001000001000" + // 4
+				"001000001000000001000001", // 3
+		},
+		{
+			name:    "too-large",
+			ID:      0x1000000,
+			wantErr: true,
+		},
+		{
+			name:    "no-sync-bit",
+			ID:      0x7fffff,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			got, err := megacode.Encode(tt.ID)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want Encode(0x%06x) to fail; got %s", tt.ID, bits.S(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !bytes.Equal(got, bits.B(tt.want)) {
+				t.Errorf("want Encode(0x%06x)==%s; got %s", tt.ID, tt.want, bits.S(got))
+			}
+
+			// Decoding should work with or without leading and trailing silence.
+			for _, input := range []string{tt.want, strings.TrimLeft(tt.want, "0"), strings.TrimRight(tt.want, "0"), "0000" + tt.want} {
+				ID, err := megacode.Decode(bits.B(input))
+				if err != nil {
+					t.Errorf("Decode(%s): %v", input, err)
+					continue
+				}
+				if ID != tt.ID {
+					t.Errorf("want Decode(%s)==0x%06x; got 0x%06x", input, tt.ID, ID)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testcases := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "silence",
+			input: "000000000000",
+		},
+		{
+			name:  "too-long",
+			input: strings.Repeat("000001", 25),
+		},
+		{
+			name:  "two-pulses-in-a-bit",
+			input: "000001001001" + strings.Repeat("001000", 22),
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			if ID, err := megacode.Decode(bits.B(tt.input)); err == nil {
+				t.Errorf("want Decode(%s) to fail; got 0x%06x", tt.input, ID)
+			}
+		})
+	}
+}
+
+func TestEncodeIntoAllocations(t *testing.T) {
+	var dst [megacode.EncodedBits]byte
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := megacode.EncodeInto(&dst, 0x876543); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("want EncodeInto to make no allocations; got %v", allocs)
+	}
+	want, _ := megacode.Encode(0x876543)
+	if !bytes.Equal(dst[:], want) {
+		t.Errorf("want EncodeInto to match Encode: %s; got %s", bits.S(want), bits.S(dst[:]))
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !tinygo
+// +build !tinygo
+
+package megacode
+
+import (
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// Default timing for MegaCode transmissions.
+const (
+	DefaultPulsewidth = time.Millisecond
+	DefaultRepeats    = 4
+)
+
+// Transmission encodes a MegaCode identifier into a complete transmission,
+// repeated repeats times with a bit's worth of silence in between. Pulse
+// positions need to be within a quarter of a pulse of where they belong.
+func Transmission(ID uint32, pulsewidth time.Duration, repeats int) (*waveform.Transmission, error) {
+	databits, err := Encode(ID)
+	if err != nil {
+		return nil, err
+	}
+	return &waveform.Transmission{
+		Protocol:  Protocol,
+		Bursts:    []waveform.Burst{{Bits: databits, Pulsewidth: pulsewidth}},
+		Repeats:   repeats,
+		RepeatGap: pulsewidth * pulsesPerBit,
+		Tolerance: pulsewidth / 4,
+	}, nil
+}
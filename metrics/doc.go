@@ -0,0 +1,10 @@
+/*
+Package metrics keeps counters, gauges and histograms, and exposes them in
+the Prometheus text format: over HTTP for long-running commands, or in a file
+for node_exporter's textfile collector for one-shot ones.
+
+A textfile is merged with what an earlier process left in it, so that
+counters keep counting across runs of a command, and gauges keep the values
+other runs set.
+*/
+package metrics
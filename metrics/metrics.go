@@ -0,0 +1,411 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+// kind is the type of a metric family.
+type kind int
+
+const (
+	counterKind kind = iota
+	gaugeKind
+	histogramKind
+)
+
+func (k kind) String() string {
+	return [...]string{"counter", "gauge", "histogram"}[k]
+}
+
+// family is a metric and all its labelled series.
+type family struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64 // Upper bounds, for histograms.
+	series  map[string]*series
+}
+
+// series is the value of a metric for one set of label values.
+type series struct {
+	values []string
+	value  float64   // Of a counter or gauge.
+	set    bool      // Whether a gauge was set by this process.
+	counts []float64 // Cumulative count in each bucket, for histograms.
+	sum    float64
+	count  float64
+}
+
+// Registry holds metrics. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	families   []*family
+	collectors []func()
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(name, help string, k kind, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == name {
+			panic(fmt.Sprintf("metric %s registered twice", name))
+		}
+	}
+	f := &family{name: name, help: help, kind: k, labels: labels, buckets: buckets, series: map[string]*series{}}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get returns the series for a set of label values, creating it if need be.
+// r.mu must be held.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v; got values %q", f.name, f.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s := f.series[key]
+	if s == nil {
+		s = &series{values: append([]string(nil), values...)}
+		if f.kind == histogramKind {
+			s.counts = make([]float64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a count that only goes up.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter registers a counter, with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.add(name, help, counterKind, nil, labels)}
+}
+
+// Add adds v, which must not be negative, to the series with the given
+// label values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.f.name))
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.get(values).value += v
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// Gauge registers a gauge, with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.add(name, help, gaugeKind, nil, labels)}
+}
+
+// Set sets the series with the given label values.
+func (g *Gauge) Set(v float64, values ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	s := g.f.get(values)
+	s.value, s.set = v, true
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Histogram registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("histogram %s buckets are not in order", name))
+	}
+	return &Histogram{r, r.add(name, help, histogramKind, buckets, labels)}
+}
+
+// Observe adds an observation to the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	s := h.f.get(values)
+	for i, b := range h.f.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// OnCollect adds a function to call before metrics are written, to update
+// gauges that are read rather than set as things happen.
+func (r *Registry) OnCollect(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, f)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := r.collectors
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b bytes.Buffer
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escape(f.help, false))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.series[k]
+			if f.kind != histogramKind {
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labels(f.labels, s.values, ""), format(s.value))
+				continue
+			}
+			for i, bound := range f.buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %s\n", f.name, labels(f.labels, s.values, format(bound)), format(s.counts[i]))
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %s\n", f.name, labels(f.labels, s.values, "+Inf"), format(s.count))
+			fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, labels(f.labels, s.values, ""), format(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %s\n", f.name, labels(f.labels, s.values, ""), format(s.count))
+		}
+	}
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// labels formats a series' labels, adding le for a histogram bucket if it
+// isn't empty.
+func labels(names, values []string, le string) string {
+	var parts []string
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", n, escape(values[i], true)))
+	}
+	if le != "" {
+		parts = append(parts, fmt.Sprintf("le=\"%s\"", le))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escape escapes help text, or a label value if quoted is true.
+func escape(s string, quoted bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quoted {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func format(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		// Whole numbers, such as rolling codes, in full.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Load merges metrics written earlier, such as by another run of the same
+// command: counters and histograms are added to, and gauges not set since
+// are set. Metrics that aren't registered, or don't match their
+// registration, are ignored.
+func (r *Registry) Load(rd io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byName := map[string]*family{}
+	for _, f := range r.families {
+		byName[f.name] = f
+	}
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		name, ls, v, err := parseSample(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		suffix := ""
+		f := byName[name]
+		if f == nil {
+			for _, sfx := range []string{"_bucket", "_sum", "_count"} {
+				if h := byName[strings.TrimSuffix(name, sfx)]; strings.HasSuffix(name, sfx) && h != nil && h.kind == histogramKind {
+					f, suffix = h, sfx
+				}
+			}
+		}
+		if f == nil || (f.kind == histogramKind) != (suffix != "") {
+			continue
+		}
+		// A missing label is the same as an empty one.
+		values := make([]string, len(f.labels))
+		for i, n := range f.labels {
+			values[i] = ls[n]
+		}
+		s := f.get(values)
+		switch {
+		case f.kind == counterKind:
+			s.value += v
+		case f.kind == gaugeKind:
+			if !s.set {
+				s.value = v
+			}
+		case suffix == "_sum":
+			s.sum += v
+		case suffix == "_count":
+			s.count += v
+		default:
+			le, err := strconv.ParseFloat(ls["le"], 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid le %q", line, ls["le"])
+			}
+			for i, b := range f.buckets {
+				if b == le {
+					s.counts[i] += v
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseSample parses a sample line, like `name{label="value"} 1`.
+func parseSample(text string) (string, map[string]string, float64, error) {
+	ls := map[string]string{}
+	end := strings.IndexAny(text, "{ ")
+	if end <= 0 {
+		return "", nil, 0, fmt.Errorf("invalid sample %q", text)
+	}
+	name, rest := text[:end], text[end:]
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for {
+			rest = strings.TrimLeft(rest, " ,")
+			if strings.HasPrefix(rest, "}") {
+				rest = rest[1:]
+				break
+			}
+			eq := strings.Index(rest, `="`)
+			if eq <= 0 {
+				return "", nil, 0, fmt.Errorf("invalid labels in %q", text)
+			}
+			label := rest[:eq]
+			rest = rest[eq+2:]
+			var value strings.Builder
+			for {
+				if rest == "" {
+					return "", nil, 0, fmt.Errorf("unterminated label value in %q", text)
+				}
+				c := rest[0]
+				rest = rest[1:]
+				if c == '"' {
+					break
+				}
+				if c == '\\' && rest != "" {
+					c, rest = rest[0], rest[1:]
+					if c == 'n' {
+						c = '\n'
+					}
+				}
+				value.WriteByte(c)
+			}
+			ls[label] = value.String()
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("missing value in %q", text)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("invalid value in %q", text)
+	}
+	return name, ls, v, nil
+}
+
+// Textfile writes metrics to a file for node_exporter's textfile collector.
+// The first write merges in what was in the file already; processes sharing
+// a file must not write it at the same time.
+type Textfile struct {
+	Path string
+
+	loaded bool
+}
+
+// Write writes the registry's metrics to the file, replacing it atomically.
+func (t *Textfile) Write(r *Registry) error {
+	if !t.loaded {
+		f, err := os.Open(t.Path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		default:
+			err := r.Load(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %v", t.Path, err)
+			}
+		}
+		t.loaded = true
+	}
+	return atomicfile.WriteFunc(t.Path, 0644, func(w io.Writer) error {
+		_, err := r.WriteTo(w)
+		return err
+	})
+}
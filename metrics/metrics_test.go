@@ -0,0 +1,171 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zellyn/openers/metrics"
+)
+
+// testRegistry is a registry with one metric of each kind.
+type testRegistry struct {
+	*metrics.Registry
+	sent     *metrics.Counter
+	rolling  *metrics.Gauge
+	duration *metrics.Histogram
+}
+
+func newTestRegistry() *testRegistry {
+	r := metrics.NewRegistry()
+	return &testRegistry{
+		Registry: r,
+		sent:     r.Counter("test_sent_total", "Transmissions sent.", "opener", "protocol"),
+		rolling:  r.Gauge("test_rolling_code", "Last rolling code sent.", "opener"),
+		duration: r.Histogram("test_duration_seconds", "How long transmitting took.", []float64{0.5, 1, 2}),
+	}
+}
+
+func (r *testRegistry) String() string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+func TestWrite(t *testing.T) {
+	r := newTestRegistry()
+	r.sent.Inc("garage", "secplus-v2")
+	r.sent.Add(2, "gate", "megacode")
+	r.sent.Inc("garage", "secplus-v2")
+	r.rolling.Set(240124710, `back "door"`)
+	r.duration.Observe(0.7)
+	r.duration.Observe(1.5)
+	r.duration.Observe(3)
+	want := `# HELP test_sent_total Transmissions sent.
+# TYPE test_sent_total counter
+test_sent_total{opener="garage",protocol="secplus-v2"} 2
+test_sent_total{opener="gate",protocol="megacode"} 2
+# HELP test_rolling_code Last rolling code sent.
+# TYPE test_rolling_code gauge
+test_rolling_code{opener="back \"door\""} 240124710
+# HELP test_duration_seconds How long transmitting took.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.5"} 0
+test_duration_seconds_bucket{le="1"} 1
+test_duration_seconds_bucket{le="2"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 5.2
+test_duration_seconds_count 3
+`
+	if got := r.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestOnCollect(t *testing.T) {
+	r := newTestRegistry()
+	code := 1.0
+	r.OnCollect(func() {
+		r.rolling.Set(code, "garage")
+		code++
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `test_rolling_code{opener="garage"} 1`) {
+		t.Errorf("want rolling code 1; got:\n%s", w.Body)
+	}
+	if got := r.String(); !strings.Contains(got, `test_rolling_code{opener="garage"} 2`) {
+		t.Errorf("want rolling code 2; got:\n%s", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	testdata := []struct {
+		name    string
+		earlier string
+		want    []string
+	}{
+		{
+			name: "merged",
+			earlier: `# TYPE test_sent_total counter
+test_sent_total{opener="garage",protocol="secplus-v2"} 5
+test_rolling_code{opener="garage"} 100
+test_rolling_code{opener="gate"} 7
+test_duration_seconds_bucket{le="1"} 4
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 2
+test_duration_seconds_count 4
+`,
+			want: []string{
+				`test_sent_total{opener="garage",protocol="secplus-v2"} 6`,
+				`test_rolling_code{opener="garage"} 101`,
+				`test_rolling_code{opener="gate"} 7`,
+				`test_duration_seconds_bucket{le="1"} 5`,
+				`test_duration_seconds_sum 2.7`,
+				`test_duration_seconds_count 5`,
+			},
+		},
+		{
+			name: "unknown metrics ignored",
+			earlier: `other_total 3
+test_sent_total_bucket{le="1"} 3
+`,
+			want: []string{`test_sent_total{opener="garage",protocol="secplus-v2"} 1`},
+		},
+		{
+			name:    "missing labels are empty",
+			earlier: `test_sent_total{opener="gate"} 3`,
+			want:    []string{`test_sent_total{opener="gate",protocol=""} 3`},
+		},
+	}
+	for _, tt := range testdata {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry()
+			r.sent.Inc("garage", "secplus-v2")
+			r.rolling.Set(101, "garage")
+			r.duration.Observe(0.7)
+			if err := r.Load(strings.NewReader(tt.earlier)); err != nil {
+				t.Fatal(err)
+			}
+			got := r.String()
+			for _, w := range tt.want {
+				if !strings.Contains(got, w+"\n") {
+					t.Errorf("want %s; got:\n%s", w, got)
+				}
+			}
+			if strings.Contains(got, "other") {
+				t.Errorf("want unknown metrics dropped; got:\n%s", got)
+			}
+		})
+	}
+
+	if err := newTestRegistry().Load(strings.NewReader(`test_sent_total{opener="garage} 1`)); err == nil {
+		t.Errorf("want error for unterminated label")
+	}
+}
+
+func TestTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openers.prom")
+	// Three runs of a one-shot command.
+	for i := 0; i < 3; i++ {
+		r := newTestRegistry()
+		r.sent.Inc("garage", "secplus-v2")
+		tf := &metrics.Textfile{Path: path}
+		if err := tf.Write(r.Registry); err != nil {
+			t.Fatal(err)
+		}
+		// Writing again doesn't count earlier runs twice.
+		if err := tf.Write(r.Registry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `test_sent_total{opener="garage",protocol="secplus-v2"} 3`; !strings.Contains(string(data), want) {
+		t.Errorf("want %s; got:\n%s", want, data)
+	}
+}
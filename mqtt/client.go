@@ -0,0 +1,248 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultAddr is the address of a broker on the same machine.
+const DefaultAddr = "localhost:1883"
+
+// DefaultKeepAlive is how often the client pings an otherwise idle broker.
+const DefaultKeepAlive = 30 * time.Second
+
+// connectTimeout limits how long the broker has to accept a connection.
+const connectTimeout = 10 * time.Second
+
+// ErrClosed is returned by a client that has been closed.
+var ErrClosed = errors.New("mqtt: client closed")
+
+// Handler is called with each message received on a subscription. Handlers
+// are called one at a time, from the goroutine reading from the broker, so
+// they should not block.
+type Handler func(m *Message)
+
+// Client is a connection to an MQTT broker. It is safe for concurrent use.
+type Client struct {
+	conn      net.Conn
+	keepAlive time.Duration
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	subacks  map[uint16]chan struct{}
+	nextID   uint16
+	err      error
+
+	done chan struct{}
+}
+
+// Dial connects to the broker at addr, such as DefaultAddr.
+func Dial(ctx context.Context, addr string, c *Connect) (*Client, error) {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to MQTT broker: %v", err)
+	}
+	client, err := NewClient(conn, c)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewClient sends the CONNECT packet over conn and waits for the broker to
+// accept it. A zero KeepAlive is replaced by DefaultKeepAlive.
+func NewClient(conn net.Conn, c *Connect) (*Client, error) {
+	connect := *c
+	if connect.KeepAlive == 0 {
+		connect.KeepAlive = DefaultKeepAlive
+	}
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+	if err := WritePacket(conn, connect.Packet()); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	p, err := ReadPacket(r)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for CONNACK: %v", err)
+	}
+	if p.Type != TypeConnack || len(p.Body) != 2 {
+		return nil, fmt.Errorf("want CONNACK; got packet type %d", p.Type)
+	}
+	if p.Body[1] != 0 {
+		return nil, ConnackError(p.Body[1])
+	}
+	conn.SetDeadline(time.Time{})
+
+	client := &Client{
+		conn:      conn,
+		keepAlive: connect.KeepAlive,
+		handlers:  map[string]Handler{},
+		subacks:   map[uint16]chan struct{}{},
+		done:      make(chan struct{}),
+	}
+	go client.read(r)
+	go client.ping()
+	return client, nil
+}
+
+// Done returns a channel that is closed when the connection is lost or
+// closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection was lost, once Done is closed.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// fail records the first error, and closes the connection.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.conn.Close()
+	close(c.done)
+}
+
+// write sends a packet.
+func (c *Client) write(p Packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.keepAlive))
+	if err := WritePacket(c.conn, p); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// read handles packets from the broker until the connection fails. The
+// broker must send something, if only a PINGRESP, every keep-alive period.
+func (c *Client) read(r *bufio.Reader) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		p, err := ReadPacket(r)
+		if err != nil {
+			c.fail(fmt.Errorf("connection to MQTT broker lost: %v", err))
+			return
+		}
+		switch p.Type {
+		case TypePublish:
+			m, _, err := ParsePublish(p)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			c.dispatch(m)
+		case TypeSuback:
+			if len(p.Body) < 2 {
+				c.fail(fmt.Errorf("invalid SUBACK"))
+				return
+			}
+			id := uint16(p.Body[0])<<8 | uint16(p.Body[1])
+			c.mu.Lock()
+			if ch, ok := c.subacks[id]; ok {
+				close(ch)
+				delete(c.subacks, id)
+			}
+			c.mu.Unlock()
+		case TypePingresp:
+		default:
+			c.fail(fmt.Errorf("unexpected packet type %d from broker", p.Type))
+			return
+		}
+	}
+}
+
+// dispatch passes a message to the handlers of matching subscriptions.
+func (c *Client) dispatch(m *Message) {
+	c.mu.Lock()
+	var handlers []Handler
+	for filter, h := range c.handlers {
+		if Match(filter, m.Topic) {
+			handlers = append(handlers, h)
+		}
+	}
+	c.mu.Unlock()
+	for _, h := range handlers {
+		h(m)
+	}
+}
+
+// ping keeps the connection alive.
+func (c *Client) ping() {
+	t := time.NewTicker(c.keepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if err := c.write(Packet{Type: TypePingreq}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Publish sends a message.
+func (c *Client) Publish(m *Message) error {
+	return c.write(m.Packet())
+}
+
+// Subscribe subscribes to a topic filter, calling handler with each message
+// received, and waits for the broker to confirm it. Retained messages
+// matching the filter may be delivered before Subscribe returns.
+func (c *Client) Subscribe(ctx context.Context, filter string, handler Handler) error {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID++
+	}
+	id := c.nextID
+	c.handlers[filter] = handler
+	ack := make(chan struct{})
+	c.subacks[id] = ack
+	c.mu.Unlock()
+
+	if err := c.write((&Subscribe{ID: id, Filters: []string{filter}}).Packet()); err != nil {
+		return err
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close disconnects cleanly, so the broker doesn't publish the will.
+func (c *Client) Close() error {
+	err := c.write(Packet{Type: TypeDisconnect})
+	c.fail(ErrClosed)
+	return err
+}
@@ -0,0 +1,9 @@
+/*
+Package mqtt is a small MQTT 3.1.1 client, enough to publish retained state
+and subscribe to commands, as Home Assistant integrations do.
+
+Only QoS 0 is supported, in both directions. See
+https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html for the
+protocol.
+*/
+package mqtt
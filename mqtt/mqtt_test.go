@@ -0,0 +1,133 @@
+package mqtt_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/mqtt"
+	"github.com/zellyn/openers/mqtt/mqtttest"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	connect := &mqtt.Connect{
+		ClientID:  "openers",
+		Username:  "user",
+		Password:  "pass",
+		KeepAlive: 30 * time.Second,
+		Will:      &mqtt.Message{Topic: "openers/status", Payload: []byte("offline"), Retain: true},
+	}
+	long := &mqtt.Message{Topic: "a/b", Payload: bytes.Repeat([]byte("x"), 200)}
+
+	var buf bytes.Buffer
+	for _, p := range []mqtt.Packet{connect.Packet(), long.Packet()} {
+		if err := mqtt.WritePacket(&buf, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	p, err := mqtt.ReadPacket(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := mqtt.ParseConnect(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, connect) {
+		t.Errorf("want %+v; got %+v", connect, got)
+	}
+	if p, err = mqtt.ReadPacket(r); err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := mqtt.ParsePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, long) {
+		t.Errorf("want %+v; got %+v", long, m)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testcases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+", "a", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"a/b/c", "a/b", false},
+	}
+	for _, tt := range testcases {
+		if got := mqtt.Match(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("want Match(%q, %q)==%v; got %v", tt.filter, tt.topic, tt.want, got)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	broker.Username, broker.Password = "user", "pass"
+	ctx := context.Background()
+
+	if _, err := mqtt.Dial(ctx, broker.Addr, &mqtt.Connect{ClientID: "x", Username: "user"}); err != mqtt.ConnackError(4) {
+		t.Errorf("want bad username or password; got %v", err)
+	}
+
+	will := &mqtt.Message{Topic: "test/status", Payload: []byte("offline"), Retain: true}
+	c, err := mqtt.Dial(ctx, broker.Addr, &mqtt.Connect{ClientID: "test", Username: "user", Password: "pass", Will: will})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(&mqtt.Message{Topic: "test/status", Payload: []byte("online"), Retain: true}); err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan *mqtt.Message, 10)
+	if err := c.Subscribe(ctx, "test/+", func(m *mqtt.Message) { got <- m }); err != nil {
+		t.Fatal(err)
+	}
+	if m := <-got; m.Topic != "test/status" || string(m.Payload) != "online" || !m.Retain {
+		t.Errorf("want retained status on subscribing; got %+v", m)
+	}
+	broker.Publish(&mqtt.Message{Topic: "test/cmd", Payload: []byte("go")})
+	broker.Publish(&mqtt.Message{Topic: "other/cmd", Payload: []byte("no")})
+	if m := <-got; m.Topic != "test/cmd" || string(m.Payload) != "go" {
+		t.Errorf("want test/cmd; got %+v", m)
+	}
+
+	broker.DropClients()
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("want connection loss noticed")
+	}
+	if c.Err() == nil {
+		t.Errorf("want an error after connection loss")
+	}
+	// The broker publishes the will once it notices the connection is gone.
+	deadline := time.Now().Add(time.Second)
+	for {
+		m := broker.Retained("test/status")
+		if m != nil && string(m.Payload) == "offline" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want will published; got %+v", m)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
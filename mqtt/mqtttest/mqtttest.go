@@ -0,0 +1,225 @@
+// Package mqtttest provides a stand-in for an MQTT broker, for testing code
+// that uses package mqtt.
+package mqtttest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+
+	"github.com/zellyn/openers/mqtt"
+)
+
+// Broker is a stand-in for an MQTT broker, listening on a local port. It
+// routes QoS 0 messages between its clients, keeps retained messages,
+// publishes wills, and records every message published to it.
+type Broker struct {
+	Addr string
+
+	// Username and Password, if Username is not empty, must be given by
+	// clients.
+	Username, Password string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	sessions  map[*session]bool
+	retained  map[string]*mqtt.Message
+	published []*mqtt.Message
+}
+
+// session is a connected client.
+type session struct {
+	conn    net.Conn
+	mu      sync.Mutex // Guards writes.
+	filters []string
+}
+
+// NewBroker starts a broker on a free local port.
+func NewBroker() (*Broker, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{
+		Addr:     l.Addr().String(),
+		listener: l,
+		sessions: map[*session]bool{},
+		retained: map[string]*mqtt.Message{},
+	}
+	b.wg.Add(1)
+	go b.serve()
+	return b, nil
+}
+
+// Close stops the broker, dropping its clients without publishing their
+// wills.
+func (b *Broker) Close() error {
+	err := b.listener.Close()
+	b.mu.Lock()
+	b.closed = true
+	for s := range b.sessions {
+		s.conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	return err
+}
+
+// Published returns every message published by clients so far, including
+// wills, in order.
+func (b *Broker) Published() []*mqtt.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*mqtt.Message(nil), b.published...)
+}
+
+// Retained returns the retained message for a topic, or nil.
+func (b *Broker) Retained(topic string) *mqtt.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retained[topic]
+}
+
+// Publish publishes a message, as if from a client.
+func (b *Broker) Publish(m *mqtt.Message) {
+	b.mu.Lock()
+	b.published = append(b.published, m)
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	var to []*session
+	for s := range b.sessions {
+		for _, f := range s.filters {
+			if mqtt.Match(f, m.Topic) {
+				to = append(to, s)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	// Forwarded messages are only marked retained when sent because of a
+	// new subscription.
+	fwd := &mqtt.Message{Topic: m.Topic, Payload: m.Payload}
+	for _, s := range to {
+		s.send(fwd.Packet())
+	}
+}
+
+// DropClients closes every client connection abruptly, so that their wills
+// are published.
+func (b *Broker) DropClients() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.sessions {
+		s.conn.Close()
+	}
+}
+
+func (s *session) send(p mqtt.Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return mqtt.WritePacket(s.conn, p)
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handle(conn)
+		}()
+	}
+}
+
+// handle serves a single client.
+func (b *Broker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	p, err := mqtt.ReadPacket(r)
+	if err != nil || p.Type != mqtt.TypeConnect {
+		return
+	}
+	c, err := mqtt.ParseConnect(p)
+	if err != nil {
+		mqtt.WritePacket(conn, mqtt.ConnackPacket(1))
+		return
+	}
+	if b.Username != "" && (c.Username != b.Username || c.Password != b.Password) {
+		mqtt.WritePacket(conn, mqtt.ConnackPacket(4))
+		return
+	}
+	s := &session{conn: conn}
+	if err := s.send(mqtt.ConnackPacket(0)); err != nil {
+		return
+	}
+	b.mu.Lock()
+	b.sessions[s] = true
+	b.mu.Unlock()
+	clean := false
+	defer func() {
+		b.mu.Lock()
+		delete(b.sessions, s)
+		closed := b.closed
+		b.mu.Unlock()
+		if !clean && !closed && c.Will != nil {
+			b.Publish(c.Will)
+		}
+	}()
+
+	for {
+		p, err := mqtt.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.Type {
+		case mqtt.TypePublish:
+			m, _, err := mqtt.ParsePublish(p)
+			if err != nil {
+				return
+			}
+			b.Publish(m)
+		case mqtt.TypeSubscribe:
+			sub, err := mqtt.ParseSubscribe(p)
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			s.filters = append(s.filters, sub.Filters...)
+			var retained []*mqtt.Message
+			for topic, m := range b.retained {
+				for _, f := range sub.Filters {
+					if mqtt.Match(f, topic) {
+						retained = append(retained, m)
+						break
+					}
+				}
+			}
+			b.mu.Unlock()
+			if err := s.send(mqtt.SubackPacket(sub.ID, len(sub.Filters))); err != nil {
+				return
+			}
+			for _, m := range retained {
+				s.send(m.Packet())
+			}
+		case mqtt.TypePingreq:
+			s.send(mqtt.Packet{Type: mqtt.TypePingresp})
+		case mqtt.TypeDisconnect:
+			clean = true
+			return
+		default:
+			return
+		}
+	}
+}
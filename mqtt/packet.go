@@ -0,0 +1,330 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Packet types.
+const (
+	TypeConnect    = 1
+	TypeConnack    = 2
+	TypePublish    = 3
+	TypePuback     = 4
+	TypeSubscribe  = 8
+	TypeSuback     = 9
+	TypePingreq    = 12
+	TypePingresp   = 13
+	TypeDisconnect = 14
+)
+
+// maxLength is the largest remaining length that can be encoded.
+const maxLength = 268435455
+
+// errShort is returned when a packet body ends early.
+var errShort = errors.New("packet too short")
+
+// Packet is an MQTT control packet: its type, the flags in the low four bits
+// of its first byte, and everything after the length.
+type Packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// ReadPacket reads a single packet.
+func ReadPacket(r *bufio.Reader) (Packet, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return Packet{}, err
+	}
+	length := 0
+	for shift := uint(0); ; shift += 7 {
+		if shift > 21 {
+			return Packet{}, fmt.Errorf("invalid packet length")
+		}
+		l, err := r.ReadByte()
+		if err != nil {
+			return Packet{}, err
+		}
+		length |= int(l&0x7f) << shift
+		if l&0x80 == 0 {
+			break
+		}
+	}
+	p := Packet{Type: b >> 4, Flags: b & 0x0f, Body: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		return Packet{}, err
+	}
+	return p, nil
+}
+
+// WritePacket writes a single packet.
+func WritePacket(w io.Writer, p Packet) error {
+	if len(p.Body) > maxLength {
+		return fmt.Errorf("packet too long: %d bytes", len(p.Body))
+	}
+	buf := make([]byte, 0, 5+len(p.Body))
+	buf = append(buf, p.Type<<4|p.Flags&0x0f)
+	for l := len(p.Body); ; {
+		b := byte(l & 0x7f)
+		l >>= 7
+		if l > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if l == 0 {
+			break
+		}
+	}
+	buf = append(buf, p.Body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// appendString appends a length-prefixed string.
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// reader takes fields from the front of a packet body.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errShort
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) string() string {
+	return string(r.bytes(int(r.uint16())))
+}
+
+// Message is an application message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Packet encodes the message as a QoS 0 PUBLISH packet.
+func (m *Message) Packet() Packet {
+	p := Packet{Type: TypePublish, Body: appendString(nil, m.Topic)}
+	if m.Retain {
+		p.Flags = 1
+	}
+	p.Body = append(p.Body, m.Payload...)
+	return p
+}
+
+// ParsePublish decodes a PUBLISH packet, of any QoS, returning the message
+// and, for QoS 1 and 2, its packet identifier.
+func ParsePublish(p Packet) (*Message, uint16, error) {
+	r := &reader{b: p.Body}
+	m := &Message{Topic: r.string(), Retain: p.Flags&1 == 1}
+	var id uint16
+	if qos := p.Flags >> 1 & 3; qos > 0 {
+		id = r.uint16()
+	}
+	if r.err != nil {
+		return nil, 0, fmt.Errorf("invalid PUBLISH: %v", r.err)
+	}
+	m.Payload = r.b
+	return m, id, nil
+}
+
+// Connect is the content of a CONNECT packet.
+type Connect struct {
+	ClientID  string
+	Username  string // Empty for none.
+	Password  string // Empty for none.
+	KeepAlive time.Duration
+	// Will, if not nil, is published by the broker if the client goes away
+	// without disconnecting.
+	Will *Message
+}
+
+// Connect flags.
+const (
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+)
+
+// Packet encodes the CONNECT packet, always asking for a clean session.
+func (c *Connect) Packet() Packet {
+	b := appendString(nil, "MQTT")
+	flags := byte(flagCleanSession)
+	if c.Will != nil {
+		flags |= flagWill
+		if c.Will.Retain {
+			flags |= flagWillRetain
+		}
+	}
+	if c.Username != "" {
+		flags |= flagUsername
+	}
+	if c.Password != "" {
+		flags |= flagPassword
+	}
+	keepAlive := c.KeepAlive / time.Second
+	b = append(b, 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	b = appendString(b, c.ClientID)
+	if c.Will != nil {
+		b = appendString(b, c.Will.Topic)
+		b = appendString(b, string(c.Will.Payload))
+	}
+	if c.Username != "" {
+		b = appendString(b, c.Username)
+	}
+	if c.Password != "" {
+		b = appendString(b, c.Password)
+	}
+	return Packet{Type: TypeConnect, Body: b}
+}
+
+// ParseConnect decodes a CONNECT packet.
+func ParseConnect(p Packet) (*Connect, error) {
+	r := &reader{b: p.Body}
+	if name := r.string(); r.err == nil && name != "MQTT" {
+		return nil, fmt.Errorf("unsupported protocol %q", name)
+	}
+	level := r.bytes(1)
+	flags := r.bytes(1)
+	c := &Connect{KeepAlive: time.Duration(r.uint16()) * time.Second}
+	c.ClientID = r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid CONNECT: %v", r.err)
+	}
+	if level[0] != 4 {
+		return nil, fmt.Errorf("unsupported protocol level %d", level[0])
+	}
+	if flags[0]&flagWill != 0 {
+		c.Will = &Message{Topic: r.string(), Payload: []byte(r.string()), Retain: flags[0]&flagWillRetain != 0}
+	}
+	if flags[0]&flagUsername != 0 {
+		c.Username = r.string()
+	}
+	if flags[0]&flagPassword != 0 {
+		c.Password = r.string()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid CONNECT: %v", r.err)
+	}
+	return c, nil
+}
+
+// ConnackError is a CONNACK return code refusing a connection.
+type ConnackError byte
+
+var connackErrors = map[ConnackError]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+func (e ConnackError) Error() string {
+	if s, ok := connackErrors[e]; ok {
+		return "connection refused: " + s
+	}
+	return fmt.Sprintf("connection refused with code %d", byte(e))
+}
+
+// ConnackPacket returns a CONNACK packet with the given return code, zero
+// for success.
+func ConnackPacket(code byte) Packet {
+	return Packet{Type: TypeConnack, Body: []byte{0, code}}
+}
+
+// Subscribe is the content of a SUBSCRIBE packet.
+type Subscribe struct {
+	ID      uint16
+	Filters []string
+}
+
+// Packet encodes the SUBSCRIBE packet, asking for QoS 0.
+func (s *Subscribe) Packet() Packet {
+	b := []byte{byte(s.ID >> 8), byte(s.ID)}
+	for _, f := range s.Filters {
+		b = appendString(b, f)
+		b = append(b, 0)
+	}
+	return Packet{Type: TypeSubscribe, Flags: 2, Body: b}
+}
+
+// ParseSubscribe decodes a SUBSCRIBE packet.
+func ParseSubscribe(p Packet) (*Subscribe, error) {
+	r := &reader{b: p.Body}
+	s := &Subscribe{ID: r.uint16()}
+	for r.err == nil && len(r.b) > 0 {
+		s.Filters = append(s.Filters, r.string())
+		r.bytes(1)
+	}
+	if r.err != nil || len(s.Filters) == 0 {
+		return nil, fmt.Errorf("invalid SUBSCRIBE")
+	}
+	return s, nil
+}
+
+// SubackPacket returns a SUBACK packet granting QoS 0 for n filters.
+func SubackPacket(id uint16, n int) Packet {
+	b := []byte{byte(id >> 8), byte(id)}
+	b = append(b, make([]byte, n)...)
+	return Packet{Type: TypeSuback, Body: b}
+}
+
+// Match reports whether a topic matches a subscription filter, which may
+// use the + (one level) and # (any remaining levels) wildcards.
+func Match(filter, topic string) bool {
+	for {
+		if filter == "#" {
+			return true
+		}
+		fl, frest, fmore := cut(filter)
+		tl, trest, tmore := cut(topic)
+		if fl != "+" && fl != tl {
+			return false
+		}
+		if !fmore || !tmore {
+			return fmore == tmore || frest == "#"
+		}
+		filter, topic = frest, trest
+	}
+}
+
+// cut splits the first level off a topic.
+func cut(s string) (level, rest string, more bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' {
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
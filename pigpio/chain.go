@@ -0,0 +1,66 @@
+package pigpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxChainDelay is the longest delay a single chain command can express.
+const maxChainDelay = 65535 * time.Microsecond
+
+// maxLoopCount is the largest repeat count of a chain loop.
+const maxLoopCount = 65535
+
+// ChainBuilder builds the byte sequence passed to WaveChain.
+type ChainBuilder struct {
+	chain []byte
+	err   error
+}
+
+// Wave appends a wave to the chain.
+func (b *ChainBuilder) Wave(id int) *ChainBuilder {
+	if id < 0 || id > 250 {
+		b.fail(fmt.Errorf("wave ID %d cannot be chained", id))
+	}
+	b.chain = append(b.chain, byte(id))
+	return b
+}
+
+// Delay appends a delay, rounded to the nearest microsecond, to the chain.
+// Delays longer than a single chain command supports are split.
+func (b *ChainBuilder) Delay(d time.Duration) *ChainBuilder {
+	d = d.Round(time.Microsecond)
+	for d > 0 {
+		step := d
+		if step > maxChainDelay {
+			step = maxChainDelay
+		}
+		us := int(step / time.Microsecond)
+		b.chain = append(b.chain, 255, 2, byte(us), byte(us>>8))
+		d -= step
+	}
+	return b
+}
+
+// Loop appends body, repeated count times, to the chain.
+func (b *ChainBuilder) Loop(count int, body func(*ChainBuilder)) *ChainBuilder {
+	if count < 1 || count > maxLoopCount {
+		b.fail(fmt.Errorf("chain loop count %d out of range 1-%d", count, maxLoopCount))
+	}
+	b.chain = append(b.chain, 255, 0)
+	body(b)
+	b.chain = append(b.chain, 255, 1, byte(count), byte(count>>8))
+	return b
+}
+
+// Bytes returns the chain built so far, or the first error encountered while
+// building it.
+func (b *ChainBuilder) Bytes() ([]byte, error) {
+	return b.chain, b.err
+}
+
+func (b *ChainBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package pigpio is a client for the socket interface of pigpiod, the pigpio
+daemon, which can play precisely timed waveforms on Raspberry Pi GPIO pins
+using DMA.
+
+Only the commands needed to build and play waveforms are supported. See
+https://abyz.me.uk/rpi/pigpio/sif.html for the protocol.
+*/
+package pigpio
@@ -0,0 +1,185 @@
+package pigpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultAddr is the address pigpiod listens on by default.
+const DefaultAddr = "localhost:8888"
+
+// Command numbers, from pigpio.h.
+const (
+	CmdModes = 0  // Set GPIO mode.
+	CmdWrite = 4  // Write GPIO level.
+	CmdWVAG  = 28 // Add generic pulses to the wave being built.
+	CmdWVBSY = 32 // Is a wave being transmitted?
+	CmdWVHLT = 33 // Stop transmitting waves.
+	CmdWVCRE = 49 // Create a wave from the pulses added so far.
+	CmdWVDEL = 50 // Delete a wave.
+	CmdWVNEW = 53 // Start building a new wave.
+	CmdWVCHA = 93 // Transmit a chain of waves.
+)
+
+// Output is the GPIO mode for outputs.
+const Output = 1
+
+// Pulse is one step of a generic waveform: the GPIOs in On are switched on,
+// those in Off are switched off, and then the wave waits for Delay before the
+// next step. On and Off are bit masks with bit n for GPIO n.
+type Pulse struct {
+	On, Off uint32
+	Delay   uint32 // In microseconds.
+}
+
+// Error is an error reported by pigpiod.
+type Error struct {
+	Cmd  uint32
+	Code int32
+}
+
+// errorNames describes the errors most likely to be returned by the commands
+// this package supports.
+var errorNames = map[int32]string{
+	-2:   "bad GPIO",
+	-4:   "bad mode",
+	-5:   "bad level",
+	-41:  "no permission to update GPIO",
+	-66:  "waveform too long",
+	-67:  "too many waveform pulses",
+	-78:  "too many control blocks",
+	-101: "wave ID not found",
+	-129: "chain counter too large",
+	-130: "bad chain command",
+	-131: "chain loops nested too deeply",
+	-132: "chain too long",
+	-133: "chain delay too long",
+}
+
+func (e *Error) Error() string {
+	if name, ok := errorNames[e.Code]; ok {
+		return fmt.Sprintf("pigpiod command %d failed: %s (%d)", e.Cmd, name, e.Code)
+	}
+	return fmt.Sprintf("pigpiod command %d failed with error %d", e.Cmd, e.Code)
+}
+
+// Client is a connection to pigpiod. It is safe for concurrent use.
+type Client struct {
+	mu   sync.Mutex
+	conn io.ReadWriteCloser
+}
+
+// Dial connects to pigpiod at addr, such as DefaultAddr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to pigpiod: %v", err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a client talking to pigpiod over conn.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{conn: conn}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command sends a command with its parameters and extension data, returning
+// its result. Negative results are returned as an *Error.
+func (c *Client) Command(cmd, p1, p2 uint32, ext []byte) (int32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req := make([]byte, 16, 16+len(ext))
+	binary.LittleEndian.PutUint32(req[0:], cmd)
+	binary.LittleEndian.PutUint32(req[4:], p1)
+	binary.LittleEndian.PutUint32(req[8:], p2)
+	binary.LittleEndian.PutUint32(req[12:], uint32(len(ext)))
+	req = append(req, ext...)
+	if _, err := c.conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 16)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return 0, fmt.Errorf("reading pigpiod response: %v", err)
+	}
+	if got := binary.LittleEndian.Uint32(resp); got != cmd {
+		return 0, fmt.Errorf("pigpiod responded to command %d; want %d", got, cmd)
+	}
+	res := int32(binary.LittleEndian.Uint32(resp[12:]))
+	if res < 0 {
+		return res, &Error{Cmd: cmd, Code: res}
+	}
+	return res, nil
+}
+
+// SetMode sets the mode of a GPIO, such as Output.
+func (c *Client) SetMode(gpio int, mode uint32) error {
+	_, err := c.Command(CmdModes, uint32(gpio), mode, nil)
+	return err
+}
+
+// Write sets the level of a GPIO.
+func (c *Client) Write(gpio int, level int) error {
+	_, err := c.Command(CmdWrite, uint32(gpio), uint32(level), nil)
+	return err
+}
+
+// WaveAddNew starts building a new wave.
+func (c *Client) WaveAddNew() error {
+	_, err := c.Command(CmdWVNEW, 0, 0, nil)
+	return err
+}
+
+// WaveAddGeneric adds pulses to the wave being built, returning the total
+// number of pulses in it.
+func (c *Client) WaveAddGeneric(pulses []Pulse) (int, error) {
+	ext := make([]byte, 12*len(pulses))
+	for i, p := range pulses {
+		binary.LittleEndian.PutUint32(ext[12*i:], p.On)
+		binary.LittleEndian.PutUint32(ext[12*i+4:], p.Off)
+		binary.LittleEndian.PutUint32(ext[12*i+8:], p.Delay)
+	}
+	n, err := c.Command(CmdWVAG, 0, 0, ext)
+	return int(n), err
+}
+
+// WaveCreate creates a wave from the pulses added since WaveAddNew, returning
+// its ID.
+func (c *Client) WaveCreate() (int, error) {
+	id, err := c.Command(CmdWVCRE, 0, 0, nil)
+	return int(id), err
+}
+
+// WaveDelete deletes a wave.
+func (c *Client) WaveDelete(id int) error {
+	_, err := c.Command(CmdWVDEL, uint32(id), 0, nil)
+	return err
+}
+
+// WaveChain starts transmitting a chain of waves, built with a ChainBuilder.
+func (c *Client) WaveChain(chain []byte) error {
+	_, err := c.Command(CmdWVCHA, 0, 0, chain)
+	return err
+}
+
+// WaveTxBusy returns true while a wave is being transmitted.
+func (c *Client) WaveTxBusy() (bool, error) {
+	busy, err := c.Command(CmdWVBSY, 0, 0, nil)
+	return busy == 1, err
+}
+
+// WaveTxStop stops transmitting waves.
+func (c *Client) WaveTxStop() error {
+	_, err := c.Command(CmdWVHLT, 0, 0, nil)
+	return err
+}
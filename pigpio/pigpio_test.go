@@ -0,0 +1,93 @@
+package pigpio_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/pigpio"
+	"github.com/zellyn/openers/pigpio/pigpiotest"
+)
+
+func TestClient(t *testing.T) {
+	server, err := pigpiotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Errors = map[uint32]int32{pigpio.CmdWrite: -41}
+
+	c, err := pigpio.Dial(server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SetMode(12, pigpio.Output); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaveAddNew(); err != nil {
+		t.Fatal(err)
+	}
+	pulses := []pigpio.Pulse{{On: 1 << 12, Delay: 250}, {Off: 1 << 12, Delay: 500}}
+	if n, err := c.WaveAddGeneric(pulses); err != nil || n != 2 {
+		t.Fatalf("want 2 pulses added; got %d, %v", n, err)
+	}
+	id, err := c.WaveCreate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Waves()[id]; !reflect.DeepEqual(got, pulses) {
+		t.Errorf("want wave %d to be %v; got %v", id, pulses, got)
+	}
+	if err := c.WaveDelete(id); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.WaveDelete(id)
+	if e, ok := err.(*pigpio.Error); !ok || e.Code != -101 {
+		t.Errorf("want wave ID not found error deleting a deleted wave; got %v", err)
+	}
+	err = c.Write(12, 1)
+	if e, ok := err.(*pigpio.Error); !ok || e.Code != -41 || e.Error() != "pigpiod command 4 failed: no permission to update GPIO (-41)" {
+		t.Errorf("want permission error from Write; got %v", err)
+	}
+
+	req := server.Requests()[0]
+	if want := (pigpiotest.Request{Cmd: pigpio.CmdModes, P1: 12, P2: pigpio.Output}); !reflect.DeepEqual(req, want) {
+		t.Errorf("want first request %+v; got %+v", want, req)
+	}
+}
+
+func TestChainBuilder(t *testing.T) {
+	b := &pigpio.ChainBuilder{}
+	b.Loop(3, func(b *pigpio.ChainBuilder) {
+		b.Wave(0).Delay(90 * time.Millisecond).Wave(1).Delay(1000 * time.Microsecond)
+	})
+	b.Wave(0)
+	got, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		255, 0,
+		0,
+		255, 2, 0xff, 0xff, // 65535µs
+		255, 2, 0x91, 0x5f, // 24465µs
+		1,
+		255, 2, 0xe8, 0x03,
+		255, 1, 3, 0,
+		0,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("want chain %v; got %v", want, got)
+	}
+
+	if _, err := (&pigpio.ChainBuilder{}).Wave(251).Bytes(); err == nil {
+		t.Errorf("want error chaining wave 251")
+	}
+	if _, err := (&pigpio.ChainBuilder{}).Loop(0, func(*pigpio.ChainBuilder) {}).Bytes(); err == nil {
+		t.Errorf("want error looping 0 times")
+	}
+}
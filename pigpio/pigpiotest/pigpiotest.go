@@ -0,0 +1,164 @@
+// Package pigpiotest provides a stand-in for pigpiod, for testing code that
+// uses package pigpio.
+package pigpiotest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/zellyn/openers/pigpio"
+)
+
+// Request is a command received by the server.
+type Request struct {
+	Cmd, P1, P2 uint32
+	Ext         []byte
+}
+
+// Server is a stand-in for pigpiod, listening on a local port. It records
+// every request, and keeps track of the waves built and chained.
+type Server struct {
+	Addr string
+
+	// Errors maps command numbers to (negative) results to return instead of
+	// handling them. It must be set before any requests are made.
+	Errors map[uint32]int32
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	requests []Request
+	building []pigpio.Pulse
+	waves    map[int][]pigpio.Pulse
+	nextWave int
+	chains   [][]byte
+}
+
+// NewServer starts a server on a free local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		waves:    map[int][]pigpio.Pulse{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server, and waits for its connections to close.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Waves returns the pulses of the waves that currently exist, by ID.
+func (s *Server) Waves() map[int][]pigpio.Pulse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := map[int][]pigpio.Pulse{}
+	for id, pulses := range s.waves {
+		result[id] = pulses
+	}
+	return result
+}
+
+// Chains returns the chains transmitted so far.
+func (s *Server) Chains() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.chains...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		req := Request{
+			Cmd: binary.LittleEndian.Uint32(header[0:]),
+			P1:  binary.LittleEndian.Uint32(header[4:]),
+			P2:  binary.LittleEndian.Uint32(header[8:]),
+		}
+		if n := binary.LittleEndian.Uint32(header[12:]); n > 0 {
+			req.Ext = make([]byte, n)
+			if _, err := io.ReadFull(conn, req.Ext); err != nil {
+				return
+			}
+		}
+		res := s.do(req)
+		binary.LittleEndian.PutUint32(header[12:], uint32(res))
+		if _, err := conn.Write(header); err != nil {
+			return
+		}
+	}
+}
+
+// do handles a request, returning its result.
+func (s *Server) do(req Request) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if res, ok := s.Errors[req.Cmd]; ok {
+		return res
+	}
+
+	switch req.Cmd {
+	case pigpio.CmdWVNEW:
+		s.building = nil
+	case pigpio.CmdWVAG:
+		for i := 0; i+12 <= len(req.Ext); i += 12 {
+			s.building = append(s.building, pigpio.Pulse{
+				On:    binary.LittleEndian.Uint32(req.Ext[i:]),
+				Off:   binary.LittleEndian.Uint32(req.Ext[i+4:]),
+				Delay: binary.LittleEndian.Uint32(req.Ext[i+8:]),
+			})
+		}
+		return int32(len(s.building))
+	case pigpio.CmdWVCRE:
+		id := s.nextWave
+		s.nextWave++
+		s.waves[id] = s.building
+		s.building = nil
+		return int32(id)
+	case pigpio.CmdWVDEL:
+		if _, ok := s.waves[int(req.P1)]; !ok {
+			return -101
+		}
+		delete(s.waves, int(req.P1))
+	case pigpio.CmdWVCHA:
+		s.chains = append(s.chains, req.Ext)
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+/*
+Package realtime sets up a real-time environment for timing-critical code,
+such as the busy-wait loops used to transmit by toggling a GPIO pin.
+
+On Linux, Enter locks the calling goroutine to its OS thread, gives that
+thread SCHED_FIFO priority, optionally pins it to a single (ideally isolated)
+CPU, locks the process's memory to prevent page faults, and disables garbage
+collection. The returned function undoes all of it. On other platforms, Enter
+only manages garbage collection, and reports the rest as unsupported.
+*/
+package realtime
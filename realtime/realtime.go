@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Options configures the real-time environment set up by Enter.
+type Options struct {
+	Priority   int  // SCHED_FIFO priority, from 1 to 99. 0 leaves scheduling alone.
+	CPU        int  // CPU to pin the thread to. -1 leaves affinity alone.
+	LockMemory bool // Lock all current and future memory into RAM.
+}
+
+// Default is a sensible real-time environment: highest priority, locked
+// memory, and no CPU pinning.
+var Default = Options{
+	Priority:   99,
+	CPU:        -1,
+	LockMemory: true,
+}
+
+// Error reports the parts of the real-time environment that could not be set
+// up. Enter still sets up everything it can.
+type Error struct {
+	Failures []string
+	// Privileged is true if any of the failures were due to insufficient
+	// privileges.
+	Privileged bool
+}
+
+func (e *Error) Error() string {
+	msg := "could not set up real-time environment: " + strings.Join(e.Failures, "; ")
+	if e.Privileged {
+		msg += " (run as root, or grant the binary CAP_SYS_NICE and CAP_IPC_LOCK with `sudo setcap cap_sys_nice,cap_ipc_lock+ep openers`)"
+	}
+	return msg
+}
+
+// Enter sets up a real-time environment for the calling goroutine, which
+// stays locked to its OS thread until the returned function is called to
+// restore everything, including garbage collection.
+//
+// If some parts could not be set up, Enter returns an *Error along with the
+// restore function, which must still be called.
+func Enter(opts Options) (func(), error) {
+	runtime.LockOSThread()
+	runtime.GC()
+	gcPercent := debug.SetGCPercent(-1)
+
+	restorePlatform, e := enter(opts)
+
+	restore := func() {
+		restorePlatform()
+		debug.SetGCPercent(gcPercent)
+		runtime.UnlockOSThread()
+	}
+	if len(e.Failures) > 0 {
+		return restore, e
+	}
+	return restore, nil
+}
+
+// fail records a failure to set up part of the real-time environment.
+func (e *Error) fail(privileged bool, format string, args ...interface{}) {
+	e.Failures = append(e.Failures, fmt.Sprintf(format, args...))
+	e.Privileged = e.Privileged || privileged
+}
@@ -0,0 +1,71 @@
+package realtime
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// schedFIFO is the SCHED_FIFO scheduling policy.
+const schedFIFO = 1
+
+// schedParam is struct sched_param.
+type schedParam struct {
+	priority int32
+}
+
+// enter sets up the platform-specific parts of the real-time environment, on
+// the current thread, returning a function to undo them.
+func enter(opts Options) (func(), *Error) {
+	e := &Error{}
+	var undo []func()
+
+	if opts.CPU >= 0 {
+		var old, set unix.CPUSet
+		if err := unix.SchedGetaffinity(0, &old); err != nil {
+			e.fail(false, "reading CPU affinity: %v", err)
+		} else {
+			set.Set(opts.CPU)
+			if err := unix.SchedSetaffinity(0, &set); err != nil {
+				e.fail(err == unix.EPERM, "pinning to CPU %d: %v", opts.CPU, err)
+			} else {
+				undo = append(undo, func() { unix.SchedSetaffinity(0, &old) })
+			}
+		}
+	}
+
+	if opts.Priority > 0 {
+		oldPolicy, _, errno := unix.RawSyscall(unix.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+		var oldParam schedParam
+		if errno == 0 {
+			_, _, errno = unix.RawSyscall(unix.SYS_SCHED_GETPARAM, 0, uintptr(unsafe.Pointer(&oldParam)), 0)
+		}
+		if errno != 0 {
+			e.fail(false, "reading scheduling policy: %v", errno)
+		} else {
+			param := schedParam{priority: int32(opts.Priority)}
+			_, _, errno = unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, 0, schedFIFO, uintptr(unsafe.Pointer(&param)))
+			if errno != 0 {
+				e.fail(errno == unix.EPERM, "setting SCHED_FIFO priority %d: %v", opts.Priority, errno)
+			} else {
+				undo = append(undo, func() {
+					unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, 0, oldPolicy, uintptr(unsafe.Pointer(&oldParam)))
+				})
+			}
+		}
+	}
+
+	if opts.LockMemory {
+		if err := unix.Mlockall(unix.MCL_CURRENT | unix.MCL_FUTURE); err != nil {
+			e.fail(err == unix.EPERM || err == unix.ENOMEM, "locking memory: %v", err)
+		} else {
+			undo = append(undo, func() { unix.Munlockall() })
+		}
+	}
+
+	return func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}, e
+}
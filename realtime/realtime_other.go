@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package realtime
+
+// enter reports the platform-specific parts of the real-time environment as
+// unsupported.
+func enter(opts Options) (func(), *Error) {
+	e := &Error{}
+	if opts.CPU >= 0 || opts.Priority > 0 || opts.LockMemory {
+		e.fail(false, "real-time scheduling and memory locking are only supported on Linux")
+	}
+	return func() {}, e
+}
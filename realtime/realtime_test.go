@@ -0,0 +1,36 @@
+package realtime_test
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/zellyn/openers/realtime"
+)
+
+func TestEnterRestoresGC(t *testing.T) {
+	old := debug.SetGCPercent(123)
+	defer debug.SetGCPercent(old)
+
+	restore, err := realtime.Enter(realtime.Options{CPU: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := debug.SetGCPercent(-1); got != -1 {
+		t.Errorf("want garbage collection disabled inside real-time environment; got GC percent %d", got)
+	}
+	restore()
+	if got := debug.SetGCPercent(123); got != 123 {
+		t.Errorf("want GC percent restored to 123; got %d", got)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	e := &realtime.Error{
+		Failures:   []string{"setting SCHED_FIFO priority 99: operation not permitted"},
+		Privileged: true,
+	}
+	want := "could not set up real-time environment: setting SCHED_FIFO priority 99: operation not permitted (run as root, or grant the binary CAP_SYS_NICE and CAP_IPC_LOCK with `sudo setcap cap_sys_nice,cap_ipc_lock+ep openers`)"
+	if got := e.Error(); got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
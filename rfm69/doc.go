@@ -0,0 +1,10 @@
+/*
+Package rfm69 drives a HopeRF RFM69 (Semtech SX1231) sub-GHz transceiver
+module over SPI, configured for OOK (on-off keying) in continuous mode.
+
+In continuous mode, the module's DIO2 pin carries the data: while
+transmitting, the carrier follows the level some other transmitter drives on
+DIO2, and while receiving, the module drives DIO2 with the demodulated
+signal, ready for package capture.
+*/
+package rfm69
@@ -0,0 +1,236 @@
+package rfm69
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/zellyn/openers/spidev"
+)
+
+// Registers.
+const (
+	RegOpMode      = 0x01
+	RegDataModul   = 0x02
+	RegBitrateMsb  = 0x03
+	RegBitrateLsb  = 0x04
+	RegFrfMsb      = 0x07
+	RegFrfMid      = 0x08
+	RegFrfLsb      = 0x09
+	RegVersion     = 0x10
+	RegPaLevel     = 0x11
+	RegOcp         = 0x13
+	RegLna         = 0x18
+	RegRxBw        = 0x19
+	RegOokPeak     = 0x1B
+	RegDioMapping1 = 0x25
+	RegIrqFlags1   = 0x27
+	RegRssiThresh  = 0x29
+	RegTestPa1     = 0x5A
+	RegTestPa2     = 0x5C
+)
+
+const (
+	writeFlag       = 0x80 // Set in the address byte to write a register.
+	modeReady       = 0x80 // RegIrqFlags1 flag set once a mode change is complete.
+	expectedVersion = 0x24 // RegVersion of all RFM69 modules.
+)
+
+// Oscillator is the frequency of the module's crystal, in Hz.
+const Oscillator = 32e6
+
+// fstep is the frequency synthesizer step, in Hz.
+const fstep = Oscillator / (1 << 19)
+
+// SPISpeed is a comfortable SPI clock speed for talking to the module.
+const SPISpeed = 1000000
+
+// Mode is an operating mode.
+type Mode byte
+
+// Operating modes, as the value of RegOpMode.
+const (
+	Sleep   Mode = 0x00
+	Standby Mode = 0x04
+	Synth   Mode = 0x08
+	TX      Mode = 0x0C
+	RX      Mode = 0x10
+)
+
+// modeTimeout is how long to wait for the module to report a mode change.
+const modeTimeout = 100 * time.Millisecond
+
+// Config describes how to set up the module.
+type Config struct {
+	Frequency float64 // Carrier frequency in Hz.
+	Bitrate   float64 // Bits per second; in continuous mode, it sets the receiver's filters.
+	Power     int     // Output power in dBm.
+	// HighPower is true for the high-power modules (RFM69HW and RFM69HCW),
+	// whose antenna is wired to PA1 and PA2 rather than PA0.
+	HighPower bool
+}
+
+// Setting is a value for a register.
+type Setting struct {
+	Register byte
+	Value    byte
+}
+
+// Settings returns the register values for cfg, in the order they should be
+// written.
+func Settings(cfg Config) ([]Setting, error) {
+	if cfg.Frequency < 290e6 || cfg.Frequency > 1020e6 {
+		return nil, fmt.Errorf("RFM69 cannot transmit at %gMHz; it supports 290-1020MHz", cfg.Frequency/1e6)
+	}
+	if cfg.Bitrate < 500 || cfg.Bitrate > 300e3 {
+		return nil, fmt.Errorf("RFM69 bitrate %gbps out of range 500-300000", cfg.Bitrate)
+	}
+	pa, err := paLevel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bitrate := uint16(math.Round(Oscillator / cfg.Bitrate))
+	frf := Frf(cfg.Frequency)
+	ocp := byte(0x1A) // Over-current protection on, at 95mA.
+	if cfg.HighPower {
+		ocp = 0x0F // Off, as high power needs.
+	}
+	return []Setting{
+		{RegOpMode, byte(Standby)},
+		{RegDataModul, 0x68}, // Continuous mode without bit synchronizer, OOK, no shaping.
+		{RegBitrateMsb, byte(bitrate >> 8)},
+		{RegBitrateLsb, byte(bitrate)},
+		{RegFrfMsb, byte(frf >> 16)},
+		{RegFrfMid, byte(frf >> 8)},
+		{RegFrfLsb, byte(frf)},
+		{RegPaLevel, pa},
+		{RegOcp, ocp},
+		{RegLna, 0x88},         // 200Ω input, gain set by AGC.
+		{RegRxBw, 0x41},        // 125kHz receiver bandwidth.
+		{RegOokPeak, 0x40},     // Peak threshold demodulation.
+		{RegDioMapping1, 0x00}, // DIO2 is data in continuous mode.
+		{RegRssiThresh, 0xE4},
+		{RegTestPa1, 0x55},
+		{RegTestPa2, 0x70},
+	}, nil
+}
+
+// Frf returns the value of the RegFrf registers for a frequency in Hz.
+func Frf(frequency float64) uint32 {
+	return uint32(math.Round(frequency / fstep))
+}
+
+// paLevel returns the RegPaLevel value for the configured power.
+func paLevel(cfg Config) (byte, error) {
+	p := cfg.Power
+	switch {
+	case !cfg.HighPower && p >= -18 && p <= 13:
+		return 0x80 | byte(p+18), nil // PA0.
+	case cfg.HighPower && p >= -2 && p <= 13:
+		return 0x40 | byte(p+18), nil // PA1.
+	case cfg.HighPower && p >= 14 && p <= 17:
+		return 0x60 | byte(p+14), nil // PA1 and PA2.
+	case cfg.HighPower && p >= 18 && p <= 20:
+		return 0x60 | byte(p+11), nil // PA1 and PA2, with the high power settings.
+	case cfg.HighPower:
+		return 0, fmt.Errorf("unsupported RFM69 output power %ddBm; high-power modules support -2 to 20dBm", p)
+	}
+	return 0, fmt.Errorf("unsupported RFM69 output power %ddBm; modules support -18 to 13dBm", p)
+}
+
+// Radio is an RFM69 connected to an SPI device.
+type Radio struct {
+	Conn spidev.Conn
+	cfg  Config
+}
+
+// WriteReg writes a register.
+func (r *Radio) WriteReg(reg, value byte) error {
+	return r.Conn.Tx([]byte{reg | writeFlag, value}, nil)
+}
+
+// ReadReg reads a register.
+func (r *Radio) ReadReg(reg byte) (byte, error) {
+	resp := make([]byte, 2)
+	if err := r.Conn.Tx([]byte{reg &^ writeFlag, 0}, resp); err != nil {
+		return 0, err
+	}
+	return resp[1], nil
+}
+
+// Configure checks that the module is there, and sets it up, leaving it in
+// standby.
+func (r *Radio) Configure(cfg Config) error {
+	settings, err := Settings(cfg)
+	if err != nil {
+		return err
+	}
+	version, err := r.ReadReg(RegVersion)
+	if err != nil {
+		return err
+	}
+	if version != expectedVersion {
+		return fmt.Errorf("no RFM69 found: RegVersion=0x%02x; check the wiring", version)
+	}
+	for _, s := range settings {
+		if err := r.WriteReg(s.Register, s.Value); err != nil {
+			return err
+		}
+	}
+	r.cfg = cfg
+	return r.waitModeReady()
+}
+
+// Tune changes the carrier frequency.
+func (r *Radio) Tune(frequency float64) error {
+	cfg := r.cfg
+	cfg.Frequency = frequency
+	if _, err := Settings(cfg); err != nil {
+		return err
+	}
+	frf := Frf(frequency)
+	for _, s := range []Setting{{RegFrfMsb, byte(frf >> 16)}, {RegFrfMid, byte(frf >> 8)}, {RegFrfLsb, byte(frf)}} {
+		if err := r.WriteReg(s.Register, s.Value); err != nil {
+			return err
+		}
+	}
+	r.cfg = cfg
+	return nil
+}
+
+// SetMode switches operating mode, and waits for the module to be ready. The
+// high power settings are only enabled while transmitting, as the datasheet
+// requires.
+func (r *Radio) SetMode(mode Mode) error {
+	pa1, pa2 := byte(0x55), byte(0x70)
+	if mode == TX && r.cfg.HighPower && r.cfg.Power >= 18 {
+		pa1, pa2 = 0x5D, 0x7C
+	}
+	if err := r.WriteReg(RegTestPa1, pa1); err != nil {
+		return err
+	}
+	if err := r.WriteReg(RegTestPa2, pa2); err != nil {
+		return err
+	}
+	if err := r.WriteReg(RegOpMode, byte(mode)); err != nil {
+		return err
+	}
+	return r.waitModeReady()
+}
+
+func (r *Radio) waitModeReady() error {
+	deadline := time.Now().Add(modeTimeout)
+	for {
+		flags, err := r.ReadReg(RegIrqFlags1)
+		if err != nil {
+			return err
+		}
+		if flags&modeReady != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("RFM69 did not become ready within %v", modeTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
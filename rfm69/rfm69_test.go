@@ -0,0 +1,105 @@
+package rfm69_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/rfm69/rfm69test"
+)
+
+func TestConfigure(t *testing.T) {
+	testcases := []struct {
+		cfg     rfm69.Config
+		regs    map[byte]byte
+		wantErr bool
+	}{
+		{
+			cfg: rfm69.Config{Frequency: 315e6, Bitrate: 4000, Power: 13, HighPower: true},
+			regs: map[byte]byte{
+				rfm69.RegDataModul: 0x68, rfm69.RegOpMode: byte(rfm69.Standby),
+				rfm69.RegFrfMsb: 0x4E, rfm69.RegFrfMid: 0xC0, rfm69.RegFrfLsb: 0x00,
+				rfm69.RegBitrateMsb: 0x1F, rfm69.RegBitrateLsb: 0x40,
+				rfm69.RegPaLevel: 0x5F, rfm69.RegOcp: 0x0F,
+			},
+		},
+		{
+			cfg:  rfm69.Config{Frequency: 310e6, Bitrate: 1000, Power: 20, HighPower: true},
+			regs: map[byte]byte{rfm69.RegFrfMsb: 0x4D, rfm69.RegFrfMid: 0x80, rfm69.RegPaLevel: 0x7F},
+		},
+		{
+			cfg:  rfm69.Config{Frequency: 390e6, Bitrate: 4000, Power: 0},
+			regs: map[byte]byte{rfm69.RegFrfMsb: 0x61, rfm69.RegFrfMid: 0x80, rfm69.RegPaLevel: 0x92, rfm69.RegOcp: 0x1A},
+		},
+		{cfg: rfm69.Config{Frequency: 250e6, Bitrate: 4000}, wantErr: true},
+		{cfg: rfm69.Config{Frequency: 315e6, Bitrate: 4000, Power: 20}, wantErr: true},
+		{cfg: rfm69.Config{Frequency: 315e6, Bitrate: 4000, Power: -10, HighPower: true}, wantErr: true},
+		{cfg: rfm69.Config{Frequency: 315e6, Bitrate: 100}, wantErr: true},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%gMHz", i, tt.cfg.Frequency/1e6), func(t *testing.T) {
+			m := rfm69test.New()
+			r := &rfm69.Radio{Conn: m}
+			err := r.Configure(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("want error configuring %+v", tt.cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for reg, want := range tt.regs {
+				if got := m.Register(reg); got != want {
+					t.Errorf("want register 0x%02x==0x%02x; got 0x%02x", reg, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestModes(t *testing.T) {
+	m := rfm69test.New()
+	r := &rfm69.Radio{Conn: m}
+	if err := r.Configure(rfm69.Config{Frequency: 315e6, Bitrate: 4000, Power: 20, HighPower: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMode(rfm69.TX); err != nil {
+		t.Fatal(err)
+	}
+	if m.Register(rfm69.RegTestPa1) != 0x5D || m.Register(rfm69.RegTestPa2) != 0x7C {
+		t.Errorf("want high power settings while transmitting at 20dBm")
+	}
+	if err := r.Tune(390e6); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Register(rfm69.RegFrfMsb); got != 0x61 {
+		t.Errorf("want RegFrfMsb==0x61 after tuning to 390MHz; got 0x%02x", got)
+	}
+	if err := r.SetMode(rfm69.RX); err != nil {
+		t.Fatal(err)
+	}
+	if m.Register(rfm69.RegTestPa1) != 0x55 || m.Register(rfm69.RegTestPa2) != 0x70 {
+		t.Errorf("want normal power settings while receiving")
+	}
+	if want := []rfm69.Mode{rfm69.Standby, rfm69.TX, rfm69.RX}; !reflect.DeepEqual(m.Modes(), want) {
+		t.Errorf("want modes %v; got %v", want, m.Modes())
+	}
+}
+
+func TestNoModule(t *testing.T) {
+	r := &rfm69.Radio{Conn: brokenConn{}}
+	if err := r.Configure(rfm69.Config{Frequency: 315e6, Bitrate: 4000}); err == nil {
+		t.Errorf("want error configuring a missing module")
+	}
+}
+
+// brokenConn reads back zeros, like an SPI bus with nothing on it.
+type brokenConn struct{}
+
+func (brokenConn) Tx(w, r []byte) error        { return nil }
+func (brokenConn) Write(p []byte) (int, error) { return len(p), nil }
+func (brokenConn) MaxTransfer() int            { return 4096 }
@@ -0,0 +1,84 @@
+// Package rfm69test provides a fake RFM69 on a fake SPI device, for testing
+// code that uses package rfm69.
+package rfm69test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/spidev"
+)
+
+// Module is a fake RFM69. It records the register map and every mode change,
+// and becomes ready for a new mode immediately.
+type Module struct {
+	mu        sync.Mutex
+	registers [0x80]byte
+	modes     []rfm69.Mode
+}
+
+var _ spidev.Conn = (*Module)(nil)
+
+// New returns a fake module, with the version of real ones.
+func New() *Module {
+	m := &Module{}
+	m.registers[rfm69.RegVersion] = 0x24
+	m.registers[rfm69.RegIrqFlags1] = 0x80
+	return m
+}
+
+// Tx handles a single SPI transfer: a register address, with the top bit set
+// for writes, followed by data for consecutive registers.
+func (m *Module) Tx(w, r []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(w) == 0 {
+		return nil
+	}
+	addr := int(w[0] & 0x7F)
+	if w[0]&0x80 == 0 {
+		if r == nil || len(r) != len(w) {
+			return fmt.Errorf("register reads need a response buffer")
+		}
+		for i := 1; i < len(w); i++ {
+			r[i] = m.registers[(addr+i-1)&0x7F]
+		}
+		return nil
+	}
+	for i, v := range w[1:] {
+		reg := (addr + i) & 0x7F
+		if reg == rfm69.RegVersion {
+			continue
+		}
+		m.registers[reg] = v
+		if reg == rfm69.RegOpMode {
+			m.modes = append(m.modes, rfm69.Mode(v&0x1C))
+		}
+	}
+	return nil
+}
+
+// Write handles a single write-only SPI transfer.
+func (m *Module) Write(p []byte) (int, error) {
+	return len(p), m.Tx(p, nil)
+}
+
+// MaxTransfer returns the size of the largest transfer.
+func (m *Module) MaxTransfer() int {
+	return spidev.DefaultMaxTransfer
+}
+
+// Register returns the value of a register.
+func (m *Module) Register(reg byte) byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.registers[reg&0x7F]
+}
+
+// Modes returns the operating modes set so far.
+func (m *Module) Modes() []rfm69.Mode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]rfm69.Mode(nil), m.modes...)
+}
@@ -0,0 +1,10 @@
+/*
+Package rolling keeps track of the next Security+2.0 rolling code to send to
+each opener, in a small JSON file, so that codes are never reused: openers
+ignore codes they have already seen.
+
+Each code is recorded as used before it is handed out, so a transmission that
+fails, or a process that dies part way through, skips a code rather than
+repeating one. Openers accept codes that skip ahead.
+*/
+package rolling
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package rolling
+
+import "os"
+
+// lockFile does nothing: locking is only supported on Linux, so elsewhere
+// only one process should use a store at a time.
+func lockFile(f *os.File) error {
+	return nil
+}
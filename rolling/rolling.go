@@ -0,0 +1,115 @@
+package rolling
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zellyn/openers/internal/atomicfile"
+)
+
+// DefaultPath is where the rolling codes are kept by default.
+const DefaultPath = "/var/lib/openers/rolling.json"
+
+// Max is one more than the largest Security+2.0 rolling code.
+const Max = 1 << 28
+
+// Store is a file of rolling codes, keyed by opener name. It is safe for
+// concurrent use, and on Linux, for use by several processes at once.
+type Store struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// lock takes the lock on the file, shared with other processes, returning
+// the function to release it. The file itself is replaced on every save, so
+// the lock is on another file next to it.
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.Path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// load reads the file, which need not exist yet.
+func (s *Store) load() (map[string]uint32, error) {
+	codes := map[string]uint32{}
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return codes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return codes, nil
+}
+
+// save replaces the file atomically, so that a crash can't lose it.
+func (s *Store) save(codes map[string]uint32) error {
+	data, err := json.MarshalIndent(codes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.Path, append(data, '\n'), 0600)
+}
+
+// Next returns the rolling code to send to the named opener, and records it
+// as used. Openers with no codes recorded yet start from start.
+func (s *Store) Next(name string, start uint32) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	release, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	codes, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	code, ok := codes[name]
+	if !ok {
+		code = start
+	}
+	if code >= Max {
+		return 0, fmt.Errorf("rolling codes for opener %q are used up", name)
+	}
+	codes[name] = code + 1
+	if err := s.save(codes); err != nil {
+		return 0, fmt.Errorf("cannot record rolling code: %v", err)
+	}
+	return code, nil
+}
+
+// Peek returns the rolling code Next would return for the named opener, and
+// whether any codes have been recorded for it.
+func (s *Store) Peek(name string) (uint32, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	release, err := s.lock()
+	if err != nil {
+		return 0, false, err
+	}
+	defer release()
+	codes, err := s.load()
+	if err != nil {
+		return 0, false, err
+	}
+	code, ok := codes[name]
+	return code, ok, nil
+}
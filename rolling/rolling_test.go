@@ -0,0 +1,92 @@
+package rolling_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/zellyn/openers/rolling"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "rolling.json")
+	s := &rolling.Store{Path: path}
+
+	if _, ok, err := s.Peek("garage"); err != nil || ok {
+		t.Errorf("want no codes recorded yet; got %v, %v", ok, err)
+	}
+	for _, want := range []uint32{100, 101, 102} {
+		got, err := s.Next("garage", 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("want code %d; got %d", want, got)
+		}
+	}
+	if got, _ := s.Next("shed", 5); got != 5 {
+		t.Errorf("want shed to start at 5; got %d", got)
+	}
+
+	// A new store on the same file carries on where the old one left off,
+	// ignoring the configured start.
+	s = &rolling.Store{Path: path}
+	if got, ok, _ := s.Peek("garage"); !ok || got != 103 {
+		t.Errorf("want next garage code 103; got %d, %v", got, ok)
+	}
+	if got, _ := s.Next("garage", 0); got != 103 {
+		t.Errorf("want code 103; got %d", got)
+	}
+
+	if _, err := s.Next("full", rolling.Max); err == nil {
+		t.Errorf("want error when codes are used up")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Next("garage", 0); err == nil {
+		t.Errorf("want error for corrupt file")
+	}
+}
+
+func TestStoresSharingFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("locking is only supported on Linux")
+	}
+	// Each store stands in for a process: they share nothing but the file.
+	path := filepath.Join(t.TempDir(), "rolling.json")
+	stores := []*rolling.Store{{Path: path}, {Path: path}}
+	const n = 50
+	codes := make(chan uint32, len(stores)*n)
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		wg.Add(1)
+		go func(s *rolling.Store) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				code, err := s.Next("garage", 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				codes <- code
+			}
+		}(s)
+	}
+	wg.Wait()
+	close(codes)
+
+	seen := map[uint32]bool{}
+	for code := range codes {
+		if seen[code] {
+			t.Errorf("code %d handed out twice", code)
+		}
+		seen[code] = true
+	}
+	if got, _, _ := stores[0].Peek("garage"); int(got) != len(stores)*n {
+		t.Errorf("want next code %d; got %d", len(stores)*n, got)
+	}
+}
@@ -0,0 +1,10 @@
+/*
+Package schedule follows the rules in the configuration file's "schedule":
+actions at a time of day, on some or every day of the week, and actions once
+a door has been open for a while, as seen by its sensor.
+
+Rules are evaluated on ticks of a clock, which tests replace with a fake
+one. A time of day is acted on when a tick crosses it; times passed while
+the scheduler wasn't running are not caught up on.
+*/
+package schedule
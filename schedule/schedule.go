@@ -0,0 +1,190 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+)
+
+// DefaultInterval is how often rules are evaluated by default.
+const DefaultInterval = 5 * time.Second
+
+// Clock tells the time of day, and waits.
+type Clock interface {
+	Now() time.Time
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock is the real clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Door reports where a door is now.
+type Door interface {
+	State() door.State
+}
+
+// ActionFunc performs an action, open, close or toggle, on the named
+// opener.
+type ActionFunc func(ctx context.Context, opener, action string) error
+
+// openRule is the state of an open_for rule.
+type openRule struct {
+	rule  config.Rule
+	after time.Duration
+	since time.Time // When the door was first seen open; zero if closed.
+}
+
+// atRule is a rule acting at a time of day.
+type atRule struct {
+	rule config.Rule
+	at   time.Duration
+	days map[time.Weekday]bool // nil for every day.
+}
+
+// Scheduler evaluates schedule rules on each tick of its clock.
+type Scheduler struct {
+	// Act performs rules' actions.
+	Act ActionFunc
+	// Doors are the doors of openers with sensors, by opener name. Rules
+	// with open_for are skipped for openers not in it.
+	Doors map[string]Door
+	// Interval is how often rules are evaluated.
+	Interval time.Duration
+	// Clock, if not nil, replaces the real clock.
+	Clock Clock
+	// Log, if not nil, gets a line for each action and its outcome.
+	Log io.Writer
+
+	at   []atRule
+	open []*openRule
+	last time.Time // Time of the last tick; zero before the first.
+}
+
+// New returns a scheduler for the rules in a configuration, performing
+// their actions with act.
+func New(c *config.Config, act ActionFunc) (*Scheduler, error) {
+	s := &Scheduler{Act: act, Doors: map[string]Door{}, Interval: DefaultInterval}
+	for i, r := range c.Schedule {
+		if r.OpenFor != "" {
+			d, err := r.OpenDuration()
+			if err != nil {
+				return nil, fmt.Errorf("schedule rule %d: %v", i+1, err)
+			}
+			s.open = append(s.open, &openRule{rule: r, after: d})
+			continue
+		}
+		at, err := r.TimeOfDay()
+		if err != nil {
+			return nil, fmt.Errorf("schedule rule %d: %v", i+1, err)
+		}
+		days, err := r.Weekdays()
+		if err != nil {
+			return nil, fmt.Errorf("schedule rule %d: %v", i+1, err)
+		}
+		s.at = append(s.at, atRule{rule: r, at: at, days: days})
+	}
+	return s, nil
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return realClock{}
+	}
+	return s.Clock
+}
+
+func (s *Scheduler) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
+
+// Run evaluates the rules every Interval until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	c := s.clock()
+	for {
+		s.Tick(ctx)
+		if err := c.Sleep(ctx, s.Interval); err != nil {
+			return err
+		}
+	}
+}
+
+// Tick evaluates the rules once, performing the actions of rules whose time
+// of day has come since the last tick, and of rules whose door has been
+// open long enough.
+func (s *Scheduler) Tick(ctx context.Context) {
+	now := s.clock().Now()
+	if !s.last.IsZero() {
+		for _, r := range s.at {
+			if r.due(s.last, now) {
+				s.act(ctx, r.rule, "at "+r.rule.At)
+			}
+		}
+	}
+	s.last = now
+
+	for _, r := range s.open {
+		d, ok := s.Doors[r.rule.Opener]
+		if !ok {
+			continue
+		}
+		switch d.State() {
+		case door.Unknown:
+			continue
+		case door.Closed:
+			r.since = time.Time{}
+			continue
+		}
+		if r.since.IsZero() {
+			r.since = now
+			continue
+		}
+		if now.Sub(r.since) >= r.after {
+			s.act(ctx, r.rule, fmt.Sprintf("open for %s", now.Sub(r.since).Round(time.Second)))
+			// Act again if it is still open after as long again.
+			r.since = now
+		}
+	}
+}
+
+// due reports whether the rule's time of day, on one of its days, is after
+// last and no later than now.
+func (r atRule) due(last, now time.Time) bool {
+	y, m, d := last.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, now.Location()); !day.After(now); day = day.AddDate(0, 0, 1) {
+		if r.days != nil && !r.days[day.Weekday()] {
+			continue
+		}
+		t := time.Date(day.Year(), day.Month(), day.Day(), int(r.at/time.Hour), int(r.at%time.Hour/time.Minute), 0, 0, now.Location())
+		if t.After(last) && !t.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// act performs a rule's action, logging the outcome.
+func (s *Scheduler) act(ctx context.Context, r config.Rule, why string) {
+	s.logf("%s %s (%s)", r.Action, r.Opener, why)
+	if err := s.Act(ctx, r.Opener, r.Action); err != nil {
+		s.logf("%s %s: %v", r.Action, r.Opener, err)
+	}
+}
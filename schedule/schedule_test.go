@@ -0,0 +1,211 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/schedule"
+)
+
+const openers = `"openers": [
+	{"name": "garage", "protocol": "secplus-v2", "fixed": "1222022221850123456789", "sensor": {"closed_pin": 17}},
+	{"name": "gate", "protocol": "megacode", "identifier": "0x876543"}
+]`
+
+// clock is a fake clock, and the door of the garage.
+type clock struct {
+	now   time.Time
+	end   time.Time
+	state func(time.Time) door.State // Where the door is.
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Sleep(ctx context.Context, d time.Duration) error {
+	c.now = c.now.Add(d)
+	if c.now.After(c.end) {
+		return context.Canceled
+	}
+	return nil
+}
+
+func (c *clock) State() door.State { return c.state(c.now) }
+
+// run runs a schedule from start until end, returning the actions taken
+// and when.
+func run(t *testing.T, rules string, start, end time.Time, state func(time.Time) door.State) []string {
+	t.Helper()
+	c, err := config.Parse(strings.NewReader(`{` + openers + `, "schedule": [` + rules + `]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := &clock{now: start, end: end, state: state}
+	var got []string
+	s, err := schedule.New(c, func(ctx context.Context, opener, action string) error {
+		got = append(got, fmt.Sprintf("%s %s %s", clk.now.Format("Mon 15:04:05"), action, opener))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Clock = clk
+	s.Doors["garage"] = clk
+	if err := s.Run(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled; got %v", err)
+	}
+	return got
+}
+
+// at returns a time on Monday the 5th of October 2026, the given number of
+// days later.
+func at(days int, hhmmss string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", "2026-10-05 "+hhmmss, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, days)
+}
+
+func closed(time.Time) door.State { return door.Closed }
+
+func TestAt(t *testing.T) {
+	testdata := []struct {
+		name       string
+		rules      string
+		start, end time.Time
+		want       []string
+	}{
+		{
+			name:  "every day",
+			rules: `{"opener": "garage", "action": "close", "at": "22:30"}`,
+			start: at(0, "22:00:00"),
+			end:   at(2, "23:00:00"),
+			want:  []string{"Mon 22:30:00 close garage", "Tue 22:30:00 close garage", "Wed 22:30:00 close garage"},
+		},
+		{
+			name:  "no catching up",
+			rules: `{"opener": "garage", "action": "close", "at": "22:30"}`,
+			start: at(0, "22:30:00"),
+			end:   at(0, "23:00:00"),
+		},
+		{
+			name:  "crossed between ticks",
+			rules: `{"opener": "gate", "action": "toggle", "at": "07:00"}`,
+			start: at(0, "06:59:57"),
+			end:   at(0, "07:01:00"),
+			want:  []string{"Mon 07:00:02 toggle gate"},
+		},
+		{
+			name:  "midnight",
+			rules: `{"opener": "gate", "action": "toggle", "at": "00:00"}`,
+			start: at(0, "23:59:00"),
+			end:   at(1, "00:01:00"),
+			want:  []string{"Tue 00:00:00 toggle gate"},
+		},
+		{
+			name:  "weekends",
+			rules: `{"opener": "gate", "action": "toggle", "at": "09:00", "days": ["sat", "sun"]}`,
+			start: at(0, "00:00:00"),
+			end:   at(7, "00:00:00"),
+			want:  []string{"Sat 09:00:00 toggle gate", "Sun 09:00:00 toggle gate"},
+		},
+		{
+			name: "two rules",
+			rules: `{"opener": "gate", "action": "toggle", "at": "22:00"},
+				{"opener": "garage", "action": "close", "at": "22:00"}`,
+			start: at(0, "21:00:00"),
+			end:   at(0, "23:00:00"),
+			want:  []string{"Mon 22:00:00 toggle gate", "Mon 22:00:00 close garage"},
+		},
+	}
+	for _, tt := range testdata {
+		t.Run(tt.name, func(t *testing.T) {
+			got := run(t, tt.rules, tt.start, tt.end, closed)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenFor(t *testing.T) {
+	const rule = `{"opener": "garage", "action": "close", "open_for": "15m"}`
+	// openBetween returns a door that is open between two times, and
+	// closed otherwise.
+	openBetween := func(from, to string) func(time.Time) door.State {
+		return func(t time.Time) door.State {
+			if t.Before(at(0, from)) || !t.Before(at(0, to)) {
+				return door.Closed
+			}
+			return door.Open
+		}
+	}
+	testdata := []struct {
+		name  string
+		state func(time.Time) door.State
+		want  []string
+	}{
+		{
+			name:  "never open",
+			state: closed,
+		},
+		{
+			name:  "briefly open",
+			state: openBetween("18:00:00", "18:14:00"),
+		},
+		{
+			name:  "left open",
+			state: openBetween("18:00:00", "18:20:00"),
+			want:  []string{"Mon 18:15:00 close garage"},
+		},
+		{
+			name:  "still open",
+			state: openBetween("18:00:00", "18:40:00"),
+			want:  []string{"Mon 18:15:00 close garage", "Mon 18:30:00 close garage"},
+		},
+		{
+			name: "opened twice",
+			state: func(t time.Time) door.State {
+				if t.Before(at(0, "18:15:00")) {
+					return openBetween("18:00:00", "18:10:00")(t)
+				}
+				return openBetween("18:20:00", "18:40:00")(t)
+			},
+			want: []string{"Mon 18:35:00 close garage"},
+		},
+		{
+			name: "moving counts as open",
+			state: func(t time.Time) door.State {
+				if t.Before(at(0, "18:00:00")) {
+					return door.Closed
+				}
+				return door.Stopped
+			},
+			want: []string{"Mon 18:15:00 close garage", "Mon 18:30:00 close garage"},
+		},
+		{
+			name: "unknown is ignored",
+			state: func(t time.Time) door.State {
+				if t.Before(at(0, "18:10:00")) {
+					return door.Unknown
+				}
+				return openBetween("18:00:00", "18:30:00")(t)
+			},
+			want: []string{"Mon 18:25:00 close garage"},
+		},
+	}
+	for _, tt := range testdata {
+		t.Run(tt.name, func(t *testing.T) {
+			got := run(t, rule, at(0, "17:30:00"), at(0, "18:40:00"), tt.state)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+//go:build !tinygo
+// +build !tinygo
+
+package secplus
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ManchesterEncode encodes a byte array of 0s and 1s to a byte array of 0s and
+// 1s, but Manchester-encoded. Input is expected to be 0s and 1s.
+// See https://en.wikipedia.org/wiki/Manchester_code
+func ManchesterEncode(input []byte) ([]byte, error) {
+	result := make([]byte, 0, len(input)*2)
+	for i, b := range input {
+		switch b {
+		case 0:
+			result = append(result, 1, 0)
+		case 1:
+			result = append(result, 0, 1)
+		default:
+			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b, i)
+		}
+	}
+	return result, nil
+}
+
+// ManchesterDecode decodes a Manchester-encoded byte array of 0s and 1s to a
+// byte array of 0s and 1s.
+// See https://en.wikipedia.org/wiki/Manchester_code
+func ManchesterDecode(input []byte) ([]byte, error) {
+	if len(input)%2 != 0 {
+		return nil, fmt.Errorf("expected even length; got %d", len(input))
+	}
+	result := make([]byte, 0, len(input)/2)
+	for i := 0; i < len(input); i += 2 {
+		b1, b2 := input[i], input[i+1]
+		if b1 > 1 {
+			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b1, i)
+		}
+		if b2 > 1 {
+			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b2, i+1)
+		}
+		if b1 == b2 {
+			return nil, fmt.Errorf("pairs should be 01 or 10; got %d%d at position %d", b1, b2, i)
+		}
+		result = append(result, b2)
+	}
+	return result, nil
+}
+
+// DecodeV2Burst decodes a single Manchester-coded burst, as produced by
+// EncodeV2ToBursts, returning which half of the message it carries (0 or 1)
+// and the packet within it.
+func DecodeV2Burst(burst []byte) (int, []byte, error) {
+	decoded, err := ManchesterDecode(burst)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n := len(decoded) - len(syncHeader) - 2; n != 40 && n != 64 {
+		return 0, nil, fmt.Errorf("expected a 40 or 64-bit packet; got %d bits", n)
+	}
+	if !bytes.Equal(decoded[:len(syncHeader)], syncHeader) {
+		return 0, nil, fmt.Errorf("expected sync header %v; got %v", syncHeader, decoded[:len(syncHeader)])
+	}
+	if decoded[len(syncHeader)] != 0 {
+		return 0, nil, fmt.Errorf("expected 0 before frame indicator; got 1")
+	}
+	return int(decoded[len(syncHeader)+1]), decoded[len(syncHeader)+2:], nil
+}
+
+// DecodeV2 decodes two Security+2.0 packets, one for each half, as produced by
+// EncodeV2, back into the fixed and rolling code.
+func DecodeV2(packets [2][]byte) (fixedHigh uint8, fixedLow uint64, rolling uint32, err error) {
+	var fixedHalves [2]uint64
+	var rollingHalves [2][]byte
+	var long [2]bool
+	for i, packet := range packets {
+		var fixed []byte
+		fixed, rollingHalves[i], long[i], err = decodeHalfV2(packet)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("packet %d: %w", i, err)
+		}
+		for _, b := range fixed {
+			fixedHalves[i] = fixedHalves[i]<<1 | uint64(b)
+		}
+	}
+	if long[0] != long[1] {
+		return 0, 0, 0, fmt.Errorf("packets have different lengths: %d and %d", len(packets[0]), len(packets[1]))
+	}
+
+	rolling, err = getRollingFromTernaryHalves(rollingHalves)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if long[0] {
+		return uint8(fixedHalves[0] >> 28), fixedHalves[0]<<36 | fixedHalves[1], rolling, nil
+	}
+	return 0, fixedHalves[0]<<20 | fixedHalves[1], rolling, nil
+}
+
+// decodeHalfV2 is the inverse of encodeHalfV2: it returns the half of the fixed
+// code and the half of the ternary-"encrypted" rolling code carried by a
+// packet, and whether the packet was in the long format.
+func decodeHalfV2(packet []byte) ([]byte, []byte, bool, error) {
+	var long bool
+	partLength := 10
+	switch len(packet) {
+	case 40:
+	case 64:
+		long = true
+		partLength = 18
+	default:
+		return nil, nil, false, fmt.Errorf("expected 40 or 64 bits; got %d", len(packet))
+	}
+	for i, b := range packet {
+		if b > 1 {
+			return nil, nil, false, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b, i)
+		}
+	}
+	if packet[0] != 0 || (packet[1] == 1) != long {
+		return nil, nil, false, fmt.Errorf("packet type %d%d does not match length %d", packet[0], packet[1], len(packet))
+	}
+
+	rolling := make([]byte, 18)
+	copy(rolling, packet[2:10])
+
+	var orderIndicator [4]byte
+	copy(orderIndicator[:], rolling[:4])
+	var inversionIndicator [4]byte
+	copy(inversionIndicator[:], rolling[4:8])
+	order, ok := orders[orderIndicator]
+	if !ok {
+		return nil, nil, false, fmt.Errorf("no order found for indicator %v", orderIndicator)
+	}
+	invert, ok := inversions[inversionIndicator]
+	if !ok {
+		return nil, nil, false, fmt.Errorf("no inversion found for indicator %v", inversionIndicator)
+	}
+
+	var parts [3][]byte
+	for j := range parts {
+		parts[j] = make([]byte, partLength)
+	}
+	for i := 0; i < partLength; i++ {
+		for j := 0; j < 3; j++ {
+			parts[j][i] = packet[10+i*3+j] ^ invert[j]
+		}
+	}
+	var unordered [3][]byte
+	for j := 0; j < 3; j++ {
+		unordered[order[j]] = parts[j]
+	}
+
+	copy(rolling[8:], unordered[2][:10])
+	if long && !bytes.Equal(unordered[2][10:], rolling[:8]) {
+		return nil, nil, false, fmt.Errorf("repeated rolling code bits %v do not match %v", unordered[2][10:], rolling[:8])
+	}
+
+	fixed := append(unordered[0], unordered[1]...)
+	return fixed, rolling, long, nil
+}
+
+// getRollingFromTernaryHalves is the inverse of getRollingTernaryHalves: it
+// reassembles the rolling code from its two binary-pair-coded ternary halves.
+func getRollingFromTernaryHalves(halves [2][]byte) (uint32, error) {
+	var ternary [18]byte
+	unpack := func(half []byte, pieces [3][2]int) error {
+		pos := 0
+		for _, piece := range pieces {
+			for i := piece[1] - 1; i >= piece[0]; i-- {
+				trit := half[pos]<<1 | half[pos+1]
+				if trit > 2 {
+					return fmt.Errorf("invalid ternary digit 11 at position %d", pos)
+				}
+				ternary[i] = trit
+				pos += 2
+			}
+		}
+		return nil
+	}
+	if err := unpack(halves[0], [3][2]int{{0, 4}, {8, 12}, {16, 17}}); err != nil {
+		return 0, err
+	}
+	if err := unpack(halves[1], [3][2]int{{4, 8}, {12, 16}, {17, 18}}); err != nil {
+		return 0, err
+	}
+
+	bitReversed := uint32(0)
+	for i := len(ternary) - 1; i >= 0; i-- {
+		bitReversed = bitReversed*3 + uint32(ternary[i])
+	}
+	if bitReversed >= 1<<28 {
+		return 0, fmt.Errorf("rolling code must be <= 2^28; got %d", bitReversed)
+	}
+	rolling := uint32(0)
+	for i := 0; i < 28; i++ {
+		rolling = rolling*2 + bitReversed&1
+		bitReversed >>= 1
+	}
+	return rolling, nil
+}
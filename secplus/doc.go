@@ -1,9 +1,14 @@
 /*
-Package secplus implements Security+2.0 encoding.
+Package secplus implements Security+2.0 encoding and decoding.
 
 Writing it would not have been possible without the work done by @argilo in
 decoding the Security+2.0 protocol for their excellent [Python `secplus`
 package](https://github.com/argilo/secplus), and the helpful debugging by
 @acoursen in understanding longer transmissions (argilo/secplus#6).
+
+Encoding into buffers the caller provides, with EncodeV2BurstsInto, needs
+nothing but package errors, so that TinyGo firmware can use it.
+Transmissions and decoding, which need packages waveform, time and fmt, are
+left out of TinyGo builds.
 */
 package secplus
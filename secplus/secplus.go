@@ -1,9 +1,16 @@
 package secplus
 
-import (
-	"fmt"
+import "errors"
 
-	"github.com/zellyn/openers/bits"
+// Protocol is the name of the Security+2.0 protocol, as used in
+// waveform.Transmission.Protocol.
+const Protocol = "secplus-v2"
+
+// Errors from encoding, made once so that it doesn't allocate.
+var (
+	errRollingRange = errors.New("rolling code must be < 2^28")
+	errNoOrder      = errors.New("no order found for the rolling code's order indicator")
+	errNoInversion  = errors.New("no inversion found for the rolling code's inversion indicator")
 )
 
 // orders is a map of order indicators to orders.
@@ -32,117 +39,112 @@ var inversions = map[[4]byte][3]byte{
 	{1, 0, 1, 0}: {1, 0, 1},
 }
 
+// syncHeaderBits is the length of syncHeader.
+const syncHeaderBits = 20
+
 // syncHeader is the standard synchronization header applied to each burst.
 var syncHeader []byte = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1}
 
-// ManchesterEncode encodes a byte array of 0s and 1s to a byte array of 0s and
-// 1s, but Manchester-encoded. Input is expected to be 0s and 1s.
-// See https://en.wikipedia.org/wiki/Manchester_code
-func ManchesterEncode(input []byte) ([]byte, error) {
-	result := make([]byte, 0, len(input)*2)
-	for i, b := range input {
-		switch b {
-		case 0:
-			result = append(result, 1, 0)
-		case 1:
-			result = append(result, 0, 1)
-		default:
-			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b, i)
-		}
-	}
-	return result, nil
-}
-
-// ManchesterDecode decodes a Manchester-encoded byte array of 0s and 1s to a
-// byte array of 0s and 1s.
-// See https://en.wikipedia.org/wiki/Manchester_code
-func ManchesterDecode(input []byte) ([]byte, error) {
-	if len(input)%2 != 0 {
-		return nil, fmt.Errorf("expected even length; got %d", len(input))
-	}
-	result := make([]byte, 0, len(input)/2)
-	for i := 0; i < len(input); i += 2 {
-		b1, b2 := input[i], input[i+1]
-		if b1 > 1 {
-			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b1, i)
-		}
-		if b2 > 1 {
-			return nil, fmt.Errorf("expected only 0s and 1s; got %d at position %d", b2, i+1)
-		}
-		if b1 == b2 {
-			return nil, fmt.Errorf("pairs should be 01 or 10; got %d%d at position %d", b1, b2, i)
-		}
-		result = append(result, b2)
-	}
-	return result, nil
-}
+// Sizes of encoded Security+2.0 data, for callers providing their own
+// buffers.
+const (
+	MaxPacketBits = 64                                       // Bits in a packet for a long fixed code; short ones have 40.
+	MaxBurstBits  = 2 * (syncHeaderBits + 2 + MaxPacketBits) // Manchester-coded bits in a burst for a long fixed code.
+)
 
 // EnvodeV2ToBursts encodes a Security+2.0 fixed and rolling code into two
 // bitstreams, one for each half. The bitstreams have a standard prefix, and are
 // then Manchester coded.
 func EncodeV2ToBursts(fixedHigh uint8, fixedLow uint64, rolling uint32) ([2][]byte, error) {
-	packets, err := EncodeV2(fixedHigh, fixedLow, rolling)
+	var bursts [2][MaxBurstBits]byte
+	n, err := EncodeV2BurstsInto(&bursts, fixedHigh, fixedLow, rolling)
 	if err != nil {
 		return [2][]byte{}, err
 	}
+	return [2][]byte{append([]byte(nil), bursts[0][:n]...), append([]byte(nil), bursts[1][:n]...)}, nil
+}
 
-	var result [2][]byte
-
-	for i, databits := range packets {
-		bitsWithHeader := make([]byte, len(syncHeader)+2+len(databits))
-		copy(bitsWithHeader, syncHeader)
-		bitsWithHeader[len(syncHeader)+1] = byte(i)
-		copy(bitsWithHeader[len(syncHeader)+2:], databits)
-		result[i], err = ManchesterEncode(bitsWithHeader)
-		if err != nil {
-			return [2][]byte{}, err
+// EncodeV2BurstsInto is EncodeV2ToBursts, writing the bursts into buffers
+// provided by the caller instead of allocating them, so that it can be used
+// on microcontrollers. It returns the length of each burst.
+func EncodeV2BurstsInto(bursts *[2][MaxBurstBits]byte, fixedHigh uint8, fixedLow uint64, rolling uint32) (int, error) {
+	var packets [2][MaxPacketBits]byte
+	n, err := EncodeV2Into(&packets, fixedHigh, fixedLow, rolling)
+	if err != nil {
+		return 0, err
+	}
+	for i := range packets {
+		pos := 0
+		put := func(b byte) {
+			bursts[i][pos], bursts[i][pos+1] = b^1, b
+			pos += 2
+		}
+		for _, b := range syncHeader {
+			put(b)
+		}
+		put(0)
+		put(byte(i))
+		for _, b := range packets[i][:n] {
+			put(b)
 		}
 	}
-
-	return result, nil
+	return 2 * (syncHeaderBits + 2 + n), nil
 }
 
 // EncodeV2 encodes a Security+2.0 fixed and rolling code into two packets, one
 // for each half. It supports short (< 2**40) and long (< 2**72) fixed codes.
 // Rolling code must be < 2**28.
 func EncodeV2(fixedHigh uint8, fixedLow uint64, rolling uint32) ([2][]byte, error) {
-	fixedHalves, long := getFixedHalves(fixedHigh, fixedLow)
-	ternaryHalves, err := getRollingTernaryHalves(rolling)
+	var packets [2][MaxPacketBits]byte
+	n, err := EncodeV2Into(&packets, fixedHigh, fixedLow, rolling)
 	if err != nil {
 		return [2][]byte{}, err
 	}
+	return [2][]byte{append([]byte(nil), packets[0][:n]...), append([]byte(nil), packets[1][:n]...)}, nil
+}
 
-	h1, err := encodeHalfV2(fixedHalves[0], ternaryHalves[0], long)
+// EncodeV2Into is EncodeV2, writing the packets into buffers provided by the
+// caller instead of allocating them, so that it can be used on
+// microcontrollers. It returns the length of each packet: 40 bits for short
+// fixed codes, and 64 for long ones.
+func EncodeV2Into(packets *[2][MaxPacketBits]byte, fixedHigh uint8, fixedLow uint64, rolling uint32) (int, error) {
+	fixedHalves, long := getFixedHalves(fixedHigh, fixedLow)
+	ternaryHalves, err := getRollingTernaryHalves(rolling)
 	if err != nil {
-		return [2][]byte{}, err
+		return 0, err
 	}
-	h2, err := encodeHalfV2(fixedHalves[1], ternaryHalves[1], long)
+
+	n, err := encodeHalfV2(packets[0][:], fixedHalves[0], &ternaryHalves[0], long)
 	if err != nil {
-		return [2][]byte{}, err
+		return 0, err
 	}
-
-	return [2][]byte{h1, h2}, nil
+	if _, err := encodeHalfV2(packets[1][:], fixedHalves[1], &ternaryHalves[1], long); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-// encodeHalfV2 encodes half of a v2 code. The first half of the fixed code, and
-// the first half of the ternary-"encrypted" rolling code are encoded completely
-// separately from the second halves.
-func encodeHalfV2(fixed []byte, rolling []byte, long bool) ([]byte, error) {
-	result := make([]byte, 2, 64)
+// encodeHalfV2 encodes half of a v2 code into dst, returning the number of
+// bits written. The first half of the fixed code, and the first half of the
+// ternary-"encrypted" rolling code are encoded completely separately from the
+// second halves.
+func encodeHalfV2(dst []byte, fixed uint64, rolling *[18]byte, long bool) (int, error) {
 	partLength := 10
+	dst[0], dst[1] = 0, 0
 	if long {
-		result[1] = 1
+		dst[1] = 1
 		partLength = 18
 	}
-	parts := [3][]byte{fixed[0:partLength], fixed[partLength : partLength*2], {}}
-
-	result = append(result, rolling[:8]...)
-	rest := make([]byte, partLength)
-	copy(rest, rolling[8:])
+	var parts [3][18]byte
+	for i := 0; i < partLength; i++ {
+		parts[0][i] = byte(fixed>>(2*partLength-1-i)) & 1
+		parts[1][i] = byte(fixed>>(partLength-1-i)) & 1
+	}
+	copy(dst[2:10], rolling[:8])
+	copy(parts[2][:], rolling[8:])
 	if long {
-		copy(rest[10:], rolling[:8])
+		copy(parts[2][10:], rolling[:8])
 	}
-	parts[2] = rest
 
 	var orderIndicator [4]byte
 	copy(orderIndicator[:], rolling[:4])
@@ -150,69 +152,59 @@ func encodeHalfV2(fixed []byte, rolling []byte, long bool) ([]byte, error) {
 	copy(inversionIndicator[:], rolling[4:8])
 	order, ok := orders[orderIndicator]
 	if !ok {
-		return nil, fmt.Errorf("no order found for indicator %v", orderIndicator)
+		return 0, errNoOrder
 	}
 	invert, ok := inversions[inversionIndicator]
 	if !ok {
-		return nil, fmt.Errorf("no inversion found for indicator %v", inversionIndicator)
+		return 0, errNoInversion
 	}
 
-	parts[0], parts[1], parts[2] = parts[order[0]], parts[order[1]], parts[order[2]]
-
 	for i := 0; i < partLength; i++ {
 		for j := 0; j < 3; j++ {
-			result = append(result, parts[j][i]^invert[j])
+			dst[10+i*3+j] = parts[order[j]][i] ^ invert[j]
 		}
 	}
 
-	return result, nil
+	return 10 + 3*partLength, nil
 }
 
-// getFixedHalves returns the first and second halves of the fixed part, as
-// []byte of 0s and 1s. If fixed < 2**40, it returns 20-bit halves, otherwise it
-// returns 36-bit halves.
-func getFixedHalves(fixedHigh uint8, fixedLow uint64) ([2][]byte, bool) {
-	long := false
-	base2 := fmt.Sprintf("%064b", fixedLow)
+// getFixedHalves returns the first and second halves of the fixed part. If
+// fixed < 2**40, it returns 20-bit halves, otherwise it returns 36-bit halves.
+func getFixedHalves(fixedHigh uint8, fixedLow uint64) ([2]uint64, bool) {
 	if fixedHigh > 0 || fixedLow >= 1<<40 {
-		long = true
-		base2 = fmt.Sprintf("%08b", fixedHigh) + base2
+		return [2]uint64{uint64(fixedHigh)<<28 | fixedLow>>36, fixedLow & (1<<36 - 1)}, true
 	}
-
-	if long {
-		return [2][]byte{bits.B(base2[0:36]), bits.B(base2[36:72])}, long
-	}
-	return [2][]byte{bits.B(base2[24:44]), bits.B(base2[44:64])}, long
+	return [2]uint64{fixedLow >> 20, fixedLow & (1<<20 - 1)}, false
 }
 
 // getRollingTernaryHalves converts the rolling code into a binary-pair-coded
-// ternary representation, split between two halves: a byte array of 0s and 1s.
-func getRollingTernaryHalves(rolling uint32) ([2][]byte, error) {
+// ternary representation, split between two halves: arrays of 0s and 1s.
+func getRollingTernaryHalves(rolling uint32) ([2][18]byte, error) {
 	if rolling >= 1<<28 {
-		return [2][]byte{}, fmt.Errorf("rolling code must be <= 2^28; got %d", rolling)
+		return [2][18]byte{}, errRollingRange
 	}
 	bitReversed := uint32(0)
 	for i := 0; i < 28; i++ {
 		bitReversed = bitReversed*2 + rolling&1
 		rolling >>= 1
 	}
-	ternary := make([]byte, 0, 18)
-	for i := 0; i < 18; i++ {
-		ternary = append(ternary, byte(bitReversed%3))
+	var ternary [18]byte
+	for i := range ternary {
+		ternary[i] = byte(bitReversed % 3)
 		bitReversed /= 3
 	}
 
-	ternaryPieces := func(pieces [][]byte) []byte {
-		var result []byte
+	var result [2][18]byte
+	pack := func(half *[18]byte, pieces [3][2]int) {
+		pos := 0
 		for _, piece := range pieces {
-			for i := len(piece) - 1; i >= 0; i-- {
-				trit := piece[i]
-				result = append(result, trit>>1, trit&1)
+			for i := piece[1] - 1; i >= piece[0]; i-- {
+				half[pos], half[pos+1] = ternary[i]>>1, ternary[i]&1
+				pos += 2
 			}
 		}
-		return result
 	}
-	part1 := ternaryPieces([][]byte{ternary[0:4], ternary[8:12], ternary[16:17]})
-	part2 := ternaryPieces([][]byte{ternary[4:8], ternary[12:16], ternary[17:18]})
-	return [2][]byte{part1, part2}, nil
+	pack(&result[0], [3][2]int{{0, 4}, {8, 12}, {16, 17}})
+	pack(&result[1], [3][2]int{{4, 8}, {12, 16}, {17, 18}})
+	return result, nil
 }
@@ -279,3 +279,190 @@ func TestEncodeV2(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeV2(t *testing.T) {
+	testcases := []struct {
+		name       string
+		fixedHigh  uint8
+		fixedLow   uint64
+		rolling    uint32
+		want       []string
+		wantBursts []string
+	}{
+		{
+			name:      "capture1",
+			fixedHigh: 4616223061045564932096 >> 64,
+			fixedLow:  4616223061045564932096 & (1<<64 - 1),
+			rolling:   240129675,
+			want:      []string{"0100010000101101100001101010001111010111100100100000100110101101", "0100100001110010010110011010011110010011110110011110010010010011"},
+			wantBursts: []string{
+				"1010101010101010101010101010101001010101101010011010100110101010011001011001011010101001011001100110101001010101100110010101011010011010011010101010011010010110011001011001",
+				"1010101010101010101010101010101001010101100110011010011010101001010110100110100110010110100101100110100101010110100110100101010110010110100101010110100110100110100110100101",
+			},
+		},
+		{
+			name:      "capture6",
+			fixedHigh: 1222022221851718057984 >> 64,
+			fixedLow:  1222022221851718057984 & (1<<64 - 1),
+			rolling:   240124668,
+			want:      []string{"0101011000001010100001000000110101100001110111110111111100010010", "0101100101100001101000001101101000101101101000001100101001101001"},
+			wantBursts: []string{
+				"1010101010101010101010101010101001010101101010011001100101101010101001100110011010101001101010101010010110011001011010101001010110010101010110010101010101011010100110100110",
+				"1010101010101010101010101010101001010101100110011001011010011001011010101001011001101010101001011001011001101010011001011001011001101010101001011010011001101001011001101001",
+			},
+		},
+		{
+			name:     "secplus-1",
+			fixedLow: 70678577664,
+			rolling:  240124710,
+			want:     []string{"0001000100001011111000111111011011101110", "0010010110001110011110010011011011011011"},
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			packets := [2][]byte{bits.B(tt.want[0]), bits.B(tt.want[1])}
+			if tt.wantBursts != nil {
+				for j, burst := range tt.wantBursts {
+					frame, packet, err := secplus.DecodeV2Burst(bits.B(burst))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if frame != j {
+						t.Errorf("want DecodeV2Burst(%s) frame==%d; got %d", burst, j, frame)
+					}
+					if !bytes.Equal(packet, packets[j]) {
+						t.Errorf("want DecodeV2Burst(%s) packet==%s; got %s", burst, tt.want[j], bits.S(packet))
+					}
+				}
+			}
+
+			fixedHigh, fixedLow, rolling, err := secplus.DecodeV2(packets)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if fixedHigh != tt.fixedHigh || fixedLow != tt.fixedLow || rolling != tt.rolling {
+				t.Errorf("want DecodeV2(%s, %s)==(%d, %d, %d); got (%d, %d, %d)", tt.want[0], tt.want[1], tt.fixedHigh, tt.fixedLow, tt.rolling, fixedHigh, fixedLow, rolling)
+			}
+		})
+	}
+}
+
+func TestDecodeV2Errors(t *testing.T) {
+	good := []string{"0100010000101101100001101010001111010111100100100000100110101101", "0100100001110010010110011010011110010011110110011110010010010011"}
+	short := "0010010110001110011110010011011011011011" // A 40-bit packet.
+	testcases := []struct {
+		name    string
+		packets [2]string
+	}{
+		{
+			name:    "too-short",
+			packets: [2]string{good[0][:39], good[1]},
+		},
+		{
+			name:    "invalid-trit",
+			packets: [2]string{flip(good[0], 16), good[1]},
+		},
+		{
+			name:    "mixed-lengths",
+			packets: [2]string{good[0], short},
+		},
+		{
+			name:    "corrupted-repeat",
+			packets: [2]string{flip(good[0], 40), good[1]},
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			if _, _, _, err := secplus.DecodeV2([2][]byte{bits.B(tt.packets[0]), bits.B(tt.packets[1])}); err == nil {
+				t.Errorf("want DecodeV2(%s, %s) to fail; got no error", tt.packets[0], tt.packets[1])
+			}
+		})
+	}
+}
+
+// flip returns s, a string of ASCII 0s and 1s, with the bit at position i flipped.
+func flip(s string, i int) string {
+	b := bits.B(s)
+	b[i] ^= 1
+	return bits.S(b)
+}
+
+func TestEncodeV2Into(t *testing.T) {
+	testcases := []struct {
+		name       string
+		fixedHigh  uint8
+		fixedLow   uint64
+		rolling    uint32
+		want       []string
+		wantBursts []string
+	}{
+		{
+			name:      "capture1",
+			fixedHigh: 4616223061045564932096 >> 64,
+			fixedLow:  4616223061045564932096 & (1<<64 - 1),
+			rolling:   240129675,
+			want:      []string{"0100010000101101100001101010001111010111100100100000100110101101", "0100100001110010010110011010011110010011110110011110010010010011"},
+			wantBursts: []string{
+				"1010101010101010101010101010101001010101101010011010100110101010011001011001011010101001011001100110101001010101100110010101011010011010011010101010011010010110011001011001",
+				"1010101010101010101010101010101001010101100110011010011010101001010110100110100110010110100101100110100101010110100110100101010110010110100101010110100110100110100110100101",
+			},
+		},
+		{
+			name:      "capture6",
+			fixedHigh: 1222022221851718057984 >> 64,
+			fixedLow:  1222022221851718057984 & (1<<64 - 1),
+			rolling:   240124668,
+			want:      []string{"0101011000001010100001000000110101100001110111110111111100010010", "0101100101100001101000001101101000101101101000001100101001101001"},
+			wantBursts: []string{
+				"1010101010101010101010101010101001010101101010011001100101101010101001100110011010101001101010101010010110011001011010101001010110010101010110010101010101011010100110100110",
+				"1010101010101010101010101010101001010101100110011001011010011001011010101001011001101010101001011001011001101010011001011001011001101010101001011010011001101001011001101001",
+			},
+		},
+		{
+			name:     "secplus-1",
+			fixedLow: 70678577664,
+			rolling:  240124710,
+			want:     []string{"0001000100001011111000111111011011101110", "0010010110001110011110010011011011011011"},
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			var packets [2][secplus.MaxPacketBits]byte
+			var bursts [2][secplus.MaxBurstBits]byte
+			allocs := testing.AllocsPerRun(10, func() {
+				if _, err := secplus.EncodeV2Into(&packets, tt.fixedHigh, tt.fixedLow, tt.rolling); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := secplus.EncodeV2BurstsInto(&bursts, tt.fixedHigh, tt.fixedLow, tt.rolling); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("want no allocations; got %v", allocs)
+			}
+
+			n, _ := secplus.EncodeV2Into(&packets, tt.fixedHigh, tt.fixedLow, tt.rolling)
+			if n != len(tt.want[0]) {
+				t.Fatalf("want %d-bit packets; got %d", len(tt.want[0]), n)
+			}
+			for j := range packets {
+				if got := bits.S(packets[j][:n]); got != tt.want[j] {
+					t.Errorf("want packet %d==%s; got %s", j, tt.want[j], got)
+				}
+			}
+			if tt.wantBursts != nil {
+				n, _ := secplus.EncodeV2BurstsInto(&bursts, tt.fixedHigh, tt.fixedLow, tt.rolling)
+				for j := range bursts {
+					if got := bits.S(bursts[j][:n]); got != tt.wantBursts[j] {
+						t.Errorf("want burst %d==%s; got %s", j, tt.wantBursts[j], got)
+					}
+				}
+			}
+		})
+	}
+}
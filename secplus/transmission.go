@@ -0,0 +1,47 @@
+//go:build !tinygo
+// +build !tinygo
+
+package secplus
+
+import (
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// TimingV2 holds the timing of a Security+2.0 transmission.
+type TimingV2 struct {
+	Pulsewidth time.Duration // Duration of a single on/off pulse (half a Manchester-coded bit).
+	Burstgap   time.Duration // Gap between first and second burst in a message.
+	Repeatgap  time.Duration // Gap between repeats of the whole message.
+	Repeats    int           // Number of times to send the whole message.
+}
+
+// DefaultTimingV2 is the timing of the remotes Security+2.0 openers come with.
+var DefaultTimingV2 = TimingV2{
+	Pulsewidth: 250 * time.Microsecond,
+	Burstgap:   90 * time.Millisecond,
+	Repeatgap:  90 * time.Millisecond,
+	Repeats:    4,
+}
+
+// TransmissionV2 encodes a Security+2.0 fixed and rolling code into a complete
+// transmission: both bursts, separated and repeated according to timing.
+// Manchester decoding needs every edge within a fifth of a pulse of where it
+// belongs.
+func TransmissionV2(fixedHigh uint8, fixedLow uint64, rolling uint32, timing TimingV2) (*waveform.Transmission, error) {
+	bursts, err := EncodeV2ToBursts(fixedHigh, fixedLow, rolling)
+	if err != nil {
+		return nil, err
+	}
+	return &waveform.Transmission{
+		Protocol: Protocol,
+		Bursts: []waveform.Burst{
+			{Bits: bursts[0], Pulsewidth: timing.Pulsewidth, Gap: timing.Burstgap},
+			{Bits: bursts[1], Pulsewidth: timing.Pulsewidth},
+		},
+		Repeats:   timing.Repeats,
+		RepeatGap: timing.Repeatgap,
+		Tolerance: timing.Pulsewidth / 5,
+	}, nil
+}
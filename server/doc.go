@@ -0,0 +1,23 @@
+/*
+Package server is an HTTP API for triggering openers, for phones and scripts.
+
+Requests are queued, and transmitted one at a time by a single worker, so
+the hardware is never shared. The API is JSON throughout:
+
+	GET  /api/openers                  List the configured openers.
+	GET  /api/openers/{name}           One opener, and its last result.
+	POST /api/openers/{name}/{action}  Trigger it: open, close or toggle.
+	GET  /api/status                   What is transmitting, and how many are queued.
+	GET  /api/history?limit=n          Recent results, newest first.
+
+A trigger waits for the transmission to finish, and returns its result,
+unless ?wait=false is given, in which case it returns as soon as it is
+queued. Openers only understand "toggle", so open and close are only allowed
+for openers whose door sensors are being watched: the worker checks where
+the door is, and transmits only if it isn't there already, again if it
+doesn't move. Other openers can only be toggled.
+
+If a token is set, every request must carry it as
+"Authorization: Bearer <token>".
+*/
+package server
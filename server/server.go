@@ -0,0 +1,547 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Defaults for the queue and history sizes.
+const (
+	DefaultQueueSize   = 16
+	DefaultHistorySize = 100
+)
+
+// Actions that can be requested.
+const (
+	Open   = "open"
+	Close  = "close"
+	Toggle = "toggle"
+)
+
+// errQueueFull is returned when too many requests are waiting.
+var errQueueFull = errors.New("too many requests queued; try again later")
+
+// Sources of requests made on the machine itself, which quiet hours don't
+// apply to.
+const (
+	SourceCLI      = "cli"
+	SourceSchedule = "schedule"
+)
+
+// QuietError is returned for remote requests during an opener's quiet hours.
+type QuietError struct {
+	Opener string
+	Hours  string
+}
+
+func (e *QuietError) Error() string {
+	return fmt.Sprintf("opener %q may not be triggered remotely during its quiet hours, %s", e.Opener, e.Hours)
+}
+
+// SendFunc sends a transmission on the hardware, frequency plan and all. The
+// context carries the request's audit entry, for it to complete and record.
+type SendFunc func(ctx context.Context, tx *waveform.Transmission) error
+
+// Result records a request to trigger an opener, and its outcome.
+type Result struct {
+	ID       int        `json:"id"`
+	Opener   string     `json:"opener"`
+	Action   string     `json:"action"`
+	Protocol string     `json:"protocol"`
+	Source   string     `json:"source"`
+	Rolling  *uint32    `json:"rolling,omitempty"` // Security+2.0 only; the last one sent.
+	Sent     int        `json:"sent"`              // None if the door was already there.
+	State    string     `json:"state"`             // queued, transmitting, done or failed.
+	Error    string     `json:"error,omitempty"`
+	Queued   time.Time  `json:"queued"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// Result states.
+const (
+	StateQueued       = "queued"
+	StateTransmitting = "transmitting"
+	StateDone         = "done"
+	StateFailed       = "failed"
+)
+
+// job is a queued request.
+type job struct {
+	result *Result
+	done   chan struct{}
+}
+
+// Server serves the API. Create one with New, and start its worker with Run.
+type Server struct {
+	config  *config.Config
+	send    SendFunc
+	rolling *rolling.Store
+
+	// Token, if not empty, must be given by every request.
+	Token string
+	// HistorySize is the number of results kept.
+	HistorySize int
+	// Limits, if not nil, records recent transmissions. Requests that would
+	// exceed their opener's limits fail before a rolling code is used up.
+	Limits *limit.Store
+	// AuditKey fingerprints fixed codes in audit entries. Without it, they
+	// are left out.
+	AuditKey audit.Key
+	// Doors holds the doors of openers with sensors, by opener name. Opening
+	// or closing one moves it with a door.Mover, which transmits only if it
+	// isn't there already. Other openers can only be toggled.
+	Doors map[string]*door.Door
+	// Retries is how many more times to transmit when a door doesn't move,
+	// or moves the wrong way.
+	Retries int
+	// Log, if not nil, gets a line for each transmission moving a door, and
+	// what the door did.
+	Log io.Writer
+
+	queue chan *job
+
+	mu      sync.Mutex
+	nextID  int
+	current *Result
+	waiting int
+	history []*Result // Oldest first.
+}
+
+// New returns a server triggering the openers in cfg by passing their
+// transmissions to send, taking Security+2.0 rolling codes from store.
+func New(cfg *config.Config, send SendFunc, store *rolling.Store) *Server {
+	return &Server{
+		config:      cfg,
+		send:        send,
+		rolling:     store,
+		HistorySize: DefaultHistorySize,
+		Retries:     door.DefaultRetries,
+		queue:       make(chan *job, DefaultQueueSize),
+	}
+}
+
+// Run transmits queued requests one at a time, until the context is
+// cancelled.
+func (s *Server) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.queue:
+			s.run(ctx, j)
+		}
+	}
+}
+
+// run transmits a single request.
+func (s *Server) run(ctx context.Context, j *job) {
+	defer close(j.done)
+	s.mu.Lock()
+	s.waiting--
+	s.current = j.result
+	started := time.Now()
+	j.result.Started = &started
+	j.result.State = StateTransmitting
+	s.mu.Unlock()
+
+	err := s.transmit(ctx, j.result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := time.Now()
+	j.result.Finished = &finished
+	j.result.State = StateDone
+	if err != nil {
+		j.result.State = StateFailed
+		j.result.Error = err.Error()
+	}
+	s.current = nil
+}
+
+// transmit carries out a request: toggling the opener, or moving its door,
+// transmitting only if it isn't where it should be.
+func (s *Server) transmit(ctx context.Context, r *Result) error {
+	o, err := s.config.Opener(r.Opener)
+	if err != nil {
+		return err
+	}
+	if r.Action == Toggle {
+		return s.toggle(ctx, o, r)
+	}
+	d, err := s.doorOf(o)
+	if err != nil {
+		return err
+	}
+	m := door.NewMover(d, func(ctx context.Context) error {
+		return s.toggle(ctx, o, r)
+	})
+	m.Retries = s.Retries
+	m.Log = s.Log
+	target := door.Open
+	if r.Action == Close {
+		target = door.Closed
+	}
+	_, err = m.MoveTo(ctx, target)
+	return err
+}
+
+// toggle encodes and sends the opener's transmission once, for a request.
+func (s *Server) toggle(ctx context.Context, o *config.Opener, r *Result) error {
+	limits, err := o.Limits.Limits()
+	if err != nil {
+		return err
+	}
+	if s.Limits != nil {
+		if err := s.Limits.Check(o.Name, limits); err != nil {
+			return err
+		}
+	}
+	e := &audit.Entry{
+		Opener:   o.Name,
+		Action:   r.Action,
+		Protocol: o.Protocol,
+		Fixed:    s.AuditKey.OpenerFingerprint(o),
+		Source:   r.Source,
+	}
+	var code uint32
+	if o.Protocol == secplus.Protocol {
+		start, err := o.RollingStart()
+		if err != nil {
+			return err
+		}
+		if code, err = s.rolling.Next(o.Name, start); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		r.Rolling = &code
+		s.mu.Unlock()
+		e.Rolling = &code
+	}
+	tx, err := o.Transmission(code)
+	if err != nil {
+		return err
+	}
+	if err := s.send(limit.NewContext(audit.NewContext(ctx, e), limits), tx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	r.Sent++
+	s.mu.Unlock()
+	return nil
+}
+
+// doorOf returns the door an open or close request for o moves. Openers
+// without one can only be toggled, since their doors might already be where
+// a request would send them.
+func (s *Server) doorOf(o *config.Opener) (*door.Door, error) {
+	if d := s.Doors[o.Name]; d != nil {
+		return d, nil
+	}
+	if o.Sensor == nil {
+		return nil, fmt.Errorf("opener %q has no sensor, so it can only be toggled", o.Name)
+	}
+	return nil, fmt.Errorf("the sensor of opener %q isn't being watched, so it can only be toggled", o.Name)
+}
+
+// enqueue queues a request, returning a channel that is closed when it has
+// been sent.
+func (s *Server) enqueue(o *config.Opener, action, source string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	j := &job{
+		result: &Result{
+			ID:       s.nextID,
+			Opener:   o.Name,
+			Action:   action,
+			Protocol: o.Protocol,
+			Source:   source,
+			State:    StateQueued,
+			Queued:   time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	select {
+	case s.queue <- j:
+	default:
+		s.nextID--
+		return nil, errQueueFull
+	}
+	s.waiting++
+	s.history = append(s.history, j.result)
+	if n := len(s.history) - s.HistorySize; n > 0 {
+		s.history = s.history[n:]
+	}
+	return j, nil
+}
+
+// checkQuiet returns a *QuietError if a request from source falls in the
+// opener's quiet hours.
+func (s *Server) checkQuiet(o *config.Opener, source string) error {
+	if source == SourceCLI || source == SourceSchedule {
+		return nil
+	}
+	if q, quiet := s.config.Quiet(o.Name, time.Now()); quiet {
+		return &QuietError{Opener: o.Name, Hours: q.Hours}
+	}
+	return nil
+}
+
+// Trigger queues a request to trigger the named opener, waits for it to be
+// sent, and returns its result. It is the same as a POST to the API, for
+// callers in the same process. It returns an error if the request couldn't be
+// queued, or the context was cancelled while waiting; a failed transmission
+// is reported in the result.
+func (s *Server) Trigger(ctx context.Context, opener, action, source string) (*Result, error) {
+	o, err := s.config.Opener(opener)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case Open, Close, Toggle:
+	default:
+		return nil, fmt.Errorf("unknown action %q; expected open, close or toggle", action)
+	}
+	if action != Toggle {
+		if _, err := s.doorOf(o); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkQuiet(o, source); err != nil {
+		return nil, err
+	}
+	j, err := s.enqueue(o, action, source)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.snapshot(j.result), nil
+}
+
+// snapshot copies a result, so it can be encoded without holding the lock.
+func (s *Server) snapshot(r *Result) *Result {
+	if r == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *r
+	return &c
+}
+
+// ServeHTTP serves the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "openers":
+		s.handleList(w, r)
+	case len(parts) == 3 && parts[0] == "api" && parts[1] == "openers":
+		s.handleOpener(w, r, parts[2])
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "openers":
+		s.handleTrigger(w, r, parts[2], parts[3])
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "status":
+		s.handleStatus(w, r)
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "history":
+		s.handleHistory(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// openerInfo is what the API reveals about an opener: not its codes.
+type openerInfo struct {
+	Name           string    `json:"name"`
+	Protocol       string    `json:"protocol"`
+	FrequenciesMHz []float64 `json:"frequencies_mhz,omitempty"`
+	Last           *Result   `json:"last,omitempty"`
+	// Budget is what is left of the opener's limits, if they are enforced.
+	Budget string `json:"budget,omitempty"`
+}
+
+func (s *Server) info(o *config.Opener) openerInfo {
+	info := openerInfo{Name: o.Name, Protocol: o.Protocol, FrequenciesMHz: o.FrequenciesMHz}
+	s.mu.Lock()
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].Opener == o.Name {
+			c := *s.history[i]
+			info.Last = &c
+			break
+		}
+	}
+	s.mu.Unlock()
+	if s.Limits != nil {
+		if l, err := o.Limits.Limits(); err == nil {
+			if b, err := s.Limits.Budget(o.Name, l); err == nil {
+				info.Budget = b.String()
+			}
+		}
+	}
+	return info
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	list := []openerInfo{}
+	for i := range s.config.Openers {
+		list = append(list, s.info(&s.config.Openers[i]))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleOpener(w http.ResponseWriter, r *http.Request, name string) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	o, err := s.config.Opener(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.info(o))
+}
+
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request, name, action string) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	o, err := s.config.Opener(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	switch action {
+	case Open, Close, Toggle:
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown action %q; expected open, close or toggle", action))
+		return
+	}
+	if action != Toggle {
+		if _, err := s.doorOf(o); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if err := s.checkQuiet(o, r.RemoteAddr); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	j, err := s.enqueue(o, action, r.RemoteAddr)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if r.URL.Query().Get("wait") == "false" {
+		writeJSON(w, http.StatusAccepted, s.snapshot(j.result))
+		return
+	}
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		// The client gave up; the request stays queued.
+		return
+	}
+	result := s.snapshot(j.result)
+	status := http.StatusOK
+	if result.State == StateFailed {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, result)
+}
+
+// status is the response to /api/status.
+type status struct {
+	Busy    bool    `json:"busy"`
+	Current *Result `json:"current,omitempty"`
+	Queued  int     `json:"queued"`
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.Lock()
+	st := status{Busy: s.current != nil, Queued: s.waiting}
+	if s.current != nil {
+		c := *s.current
+		st.Current = &c
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	limit := s.HistorySize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", l))
+			return
+		}
+		limit = n
+	}
+	s.mu.Lock()
+	results := []Result{}
+	for i := len(s.history) - 1; i >= 0 && len(results) < limit; i-- {
+		results = append(results, *s.history[i])
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, results)
+}
+
+// allow checks the request method, responding with an error if it's wrong.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("use %s", method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
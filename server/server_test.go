@@ -0,0 +1,357 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/audit"
+	"github.com/zellyn/openers/config"
+	"github.com/zellyn/openers/door"
+	"github.com/zellyn/openers/limit"
+	"github.com/zellyn/openers/rolling"
+	"github.com/zellyn/openers/server"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+const testConfig = `{
+  "openers": [
+    {"name": "garage", "protocol": "secplus-v2", "fixed": "70678577664", "rolling": "240124710", "frequencies_mhz": [310, 315, 390]},
+    {"name": "gate", "protocol": "megacode", "identifier": "0x876543"}
+  ]
+}`
+
+// syncBuffer is a bytes.Buffer safe for use from the worker and the test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// newServer starts a server sending with the dry-run backend, returning it
+// and what it has sent so far.
+func newServer(t *testing.T, send server.SendFunc) (*server.Server, *httptest.Server, *syncBuffer) {
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &syncBuffer{}
+	if send == nil {
+		send = (&transmit.DryRun{Log: log}).Transmit
+	}
+	s := server.New(cfg, send, &rolling.Store{Path: filepath.Join(t.TempDir(), "rolling.json")})
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx)
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+	})
+	return s, ts, log
+}
+
+// do makes a request, decoding the JSON response into v if it is not nil.
+func do(t *testing.T, method, url string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestTrigger(t *testing.T) {
+	_, ts, log := newServer(t, nil)
+
+	var openers []struct {
+		Name     string `json:"name"`
+		Protocol string `json:"protocol"`
+		Fixed    string `json:"fixed"`
+	}
+	if code := do(t, "GET", ts.URL+"/api/openers", &openers); code != http.StatusOK {
+		t.Fatalf("want status 200; got %d", code)
+	}
+	if len(openers) != 2 || openers[0].Name != "garage" || openers[1].Protocol != "megacode" || openers[0].Fixed != "" {
+		t.Errorf("want garage and gate, without codes; got %+v", openers)
+	}
+
+	for i, want := range []uint32{240124710, 240124711} {
+		var r server.Result
+		if code := do(t, "POST", ts.URL+"/api/openers/garage/toggle", &r); code != http.StatusOK {
+			t.Fatalf("want status 200; got %d: %+v", code, r)
+		}
+		if r.State != server.StateDone || r.Action != "toggle" || r.Rolling == nil || *r.Rolling != want || r.ID != i+1 {
+			t.Errorf("want done toggle with rolling code %d; got %+v", want, r)
+		}
+	}
+	var r server.Result
+	if code := do(t, "POST", ts.URL+"/api/openers/gate/toggle", &r); code != http.StatusOK || r.Rolling != nil || r.Protocol != "megacode" {
+		t.Errorf("want megacode toggle without rolling code; got %d: %+v", code, r)
+	}
+	if got := strings.Count(log.String(), "Would send"); got != 3 {
+		t.Errorf("want 3 transmissions; got %d:\n%s", got, log)
+	}
+	if !strings.Contains(log.String(), "on 310MHz, 315MHz, 390MHz") {
+		t.Errorf("want garage sent with its frequency plan; got:\n%s", log)
+	}
+
+	var history []server.Result
+	do(t, "GET", ts.URL+"/api/history?limit=2", &history)
+	if len(history) != 2 || history[0].Opener != "gate" || history[1].Opener != "garage" {
+		t.Errorf("want last two results, newest first; got %+v", history)
+	}
+	var garage struct {
+		Last *server.Result `json:"last"`
+	}
+	do(t, "GET", ts.URL+"/api/openers/garage", &garage)
+	if garage.Last == nil || garage.Last.ID != 2 {
+		t.Errorf("want garage's last result to be #2; got %+v", garage.Last)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		started <- struct{}{}
+		<-release
+		if tx.Protocol == "megacode" {
+			return fmt.Errorf("transmitter on fire")
+		}
+		return nil
+	}
+	_, ts, _ := newServer(t, send)
+
+	var first, second server.Result
+	if code := do(t, "POST", ts.URL+"/api/openers/garage/toggle?wait=false", &first); code != http.StatusAccepted {
+		t.Errorf("want status 202; got %d", code)
+	}
+	<-started
+	do(t, "POST", ts.URL+"/api/openers/gate/toggle?wait=false", &second)
+
+	var st struct {
+		Busy    bool           `json:"busy"`
+		Current *server.Result `json:"current"`
+		Queued  int            `json:"queued"`
+	}
+	do(t, "GET", ts.URL+"/api/status", &st)
+	if !st.Busy || st.Current == nil || st.Current.ID != first.ID || st.Queued != 1 {
+		t.Errorf("want #%d transmitting, and one queued; got %+v", first.ID, st)
+	}
+
+	close(release)
+	var r server.Result
+	code := do(t, "POST", ts.URL+"/api/openers/gate/toggle", &r)
+	if code != http.StatusBadGateway || r.State != server.StateFailed || !strings.Contains(r.Error, "on fire") {
+		t.Errorf("want failure; got %d: %+v", code, r)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s, ts, _ := newServer(t, nil)
+	testcases := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/api/openers/shed", http.StatusNotFound},
+		{"POST", "/api/openers/shed/open", http.StatusNotFound},
+		{"POST", "/api/openers/garage/explode", http.StatusNotFound},
+		{"GET", "/api/openers/garage/open", http.StatusMethodNotAllowed},
+		{"POST", "/api/openers/gate/open", http.StatusBadRequest},
+		{"POST", "/api/openers", http.StatusMethodNotAllowed},
+		{"GET", "/api/history?limit=x", http.StatusBadRequest},
+		{"GET", "/nope", http.StatusNotFound},
+	}
+	for _, tt := range testcases {
+		var body map[string]string
+		if got := do(t, tt.method, ts.URL+tt.path, &body); got != tt.want || body["error"] == "" {
+			t.Errorf("%s %s: want status %d with an error; got %d, %v", tt.method, tt.path, tt.want, got, body)
+		}
+	}
+
+	s.Token = "sesame"
+	if got := do(t, "GET", ts.URL+"/api/openers", nil); got != http.StatusUnauthorized {
+		t.Errorf("want status 401 without token; got %d", got)
+	}
+	req, _ := http.NewRequest("GET", ts.URL+"/api/openers", nil)
+	req.Header.Set("Authorization", "Bearer sesame")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status 200 with token; got %d", resp.StatusCode)
+	}
+}
+
+func TestDirectTrigger(t *testing.T) {
+	s, ts, _ := newServer(t, nil)
+	ctx := context.Background()
+	r, err := s.Trigger(ctx, "gate", server.Toggle, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != server.StateDone || r.Source != "test" {
+		t.Errorf("want done, from test; got %+v", r)
+	}
+	if _, err := s.Trigger(ctx, "shed", server.Toggle, "test"); err == nil {
+		t.Errorf("want error for unknown opener")
+	}
+	if _, err := s.Trigger(ctx, "gate", "explode", "test"); err == nil {
+		t.Errorf("want error for unknown action")
+	}
+	var history []server.Result
+	do(t, "GET", ts.URL+"/api/history", &history)
+	if len(history) != 1 || history[0].Source != "test" {
+		t.Errorf("want direct trigger in history; got %+v", history)
+	}
+}
+
+func TestMove(t *testing.T) {
+	var d *door.Door
+	sent := 0
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		// The door swings from one limit to the other.
+		sent++
+		closed := d.State(door.Now()) == door.Closed
+		d.Set(door.ClosedSwitch, !closed)
+		d.Set(door.OpenSwitch, closed)
+		return nil
+	}
+	s, _, _ := newServer(t, send)
+	d = door.New(door.ClosedSwitch, door.OpenSwitch)
+	d.Set(door.ClosedSwitch, true)
+	s.Doors = map[string]*door.Door{"garage": d}
+	ctx := context.Background()
+
+	if r, err := s.Trigger(ctx, "garage", server.Close, "test"); err != nil || r.State != server.StateDone || r.Sent != 0 || sent != 0 {
+		t.Errorf("want closed door left alone; got %+v, %v, %d sent", r, err, sent)
+	}
+	if r, err := s.Trigger(ctx, "garage", server.Open, "test"); err != nil || r.State != server.StateDone || r.Sent != 1 || r.Rolling == nil {
+		t.Errorf("want one transmission opening the door; got %+v, %v", r, err)
+	}
+	if st := d.State(door.Now()); st != door.Open {
+		t.Errorf("want door open; got %s", st)
+	}
+	if _, err := s.Trigger(ctx, "gate", server.Open, "test"); err == nil || !strings.Contains(err.Error(), "only be toggled") {
+		t.Errorf("want opener without a sensor refused; got %v", err)
+	}
+}
+
+func TestAuditEntry(t *testing.T) {
+	entries := make(chan *audit.Entry, 1)
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		entries <- audit.FromContext(ctx)
+		return nil
+	}
+	s, _, _ := newServer(t, send)
+	s.AuditKey = audit.Key(bytes.Repeat([]byte{0x5a}, 32))
+	if _, err := s.Trigger(context.Background(), "garage", server.Toggle, "mqtt"); err != nil {
+		t.Fatal(err)
+	}
+	e := <-entries
+	if e == nil || e.Opener != "garage" || e.Action != "toggle" || e.Source != "mqtt" || e.Rolling == nil || *e.Rolling != 240124710 {
+		t.Fatalf("want audit entry for garage toggle from mqtt with rolling code; got %+v", e)
+	}
+	if e.Fixed == "" || strings.Contains(e.Fixed, "70678577664") {
+		t.Errorf("want fingerprint of fixed code; got %q", e.Fixed)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	cfg, err := config.Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Quiet for the hour either side of now.
+	now := time.Now()
+	hours := now.Add(-time.Hour).Format("15:04") + "-" + now.Add(time.Hour).Format("15:04")
+	cfg.QuietHours = []config.Quiet{{Hours: hours, Openers: []string{"gate"}}}
+	s := server.New(cfg, (&transmit.DryRun{Log: ioutil.Discard}).Transmit, &rolling.Store{Path: filepath.Join(t.TempDir(), "rolling.json")})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	var body map[string]string
+	if code := do(t, "POST", ts.URL+"/api/openers/gate/toggle", &body); code != http.StatusForbidden || !strings.Contains(body["error"], "quiet hours") {
+		t.Errorf("want status 403 for gate in quiet hours; got %d: %v", code, body)
+	}
+	var qe *server.QuietError
+	if _, err := s.Trigger(ctx, "gate", server.Toggle, "mqtt"); !errors.As(err, &qe) || qe.Hours != hours {
+		t.Errorf("want QuietError for mqtt; got %v", err)
+	}
+	for _, source := range []string{server.SourceCLI, server.SourceSchedule} {
+		if r, err := s.Trigger(ctx, "gate", server.Toggle, source); err != nil || r.State != server.StateDone {
+			t.Errorf("want %s allowed in quiet hours; got %+v, %v", source, r, err)
+		}
+	}
+	if r, err := s.Trigger(ctx, "garage", server.Toggle, "mqtt"); err != nil || r.State != server.StateDone {
+		t.Errorf("want garage allowed; got %+v, %v", r, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	store := &limit.Store{Path: filepath.Join(t.TempDir(), "limits.json")}
+	send := func(ctx context.Context, tx *waveform.Transmission) error {
+		return store.Take(audit.FromContext(ctx).Opener, limit.FromContext(ctx), tx.OnAir())
+	}
+	s, ts, _ := newServer(t, send)
+	s.Limits = store
+	ctx := context.Background()
+	for i := 0; i < limit.Defaults.PerMinute; i++ {
+		if r, err := s.Trigger(ctx, "garage", server.Toggle, "mqtt"); err != nil || r.State != server.StateDone {
+			t.Fatalf("want transmission %d allowed; got %+v, %v", i+1, r, err)
+		}
+	}
+	r, err := s.Trigger(ctx, "garage", server.Toggle, "mqtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != server.StateFailed || !strings.Contains(r.Error, "in the last minute") || r.Rolling != nil {
+		t.Errorf("want failure over the limit, before using a rolling code; got %+v", r)
+	}
+	if r, err := s.Trigger(ctx, "gate", server.Toggle, "mqtt"); err != nil || r.State != server.StateDone {
+		t.Errorf("want gate allowed; got %+v, %v", r, err)
+	}
+
+	var info struct{ Budget string }
+	do(t, "GET", ts.URL+"/api/openers/garage", &info)
+	if !strings.HasPrefix(info.Budget, "0 of 6 transmissions left this minute") {
+		t.Errorf("want garage budget used up; got %q", info.Budget)
+	}
+}
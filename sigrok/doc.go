@@ -0,0 +1,9 @@
+/*
+Package sigrok reads and writes pulses as sigrok session files (.sr), the
+native format of PulseView and sigrok-cli.
+
+A session file is a zip archive containing a version file, an INI-style
+metadata file describing the channels and sample rate, and the raw logic
+samples, split into one or more chunks.
+*/
+package sigrok
@@ -0,0 +1,267 @@
+package sigrok
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// captureFile is the prefix of the logic sample chunks written by Write.
+const captureFile = "logic-1"
+
+// chunkSize is the size of each logic sample chunk written by Write, matching
+// what sigrok itself uses.
+const chunkSize = 4 << 20
+
+// Write writes pulses to w as a sigrok session file with a single logic
+// channel, sampled at samplerate samples per second.
+func Write(w io.Writer, pulses []waveform.Pulse, channel string, samplerate float64) error {
+	if samplerate < 1 {
+		return fmt.Errorf("sample rate must be at least 1Hz; got %g", samplerate)
+	}
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("version")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "2"); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("metadata")
+	if err != nil {
+		return err
+	}
+	metadata := fmt.Sprintf(`[global]
+sigrok version=0.5.2
+
+[device 1]
+capturefile=%s
+total probes=1
+samplerate=%s
+total analog=0
+probe1=%s
+unitsize=1
+`, captureFile, formatSamplerate(samplerate), channel)
+	if _, err := io.WriteString(f, metadata); err != nil {
+		return err
+	}
+
+	samples := waveform.Render(pulses, math.Round(samplerate))
+	for i := 0; i*chunkSize < len(samples) || i == 0; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		f, err = zw.Create(fmt.Sprintf("%s-%d", captureFile, i+1))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(samples[i*chunkSize : end]); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Read reads a single logic channel from a sigrok session file as pulses. If
+// channel is empty, the first channel is used.
+func Read(r io.ReaderAt, size int64, channel string) ([]waveform.Pulse, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	if f, ok := files["version"]; ok {
+		version, err := readAll(f)
+		if err != nil {
+			return nil, err
+		}
+		if v := strings.TrimSpace(string(version)); v != "2" {
+			return nil, fmt.Errorf("unsupported sigrok session version %q", v)
+		}
+	}
+
+	f, ok := files["metadata"]
+	if !ok {
+		return nil, fmt.Errorf("not a sigrok session file: no metadata")
+	}
+	metadata, err := readAll(f)
+	if err != nil {
+		return nil, err
+	}
+	device, err := parseMetadata(string(metadata))
+	if err != nil {
+		return nil, err
+	}
+
+	samplerate, err := parseSamplerate(device["samplerate"])
+	if err != nil {
+		return nil, err
+	}
+	unitsize, err := strconv.Atoi(device["unitsize"])
+	if err != nil || unitsize < 1 {
+		return nil, fmt.Errorf("bad unitsize %q", device["unitsize"])
+	}
+	probes, err := strconv.Atoi(device["total probes"])
+	if err != nil {
+		return nil, fmt.Errorf("bad total probes %q", device["total probes"])
+	}
+	bit := -1
+	var names []string
+	for i := 1; i <= probes; i++ {
+		name, ok := device[fmt.Sprintf("probe%d", i)]
+		if !ok {
+			continue
+		}
+		names = append(names, name)
+		if bit < 0 && (channel == "" || channel == name) {
+			bit = i - 1
+		}
+	}
+	if bit < 0 {
+		if channel == "" {
+			return nil, fmt.Errorf("no logic channels found")
+		}
+		return nil, fmt.Errorf("no logic channel named %q found; channels are: %s", channel, strings.Join(names, ", "))
+	}
+
+	chunks, err := chunkFiles(files, device["capturefile"])
+	if err != nil {
+		return nil, err
+	}
+	var samples []byte
+	for _, chunk := range chunks {
+		data, err := readAll(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+unitsize <= len(data); i += unitsize {
+			samples = append(samples, data[i+bit/8]>>(bit%8)&1)
+		}
+	}
+	return waveform.FromSamples(samples, samplerate), nil
+}
+
+// chunkFiles returns the files holding the logic samples, in order. Older
+// session files have a single file named after the capture file; newer ones
+// split it into numbered chunks.
+func chunkFiles(files map[string]*zip.File, capturefile string) ([]*zip.File, error) {
+	if capturefile == "" {
+		return nil, fmt.Errorf("no capturefile in metadata")
+	}
+	if f, ok := files[capturefile]; ok {
+		return []*zip.File{f}, nil
+	}
+	type numbered struct {
+		n int
+		f *zip.File
+	}
+	var chunks []numbered
+	for name, f := range files {
+		if !strings.HasPrefix(name, capturefile+"-") {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(name, capturefile+"-"))
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, numbered{n, f})
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no logic data found for %q", capturefile)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].n < chunks[j].n })
+	result := make([]*zip.File, len(chunks))
+	for i, c := range chunks {
+		result[i] = c.f
+	}
+	return result, nil
+}
+
+// parseMetadata returns the keys and values of the first device section of a
+// session file's metadata.
+func parseMetadata(metadata string) (map[string]string, error) {
+	var device map[string]string
+	sc := bufio.NewScanner(strings.NewReader(metadata))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "["):
+			if device != nil {
+				return device, nil
+			}
+			if strings.HasPrefix(line, "[device ") {
+				device = map[string]string{}
+			}
+		case device != nil:
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("malformed metadata line %q", line)
+			}
+			device[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	if device == nil {
+		return nil, fmt.Errorf("no device found in metadata")
+	}
+	return device, nil
+}
+
+var samplerateUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"GHz", 1e9},
+	{"MHz", 1e6},
+	{"kHz", 1e3},
+	{"Hz", 1},
+}
+
+// formatSamplerate formats a sample rate the way sigrok does, eg. "24 MHz".
+func formatSamplerate(samplerate float64) string {
+	for _, u := range samplerateUnits {
+		if samplerate >= u.multiplier && math.Mod(samplerate, u.multiplier) == 0 {
+			return fmt.Sprintf("%d %s", int64(samplerate/u.multiplier), u.suffix)
+		}
+	}
+	return fmt.Sprintf("%d Hz", int64(math.Round(samplerate)))
+}
+
+// parseSamplerate parses a sample rate as formatted by sigrok.
+func parseSamplerate(s string) (float64, error) {
+	for _, u := range samplerateUnits {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), 64)
+		if err != nil || f <= 0 {
+			break
+		}
+		return f * u.multiplier, nil
+	}
+	return 0, fmt.Errorf("bad samplerate %q", s)
+}
+
+func readAll(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
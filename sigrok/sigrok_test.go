@@ -0,0 +1,89 @@
+package sigrok_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/sigrok"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestRoundTrip(t *testing.T) {
+	pulses := waveform.FromBits(bits.B("0110100011"), 250*time.Microsecond)
+	var buf bytes.Buffer
+	if err := sigrok.Write(&buf, pulses, "tx", 1e6); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sigrok.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("want Read(Write(%v))==%v; got %v", pulses, pulses, got)
+	}
+}
+
+// TestReadChunked reads a capture in the style of PulseView: several probes
+// at an awkward sample rate, with the samples split into chunks.
+func TestReadChunked(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"version", []byte("2")},
+		{"metadata", []byte(`[global]
+sigrok version=0.5.2
+
+[device 1]
+driver=fx2lafw
+capturefile=logic-1
+total probes=8
+samplerate=24 MHz
+total analog=0
+probe1=D0
+probe2=D1
+probe3=D2
+unitsize=1
+`)},
+		// D2 is high for samples 2-4 and 7.
+		{"logic-1-2", []byte{0x00, 0x00, 0x04}},
+		{"logic-1-1", []byte{0x01, 0x02, 0x04, 0x04, 0x04, 0x00, 0xff}},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sigrok.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "D2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sample boundaries at 24MHz fall at 41.667ns intervals.
+	want := []waveform.Pulse{
+		{Level: 0, Duration: 83 * time.Nanosecond},
+		{Level: 1, Duration: 125 * time.Nanosecond},
+		{Level: 0, Duration: 42 * time.Nanosecond},
+		{Level: 1, Duration: 42 * time.Nanosecond},
+		{Level: 0, Duration: 83 * time.Nanosecond},
+		{Level: 1, Duration: 42 * time.Nanosecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+
+	if _, err := sigrok.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), "D7"); err == nil {
+		t.Errorf("want error reading missing channel D7")
+	}
+}
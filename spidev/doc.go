@@ -0,0 +1,10 @@
+/*
+Package spidev talks to SPI devices through the Linux spidev driver
+(/dev/spidevB.C).
+
+Every Write is a single half-duplex SPI transfer, clocked at the speed the
+device was opened with, so a bitstream written to it comes out of the MOSI pin
+with hardware timing. Tx does full-duplex transfers, for devices with
+registers to read back.
+*/
+package spidev
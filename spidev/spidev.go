@@ -0,0 +1,57 @@
+package spidev
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxTransfer is the spidev driver's default limit on the size of a
+// single transfer, used if the actual limit can't be read.
+const DefaultMaxTransfer = 4096
+
+// bufsizPath is where the spidev driver publishes its transfer size limit.
+const bufsizPath = "/sys/module/spidev/parameters/bufsiz"
+
+// Options configures an SPI device.
+type Options struct {
+	SpeedHz uint32 // Clock speed.
+	Mode    uint8  // SPI mode, 0 to 3.
+}
+
+// Port is the part of an SPI device needed to send data out of its MOSI pin.
+type Port interface {
+	io.Writer // Each Write is a single transfer.
+
+	// MaxTransfer returns the size of the largest transfer Write supports.
+	MaxTransfer() int
+}
+
+// Conn is an SPI device that also supports full-duplex transfers.
+type Conn interface {
+	Port
+
+	// Tx sends w while reading the same number of bytes into r, in a single
+	// transfer. r may be nil if the response isn't needed.
+	Tx(w, r []byte) error
+}
+
+// maxTransfer reads the spidev driver's transfer size limit.
+func maxTransfer() int {
+	data, err := ioutil.ReadFile(bufsizPath)
+	if err != nil {
+		return DefaultMaxTransfer
+	}
+	return parseBufsiz(string(data))
+}
+
+// parseBufsiz parses the contents of the spidev bufsiz parameter, falling
+// back to DefaultMaxTransfer if it is invalid.
+func parseBufsiz(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return DefaultMaxTransfer
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package spidev
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Request codes for the spidev ioctls, from linux/spi/spidev.h.
+const (
+	spiIOCWrMode        = 0x40016b01 // _IOW('k', 1, __u8)
+	spiIOCWrBitsPerWord = 0x40016b03 // _IOW('k', 3, __u8)
+	spiIOCWrMaxSpeedHz  = 0x40046b04 // _IOW('k', 4, __u32)
+	spiIOCMessage1      = 0x40206b00 // _IOW('k', 0, char[sizeof(struct spi_ioc_transfer)])
+)
+
+// spiIOCTransfer mirrors struct spi_ioc_transfer.
+type spiIOCTransfer struct {
+	txBuf          uint64
+	rxBuf          uint64
+	len            uint32
+	speedHz        uint32
+	delayUsecs     uint16
+	bitsPerWord    uint8
+	csChange       uint8
+	txNbits        uint8
+	rxNbits        uint8
+	wordDelayUsecs uint8
+	pad            uint8
+}
+
+// Device is an open spidev device.
+type Device struct {
+	f           *os.File
+	speedHz     uint32
+	maxTransfer int
+}
+
+var _ Conn = (*Device)(nil)
+
+// Open opens and configures an spidev device, such as /dev/spidev0.0.
+func Open(path string, opts Options) (*Device, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{f: f, speedHz: opts.SpeedHz, maxTransfer: maxTransfer()}
+	mode, bitsPerWord := opts.Mode, uint8(8)
+	for _, s := range []struct {
+		name string
+		req  uintptr
+		arg  unsafe.Pointer
+	}{
+		{"mode", spiIOCWrMode, unsafe.Pointer(&mode)},
+		{"bits per word", spiIOCWrBitsPerWord, unsafe.Pointer(&bitsPerWord)},
+		{"speed", spiIOCWrMaxSpeedHz, unsafe.Pointer(&d.speedHz)},
+	} {
+		if err := d.ioctl(s.req, s.arg); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: cannot set SPI %s: %v", path, s.name, err)
+		}
+	}
+	return d, nil
+}
+
+// Write sends p in a single transfer.
+func (d *Device) Write(p []byte) (int, error) {
+	if len(p) > d.maxTransfer {
+		return 0, fmt.Errorf("SPI transfer of %d bytes exceeds the driver limit of %d; see %s", len(p), d.maxTransfer, bufsizPath)
+	}
+	return d.f.Write(p)
+}
+
+// Tx sends w while reading the same number of bytes into r, in a single
+// transfer. r may be nil if the response isn't needed.
+func (d *Device) Tx(w, r []byte) error {
+	if r != nil && len(r) != len(w) {
+		return fmt.Errorf("SPI read buffer is %d bytes; want %d", len(r), len(w))
+	}
+	if len(w) > d.maxTransfer {
+		return fmt.Errorf("SPI transfer of %d bytes exceeds the driver limit of %d; see %s", len(w), d.maxTransfer, bufsizPath)
+	}
+	if len(w) == 0 {
+		return nil
+	}
+	tr := spiIOCTransfer{
+		txBuf:       uint64(uintptr(unsafe.Pointer(&w[0]))),
+		len:         uint32(len(w)),
+		speedHz:     d.speedHz,
+		bitsPerWord: 8,
+	}
+	if r != nil {
+		tr.rxBuf = uint64(uintptr(unsafe.Pointer(&r[0])))
+	}
+	return d.ioctl(spiIOCMessage1, unsafe.Pointer(&tr))
+}
+
+// MaxTransfer returns the size of the largest transfer the driver supports.
+func (d *Device) MaxTransfer() int {
+	return d.maxTransfer
+}
+
+// Close closes the device.
+func (d *Device) Close() error {
+	return d.f.Close()
+}
+
+func (d *Device) ioctl(req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, d.f.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
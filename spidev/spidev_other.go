@@ -0,0 +1,37 @@
+//go:build !linux
+// +build !linux
+
+package spidev
+
+import "fmt"
+
+// Device is an open spidev device.
+type Device struct{}
+
+var _ Conn = (*Device)(nil)
+
+// Open reports that spidev devices are only supported on Linux.
+func Open(path string, opts Options) (*Device, error) {
+	return nil, fmt.Errorf("spidev devices are only supported on Linux")
+}
+
+// Write sends p in a single transfer.
+func (d *Device) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("spidev devices are only supported on Linux")
+}
+
+// Tx sends w while reading the same number of bytes into r, in a single
+// transfer.
+func (d *Device) Tx(w, r []byte) error {
+	return fmt.Errorf("spidev devices are only supported on Linux")
+}
+
+// MaxTransfer returns the size of the largest transfer the driver supports.
+func (d *Device) MaxTransfer() int {
+	return DefaultMaxTransfer
+}
+
+// Close closes the device.
+func (d *Device) Close() error {
+	return nil
+}
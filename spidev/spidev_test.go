@@ -0,0 +1,30 @@
+package spidev_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zellyn/openers/spidev"
+)
+
+func TestOpenNotSPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spidev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spidev0.0")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err := spidev.Open(path, spidev.Options{SpeedHz: 100000}); err == nil {
+		d.Close()
+		t.Errorf("want error opening a regular file as an SPI device")
+	}
+	if _, err := spidev.Open(filepath.Join(dir, "missing"), spidev.Options{}); err == nil {
+		t.Errorf("want error opening a missing device")
+	}
+}
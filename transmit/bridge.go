@@ -0,0 +1,37 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/zellyn/openers/bridge"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Bridge hands transmissions to a microcontroller over a serial line, which
+// plays them with its own hardware timers.
+type Bridge struct {
+	Client *bridge.Client
+	Log    io.Writer // If not nil, progress messages are written here.
+}
+
+var _ Transmitter = (*Bridge)(nil)
+
+// Transmit sends a transmission, waiting for the device to report that it
+// has finished. The device can't be interrupted, so cancellation only stops
+// the wait.
+func (b *Bridge) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	t := bridge.FromTransmission(tx)
+	if b.Log != nil {
+		fmt.Fprintf(b.Log, "Sending %d pulses %d times via the serial bridge\n", len(t.Pulses), t.Repeats)
+	}
+	elapsed, err := b.Client.Transmit(ctx, t)
+	if err != nil {
+		return err
+	}
+	if b.Log != nil {
+		fmt.Fprintf(b.Log, "Bridge device took %v (expected %v)\n", elapsed, t.Duration())
+	}
+	return nil
+}
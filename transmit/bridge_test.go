@@ -0,0 +1,42 @@
+package transmit_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/bridge"
+	"github.com/zellyn/openers/bridge/bridgetest"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestBridge(t *testing.T) {
+	host, device := net.Pipe()
+	d := &bridgetest.Device{}
+	go d.Serve(device)
+	client := bridge.NewClient(host)
+	defer client.Close()
+
+	tx := &waveform.Transmission{
+		Bursts:    []waveform.Burst{{Bits: bits.B("1101"), Pulsewidth: 250 * time.Microsecond}},
+		Repeats:   3,
+		RepeatGap: 10 * time.Millisecond,
+	}
+	b := &transmit.Bridge{Client: client}
+	if err := b.Transmit(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	sent := d.Transmissions()
+	if len(sent) != 1 {
+		t.Fatalf("want 1 transmission; got %d", len(sent))
+	}
+	if sent[0].Repeats != 3 || sent[0].RepeatGap != 10*time.Millisecond {
+		t.Errorf("want 3 repeats 10ms apart; got %d repeats %v apart", sent[0].Repeats, sent[0].RepeatGap)
+	}
+	if got := waveform.ToBits(sent[0].Pulses, 250*time.Microsecond); bits.S(got) != "1101" {
+		t.Errorf("want pulses for 1101; got %s", bits.S(got))
+	}
+}
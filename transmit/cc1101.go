@@ -0,0 +1,68 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zellyn/openers/cc1101"
+	"github.com/zellyn/openers/waveform"
+)
+
+// CC1101 transmits directly with a CC1101 radio, rather than keying a
+// fixed-frequency transmitter module.
+type CC1101 struct {
+	Radio     *cc1101.Radio
+	Frequency float64 // Carrier frequency in Hz.
+	Power     int     // Output power in dBm; see cc1101.Powers.
+
+	// Async, if not nil, drives the radio's GDO0 pin, such as a GPIO
+	// transmitter on the pin wired to it. Otherwise, transmissions are sent
+	// through the radio's FIFO, at one bit per pulse.
+	Async Transmitter
+
+	Log io.Writer // If not nil, progress messages are written here.
+}
+
+var _ Transmitter = (*CC1101)(nil)
+
+// Transmit configures the radio and sends a transmission, leaving the radio
+// idle afterwards.
+func (c *CC1101) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	cfg := cc1101.Config{Frequency: c.Frequency, Power: c.Power}
+	if c.Async != nil {
+		cfg.Mode = cc1101.Async
+		if err := c.Radio.Configure(cfg); err != nil {
+			return err
+		}
+		c.logf("Sending at %gMHz, %ddBm, keyed through GDO0\n", c.Frequency/1e6, c.Power)
+		if err := c.Radio.StartTx(); err != nil {
+			return err
+		}
+		err := c.Async.Transmit(ctx, tx)
+		if idleErr := c.Radio.Idle(); err == nil {
+			err = idleErr
+		}
+		return err
+	}
+
+	cfg.Mode = cc1101.FIFO
+	cfg.DataRate = float64(time.Second) / float64(tx.Bursts[0].Pulsewidth)
+	if err := c.Radio.Configure(cfg); err != nil {
+		return err
+	}
+	rate, err := cc1101.DataRate(cfg.DataRate)
+	if err != nil {
+		return err
+	}
+	stream := Bitstream(tx.Pulses(), rate)
+	c.logf("Sending at %gMHz, %ddBm: %d bytes at %.1fbps through the FIFO\n", c.Frequency/1e6, c.Power, len(stream), rate)
+	return c.Radio.SendFIFO(ctx, stream)
+}
+
+func (c *CC1101) logf(format string, args ...interface{}) {
+	if c.Log != nil {
+		fmt.Fprintf(c.Log, format, args...)
+	}
+}
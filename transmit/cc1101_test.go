@@ -0,0 +1,69 @@
+package transmit_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/cc1101"
+	"github.com/zellyn/openers/cc1101/cc1101test"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// fakeTransmitter records the transmissions it is asked to send.
+type fakeTransmitter struct {
+	sent []*waveform.Transmission
+}
+
+func (f *fakeTransmitter) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	f.sent = append(f.sent, tx)
+	return nil
+}
+
+func TestCC1101(t *testing.T) {
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("1011"), Pulsewidth: 10 * time.Microsecond, Gap: time.Millisecond},
+			{Bits: bits.B("1101"), Pulsewidth: 10 * time.Microsecond},
+		},
+		Repeats:   2,
+		RepeatGap: time.Millisecond,
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		chip := cc1101test.New()
+		c := &transmit.CC1101{Radio: &cc1101.Radio{Conn: chip}, Frequency: 310e6, Power: 10}
+		if err := c.Transmit(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+		if chip.Register(cc1101.PKTCTRL0) != 0x02 {
+			t.Errorf("want FIFO mode")
+		}
+		rate, _ := cc1101.DataRate(100e3)
+		if got, want := chip.Sent(), transmit.Bitstream(tx.Pulses(), rate); !bytes.Equal(got, want) {
+			t.Errorf("want bitstream % x sent; got % x", want, got)
+		}
+	})
+
+	t.Run("async", func(t *testing.T) {
+		chip := cc1101test.New()
+		gdo0 := &fakeTransmitter{}
+		c := &transmit.CC1101{Radio: &cc1101.Radio{Conn: chip}, Frequency: 390e6, Power: 10, Async: gdo0}
+		if err := c.Transmit(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+		if chip.Register(cc1101.PKTCTRL0) != 0x32 {
+			t.Errorf("want asynchronous serial mode")
+		}
+		if len(gdo0.sent) != 1 || gdo0.sent[0] != tx {
+			t.Errorf("want transmission sent through GDO0")
+		}
+		want := []byte{cc1101.SRES, cc1101.STX, cc1101.SIDLE, cc1101.SFTX}
+		if got := chip.Strobes(); !bytes.Equal(got, want) {
+			t.Errorf("want strobes % x; got % x", want, got)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+/*
+Package transmit sends transmissions described by package waveform.
+
+The GPIO transmitter keys a simple transmitter module connected to a GPIO
+pin, busy-waiting between pin changes to keep the timing accurate. For best
+results, run it with real-time scheduling priority.
+
+The SPI transmitter instead renders the whole transmission into a bitstream,
+and sends it out of the MOSI pin of an SPI device, letting the SPI hardware
+provide the timing.
+
+The Pigpio transmitter hands the transmission to pigpiod, the pigpio daemon,
+which plays it on a GPIO pin with DMA timing.
+
+Transmissions can be cancelled through their context: transmitters stop
+between pulses, and leave the transmitter unkeyed. A Watchdog around a GPIO
+line also forces it low if it is left high for too long.
+*/
+package transmit
@@ -0,0 +1,136 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zellyn/openers/pigpio"
+	"github.com/zellyn/openers/waveform"
+)
+
+// pigpioPoll is how often the pigpio transmitter checks whether pigpiod has
+// finished sending.
+const pigpioPoll = 10 * time.Millisecond
+
+// Pigpio keys a transmitter connected to a GPIO pin, by having pigpiod play the
+// transmission as a chain of DMA-timed waves: one per burst, with the gaps and
+// repeats expressed in the chain.
+type Pigpio struct {
+	Client *pigpio.Client
+	GPIO   int
+	Log    io.Writer // If not nil, progress messages are written here.
+}
+
+var _ Transmitter = (*Pigpio)(nil)
+
+// Transmit sends a transmission, waiting for pigpiod to finish, and leaving
+// the pin low afterwards. If the context is cancelled, pigpiod is told to stop.
+func (p *Pigpio) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	if err := p.Client.SetMode(p.GPIO, pigpio.Output); err != nil {
+		return err
+	}
+	if err := p.Client.Write(p.GPIO, 0); err != nil {
+		return err
+	}
+
+	var ids []int
+	defer func() {
+		for _, id := range ids {
+			p.Client.WaveDelete(id)
+		}
+	}()
+	for _, burst := range tx.Bursts {
+		if err := p.Client.WaveAddNew(); err != nil {
+			return err
+		}
+		if _, err := p.Client.WaveAddGeneric(WavePulses(burst, p.GPIO)); err != nil {
+			return err
+		}
+		id, err := p.Client.WaveCreate()
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	chain, err := Chain(tx, ids)
+	if err != nil {
+		return err
+	}
+	if p.Log != nil {
+		fmt.Fprintf(p.Log, "Sending %d waves %d times via pigpiod\n", len(ids), tx.Repeats)
+	}
+	if err := p.Client.WaveChain(chain); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(tx.Duration() + time.Second)
+	for {
+		if err := Sleep(ctx, pigpioPoll); err != nil {
+			p.Client.WaveTxStop()
+			p.Client.Write(p.GPIO, 0)
+			return err
+		}
+		busy, err := p.Client.WaveTxBusy()
+		if err != nil {
+			return err
+		}
+		if !busy {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			p.Client.WaveTxStop()
+			p.Client.Write(p.GPIO, 0)
+			return fmt.Errorf("pigpiod was still transmitting after %v; stopped it", tx.Duration()+time.Second)
+		}
+	}
+}
+
+// WavePulses converts a burst to pigpio pulses switching a single GPIO,
+// ending with it switched off.
+func WavePulses(burst waveform.Burst, gpio int) []pigpio.Pulse {
+	mask := uint32(1) << uint(gpio)
+	var result []pigpio.Pulse
+	level := byte(0)
+	for _, p := range waveform.FromBits(burst.Bits, burst.Pulsewidth) {
+		pulse := pigpio.Pulse{Off: mask, Delay: uint32(p.Duration.Round(time.Microsecond) / time.Microsecond)}
+		if p.Level == 1 {
+			pulse.On, pulse.Off = mask, 0
+		}
+		result = append(result, pulse)
+		level = p.Level
+	}
+	if level == 1 {
+		result = append(result, pigpio.Pulse{Off: mask})
+	}
+	return result
+}
+
+// Chain builds the pigpio wave chain sending a whole transmission, given the
+// IDs of the waves for each of its bursts.
+func Chain(tx *waveform.Transmission, ids []int) ([]byte, error) {
+	if len(ids) != len(tx.Bursts) {
+		return nil, fmt.Errorf("want %d wave IDs, one per burst; got %d", len(tx.Bursts), len(ids))
+	}
+	message := func(b *pigpio.ChainBuilder) {
+		for i, id := range ids {
+			b.Wave(id)
+			if i+1 < len(ids) {
+				b.Delay(tx.Bursts[i].Gap)
+			}
+		}
+	}
+	b := &pigpio.ChainBuilder{}
+	if tx.Repeats > 1 {
+		b.Loop(tx.Repeats-1, func(b *pigpio.ChainBuilder) {
+			message(b)
+			b.Delay(tx.RepeatGap)
+		})
+	}
+	if tx.Repeats > 0 {
+		message(b)
+	}
+	return b.Bytes()
+}
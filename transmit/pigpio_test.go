@@ -0,0 +1,106 @@
+package transmit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/pigpio"
+	"github.com/zellyn/openers/pigpio/pigpiotest"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestPigpio(t *testing.T) {
+	server, err := pigpiotest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := pigpio.Dial(server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("0110"), Pulsewidth: 250 * time.Microsecond, Gap: 90 * time.Millisecond},
+			{Bits: bits.B("101"), Pulsewidth: 250 * time.Microsecond},
+		},
+		Repeats:   3,
+		RepeatGap: 80 * time.Millisecond,
+	}
+	p := &transmit.Pigpio{Client: client, GPIO: 12}
+	if err := p.Transmit(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The waves are deleted after transmitting, so collect them from the
+	// requests.
+	var waves [][]pigpio.Pulse
+	for _, req := range server.Requests() {
+		if req.Cmd == pigpio.CmdWVAG {
+			var pulses []pigpio.Pulse
+			for i := 0; i < len(req.Ext); i += 12 {
+				pulses = append(pulses, pigpio.Pulse{
+					On:    binary.LittleEndian.Uint32(req.Ext[i:]),
+					Off:   binary.LittleEndian.Uint32(req.Ext[i+4:]),
+					Delay: binary.LittleEndian.Uint32(req.Ext[i+8:]),
+				})
+			}
+			waves = append(waves, pulses)
+		}
+	}
+	const pin = 1 << 12
+	wantWaves := [][]pigpio.Pulse{
+		{{Off: pin, Delay: 250}, {On: pin, Delay: 500}, {Off: pin, Delay: 250}},
+		{{On: pin, Delay: 250}, {Off: pin, Delay: 250}, {On: pin, Delay: 250}, {Off: pin}},
+	}
+	if !reflect.DeepEqual(waves, wantWaves) {
+		t.Errorf("want waves %v; got %v", wantWaves, waves)
+	}
+
+	wantChain, err := transmit.Chain(tx, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chains := server.Chains(); len(chains) != 1 || !bytes.Equal(chains[0], wantChain) {
+		t.Errorf("want chain %v; got %v", wantChain, chains)
+	}
+	if waves := server.Waves(); len(waves) != 0 {
+		t.Errorf("want waves deleted after transmitting; %d left", len(waves))
+	}
+}
+
+func TestChain(t *testing.T) {
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("1"), Pulsewidth: time.Millisecond, Gap: 2 * time.Millisecond},
+			{Bits: bits.B("1"), Pulsewidth: time.Millisecond},
+		},
+		Repeats:   4,
+		RepeatGap: 3 * time.Millisecond,
+	}
+	got, err := transmit.Chain(tx, []int{5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		255, 0, 5, 255, 2, 0xd0, 0x07, 6, 255, 2, 0xb8, 0x0b, 255, 1, 3, 0,
+		5, 255, 2, 0xd0, 0x07, 6,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("want chain %v; got %v", want, got)
+	}
+
+	tx.Repeats = 1
+	got, err = transmit.Chain(tx, []int{5, 6})
+	if want := []byte{5, 255, 2, 0xd0, 0x07, 6}; err != nil || !bytes.Equal(got, want) {
+		t.Errorf("want chain %v for a single repeat; got %v, %v", want, got, err)
+	}
+}
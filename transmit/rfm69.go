@@ -0,0 +1,43 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/waveform"
+)
+
+// RFM69 transmits with an RFM69 radio module in continuous mode, keying the
+// carrier through its DIO2 pin.
+type RFM69 struct {
+	Radio *rfm69.Radio // Already configured.
+	Data  Transmitter  // Drives DIO2, such as a GPIO transmitter on the pin wired to it.
+	Log   io.Writer    // If not nil, progress messages are written here.
+}
+
+var (
+	_ Transmitter = (*RFM69)(nil)
+	_ Tuner       = (*RFM69)(nil)
+)
+
+// Transmit sends a transmission, leaving the radio in standby afterwards.
+func (r *RFM69) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	if r.Log != nil {
+		fmt.Fprintf(r.Log, "Sending with the RFM69, keyed through DIO2\n")
+	}
+	if err := r.Radio.SetMode(rfm69.TX); err != nil {
+		return err
+	}
+	err := r.Data.Transmit(ctx, tx)
+	if standbyErr := r.Radio.SetMode(rfm69.Standby); err == nil {
+		err = standbyErr
+	}
+	return err
+}
+
+// Tune changes the carrier frequency.
+func (r *RFM69) Tune(frequency float64) error {
+	return r.Radio.Tune(frequency)
+}
@@ -0,0 +1,43 @@
+package transmit_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/rfm69"
+	"github.com/zellyn/openers/rfm69/rfm69test"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestRFM69(t *testing.T) {
+	module := rfm69test.New()
+	radio := &rfm69.Radio{Conn: module}
+	if err := radio.Configure(rfm69.Config{Frequency: 315e6, Bitrate: 4000, Power: 13, HighPower: true}); err != nil {
+		t.Fatal(err)
+	}
+	dio2 := &fakeTransmitter{}
+	r := &transmit.RFM69{Radio: radio, Data: dio2}
+	tx := &waveform.Transmission{
+		Bursts:  []waveform.Burst{{Bits: bits.B("101"), Pulsewidth: 250 * time.Microsecond}},
+		Repeats: 1,
+	}
+	if err := r.Tune(390e6); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Transmit(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(dio2.sent) != 1 || dio2.sent[0] != tx {
+		t.Errorf("want transmission sent through DIO2")
+	}
+	if want := []rfm69.Mode{rfm69.Standby, rfm69.TX, rfm69.Standby}; !reflect.DeepEqual(module.Modes(), want) {
+		t.Errorf("want modes %v; got %v", want, module.Modes())
+	}
+	if got := module.Register(rfm69.RegFrfMsb); got != 0x61 {
+		t.Errorf("want radio tuned to 390MHz; got RegFrfMsb=0x%02x", got)
+	}
+}
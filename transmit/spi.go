@@ -0,0 +1,106 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/zellyn/openers/spidev"
+	"github.com/zellyn/openers/waveform"
+)
+
+// SPIRate is the sample clock the SPI transmitter aims for: fast enough to
+// place edges within 10µs, and slow enough to keep bitstreams small and well
+// within the range of Raspberry Pi SPI clocks.
+const SPIRate = 100e3
+
+// SPIClock chooses an SPI clock speed close to SPIRate that gives a whole
+// number of samples per pulse of pulsewidth, so that edges land exactly.
+func SPIClock(pulsewidth time.Duration) uint32 {
+	samples := math.Round(pulsewidth.Seconds() * SPIRate)
+	if samples < 1 {
+		samples = 1
+	}
+	return uint32(math.Round(samples / pulsewidth.Seconds()))
+}
+
+// SPI keys a transmitter connected to the MOSI pin of an SPI device. The
+// whole transmission, gaps included, is rendered into a bitstream at the
+// device's clock speed, so the SPI hardware provides the timing.
+type SPI struct {
+	Port    spidev.Port
+	SpeedHz uint32    // Clock speed the port was opened with; see SPIClock.
+	Log     io.Writer // If not nil, progress messages are written here.
+}
+
+var _ Transmitter = (*SPI)(nil)
+
+// Transmit sends a transmission, leaving MOSI low afterwards. Cancellation
+// takes effect between transfers, which always end low.
+func (s *SPI) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	stream := Bitstream(tx.Pulses(), float64(s.SpeedHz))
+	chunks, err := Chunk(stream, s.Port.MaxTransfer())
+	if err != nil {
+		return err
+	}
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "Sending %d bytes at %dHz in %d transfers\n", len(stream), s.SpeedHz, len(chunks))
+	}
+	for _, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := s.Port.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bitstream renders pulses at rate samples per second, and packs the samples
+// into bytes, most significant bit first, the order SPI sends them in. The
+// stream always ends with a zero byte, so the line is left low.
+func Bitstream(pulses []waveform.Pulse, rate float64) []byte {
+	samples := waveform.Render(pulses, rate)
+	result := make([]byte, len(samples)/8+1)
+	for i, s := range samples {
+		result[i/8] |= (s & 1) << (7 - i%8)
+	}
+	if result[len(result)-1] != 0 {
+		result = append(result, 0)
+	}
+	return result
+}
+
+// Chunk splits a bitstream into transfers of at most max bytes. Each split is
+// made in the middle of the longest silence (run of zero bytes) available,
+// so that the short pause between transfers only stretches a gap. It returns
+// an error if some stretch of max bytes has no silence to split at.
+func Chunk(stream []byte, max int) ([][]byte, error) {
+	var result [][]byte
+	for len(stream) > max {
+		bestStart, bestLen := 0, 0
+		for i := 0; i < max; {
+			if stream[i] != 0 {
+				i++
+				continue
+			}
+			start := i
+			for i < max && stream[i] == 0 {
+				i++
+			}
+			if i-start > bestLen {
+				bestStart, bestLen = start, i-start
+			}
+		}
+		if bestLen == 0 {
+			return nil, fmt.Errorf("cannot split bitstream into %d-byte transfers: no silence to split at", max)
+		}
+		cut := bestStart + (bestLen+1)/2
+		result = append(result, stream[:cut])
+		stream = stream[cut:]
+	}
+	return append(result, stream), nil
+}
@@ -0,0 +1,120 @@
+package transmit_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/megacode"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// fakePort records the transfers written to it.
+type fakePort struct {
+	max       int
+	transfers [][]byte
+}
+
+func (f *fakePort) Write(p []byte) (int, error) {
+	if len(p) > f.max {
+		return 0, fmt.Errorf("transfer of %d bytes exceeds %d", len(p), f.max)
+	}
+	f.transfers = append(f.transfers, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (f *fakePort) MaxTransfer() int {
+	return f.max
+}
+
+func TestSPIClock(t *testing.T) {
+	testcases := []struct {
+		pulsewidth time.Duration
+		want       uint32
+	}{
+		{pulsewidth: 250 * time.Microsecond, want: 100000},
+		{pulsewidth: time.Millisecond, want: 100000},
+		{pulsewidth: 333 * time.Microsecond, want: 99099},
+		{pulsewidth: time.Microsecond, want: 1000000},
+	}
+	for _, tt := range testcases {
+		if got := transmit.SPIClock(tt.pulsewidth); got != tt.want {
+			t.Errorf("want SPIClock(%v)==%d; got %d", tt.pulsewidth, tt.want, got)
+		}
+	}
+}
+
+func TestBitstream(t *testing.T) {
+	pulses := []waveform.Pulse{
+		{Level: 1, Duration: 3 * time.Millisecond},
+		{Level: 0, Duration: 2 * time.Millisecond},
+		{Level: 1, Duration: 6 * time.Millisecond},
+	}
+	got := transmit.Bitstream(pulses, 1000)
+	want := []byte{0xe7, 0xe0, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("want %x; got %x", want, got)
+	}
+	got = transmit.Bitstream(pulses[:1], 8000/3.0)
+	want = []byte{0xff, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("want %x; got %x", want, got)
+	}
+}
+
+func TestSPI(t *testing.T) {
+	fixed := uint64(70678577664)
+	secplusTx, err := secplus.TransmissionV2(0, fixed, 240124710, secplus.TimingV2{
+		Pulsewidth: 250 * time.Microsecond,
+		Burstgap:   90 * time.Millisecond,
+		Repeatgap:  90 * time.Millisecond,
+		Repeats:    4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	megacodeTx, err := megacode.Transmission(0x876543, time.Millisecond, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tx := range []*waveform.Transmission{secplusTx, megacodeTx} {
+		t.Run(tx.Protocol, func(t *testing.T) {
+			speed := transmit.SPIClock(tx.Bursts[0].Pulsewidth)
+			port := &fakePort{max: 4096}
+			s := &transmit.SPI{Port: port, SpeedHz: speed}
+			if err := s.Transmit(context.Background(), tx); err != nil {
+				t.Fatal(err)
+			}
+			if len(port.transfers) < 2 {
+				t.Errorf("want transmission to be chunked; got %d transfers", len(port.transfers))
+			}
+			sent := bytes.Join(port.transfers, nil)
+			want := transmit.Bitstream(tx.Pulses(), float64(speed))
+			if !bytes.Equal(sent, want) {
+				t.Fatalf("want the whole bitstream sent")
+			}
+			// Every split must fall in a silence.
+			for i, tr := range port.transfers[:len(port.transfers)-1] {
+				if tr[len(tr)-1] != 0 || port.transfers[i+1][0] != 0 {
+					t.Errorf("transfer %d was split outside a silence", i)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkNoSilence(t *testing.T) {
+	stream := bytes.Repeat([]byte{0xaa}, 100)
+	if _, err := transmit.Chunk(stream, 10); err == nil {
+		t.Errorf("want error chunking a stream with no silence")
+	}
+	chunks, err := transmit.Chunk(stream, 100)
+	if err != nil || len(chunks) != 1 {
+		t.Errorf("want a stream that fits to be a single chunk; got %d chunks, %v", len(chunks), err)
+	}
+}
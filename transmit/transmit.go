@@ -0,0 +1,133 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/jitter"
+	"github.com/zellyn/openers/waveform"
+)
+
+// Transmitter sends transmissions. If the context is cancelled, transmitters
+// stop as soon as they safely can, unkey the transmitter, and return the
+// context's error.
+type Transmitter interface {
+	Transmit(ctx context.Context, tx *waveform.Transmission) error
+}
+
+// Line is the part of a GPIO line needed to key a transmitter.
+type Line interface {
+	SetValue(value int) error
+}
+
+// GPIO keys a transmitter connected to a GPIO line.
+type GPIO struct {
+	Line   Line
+	Log    io.Writer        // If not nil, progress messages are written here.
+	Jitter *jitter.Recorder // If not nil, the timing of every pin change is recorded here.
+}
+
+var _ Transmitter = (*GPIO)(nil)
+
+// Transmit sends a transmission, leaving the line low afterwards, even if it
+// fails or is cancelled between pulses. Garbage collection is disabled while
+// transmitting, and restored afterwards.
+func (g *GPIO) Transmit(ctx context.Context, tx *waveform.Transmission) (err error) {
+	g.Jitter.Reset(tx.Changes())
+	runtime.GC()
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	defer func() {
+		if err != nil {
+			g.Line.SetValue(0)
+		}
+	}()
+
+	for i := 0; i < tx.Repeats; i++ {
+		if i > 0 {
+			if err := Sleep(ctx, tx.RepeatGap); err != nil {
+				return err
+			}
+		}
+
+		g.logf("Sending repetition %d/%d\n", i+1, tx.Repeats)
+
+		// Each repetition starts afresh, but within a repetition, bursts are
+		// timed from the end of the previous one.
+		var end time.Time
+		for j, burst := range tx.Bursts {
+			// Collect and log now, in the gap before the burst, while it's
+			// safe to pause, then busy-wait for the burst to start on time.
+			runtime.GC()
+			g.logf("    sending: %s\n", bits.S(burst.Bits))
+
+			start := time.Now()
+			if j > 0 {
+				start = end.Add(tx.Bursts[j-1].Gap)
+				busyWait(start)
+			}
+			var err error
+			if end, err = g.sendBurst(ctx, burst, start); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendBurst sends a single burst starting at start, busy-waiting between bits.
+// It returns the time the burst was meant to end.
+func (g *GPIO) sendBurst(ctx context.Context, burst waveform.Burst, start time.Time) (time.Time, error) {
+	target := start
+	for _, bit := range burst.Bits {
+		if err := ctx.Err(); err != nil {
+			return target, err
+		}
+		if err := g.Line.SetValue(int(bit)); err != nil {
+			return target, err
+		}
+		g.Jitter.Record(target, time.Now())
+		target = target.Add(burst.Pulsewidth)
+		if !busyWait(target) {
+			g.Jitter.Overrun()
+		}
+	}
+	err := g.Line.SetValue(0)
+	g.Jitter.Record(target, time.Now())
+	return target, err
+}
+
+func (g *GPIO) logf(format string, args ...interface{}) {
+	if g.Log != nil {
+		fmt.Fprintf(g.Log, format, args...)
+	}
+}
+
+// Sleep waits for d, or until ctx is cancelled, returning the context's error
+// in that case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// busyWait spins until target. Sleeping is too imprecise for pulse timing. It
+// returns false if target had already passed.
+func busyWait(target time.Time) bool {
+	if !target.After(time.Now()) {
+		return false
+	}
+	for target.After(time.Now()) {
+	}
+	return true
+}
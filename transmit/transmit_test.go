@@ -0,0 +1,77 @@
+package transmit_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/jitter"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+// fakeLine records the values set on it, and when.
+type fakeLine struct {
+	start  time.Time
+	values []int
+	times  []time.Duration
+}
+
+func (f *fakeLine) SetValue(value int) error {
+	f.values = append(f.values, value)
+	f.times = append(f.times, time.Since(f.start))
+	return nil
+}
+
+func TestGPIO(t *testing.T) {
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("101"), Pulsewidth: time.Millisecond, Gap: 5 * time.Millisecond},
+			{Bits: bits.B("01"), Pulsewidth: time.Millisecond},
+		},
+		Repeats:   2,
+		RepeatGap: 10 * time.Millisecond,
+	}
+	line := &fakeLine{start: time.Now()}
+	recorder := &jitter.Recorder{}
+	g := &transmit.GPIO{Line: line, Jitter: recorder}
+	if err := g.Transmit(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{1, 0, 1, 0, 0, 1, 0, 1, 0, 1, 0, 0, 1, 0}
+	if !reflect.DeepEqual(line.values, want) {
+		t.Errorf("want values %v; got %v", want, line.values)
+	}
+	if got := len(recorder.Deviations()); got != len(want) {
+		t.Errorf("want %d timings recorded; got %d", len(want), got)
+	}
+	// Timing can't be checked precisely in a test, but nothing should be
+	// early.
+	if got, min := line.times[len(line.times)-1], tx.Duration(); got < min {
+		t.Errorf("want transmission to take at least %v; took %v", min, got)
+	}
+}
+
+func TestGPIOCancel(t *testing.T) {
+	tx := &waveform.Transmission{
+		Bursts:    []waveform.Burst{{Bits: bits.B("1111111111"), Pulsewidth: time.Millisecond}},
+		Repeats:   100,
+		RepeatGap: 10 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	line := &fakeLine{start: time.Now()}
+	g := &transmit.GPIO{Line: line}
+	if err := g.Transmit(ctx, tx); err != context.DeadlineExceeded {
+		t.Errorf("want %v; got %v", context.DeadlineExceeded, err)
+	}
+	if got := line.values[len(line.values)-1]; got != 0 {
+		t.Errorf("want line left low; got %d", got)
+	}
+	if got := line.times[len(line.times)-1]; got > 100*time.Millisecond {
+		t.Errorf("want transmission to stop promptly; took %v", got)
+	}
+}
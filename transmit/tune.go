@@ -0,0 +1,76 @@
+package transmit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// Tuner is implemented by transmitters, or extra hardware, that can change the
+// carrier frequency between transmissions.
+type Tuner interface {
+	Tune(frequency float64) error
+}
+
+// BandSelect switches a multi-band transmitter module between its bands with
+// GPIO lines.
+type BandSelect struct {
+	Lines []Line
+	// Bands maps each frequency, in whole Hz, to the value of the lines
+	// selecting it: bit i drives Lines[i].
+	Bands map[int64]int
+}
+
+var _ Tuner = (*BandSelect)(nil)
+
+// Tune selects the band for frequency, rounded to the nearest Hz, so that
+// frequencies worked out in different ways, such as from MHz in a
+// configuration file and from a flag, still match.
+func (b *BandSelect) Tune(frequency float64) error {
+	value, ok := b.Bands[int64(math.Round(frequency))]
+	if !ok {
+		return fmt.Errorf("no band select setting for %s", waveform.FormatFrequency(frequency))
+	}
+	for i, line := range b.Lines {
+		if err := line.SetValue((value >> uint(i)) & 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tune sets the frequency the radio transmits on.
+func (c *CC1101) Tune(frequency float64) error {
+	c.Frequency = frequency
+	return nil
+}
+
+var _ Tuner = (*CC1101)(nil)
+
+// DryRun describes transmissions instead of sending them.
+type DryRun struct {
+	Log io.Writer
+}
+
+var (
+	_ Transmitter = (*DryRun)(nil)
+	_ Tuner       = (*DryRun)(nil)
+)
+
+// Transmit describes a transmission.
+func (d *DryRun) Transmit(ctx context.Context, tx *waveform.Transmission) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(d.Log, "Would send %s\n", tx)
+	return err
+}
+
+// Tune describes a frequency change.
+func (d *DryRun) Tune(frequency float64) error {
+	_, err := fmt.Fprintf(d.Log, "Would tune to %s\n", waveform.FormatFrequency(frequency))
+	return err
+}
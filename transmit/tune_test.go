@@ -0,0 +1,58 @@
+package transmit_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/transmit"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestBandSelect(t *testing.T) {
+	low, high := &fakeLine{start: time.Now()}, &fakeLine{start: time.Now()}
+	b := &transmit.BandSelect{
+		Lines: []transmit.Line{low, high},
+		Bands: map[int64]int{310e6: 0, 315e6: 1, 390e6: 2},
+	}
+	// Frequencies a fraction of a Hz out, from floating-point arithmetic,
+	// still select their bands.
+	for _, f := range []float64{390e6, 315e6 + 0.25, 310e6 - 1e-7} {
+		if err := b.Tune(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []int{0, 1, 0}; !reflect.DeepEqual(low.values, want) {
+		t.Errorf("want low line values %v; got %v", want, low.values)
+	}
+	if want := []int{1, 0, 0}; !reflect.DeepEqual(high.values, want) {
+		t.Errorf("want high line values %v; got %v", want, high.values)
+	}
+	if err := b.Tune(433.92e6); err == nil {
+		t.Errorf("want error tuning to an unknown band")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	d := &transmit.DryRun{Log: &buf}
+	tx := &waveform.Transmission{
+		Protocol:    "test",
+		Bursts:      []waveform.Burst{{Bits: bits.B("1"), Pulsewidth: time.Millisecond}},
+		Repeats:     1,
+		Frequencies: []float64{315e6},
+	}
+	if err := d.Tune(315e6); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Transmit(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	want := "Would tune to 315MHz\nWould send test: 1 bursts (1 bits at 1ms), 1 repeats 0s apart, lasting 1ms; on 315MHz, hopping every repeat\n"
+	if got := buf.String(); got != want {
+		t.Errorf("want output %q; got %q", want, got)
+	}
+}
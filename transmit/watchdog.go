@@ -0,0 +1,64 @@
+package transmit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Watchdog protects a transmitter from being left keyed: it wraps the line
+// keying it, and forces the line low if it stays high for longer than MaxOn,
+// whatever the code driving it is doing. Setting the line high again after
+// the watchdog has fired fails once, so that the transmission is abandoned.
+//
+// It can't help if the process is killed outright; SIGINT and SIGTERM should
+// be handled by cancelling the transmission instead.
+type Watchdog struct {
+	Line  Line
+	MaxOn time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	high    bool
+	armed   int // Incremented each time the line goes high, so stale firings can be ignored.
+	tripped bool
+}
+
+var _ Line = (*Watchdog)(nil)
+
+// SetValue sets the line, arming the watchdog when it goes high.
+func (w *Watchdog) SetValue(value int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if value == 0 {
+		if w.high {
+			w.high = false
+			w.timer.Stop()
+		}
+		return w.Line.SetValue(0)
+	}
+	if w.tripped {
+		w.tripped = false
+		return fmt.Errorf("watchdog forced the transmitter off after it was keyed for %v", w.MaxOn)
+	}
+	if !w.high {
+		w.high = true
+		w.armed++
+		armed := w.armed
+		w.timer = time.AfterFunc(w.MaxOn, func() { w.fire(armed) })
+	}
+	return w.Line.SetValue(value)
+}
+
+// fire forces the line low, unless it has gone low since the watchdog was
+// armed.
+func (w *Watchdog) fire(armed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.high || armed != w.armed {
+		return
+	}
+	w.high = false
+	w.tripped = true
+	w.Line.SetValue(0)
+}
@@ -0,0 +1,58 @@
+package transmit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/transmit"
+)
+
+// syncLine records the values set on it, from any goroutine.
+type syncLine struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (s *syncLine) SetValue(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = append(s.values, value)
+	return nil
+}
+
+func (s *syncLine) last() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[len(s.values)-1]
+}
+
+func TestWatchdog(t *testing.T) {
+	line := &syncLine{}
+	w := &transmit.Watchdog{Line: line, MaxOn: 20 * time.Millisecond}
+
+	// Short pulses, and repeated highs within one, never trip it.
+	for i := 0; i < 5; i++ {
+		w.SetValue(1)
+		w.SetValue(1)
+		time.Sleep(5 * time.Millisecond)
+		w.SetValue(0)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := w.SetValue(1); err != nil {
+		t.Fatalf("want short pulses to be allowed; got %v", err)
+	}
+
+	// Staying high does.
+	time.Sleep(40 * time.Millisecond)
+	if got := line.last(); got != 0 {
+		t.Errorf("want line forced low; got %d", got)
+	}
+	if err := w.SetValue(1); err == nil {
+		t.Errorf("want error keying the line after the watchdog fired")
+	}
+	if err := w.SetValue(1); err != nil {
+		t.Errorf("want the next transmission to be allowed; got %v", err)
+	}
+	w.SetValue(0)
+}
@@ -0,0 +1,8 @@
+/*
+Package vcd reads and writes pulses as Value Change Dump (VCD) files, the
+format used by most logic analyzer software, including PulseView.
+
+Only single-bit signals are supported, which is all a GPIO pin keying a
+transmitter needs.
+*/
+package vcd
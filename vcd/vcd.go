@@ -0,0 +1,216 @@
+package vcd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// identifier is the VCD identifier code used for the signal written by Write.
+const identifier = "!"
+
+// Write writes pulses to w as a VCD file with a single 1-bit signal, at
+// nanosecond resolution.
+func Write(w io.Writer, pulses []waveform.Pulse, signal string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$version openers $end\n")
+	fmt.Fprintf(bw, "$timescale 1ns $end\n")
+	fmt.Fprintf(bw, "$scope module openers $end\n")
+	fmt.Fprintf(bw, "$var wire 1 %s %s $end\n", identifier, signal)
+	fmt.Fprintf(bw, "$upscope $end\n")
+	fmt.Fprintf(bw, "$enddefinitions $end\n")
+
+	level := byte(0)
+	if len(pulses) > 0 {
+		level = pulses[0].Level & 1
+	}
+	fmt.Fprintf(bw, "#0\n$dumpvars\n%d%s\n$end\n", level, identifier)
+	var at time.Duration
+	for _, p := range pulses {
+		if p.Level&1 != level {
+			level = p.Level & 1
+			fmt.Fprintf(bw, "#%d\n%d%s\n", at.Nanoseconds(), level, identifier)
+		}
+		at += p.Duration
+	}
+	fmt.Fprintf(bw, "#%d\n", at.Nanoseconds())
+	return bw.Flush()
+}
+
+// Read reads a single 1-bit signal from a VCD file as pulses, starting at the
+// first timestamp in the file. If signal is empty, the first 1-bit signal is
+// used. Unknown (x) and high-impedance (z) values are treated as 0.
+func Read(r io.Reader, signal string) ([]waveform.Pulse, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	sc.Split(bufio.ScanWords)
+
+	next := func() (string, bool) {
+		if !sc.Scan() {
+			return "", false
+		}
+		return sc.Text(), true
+	}
+	// section returns the tokens up to the next $end.
+	section := func(keyword string) ([]string, error) {
+		var tokens []string
+		for {
+			tok, ok := next()
+			if !ok {
+				return nil, fmt.Errorf("unterminated %s section", keyword)
+			}
+			if tok == "$end" {
+				return tokens, nil
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+
+	// Header.
+	femtos := int64(1e6) // Femtoseconds per VCD time unit.
+	var id string
+	var names []string
+header:
+	for {
+		tok, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("unexpected end of VCD header")
+		}
+		if !strings.HasPrefix(tok, "$") {
+			return nil, fmt.Errorf("unexpected %q in VCD header", tok)
+		}
+		tokens, err := section(tok)
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "$timescale":
+			if femtos, err = parseTimescale(strings.Join(tokens, "")); err != nil {
+				return nil, err
+			}
+		case "$var":
+			// $var type size identifier reference [index] $end
+			if len(tokens) < 4 {
+				return nil, fmt.Errorf("malformed $var: %q", strings.Join(tokens, " "))
+			}
+			names = append(names, tokens[3])
+			if id == "" && tokens[1] == "1" && (signal == "" || signal == tokens[3]) {
+				id = tokens[2]
+			}
+		case "$enddefinitions":
+			break header
+		}
+	}
+	if id == "" {
+		if signal == "" {
+			return nil, fmt.Errorf("no 1-bit signals found")
+		}
+		return nil, fmt.Errorf("no 1-bit signal named %q found; signals are: %s", signal, strings.Join(names, ", "))
+	}
+	at := func(t int64) time.Duration {
+		return time.Duration(t * femtos / 1e6)
+	}
+
+	// Value changes.
+	var result []waveform.Pulse
+	level := byte(0)
+	var start, now int64
+	started := false
+	change := func(value byte) {
+		if value == level {
+			return
+		}
+		if now > start {
+			result = append(result, waveform.Pulse{Level: level, Duration: at(now) - at(start)})
+		}
+		level, start = value, now
+	}
+	for {
+		tok, ok := next()
+		if !ok {
+			break
+		}
+		switch {
+		case tok == "$dumpvars" || tok == "$dumpall" || tok == "$dumpon" || tok == "$dumpoff" || tok == "$end":
+		case tok == "$comment":
+			if _, err := section(tok); err != nil {
+				return nil, err
+			}
+		case tok[0] == '#':
+			t, err := strconv.ParseInt(tok[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad timestamp %q", tok)
+			}
+			if !started {
+				start, started = t, true
+			}
+			now = t
+		case tok[0] == 'b' || tok[0] == 'B' || tok[0] == 'r' || tok[0] == 'R':
+			ref, ok := next()
+			if !ok {
+				return nil, fmt.Errorf("missing identifier after %q", tok)
+			}
+			if ref == id {
+				change(vectorLevel(tok[1:]))
+			}
+		default:
+			if tok[1:] == id {
+				change(scalarLevel(tok[0]))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if now > start {
+		result = append(result, waveform.Pulse{Level: level, Duration: at(now) - at(start)})
+	}
+	return result, nil
+}
+
+// parseTimescale parses a VCD timescale, such as "1ns" or "100us", returning
+// the number of femtoseconds in each time unit.
+func parseTimescale(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		femtos int64
+	}{
+		{"ms", 1e12},
+		{"us", 1e9},
+		{"ns", 1e6},
+		{"ps", 1e3},
+		{"fs", 1},
+		{"s", 1e15},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, u.suffix))
+		if err != nil || (n != 1 && n != 10 && n != 100) {
+			return 0, fmt.Errorf("bad timescale %q", s)
+		}
+		return int64(n) * u.femtos, nil
+	}
+	return 0, fmt.Errorf("bad timescale %q", s)
+}
+
+func scalarLevel(c byte) byte {
+	if c == '1' {
+		return 1
+	}
+	return 0
+}
+
+// vectorLevel treats a vector or real value as 1 if it is non-zero.
+func vectorLevel(s string) byte {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f != 0 {
+		return 1
+	}
+	return 0
+}
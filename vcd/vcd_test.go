@@ -0,0 +1,89 @@
+package vcd_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/vcd"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestRoundTrip(t *testing.T) {
+	pulses := waveform.FromBits(bits.B("0110100011"), 250*time.Microsecond)
+	var buf bytes.Buffer
+	if err := vcd.Write(&buf, pulses, "pin12"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := vcd.Read(&buf, "pin12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pulses) {
+		t.Errorf("want Read(Write(%v))==%v; got %v", pulses, pulses, got)
+	}
+}
+
+// pulseView is in the style of a PulseView export of a two-channel capture.
+const pulseView = `$date Sat Oct 17 12:00:00 2026 $end
+$version libsigrok 0.5.2 $end
+$comment
+  Acquisition with 2/8 channels at 1 MHz
+$end
+$timescale 1 us $end
+$scope module libsigrok $end
+$var wire 1 ! D0 $end
+$var wire 1 " D1 $end
+$upscope $end
+$enddefinitions $end
+#0 x! 0"
+#100 1"
+#350 0"
+#600 1" 1!
+#1100 0"
+#1200
+`
+
+func TestReadPulseView(t *testing.T) {
+	testcases := []struct {
+		signal string
+		want   []waveform.Pulse
+	}{
+		{
+			signal: "D1",
+			want: []waveform.Pulse{
+				{Level: 0, Duration: 100 * time.Microsecond},
+				{Level: 1, Duration: 250 * time.Microsecond},
+				{Level: 0, Duration: 250 * time.Microsecond},
+				{Level: 1, Duration: 500 * time.Microsecond},
+				{Level: 0, Duration: 100 * time.Microsecond},
+			},
+		},
+		{
+			signal: "",
+			want: []waveform.Pulse{
+				{Level: 0, Duration: 600 * time.Microsecond},
+				{Level: 1, Duration: 600 * time.Microsecond},
+			},
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.signal, func(t *testing.T) {
+			got, err := vcd.Read(strings.NewReader(pulseView), tt.signal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := vcd.Read(strings.NewReader(pulseView), "D7"); err == nil {
+		t.Errorf("want error reading missing signal D7")
+	}
+}
@@ -0,0 +1,8 @@
+/*
+Package wav reads and writes OOK envelopes as WAV audio files.
+
+Recordings made by feeding the demodulated output of a receiver module into a
+sound card can be sliced into pulses, and transmissions can be rendered as
+audio, to be played out of an audio jack into a transmitter module.
+*/
+package wav
@@ -0,0 +1,182 @@
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/zellyn/openers/waveform"
+)
+
+// WAV sample formats.
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
+// Read reads a WAV file, returning one channel (counting from 0) of its
+// samples, scaled to the range -1 to 1, and its sample rate. It supports 8, 16,
+// 24 and 32-bit integer and 32-bit floating point samples.
+func Read(r io.Reader, channel int) ([]float32, int, error) {
+	br := bufio.NewReader(r)
+	var riff [12]byte
+	if _, err := io.ReadFull(br, riff[:]); err != nil {
+		return nil, 0, fmt.Errorf("reading WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var format, channels, bitsPerSample uint16
+	var rate uint32
+	haveFormat := false
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return nil, 0, fmt.Errorf("no data chunk found: %w", err)
+		}
+		id := string(header[0:4])
+		size := binary.LittleEndian.Uint32(header[4:8])
+
+		switch id {
+		case "fmt ":
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(br, chunk); err != nil {
+				return nil, 0, err
+			}
+			if size < 16 {
+				return nil, 0, fmt.Errorf("fmt chunk too short: %d bytes", size)
+			}
+			format = binary.LittleEndian.Uint16(chunk[0:])
+			channels = binary.LittleEndian.Uint16(chunk[2:])
+			rate = binary.LittleEndian.Uint32(chunk[4:])
+			bitsPerSample = binary.LittleEndian.Uint16(chunk[14:])
+			if format == formatExtensible && size >= 26 {
+				format = binary.LittleEndian.Uint16(chunk[24:])
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, 0, fmt.Errorf("data chunk before fmt chunk")
+			}
+			if channel < 0 || channel >= int(channels) {
+				return nil, 0, fmt.Errorf("no channel %d: recording has %d channels", channel, channels)
+			}
+			decode, err := decoder(format, bitsPerSample)
+			if err != nil {
+				return nil, 0, err
+			}
+			samples, err := readSamples(io.LimitReader(br, int64(size)), decode, int(bitsPerSample)/8, int(channels), channel)
+			return samples, int(rate), err
+
+		default:
+			// Chunks are padded to an even size.
+			if _, err := io.CopyN(io.Discard, br, int64(size+size%2)); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+}
+
+// decoder returns a function converting a single sample to the range -1 to 1.
+func decoder(format uint16, bitsPerSample uint16) (func([]byte) float32, error) {
+	switch {
+	case format == formatPCM && bitsPerSample == 8:
+		return func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }, nil
+	case format == formatPCM && bitsPerSample == 16:
+		return func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }, nil
+	case format == formatPCM && bitsPerSample == 24:
+		return func(b []byte) float32 {
+			return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}, nil
+	case format == formatPCM && bitsPerSample == 32:
+		return func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+	case format == formatFloat && bitsPerSample == 32:
+		return func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported WAV sample format %d with %d bits per sample", format, bitsPerSample)
+}
+
+// readSamples reads interleaved samples, returning those for one channel.
+func readSamples(r io.Reader, decode func([]byte) float32, size int, channels int, channel int) ([]float32, error) {
+	frame := make([]byte, size*channels)
+	var result []float32
+	for {
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return result, nil
+			}
+			return nil, err
+		}
+		result = append(result, decode(frame[channel*size:(channel+1)*size]))
+	}
+}
+
+// Write writes samples, in the range -1 to 1, to w as a mono 16-bit PCM WAV
+// file.
+func Write(w io.Writer, samples []float32, rate int) error {
+	bw := bufio.NewWriter(w)
+	dataSize := uint32(len(samples) * 2)
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		36 + dataSize,
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),
+		uint16(formatPCM),
+		uint16(1),        // Channels.
+		uint32(rate),     // Sample rate.
+		uint32(rate * 2), // Bytes per second.
+		uint16(2),        // Bytes per frame.
+		uint16(16),       // Bits per sample.
+		[4]byte{'d', 'a', 't', 'a'},
+		dataSize,
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	for _, s := range samples {
+		v := math.Round(float64(s) * (1<<15 - 1))
+		v = math.Max(-(1 << 15), math.Min(1<<15-1, v))
+		if err := binary.Write(bw, binary.LittleEndian, int16(v)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Pulses reads one channel (counting from 0) of a WAV recording of an OOK
+// envelope, and slices it into pulses. If invert is true, the carrier is
+// taken to be on when the signal is low.
+func Pulses(r io.Reader, channel int, invert bool) ([]waveform.Pulse, error) {
+	samples, rate, err := Read(r, channel)
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("bad sample rate %d", rate)
+	}
+	if invert {
+		for i := range samples {
+			samples[i] = -samples[i]
+		}
+	}
+	return waveform.FromEnvelope(samples, float64(rate)), nil
+}
+
+// Render renders pulses as audio at rate samples per second: carrier-on is
+// level, and carrier-off is silence.
+func Render(pulses []waveform.Pulse, rate int, level float32) []float32 {
+	rendered := waveform.Render(pulses, float64(rate))
+	result := make([]float32, len(rendered))
+	for i, b := range rendered {
+		result[i] = float32(b) * level
+	}
+	return result
+}
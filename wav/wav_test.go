@@ -0,0 +1,102 @@
+package wav_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/demod"
+	"github.com/zellyn/openers/secplus"
+	"github.com/zellyn/openers/wav"
+	"github.com/zellyn/openers/waveform"
+)
+
+func testTransmission(t *testing.T) []waveform.Pulse {
+	tx, err := secplus.TransmissionV2(0, 70678577664, 240124710, secplus.TimingV2{
+		Pulsewidth: 250 * time.Microsecond,
+		Burstgap:   90 * time.Millisecond,
+		Repeatgap:  90 * time.Millisecond,
+		Repeats:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pulses := []waveform.Pulse{{Level: 0, Duration: 20 * time.Millisecond}}
+	pulses = append(pulses, tx.Pulses()...)
+	return append(pulses, waveform.Pulse{Level: 0, Duration: 20 * time.Millisecond})
+}
+
+var wantMessages = []demod.Message{{
+	Start:    20 * time.Millisecond,
+	Protocol: demod.SecplusV2,
+	FixedLow: 70678577664,
+	Rolling:  240124710,
+}}
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := wav.Render(testTransmission(t), 44100, 0.5)
+	for i := range samples {
+		samples[i] += float32(rng.NormFloat64() * 0.02)
+	}
+
+	var buf bytes.Buffer
+	if err := wav.Write(&buf, samples, 44100); err != nil {
+		t.Fatal(err)
+	}
+	pulses, err := wav.Pulses(&buf, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := demod.Decode(pulses)
+	for i := range got {
+		got[i].Start = got[i].Start.Round(time.Millisecond)
+	}
+	if !reflect.DeepEqual(got, wantMessages) {
+		t.Errorf("want %v; got %v", wantMessages, got)
+	}
+}
+
+// TestStereoInverted reads the second channel of an 8-bit stereo recording,
+// from a receiver whose output is low when the carrier is on.
+func TestStereoInverted(t *testing.T) {
+	const rate = 48000
+	rendered := waveform.Render(testTransmission(t), rate)
+	var data bytes.Buffer
+	for _, b := range rendered {
+		data.WriteByte(128)
+		data.WriteByte(200 - 140*b)
+	}
+
+	var buf bytes.Buffer
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'}, uint32(4 + 8 + 16 + 8 + 4 + 8 + data.Len()), [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16), uint16(1), uint16(2), uint32(rate), uint32(rate * 2), uint16(2), uint16(8),
+		// An odd-sized chunk that should be skipped, padding and all.
+		[4]byte{'L', 'I', 'S', 'T'}, uint32(3), [4]byte{'a', 'b', 'c', 0},
+		[4]byte{'d', 'a', 't', 'a'}, uint32(data.Len()),
+	}
+	for _, v := range header {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.Write(data.Bytes())
+
+	pulses, err := wav.Pulses(bytes.NewReader(buf.Bytes()), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := demod.Decode(pulses)
+	for i := range got {
+		got[i].Start = got[i].Start.Round(time.Millisecond)
+	}
+	if !reflect.DeepEqual(got, wantMessages) {
+		t.Errorf("want %v; got %v", wantMessages, got)
+	}
+
+	if _, err := wav.Pulses(bytes.NewReader(buf.Bytes()), 2, true); err == nil {
+		t.Errorf("want error reading missing channel 2")
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package waveform describes on-off keyed (OOK) signals as sequences of pulses:
+periods of time during which the carrier is either on or off.
+
+It is the common currency between the protocol encoders and decoders, the
+recording front ends that turn captured signals into pulses, and the
+transmitters that turn pulses back into signals.
+*/
+package waveform
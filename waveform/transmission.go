@@ -0,0 +1,200 @@
+package waveform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Burst is a run of bits sent back to back at a fixed pulse width.
+type Burst struct {
+	Bits       []byte        // 0s and 1s, one per pulse.
+	Pulsewidth time.Duration // Duration of each bit.
+	Gap        time.Duration // Silence after the burst, if another burst follows in the same repetition.
+}
+
+// Transmission describes everything sent for a single button press: a
+// message made up of one or more bursts, repeated a number of times.
+type Transmission struct {
+	Protocol  string
+	Bursts    []Burst // A single repetition of the message.
+	Repeats   int     // Number of times to send the whole message.
+	RepeatGap time.Duration
+
+	// Tolerance is the largest timing error the protocol tolerates in any
+	// pulse, or 0 if unknown.
+	Tolerance time.Duration
+
+	// Frequencies is the frequency plan: the carrier frequencies, in Hz, to
+	// send on. If empty, the transmission goes out on whatever frequency the
+	// transmitter is tuned to.
+	Frequencies []float64
+	Hop         Hop // How to move through Frequencies.
+}
+
+// Hop says how a transmission moves through its frequency plan.
+type Hop int
+
+const (
+	// HopRepeat sends each repetition of the message on the next frequency,
+	// cycling through the plan, the way real multi-frequency remotes do.
+	HopRepeat Hop = iota
+	// HopRound sends each repetition of the message on every frequency,
+	// hopping through the whole plan once per repetition.
+	HopRound
+)
+
+var hopNames = map[Hop]string{
+	HopRepeat: "repeat",
+	HopRound:  "round",
+}
+
+// String returns the name of a hop mode, as accepted by ParseHop.
+func (h Hop) String() string {
+	if name, ok := hopNames[h]; ok {
+		return name
+	}
+	return fmt.Sprintf("Hop(%d)", int(h))
+}
+
+// ParseHop returns the hop mode with the given name.
+func ParseHop(name string) (Hop, error) {
+	for h, n := range hopNames {
+		if name == n {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown frequency hop mode %q; expected repeat or round", name)
+}
+
+// Leg is the part of a transmission sent on a single frequency.
+type Leg struct {
+	Frequency float64 // In Hz, or 0 if the transmission has no frequency plan.
+	Tx        *Transmission
+}
+
+// Legs splits a transmission into the parts sent on each frequency of its
+// plan, in order. The legs have no frequency plan themselves, and are
+// separated by RepeatGap.
+func (t *Transmission) Legs() []Leg {
+	single := *t
+	single.Frequencies = nil
+	single.Hop = HopRepeat
+	if len(t.Frequencies) == 0 {
+		return []Leg{{Tx: &single}}
+	}
+
+	var result []Leg
+	add := func(frequency float64, repeats int) {
+		if n := len(result); n > 0 && result[n-1].Frequency == frequency {
+			result[n-1].Tx.Repeats += repeats
+			return
+		}
+		leg := single
+		leg.Repeats = repeats
+		result = append(result, Leg{Frequency: frequency, Tx: &leg})
+	}
+	switch t.Hop {
+	case HopRound:
+		for i := 0; i < t.Repeats; i++ {
+			for _, f := range t.Frequencies {
+				add(f, 1)
+			}
+		}
+	default:
+		for i := 0; i < t.Repeats; i++ {
+			add(t.Frequencies[i%len(t.Frequencies)], 1)
+		}
+	}
+	return result
+}
+
+// repetitions returns the total number of times the message is sent, over the
+// whole frequency plan.
+func (t *Transmission) repetitions() int {
+	if t.Hop == HopRound && len(t.Frequencies) > 0 {
+		return t.Repeats * len(t.Frequencies)
+	}
+	return t.Repeats
+}
+
+// String describes a transmission for humans, frequency plan included.
+func (t *Transmission) String() string {
+	var bursts []string
+	for i, burst := range t.Bursts {
+		desc := fmt.Sprintf("%d bits at %v", len(burst.Bits), burst.Pulsewidth)
+		if i+1 < len(t.Bursts) {
+			desc += fmt.Sprintf(", then %v gap", burst.Gap)
+		}
+		bursts = append(bursts, desc)
+	}
+	s := fmt.Sprintf("%s: %d bursts (%s), %d repeats %v apart, lasting %v",
+		t.Protocol, len(t.Bursts), strings.Join(bursts, "; "), t.repetitions(), t.RepeatGap, t.Duration())
+	if len(t.Frequencies) > 0 {
+		var frequencies []string
+		for _, f := range t.Frequencies {
+			frequencies = append(frequencies, FormatFrequency(f))
+		}
+		s += fmt.Sprintf("; on %s, hopping every %s", strings.Join(frequencies, ", "), t.Hop)
+	}
+	return s
+}
+
+// FormatFrequency formats a frequency in Hz for humans, such as "315MHz".
+func FormatFrequency(f float64) string {
+	return fmt.Sprintf("%gMHz", f/1e6)
+}
+
+// Pulses returns the whole transmission, including all repetitions and gaps
+// over the whole frequency plan, as pulses. It starts with the first bit, and
+// ends with the last.
+func (t *Transmission) Pulses() []Pulse {
+	var result []Pulse
+	add := func(p Pulse) {
+		if n := len(result); n > 0 && result[n-1].Level == p.Level {
+			result[n-1].Duration += p.Duration
+			return
+		}
+		result = append(result, p)
+	}
+	for i := 0; i < t.repetitions(); i++ {
+		if i > 0 && t.RepeatGap > 0 {
+			add(Pulse{Level: 0, Duration: t.RepeatGap})
+		}
+		for j, burst := range t.Bursts {
+			if j > 0 && t.Bursts[j-1].Gap > 0 {
+				add(Pulse{Level: 0, Duration: t.Bursts[j-1].Gap})
+			}
+			for _, p := range FromBits(burst.Bits, burst.Pulsewidth) {
+				add(p)
+			}
+		}
+	}
+	return result
+}
+
+// Duration returns the total time taken to send the transmission.
+func (t *Transmission) Duration() time.Duration {
+	return Duration(t.Pulses())
+}
+
+// OnAir returns how long the carrier is on in the whole transmission.
+func (t *Transmission) OnAir() time.Duration {
+	var total time.Duration
+	for _, p := range t.Pulses() {
+		if p.Level == 1 {
+			total += p.Duration
+		}
+	}
+	return total
+}
+
+// Changes returns the number of bits sent in the whole transmission, which is
+// also the number of times a transmitter sets its pin.
+func (t *Transmission) Changes() int {
+	n := 0
+	for _, burst := range t.Bursts {
+		n += len(burst.Bits) + 1
+	}
+	return n * t.repetitions()
+}
@@ -0,0 +1,175 @@
+package waveform
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Pulse is a period of time during which the signal stays at one level.
+type Pulse struct {
+	Level    byte // 1 if the carrier is on, 0 if it is off.
+	Duration time.Duration
+}
+
+// Segment is a run of pulses surrounded by silence, as found by Split.
+type Segment struct {
+	Start  time.Duration // Offset of the first pulse from the start of the input.
+	Pulses []Pulse
+}
+
+// FromBits converts a byte slice of 0s and 1s, each lasting width, to pulses.
+// Adjacent bits with the same level are merged into a single pulse. It does no
+// validation, since it uses only the low bit.
+func FromBits(input []byte, width time.Duration) []Pulse {
+	var result []Pulse
+	for _, b := range input {
+		level := b & 1
+		if n := len(result); n > 0 && result[n-1].Level == level {
+			result[n-1].Duration += width
+			continue
+		}
+		result = append(result, Pulse{Level: level, Duration: width})
+	}
+	return result
+}
+
+// ToBits quantizes pulses to a byte slice of 0s and 1s, each lasting width.
+// Every pulse yields at least one bit, so short glitches are not lost.
+func ToBits(pulses []Pulse, width time.Duration) []byte {
+	var result []byte
+	for _, p := range pulses {
+		n := int((p.Duration + width/2) / width)
+		if n < 1 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			result = append(result, p.Level&1)
+		}
+	}
+	return result
+}
+
+// Duration returns the total duration of a sequence of pulses.
+func Duration(pulses []Pulse) time.Duration {
+	var total time.Duration
+	for _, p := range pulses {
+		total += p.Duration
+	}
+	return total
+}
+
+// Split splits pulses into segments separated by periods of silence lasting
+// at least gap. The silences themselves are dropped, so each segment starts
+// and ends with the carrier on.
+func Split(pulses []Pulse, gap time.Duration) []Segment {
+	var result []Segment
+	var current *Segment
+	var offset time.Duration
+	for _, p := range pulses {
+		switch {
+		case p.Level == 1 && current == nil:
+			result = append(result, Segment{Start: offset})
+			current = &result[len(result)-1]
+			current.Pulses = append(current.Pulses, p)
+		case current == nil:
+		case p.Level == 0 && p.Duration >= gap:
+			current = nil
+		default:
+			current.Pulses = append(current.Pulses, p)
+		}
+		offset += p.Duration
+	}
+	for i := range result {
+		ps := result[i].Pulses
+		for len(ps) > 0 && ps[len(ps)-1].Level == 0 {
+			ps = ps[:len(ps)-1]
+		}
+		result[i].Pulses = ps
+	}
+	return result
+}
+
+// squelchMiddle is the largest fraction of an envelope that FromEnvelope
+// allows to lie in the middle of its range, between the noise floor and the
+// peak, for it to be considered to contain a signal.
+const squelchMiddle = 0.1
+
+// FromEnvelope slices a signal envelope, such as the magnitude of a radio
+// recording, sampled at rate samples per second, into pulses. The threshold is
+// chosen automatically from the noise and the signal peak, with some
+// hysteresis to avoid chatter. If nothing rises far enough above the noise,
+// the result is a single period of silence.
+func FromEnvelope(envelope []float32, rate float64) []Pulse {
+	if len(envelope) == 0 {
+		return nil
+	}
+	sorted := make([]float32, len(envelope))
+	copy(sorted, envelope)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	floor := sorted[len(sorted)/1000]
+	peak := sorted[len(sorted)-1-len(sorted)/1000]
+
+	levels := make([]byte, len(envelope))
+
+	// An on-off keyed signal, whether mostly on or mostly off, spends hardly
+	// any time in the middle between the noise floor and the peak, whereas
+	// noise alone fills it. This works both for magnitudes, which are never
+	// negative, and for signed signals such as audio.
+	if peak <= floor {
+		return FromSamples(levels, rate)
+	}
+	lo := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= floor+(peak-floor)*2/5 })
+	hi := sort.Search(len(sorted), func(i int) bool { return sorted[i] > floor+(peak-floor)*3/5 })
+	if float64(hi-lo) > squelchMiddle*float64(len(sorted)) {
+		return FromSamples(levels, rate)
+	}
+	high := floor + (peak-floor)/2
+	low := floor + (peak-floor)/4
+
+	level := byte(0)
+	for i, v := range envelope {
+		switch {
+		case level == 0 && v >= high:
+			level = 1
+		case level == 1 && v < low:
+			level = 0
+		}
+		levels[i] = level
+	}
+	return FromSamples(levels, rate)
+}
+
+// Render samples pulses at rate samples per second, returning one byte per
+// sample: 1 while the carrier is on, and 0 while it is off. Pulse boundaries
+// are rounded to the nearest sample without accumulating rounding errors.
+func Render(pulses []Pulse, rate float64) []byte {
+	var result []byte
+	var end time.Duration
+	for _, p := range pulses {
+		end += p.Duration
+		n := int(math.Round(end.Seconds() * rate))
+		for len(result) < n {
+			result = append(result, p.Level&1)
+		}
+	}
+	return result
+}
+
+// FromSamples is the inverse of Render: it converts a byte slice of 0s and 1s,
+// sampled at rate samples per second, to pulses. It does no validation, since
+// it uses only the low bit.
+func FromSamples(samples []byte, rate float64) []Pulse {
+	var result []Pulse
+	at := func(n int) time.Duration {
+		return time.Duration(math.Round(float64(n) / rate * float64(time.Second)))
+	}
+	start := 0
+	for i := 1; i <= len(samples); i++ {
+		if i == len(samples) || samples[i]&1 != samples[start]&1 {
+			result = append(result, Pulse{Level: samples[start] & 1, Duration: at(i) - at(start)})
+			start = i
+		}
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package waveform_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/openers/bits"
+	"github.com/zellyn/openers/waveform"
+)
+
+func TestBitsRoundTrip(t *testing.T) {
+	testcases := []struct {
+		name  string
+		input string
+		want  []waveform.Pulse
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "merged",
+			input: "0110001",
+			want: []waveform.Pulse{
+				{Level: 0, Duration: 250 * time.Microsecond},
+				{Level: 1, Duration: 500 * time.Microsecond},
+				{Level: 0, Duration: 750 * time.Microsecond},
+				{Level: 1, Duration: 250 * time.Microsecond},
+			},
+		},
+	}
+
+	for i, tt := range testcases {
+		t.Run(fmt.Sprintf("%d-%s", i, tt.name), func(t *testing.T) {
+			got := waveform.FromBits(bits.B(tt.input), 250*time.Microsecond)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want FromBits(%s)==%v; got %v", tt.input, tt.want, got)
+			}
+			back := waveform.ToBits(got, 240*time.Microsecond)
+			if !bytes.Equal(back, bits.B(tt.input)) {
+				t.Errorf("want ToBits(FromBits(%s))==%s; got %s", tt.input, tt.input, bits.S(back))
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	ms := time.Millisecond
+	pulses := []waveform.Pulse{
+		{Level: 0, Duration: 5 * ms},
+		{Level: 1, Duration: 1 * ms},
+		{Level: 0, Duration: 2 * ms},
+		{Level: 1, Duration: 1 * ms},
+		{Level: 0, Duration: 10 * ms},
+		{Level: 1, Duration: 3 * ms},
+		{Level: 0, Duration: 1 * ms},
+	}
+	want := []waveform.Segment{
+		{Start: 5 * ms, Pulses: pulses[1:4]},
+		{Start: 19 * ms, Pulses: pulses[5:6]},
+	}
+	got := waveform.Split(pulses, 10*ms)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want Split(...)==%v; got %v", want, got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	pulses := waveform.FromBits(bits.B("1101000111"), 250*time.Microsecond)
+	// 24kHz doesn't divide evenly into 250µs: pulses should alternate between
+	// 6 and 7 samples, rather than drifting.
+	samples := waveform.Render(pulses, 24000)
+	if len(samples) != 60 {
+		t.Fatalf("want 60 samples; got %d", len(samples))
+	}
+	back := waveform.FromSamples(samples, 24000)
+	if !reflect.DeepEqual(back, pulses) {
+		t.Errorf("want FromSamples(Render(%v))==%v; got %v", pulses, pulses, back)
+	}
+}
+
+func TestTransmissionPulses(t *testing.T) {
+	ms := time.Millisecond
+	tx := &waveform.Transmission{
+		Bursts: []waveform.Burst{
+			{Bits: bits.B("10"), Pulsewidth: ms, Gap: 5 * ms},
+			{Bits: bits.B("01"), Pulsewidth: ms},
+		},
+		Repeats:   2,
+		RepeatGap: 10 * ms,
+	}
+	want := []waveform.Pulse{
+		{Level: 1, Duration: 1 * ms},
+		{Level: 0, Duration: 7 * ms},
+		{Level: 1, Duration: 1 * ms},
+		{Level: 0, Duration: 10 * ms},
+		{Level: 1, Duration: 1 * ms},
+		{Level: 0, Duration: 7 * ms},
+		{Level: 1, Duration: 1 * ms},
+	}
+	if got := tx.Pulses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want Pulses()==%v; got %v", want, got)
+	}
+	if got := tx.Duration(); got != 28*ms {
+		t.Errorf("want Duration()==28ms; got %v", got)
+	}
+	if got := tx.OnAir(); got != 4*ms {
+		t.Errorf("want OnAir()==4ms; got %v", got)
+	}
+}
+
+func TestLegs(t *testing.T) {
+	ms := time.Millisecond
+	base := waveform.Transmission{
+		Protocol:  "test",
+		Bursts:    []waveform.Burst{{Bits: bits.B("1"), Pulsewidth: ms}},
+		Repeats:   4,
+		RepeatGap: 10 * ms,
+	}
+	type leg struct {
+		frequency float64
+		repeats   int
+	}
+	testcases := []struct {
+		name        string
+		frequencies []float64
+		hop         waveform.Hop
+		want        []leg
+	}{
+		{name: "no plan", want: []leg{{0, 4}}},
+		{name: "single", frequencies: []float64{315e6}, want: []leg{{315e6, 4}}},
+		{
+			name:        "repeat",
+			frequencies: []float64{310e6, 315e6, 390e6},
+			hop:         waveform.HopRepeat,
+			want:        []leg{{310e6, 1}, {315e6, 1}, {390e6, 1}, {310e6, 1}},
+		},
+		{
+			name:        "round",
+			frequencies: []float64{310e6, 390e6},
+			hop:         waveform.HopRound,
+			want:        []leg{{310e6, 1}, {390e6, 1}, {310e6, 1}, {390e6, 1}, {310e6, 1}, {390e6, 1}, {310e6, 1}, {390e6, 1}},
+		},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := base
+			tx.Frequencies = tt.frequencies
+			tx.Hop = tt.hop
+			var got []leg
+			total := time.Duration(0)
+			for i, l := range tx.Legs() {
+				if len(l.Tx.Frequencies) != 0 {
+					t.Errorf("want legs without a frequency plan")
+				}
+				if i > 0 {
+					total += tx.RepeatGap
+				}
+				total += l.Tx.Duration()
+				got = append(got, leg{l.Frequency, l.Tx.Repeats})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want legs %v; got %v", tt.want, got)
+			}
+			if total != tx.Duration() {
+				t.Errorf("want legs to last %v in total; got %v", tx.Duration(), total)
+			}
+		})
+	}
+
+	tx := base
+	tx.Frequencies = []float64{310e6, 315e6, 390e6}
+	want := "test: 1 bursts (1 bits at 1ms), 4 repeats 10ms apart, lasting 34ms; on 310MHz, 315MHz, 390MHz, hopping every repeat"
+	if got := tx.String(); got != want {
+		t.Errorf("want String()==%q; got %q", want, got)
+	}
+}